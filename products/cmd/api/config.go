@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -12,7 +13,19 @@ type config struct {
 	idleTimeout  time.Duration
 	readTimeout  time.Duration
 	WriteTimeout time.Duration
-	db           struct {
+	// sweepInterval is how often lapsed stock reservations are expired.
+	sweepInterval time.Duration
+	// purgeRetention is how long deleted products and categories are kept before the
+	// purge command removes them for good.
+	purgeRetention time.Duration
+	// searchLanguage is the language of the catalog, searched in when a request does
+	// not ask for one.
+	searchLanguage string
+	// jobWorkers is the number of jobs run at the same time, each polling the queue
+	// every jobPollInterval while it is empty.
+	jobWorkers      int
+	jobPollInterval time.Duration
+	db              struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
@@ -45,6 +58,35 @@ func loadConfig(args []string, getEnv func(key string) string) (config, error) {
 		"API server write timeout",
 	)
 
+	fs.DurationVar(
+		&cfg.sweepInterval,
+		"reservation-sweep-interval",
+		time.Minute,
+		"Interval between sweeps of expired stock reservations",
+	)
+
+	fs.DurationVar(
+		&cfg.purgeRetention,
+		"purge-retention",
+		30*24*time.Hour,
+		"Time deleted products and categories are kept before being purged",
+	)
+
+	fs.StringVar(
+		&cfg.searchLanguage,
+		"search-language",
+		getEnv("SEARCH_LANGUAGE"),
+		"Default catalog search language (en|es)",
+	)
+
+	fs.IntVar(&cfg.jobWorkers, "job-workers", 2, "Number of background job workers")
+	fs.DurationVar(
+		&cfg.jobPollInterval,
+		"job-poll-interval",
+		time.Second,
+		"Interval between polls of an empty job queue",
+	)
+
 	//Read db configurations
 	fs.StringVar(&cfg.db.dsn, "db-dsn", getEnv("PRODUCTS_DB_DSN"), "PostgreSQL DSN")
 	fs.IntVar(
@@ -70,6 +112,32 @@ func loadConfig(args []string, getEnv func(key string) string) (config, error) {
 		return config{}, err
 	}
 
+	// The reservation sweeper ticks every sweepInterval and the job workers poll every
+	// jobPollInterval, both of which have to be positive, as does the number of job
+	// workers.
+	positive := []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"reservation-sweep-interval", cfg.sweepInterval},
+		{"job-poll-interval", cfg.jobPollInterval},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			return config{}, fmt.Errorf(
+				"invalid value %q for flag -%s: must be positive",
+				p.value,
+				p.flag,
+			)
+		}
+	}
+	if cfg.jobWorkers < 1 {
+		return config{}, fmt.Errorf(
+			"invalid value \"%d\" for flag -job-workers: must be positive",
+			cfg.jobWorkers,
+		)
+	}
+
 	return cfg, nil
 }
 
@@ -19,6 +19,11 @@ func TestLoadConfig(t *testing.T) {
 			"-db-max-open-conns=100",
 			"-db-max-idle-conns=50",
 			"-db-max-idle-time=20m",
+			"-reservation-sweep-interval=30s",
+			"-purge-retention=168h",
+			"-search-language=es",
+			"-job-workers=4",
+			"-job-poll-interval=500ms",
 		}
 
 		mockGetEnv := func(key string) string {
@@ -26,6 +31,11 @@ func TestLoadConfig(t *testing.T) {
 		}
 
 		expectedConfig := config{}
+		expectedConfig.sweepInterval = 30 * time.Second
+		expectedConfig.jobWorkers = 4
+		expectedConfig.jobPollInterval = 500 * time.Millisecond
+		expectedConfig.purgeRetention = 7 * 24 * time.Hour
+		expectedConfig.searchLanguage = "es"
 		expectedConfig.idleTimeout = time.Second
 		expectedConfig.readTimeout = 2 * time.Second
 		expectedConfig.WriteTimeout = 5 * time.Second
@@ -64,6 +74,44 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, expectedConfig, actualConfig)
 	})
 
+	t.Run("should error if an interval or the job workers are not positive", func(t *testing.T) {
+		testCases := []struct {
+			arg      string
+			expected string
+		}{
+			{
+				"-reservation-sweep-interval=0s",
+				"invalid value \"0s\" for flag -reservation-sweep-interval: must be positive",
+			},
+			{
+				"-reservation-sweep-interval=-1m",
+				"invalid value \"-1m0s\" for flag -reservation-sweep-interval: must be positive",
+			},
+			{
+				"-job-poll-interval=0s",
+				"invalid value \"0s\" for flag -job-poll-interval: must be positive",
+			},
+			{
+				"-job-workers=0",
+				"invalid value \"0\" for flag -job-workers: must be positive",
+			},
+			{
+				"-job-workers=-2",
+				"invalid value \"-2\" for flag -job-workers: must be positive",
+			},
+		}
+
+		for _, tc := range testCases {
+			actualConfig, err := loadConfig(
+				[]string{tc.arg},
+				func(key string) string { return "" },
+			)
+			assert.Error(t, err)
+			assert.Equal(t, tc.expected, err.Error())
+			assert.Equal(t, config{}, actualConfig)
+		}
+	})
+
 	t.Run("should load config from env", func(t *testing.T) {
 		args := []string{}
 
@@ -81,6 +129,8 @@ func TestLoadConfig(t *testing.T) {
 				return "5000"
 			case "ENV":
 				return "test server 2"
+			case "SEARCH_LANGUAGE":
+				return "en"
 			default:
 				return ""
 			}
@@ -88,10 +138,15 @@ func TestLoadConfig(t *testing.T) {
 
 		expectedConfig := config{}
 		expectedConfig.idleTimeout = time.Minute
+		expectedConfig.sweepInterval = time.Minute
+		expectedConfig.jobWorkers = 2
+		expectedConfig.jobPollInterval = time.Second
+		expectedConfig.purgeRetention = 30 * 24 * time.Hour
 		expectedConfig.readTimeout = 5 * time.Second
 		expectedConfig.WriteTimeout = 10 * time.Second
 		expectedConfig.env = "test server 2"
 		expectedConfig.port = 5000
+		expectedConfig.searchLanguage = "en"
 		expectedConfig.db.dsn = "env-dsn"
 		expectedConfig.db.maxOpenConns = 30
 		expectedConfig.db.maxIdleConns = 15
@@ -128,6 +183,10 @@ func TestLoadConfig(t *testing.T) {
 		expectedConfig.env = "test server 2"
 		expectedConfig.port = 5000
 		expectedConfig.idleTimeout = time.Minute
+		expectedConfig.sweepInterval = time.Minute
+		expectedConfig.jobWorkers = 2
+		expectedConfig.jobPollInterval = time.Second
+		expectedConfig.purgeRetention = 30 * 24 * time.Hour
 		expectedConfig.readTimeout = 5 * time.Second
 		expectedConfig.WriteTimeout = 10 * time.Second
 		expectedConfig.db.dsn = "env-dsn"
@@ -151,6 +210,10 @@ func TestLoadConfig(t *testing.T) {
 		expectedConfig.env = ""
 		expectedConfig.port = 4000
 		expectedConfig.idleTimeout = time.Minute
+		expectedConfig.sweepInterval = time.Minute
+		expectedConfig.jobWorkers = 2
+		expectedConfig.jobPollInterval = time.Second
+		expectedConfig.purgeRetention = 30 * 24 * time.Hour
 		expectedConfig.readTimeout = 5 * time.Second
 		expectedConfig.WriteTimeout = 10 * time.Second
 		expectedConfig.db.dsn = ""
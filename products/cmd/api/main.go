@@ -7,10 +7,11 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/chlovec/go-ecommerce/products/internal/data"
 	"github.com/chlovec/go-ecommerce/products/internal/handlers"
-	"github.com/julienschmidt/httprouter"
 	_ "github.com/lib/pq"
 )
 
@@ -31,6 +32,13 @@ func run(
 	newServer func(cfg config, logger *slog.Logger, db *sql.DB) APIServer,
 ) int {
 
+	// The purge command removes expired soft deleted records and exits instead of
+	// serving requests. It takes the same flags as the server.
+	purge := len(args) > 0 && args[0] == "purge"
+	if purge {
+		args = args[1:]
+	}
+
 	// Load config
 	cfg, err := loadConfig(args, os.Getenv)
 	if err != nil {
@@ -53,6 +61,35 @@ func run(
 	// established.
 	logger.Info("database connection pool established")
 
+	if purge {
+		err = purgeDeleted(
+			context.Background(),
+			logger,
+			data.NewProductModel(db),
+			data.NewCategoryModel(db),
+			time.Now().Add(-cfg.purgeRetention),
+		)
+		if err != nil {
+			logger.Error(err.Error(), "task", "purge")
+			return 1
+		}
+		return 0
+	}
+
+	// Start the reservation sweeper in the background. It is stopped, and waited for,
+	// once the server has shut down and before the connection pool is closed.
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sweepReservations(sweepCtx, logger, data.NewReservationModel(db), cfg.sweepInterval)
+	}()
+	defer func() {
+		stopSweeper()
+		wg.Wait()
+	}()
+
 	// Instantiate a new server and start listening and responding to requests.
 	svr := newServer(cfg, logger, db)
 	err = svr.Serve()
@@ -109,18 +146,89 @@ func openDB(
 	return db, nil
 }
 
-func routes(logger *slog.Logger, db *sql.DB) http.Handler {
-	router := httprouter.New()
-
-	h := handlers.NewHandlers(logger, db)
+func routes(h *handlers.Handlers) http.Handler {
+	mux := http.NewServeMux()
 
 	// Products request routing
-	router.HandlerFunc(http.MethodPost, "/v1/api/products", h.CreateProductHandler)
+	mux.HandleFunc("POST /v1/api/products", h.CreateProductHandler)
+	mux.HandleFunc("POST /v1/api/products/batch", h.CreateProductBatchHandler)
+	mux.HandleFunc("POST /v1/api/products/import", h.ImportProductCSVHandler)
+	mux.HandleFunc("POST /v1/api/products/bulk-update", h.BulkUpdateProductsHandler)
+	mux.HandleFunc("GET /v1/api/products/export.csv", h.ExportProductCSVHandler)
+	mux.HandleFunc("GET /v1/api/products/stream", h.StreamProductHandler)
+	mux.HandleFunc("GET /v1/api/products/search", h.SearchProductHandler)
+	mux.HandleFunc("GET /v1/api/products/{id}", h.GetProductHandler)
+	mux.HandleFunc("GET /v1/api/products", h.ListProductHandler)
+	mux.HandleFunc("PATCH /v1/api/products/{id}", h.UpdateProductHandler)
+	mux.HandleFunc("DELETE /v1/api/products/{id}", h.DeleteProductHandler)
+	mux.HandleFunc("POST /v1/api/products/{id}/restore", h.RestoreProductHandler)
+	mux.HandleFunc("GET /v1/api/products/{id}/revisions", h.ListProductRevisionHandler)
+	mux.HandleFunc(
+		"GET /v1/api/products/{id}/revisions/{version}",
+		h.GetProductRevisionHandler,
+	)
+	mux.HandleFunc("POST /v1/api/products/{id}/reservations", h.CreateReservationHandler)
+	mux.HandleFunc(
+		"POST /v1/api/products/{id}/stock-adjustments",
+		h.CreateStockAdjustmentHandler,
+	)
+	mux.HandleFunc("GET /v1/api/products/{id}/stock-movements", h.ListStockMovementHandler)
+	mux.HandleFunc("POST /v1/api/products/{id}/variants", h.CreateVariantHandler)
+	mux.HandleFunc("GET /v1/api/products/{id}/variants", h.ListVariantHandler)
+	mux.HandleFunc("GET /v1/api/products/{id}/variants/{variant_id}", h.GetVariantHandler)
+	mux.HandleFunc("PATCH /v1/api/products/{id}/variants/{variant_id}", h.UpdateVariantHandler)
+	mux.HandleFunc(
+		"DELETE /v1/api/products/{id}/variants/{variant_id}",
+		h.DeleteVariantHandler,
+	)
+
+	// Jobs request routing
+	mux.HandleFunc("GET /v1/api/jobs/{id}", h.GetJobHandler)
+
+	// Reservations request routing
+	mux.HandleFunc("GET /v1/api/reservations/{id}", h.GetReservationHandler)
+	mux.HandleFunc("POST /v1/api/reservations/{id}/commit", h.CommitReservationHandler)
+	mux.HandleFunc("POST /v1/api/reservations/{id}/release", h.ReleaseReservationHandler)
 
 	// Categories request routing
-	router.HandlerFunc(http.MethodPost, "/v1/api/categories", h.CreateCategoryHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/api/categories/:id", h.GetCategoryHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/api/categories", h.ListCategoryHandler)
-
-	return router
+	mux.HandleFunc("POST /v1/api/categories", h.CreateCategoryHandler)
+	mux.HandleFunc("GET /v1/api/categories/{id}", h.GetCategoryHandler)
+	mux.HandleFunc("GET /v1/api/categories/tree", h.GetCategoryTreeHandler)
+	mux.HandleFunc("GET /v1/api/categories/{id}/ancestors", h.GetCategoryAncestorsHandler)
+	mux.HandleFunc("GET /v1/api/categories", h.ListCategoryHandler)
+	mux.HandleFunc("PATCH /v1/api/categories/{id}", h.UpdateCategoryHandler)
+	mux.HandleFunc("DELETE /v1/api/categories/{id}", h.DeleteCategoryHandler)
+	mux.HandleFunc("POST /v1/api/categories/{id}/restore", h.RestoreCategoryHandler)
+	mux.HandleFunc("GET /v1/api/categories/{id}/revisions", h.ListCategoryRevisionHandler)
+	mux.HandleFunc(
+		"GET /v1/api/categories/{id}/revisions/{version}",
+		h.GetCategoryRevisionHandler,
+	)
+
+	// Search request routing
+	mux.HandleFunc("GET /v1/api/suggest", h.SuggestHandler)
+	mux.HandleFunc("POST /v1/api/search/synonyms", h.CreateSynonymHandler)
+	mux.HandleFunc("GET /v1/api/search/synonyms", h.ListSynonymHandler)
+	mux.HandleFunc("GET /v1/api/search/synonyms/{id}", h.GetSynonymHandler)
+	mux.HandleFunc("PATCH /v1/api/search/synonyms/{id}", h.UpdateSynonymHandler)
+	mux.HandleFunc("DELETE /v1/api/search/synonyms/{id}", h.DeleteSynonymHandler)
+	mux.HandleFunc("POST /v1/api/search/stop-words", h.CreateStopWordHandler)
+	mux.HandleFunc("GET /v1/api/search/stop-words", h.ListStopWordHandler)
+	mux.HandleFunc("DELETE /v1/api/search/stop-words/{word}", h.DeleteStopWordHandler)
+	mux.HandleFunc("POST /v1/api/search/rebuild", h.RebuildSearchHandler)
+
+	// Slug request routing. A slug may look like the path of another route, e.g.
+	// /v1/api/products/by-slug/variants, so a single ServeMux refuses to register
+	// both. The slug routes are served by a mux of their own that is tried first.
+	slugs := http.NewServeMux()
+	slugs.HandleFunc("GET /v1/api/products/by-slug/{slug}", h.GetProductBySlugHandler)
+	slugs.HandleFunc("GET /v1/api/categories/by-slug/{slug}", h.GetCategoryBySlugHandler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := slugs.Handler(r); pattern != "" {
+			slugs.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
 }
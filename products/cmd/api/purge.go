@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// purgeBatchSize is the number of deleted records removed by a single statement.
+const purgeBatchSize = 500
+
+// The purgeDeleted() function permanently removes the products, then the categories,
+// deleted before the given time. Products go first so that the categories they held
+// on to become purgeable in the same run.
+func purgeDeleted(
+	ctx context.Context,
+	logger *slog.Logger,
+	products data.ProductRepository,
+	categories data.CategoryRepository,
+	before time.Time,
+) error {
+	purged, err := purgeBatches(ctx, before, products.Purge)
+	if err != nil {
+		return err
+	}
+	logger.Info("purged products", "count", purged)
+
+	purged, err = purgeBatches(ctx, before, categories.Purge)
+	if err != nil {
+		return err
+	}
+	logger.Info("purged categories", "count", purged)
+
+	return nil
+}
+
+// The purgeBatches() function calls purge in batches until a batch comes back short,
+// and returns the total number of records removed.
+func purgeBatches(
+	ctx context.Context,
+	before time.Time,
+	purge func(ctx context.Context, before time.Time, limit int) (int64, error),
+) (int64, error) {
+	var total int64
+	for {
+		// Create a context with a 5-second timeout deadline for each batch.
+		batchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		purged, err := purge(batchCtx, before, purgeBatchSize)
+		cancel()
+
+		total += purged
+		if err != nil || purged < purgeBatchSize {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPurgeProductRepository struct {
+	data.ProductRepository
+	mock.Mock
+}
+
+func (m *MockPurgeProductRepository) Purge(
+	ctx context.Context,
+	before time.Time,
+	limit int,
+) (int64, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockPurgeCategoryRepository struct {
+	data.CategoryRepository
+	mock.Mock
+}
+
+func (m *MockPurgeCategoryRepository) Purge(
+	ctx context.Context,
+	before time.Time,
+	limit int,
+) (int64, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	before := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("purges products then categories in batches", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := newLogger(&buf)
+
+		products := new(MockPurgeProductRepository)
+		products.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(purgeBatchSize), nil).Once()
+		products.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(12), nil).Once()
+		categories := new(MockPurgeCategoryRepository)
+		categories.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(2), nil).Once()
+
+		err := purgeDeleted(context.Background(), logger, products, categories, before)
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "msg=\"purged products\" count=512")
+		assert.Contains(t, buf.String(), "msg=\"purged categories\" count=2")
+		products.AssertExpectations(t)
+		categories.AssertExpectations(t)
+	})
+
+	t.Run("stops on a product error", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := newLogger(&buf)
+
+		products := new(MockPurgeProductRepository)
+		products.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(0), errors.New("purge error")).Once()
+		categories := new(MockPurgeCategoryRepository)
+
+		err := purgeDeleted(context.Background(), logger, products, categories, before)
+		assert.Equal(t, "purge error", err.Error())
+		assert.Empty(t, buf.String())
+		products.AssertExpectations(t)
+		categories.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("stops on a category error", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := newLogger(&buf)
+
+		products := new(MockPurgeProductRepository)
+		products.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(0), nil).Once()
+		categories := new(MockPurgeCategoryRepository)
+		categories.On("Purge", mock.Anything, before, purgeBatchSize).
+			Return(int64(0), errors.New("purge error")).Once()
+
+		err := purgeDeleted(context.Background(), logger, products, categories, before)
+		assert.Equal(t, "purge error", err.Error())
+		assert.Contains(t, buf.String(), "msg=\"purged products\" count=0")
+		assert.NotContains(t, buf.String(), "purged categories")
+		products.AssertExpectations(t)
+		categories.AssertExpectations(t)
+	})
+}
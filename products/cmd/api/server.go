@@ -11,6 +11,9 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/chlovec/go-ecommerce/products/internal/handlers"
 )
 
 type APIServer interface {
@@ -27,17 +30,27 @@ type Server struct {
 	config     config
 	httpServer HTTPServer
 	logger     *slog.Logger
+	// workers run the queued jobs while the server is up.
+	workers *jobWorkers
 }
 
 func newServer(cfg config, logger *slog.Logger, db *sql.DB) APIServer {
 	addr := fmt.Sprintf(":%d", cfg.port)
+	h := handlers.NewHandlers(logger, db, cfg.searchLanguage)
 	return &Server{
 		addr:   addr,
 		config: cfg,
 		logger: logger,
+		workers: newJobWorkers(
+			logger,
+			data.NewJobModel(db),
+			h.JobRunners(),
+			cfg.jobWorkers,
+			cfg.jobPollInterval,
+		),
 		httpServer: &http.Server{
 			Addr:         addr,
-			Handler:      routes(logger, db),
+			Handler:      routes(h),
 			IdleTimeout:  cfg.idleTimeout,
 			ReadTimeout:  cfg.readTimeout,
 			WriteTimeout: cfg.WriteTimeout,
@@ -66,13 +79,29 @@ func (svr *Server) Serve() error {
 		// Call Shutdown() on the server like before, but now we only send on the
 		// shutdownError channel if it returns an error.
 		err := svr.httpServer.Shutdown(ctx)
+
+		// Once the requests are done, stop the workers. The jobs they are running are
+		// put back in the queue, to be resumed when the server starts again.
+		if svr.workers != nil {
+			if drainErr := svr.workers.drain(ctx); err == nil {
+				err = drainErr
+			}
+		}
+
 		shutdownError <- err
 	}()
 
 	svr.logger.Info("starting server", "addr", svr.addr, "env", svr.config.env)
 
+	if svr.workers != nil {
+		svr.workers.start()
+	}
+
 	err := svr.httpServer.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
+		if svr.workers != nil {
+			_ = svr.workers.drain(context.Background())
+		}
 		return err
 	}
 
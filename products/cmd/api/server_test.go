@@ -11,6 +11,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/chlovec/go-ecommerce/products/internal/handlers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -107,6 +109,51 @@ func TestServe(t *testing.T) {
 		assert.NotContains(t, logOutput, `"stopped server" addr=:8080`)
 	})
 
+	t.Run("should drain the job workers on shutdown", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		logger := newLogger(sb)
+
+		mockSrv := new(MockHTTPServer)
+		mockSrv.On("Shutdown", mock.Anything).Return(nil)
+		mockSrv.On("ListenAndServe").Return(http.ErrServerClosed)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 1, Attempts: 1}
+		mockRepo := new(MockJobRepository)
+		mockRepo.On("Release", mock.Anything, job).Return(nil).Once()
+
+		started := make(chan struct{})
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+		workers.reportInterval = time.Hour
+
+		svr := &Server{
+			addr:       ":8080",
+			config:     cfg,
+			logger:     logger,
+			httpServer: mockSrv,
+			workers:    workers,
+		}
+
+		go func() {
+			<-started
+			process, _ := os.FindProcess(os.Getpid())
+			err := process.Signal(os.Interrupt)
+			assert.NoError(t, err)
+		}()
+
+		err := svr.Serve()
+		assert.NoError(t, err)
+
+		logOutput := sb.String()
+		assert.Contains(t, logOutput, `"released job" job=12 kind=test progress=0 total=1`)
+		assert.Contains(t, logOutput, `"stopped server" addr=:8080`)
+		mockRepo.AssertExpectations(t)
+	})
+
 	t.Run("should fail with listen and serve error", func(t *testing.T) {
 		sb := &safeBuffer{b: &bytes.Buffer{}}
 		logger := newLogger(sb)
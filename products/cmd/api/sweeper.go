@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// sweepBatchSize is the number of lapsed reservations expired by a single statement.
+const sweepBatchSize = 500
+
+// The sweepReservations() function expires lapsed reservations every interval until
+// ctx is cancelled, returning their held units to the available stock.
+func sweepReservations(
+	ctx context.Context,
+	logger *slog.Logger,
+	reservations data.ReservationRepository,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := expireReservations(ctx, reservations)
+			if err != nil && ctx.Err() == nil {
+				logger.Error(err.Error(), "task", "reservation sweeper")
+			}
+			if expired > 0 {
+				logger.Info("expired reservations", "count", expired)
+			}
+		}
+	}
+}
+
+// The expireReservations() function expires lapsed reservations in batches until a
+// batch comes back short, and returns the total number expired.
+func expireReservations(
+	ctx context.Context,
+	reservations data.ReservationRepository,
+) (int64, error) {
+	var total int64
+	for {
+		// Create a context with a 5-second timeout deadline for each batch.
+		batchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		expired, err := reservations.ExpireHolds(batchCtx, sweepBatchSize)
+		cancel()
+
+		total += expired
+		if err != nil || expired < sweepBatchSize {
+			return total, err
+		}
+	}
+}
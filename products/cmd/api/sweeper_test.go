@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReservationRepository struct {
+	data.ReservationRepository
+	mock.Mock
+}
+
+func (m *MockReservationRepository) ExpireHolds(ctx context.Context, limit int) (int64, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestExpireReservations(t *testing.T) {
+	t.Run("expires in batches until a short batch", func(t *testing.T) {
+		mockRepo := new(MockReservationRepository)
+		mockRepo.On("ExpireHolds", mock.Anything, sweepBatchSize).
+			Return(int64(sweepBatchSize), nil).Twice()
+		mockRepo.On("ExpireHolds", mock.Anything, sweepBatchSize).
+			Return(int64(7), nil).Once()
+
+		expired, err := expireReservations(context.Background(), mockRepo)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2*sweepBatchSize+7), expired)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		mockRepo := new(MockReservationRepository)
+		mockRepo.On("ExpireHolds", mock.Anything, sweepBatchSize).
+			Return(int64(0), errors.New("sweep error")).Once()
+
+		expired, err := expireReservations(context.Background(), mockRepo)
+		assert.Equal(t, "sweep error", err.Error())
+		assert.Equal(t, int64(0), expired)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestSweepReservations(t *testing.T) {
+	sb := &safeBuffer{b: &bytes.Buffer{}}
+	logger := newLogger(sb)
+
+	mockRepo := new(MockReservationRepository)
+	mockRepo.On("ExpireHolds", mock.Anything, sweepBatchSize).Return(int64(3), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweepReservations(ctx, logger, mockRepo, 5*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(sb.String(), "msg=\"expired reservations\" count=3")
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop after cancel")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/chlovec/go-ecommerce/products/internal/handlers"
+)
+
+const (
+	// jobLease is how long a worker holds a job between two reports. A job whose
+	// worker went down with the server is claimed again once its lease has run out.
+	jobLease = time.Minute
+
+	// jobReportInterval is how often the progress of a running job is saved, which
+	// renews its lease.
+	jobReportInterval = 5 * time.Second
+
+	// maxJobAttempts is the number of times a job is claimed before it is failed, so
+	// that a job that takes the server down with it is not run forever.
+	maxJobAttempts = 3
+)
+
+// jobWorkers run the queued jobs in the background, one job per worker at a time.
+type jobWorkers struct {
+	logger         *slog.Logger
+	jobs           data.JobRepository
+	runners        map[string]handlers.JobRunner
+	count          int
+	pollInterval   time.Duration
+	lease          time.Duration
+	reportInterval time.Duration
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func newJobWorkers(
+	logger *slog.Logger,
+	jobs data.JobRepository,
+	runners map[string]handlers.JobRunner,
+	count int,
+	pollInterval time.Duration,
+) *jobWorkers {
+	return &jobWorkers{
+		logger:         logger,
+		jobs:           jobs,
+		runners:        runners,
+		count:          count,
+		pollInterval:   pollInterval,
+		lease:          jobLease,
+		reportInterval: jobReportInterval,
+	}
+}
+
+// The start() method starts the workers. Each looks for a job every pollInterval
+// while the queue is empty.
+func (w *jobWorkers) start() {
+	ctx, stop := context.WithCancel(context.Background())
+	w.stop = stop
+
+	kinds := slices.Sorted(maps.Keys(w.runners))
+	for range w.count {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.work(ctx, kinds)
+		}()
+	}
+}
+
+// The drain() method stops the workers and waits, until ctx is done, for them to put
+// the jobs they were running back in the queue. The jobs resume from their progress
+// once the workers are started again.
+func (w *jobWorkers) drain(ctx context.Context) error {
+	if w.stop == nil {
+		return nil
+	}
+	w.stop()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job workers did not stop: %w", ctx.Err())
+	}
+}
+
+// The work() method runs the jobs of the kinds one after the other until ctx is
+// cancelled.
+func (w *jobWorkers) work(ctx context.Context, kinds []string) {
+	for ctx.Err() == nil {
+		if w.runNext(ctx, kinds) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// The runNext() method claims the next job and runs it. It returns false if there was
+// no job to run.
+func (w *jobWorkers) runNext(ctx context.Context, kinds []string) bool {
+	// Create a context with a 5-second timeout deadline.
+	claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	job, err := w.jobs.Claim(claimCtx, kinds, w.lease)
+	cancel()
+
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) && ctx.Err() == nil {
+			w.logger.Error(err.Error(), "task", "job worker")
+		}
+		return false
+	}
+
+	w.run(ctx, job)
+	return true
+}
+
+// The run() method runs a claimed job with the runner of its kind and records how it
+// ended. Its progress is saved every reportInterval while it runs. A job stopped by
+// ctx being cancelled is put back in the queue; a job claimed by another worker in
+// the meantime is left to it.
+func (w *jobWorkers) run(ctx context.Context, job *data.Job) {
+	logger := w.logger.With("job", job.ID, "kind", job.Kind)
+
+	if job.Attempts > maxJobAttempts {
+		message := fmt.Sprintf("job was interrupted %d times", maxJobAttempts)
+		job.Status, job.Error = data.JobFailed, &message
+		w.finish(logger, job)
+		return
+	}
+
+	runCtx, cancel := context.WithCancel(data.ContextWithActor(ctx, job.Actor))
+	defer cancel()
+
+	// The runner reports to the worker; the reports are saved from copies of the job
+	// so that the job itself is not written while the runner reads it.
+	var mu sync.Mutex
+	progress, result := job.Progress, any(job.Result)
+	report := func(p int, r any) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress, result = p, r
+	}
+	snapshot := func() *data.Job {
+		mu.Lock()
+		defer mu.Unlock()
+		state := *job
+		state.Progress = progress
+		state.Result = w.marshalResult(logger, result)
+		return &state
+	}
+
+	var leaseLost atomic.Bool
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(w.reportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				reportCtx, cancelReport := context.WithTimeout(context.Background(), 5*time.Second)
+				err := w.jobs.Report(reportCtx, snapshot(), w.lease)
+				cancelReport()
+
+				if errors.Is(err, data.ErrJobLeaseLost) {
+					leaseLost.Store(true)
+					cancel()
+					return
+				}
+				if err != nil {
+					logger.Error(err.Error(), "task", "job worker")
+				}
+			}
+		}
+	}()
+
+	value, err := w.runners[job.Kind](runCtx, job, report)
+	cancel()
+	<-reported
+
+	state := snapshot()
+	switch {
+	case leaseLost.Load(), errors.Is(err, data.ErrJobLeaseLost):
+		logger.Error(data.ErrJobLeaseLost.Error())
+	case err == nil:
+		state.Status, state.Progress = data.JobSucceeded, state.Total
+		state.Result = w.marshalResult(logger, value)
+		w.finish(logger, state)
+	case ctx.Err() != nil:
+		w.release(logger, state)
+	default:
+		message := err.Error()
+		state.Status, state.Error = data.JobFailed, &message
+		w.finish(logger, state)
+	}
+}
+
+// The finish() method records the end of a job.
+func (w *jobWorkers) finish(logger *slog.Logger, job *data.Job) {
+	// The workers may be stopping, the end of the job is recorded all the same.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := w.jobs.Finish(ctx, job); err != nil {
+		logger.Error(err.Error(), "task", "job worker")
+		return
+	}
+	logger.Info("finished job", "status", job.Status)
+}
+
+// The release() method puts a job stopped by the workers back in the queue.
+func (w *jobWorkers) release(logger *slog.Logger, job *data.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := w.jobs.Release(ctx, job); err != nil {
+		logger.Error(err.Error(), "task", "job worker")
+		return
+	}
+	logger.Info("released job", "progress", job.Progress, "total", job.Total)
+}
+
+// The marshalResult() method returns the JSON of the result of a job, or nil if it has
+// none or it cannot be encoded.
+func (w *jobWorkers) marshalResult(logger *slog.Logger, result any) json.RawMessage {
+	if result == nil {
+		return nil
+	}
+	if raw, ok := result.(json.RawMessage); ok {
+		return raw
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		logger.Error(err.Error(), "task", "job worker")
+		return nil
+	}
+	return raw
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/chlovec/go-ecommerce/products/internal/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobRepository struct {
+	data.JobRepository
+	mock.Mock
+}
+
+func (m *MockJobRepository) Claim(
+	ctx context.Context,
+	kinds []string,
+	lease time.Duration,
+) (*data.Job, error) {
+	args := m.Called(ctx, kinds, lease)
+	job, _ := args.Get(0).(*data.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) Report(ctx context.Context, job *data.Job, lease time.Duration) error {
+	args := m.Called(ctx, job, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Finish(ctx context.Context, job *data.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Release(ctx context.Context, job *data.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+// newTestJobWorkers returns a single worker running the jobs claimed from mockRepo
+// with runner, which claims each job once and then finds the queue empty.
+func newTestJobWorkers(
+	sb *safeBuffer,
+	mockRepo *MockJobRepository,
+	runner handlers.JobRunner,
+	jobs ...*data.Job,
+) *jobWorkers {
+	for _, job := range jobs {
+		mockRepo.On("Claim", mock.Anything, []string{"test"}, jobLease).Return(job, nil).Once()
+	}
+	mockRepo.On("Claim", mock.Anything, []string{"test"}, jobLease).
+		Return(nil, data.ErrRecordNotFound)
+
+	workers := newJobWorkers(
+		newLogger(sb),
+		mockRepo,
+		map[string]handlers.JobRunner{"test": runner},
+		1,
+		5*time.Millisecond,
+	)
+	workers.reportInterval = 5 * time.Millisecond
+	return workers
+}
+
+func TestJobWorkers(t *testing.T) {
+	t.Run("finishes the jobs that succeed", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Actor: "alice", Total: 3, Attempts: 1}
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			assert.Equal(t, "alice", data.ActorFromContext(ctx))
+			report(2, nil)
+			return map[string]int{"created": 3}, nil
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		mockRepo.On("Finish", mock.Anything, &data.Job{
+			ID:       12,
+			Kind:     "test",
+			Actor:    "alice",
+			Status:   data.JobSucceeded,
+			Progress: 3,
+			Total:    3,
+			Result:   []byte(`{"created":3}`),
+			Attempts: 1,
+		}).Return(nil).Once()
+
+		workers.start()
+		assert.Eventually(t, func() bool {
+			return strings.Contains(sb.String(), `msg="finished job" job=12 kind=test status=succeeded`)
+		}, time.Second, 5*time.Millisecond)
+
+		assert.NoError(t, workers.drain(context.Background()))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("fails the jobs that return an error", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 3, Attempts: 1}
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			report(1, map[string]int{"created": 1})
+			return nil, errors.New("import failed")
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		message := "import failed"
+		mockRepo.On("Finish", mock.Anything, &data.Job{
+			ID:       12,
+			Kind:     "test",
+			Status:   data.JobFailed,
+			Progress: 1,
+			Total:    3,
+			Result:   []byte(`{"created":1}`),
+			Error:    &message,
+			Attempts: 1,
+		}).Return(nil).Once()
+
+		workers.start()
+		assert.Eventually(t, func() bool {
+			return strings.Contains(sb.String(), `msg="finished job" job=12 kind=test status=failed`)
+		}, time.Second, 5*time.Millisecond)
+
+		assert.NoError(t, workers.drain(context.Background()))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("fails the jobs that were interrupted too often without running them", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Attempts: maxJobAttempts + 1}
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			t.Error("the job should not run")
+			return nil, nil
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		mockRepo.On("Finish", mock.Anything, mock.MatchedBy(func(job *data.Job) bool {
+			return job.Status == data.JobFailed && *job.Error == "job was interrupted 3 times"
+		})).Return(nil).Once()
+
+		workers.start()
+		assert.Eventually(t, func() bool {
+			return strings.Contains(sb.String(), `msg="finished job"`)
+		}, time.Second, 5*time.Millisecond)
+
+		assert.NoError(t, workers.drain(context.Background()))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("saves the progress of running jobs and releases them when drained", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 3, Attempts: 1}
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			report(1, map[string]int{"created": 1})
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		reported := &data.Job{
+			ID:       12,
+			Kind:     "test",
+			Progress: 1,
+			Total:    3,
+			Result:   []byte(`{"created":1}`),
+			Attempts: 1,
+		}
+		var once sync.Once
+		saved := make(chan struct{})
+		mockRepo.On("Report", mock.Anything, reported, jobLease).
+			Run(func(mock.Arguments) { once.Do(func() { close(saved) }) }).
+			Return(nil)
+		mockRepo.On("Release", mock.Anything, reported).Return(nil).Once()
+
+		workers.start()
+		select {
+		case <-saved:
+		case <-time.After(time.Second):
+			t.Fatal("progress of the job was not saved")
+		}
+
+		assert.NoError(t, workers.drain(context.Background()))
+		assert.Contains(t, sb.String(), `msg="released job" job=12 kind=test progress=1 total=3`)
+		mockRepo.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stops the jobs claimed by another worker", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 3, Attempts: 1}
+		stopped := make(chan struct{})
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			<-ctx.Done()
+			close(stopped)
+			return nil, ctx.Err()
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		mockRepo.On("Report", mock.Anything, mock.Anything, jobLease).Return(data.ErrJobLeaseLost)
+
+		workers.start()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("job did not stop after its lease was lost")
+		}
+
+		assert.NoError(t, workers.drain(context.Background()))
+		assert.Contains(t, sb.String(), `msg="job is no longer held by this worker" job=12`)
+		mockRepo.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+	})
+
+	t.Run("leaves the jobs a runner finds claimed by another worker", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 3, Attempts: 1}
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			return nil, fmt.Errorf("saving row 2: %w", data.ErrJobLeaseLost)
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+
+		workers.start()
+		assert.Eventually(t, func() bool {
+			return strings.Contains(sb.String(), `msg="job is no longer held by this worker" job=12`)
+		}, time.Second, 5*time.Millisecond)
+
+		assert.NoError(t, workers.drain(context.Background()))
+		mockRepo.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+	})
+
+	t.Run("does not update the products again when a bulk update is claimed again", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		db, sqlMock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		runner := handlers.NewHandlers(newLogger(sb), db, "english").
+			JobRunners()["bulk_update_products"]
+
+		// The update is committed with the progress of the job, but the worker fails to
+		// finish it, so that it is claimed again once its lease has run out.
+		payload := []byte(`{"filter": {"ids": [4]}, "operation": {"type": "set_quantity", "quantity": 5}}`)
+		job := &data.Job{ID: 12, Kind: "test", Payload: payload, Total: 1, Attempts: 1}
+		claimedAgain := &data.Job{
+			ID:       12,
+			Kind:     "test",
+			Payload:  payload,
+			Progress: 1,
+			Total:    1,
+			Result:   []byte(`{"matched":1,"updated":1}`),
+			Attempts: 2,
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job, claimedAgain)
+
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec("SELECT set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectQuery("SELECT id FROM products").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		sqlMock.ExpectQuery("UPDATE products").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "version", "before", "after"}).
+				AddRow(4, "Blue Shoe", 2, 3, 5),
+		)
+		sqlMock.ExpectExec("UPDATE jobs").
+			WithArgs(1, []byte(`{"matched":1,"updated":1}`), 12, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		attempt := func(n int) any {
+			return mock.MatchedBy(func(job *data.Job) bool { return job.Attempts == n })
+		}
+		mockRepo.On("Report", mock.Anything, mock.Anything, jobLease).Return(nil).Maybe()
+		mockRepo.On("Finish", mock.Anything, attempt(1)).
+			Return(errors.New("connection reset")).Once()
+		mockRepo.On("Finish", mock.Anything, &data.Job{
+			ID:       12,
+			Kind:     "test",
+			Payload:  payload,
+			Status:   data.JobSucceeded,
+			Progress: 1,
+			Total:    1,
+			Result:   []byte(`{"matched":1,"updated":1}`),
+			Attempts: 2,
+		}).Return(nil).Once()
+
+		workers.start()
+		assert.Eventually(t, func() bool {
+			return strings.Contains(sb.String(), `msg="finished job" job=12 kind=test status=succeeded`)
+		}, time.Second, 5*time.Millisecond)
+
+		assert.NoError(t, workers.drain(context.Background()))
+		assert.Contains(t, sb.String(), `msg="connection reset" job=12 kind=test task="job worker"`)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("gives up draining when ctx is done", func(t *testing.T) {
+		sb := &safeBuffer{b: &bytes.Buffer{}}
+		mockRepo := new(MockJobRepository)
+
+		job := &data.Job{ID: 12, Kind: "test", Total: 1, Attempts: 1}
+		started, unblock := make(chan struct{}), make(chan struct{})
+		runner := func(ctx context.Context, job *data.Job, report handlers.JobReport) (any, error) {
+			close(started)
+			<-unblock
+			return nil, ctx.Err()
+		}
+		workers := newTestJobWorkers(sb, mockRepo, runner, job)
+		workers.reportInterval = time.Hour
+		mockRepo.On("Release", mock.Anything, mock.Anything).Return(nil)
+
+		workers.start()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := workers.drain(ctx)
+		assert.Equal(t, "job workers did not stop: context deadline exceeded", err.Error())
+
+		close(unblock)
+		assert.NoError(t, workers.drain(context.Background()))
+	})
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Attributes are the structured specs of a product, e.g. {"color": "red",
+// "wattage": 500}. Values are strings, numbers or booleans. They are stored as a JSONB
+// object.
+type Attributes map[string]any
+
+// Value implements the driver.Valuer interface, the attributes are stored as JSON.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface for the JSONB attributes column.
+func (a *Attributes) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Attributes", src)
+	}
+
+	return json.Unmarshal(b, a)
+}
+
+// AttributeCondition is a filter on a product attribute. The Value of an equality
+// condition is the string from the query string, it matches the attribute when the
+// attribute holds that string or the number or boolean it spells. The Value of a
+// range condition is a float64 and only matches numeric attributes.
+type AttributeCondition struct {
+	Name  string
+	Op    FilterOp
+	Value any
+}
+
+// attributeConditions translates the attribute conditions into squirrel predicates.
+// Equality is tested with @> and ranges with a jsonpath predicate, both of which are
+// served by the GIN index on the column. Values are passed as arguments and the names
+// are quoted inside the JSON documents, so neither is ever spliced into the SQL.
+func attributeConditions(conds []AttributeCondition) sq.And {
+	predicates := sq.And{}
+	for _, cond := range conds {
+		switch cond.Op {
+		case OpEq:
+			s, _ := cond.Value.(string)
+			matches := sq.Or{containsAttribute(cond.Name, s)}
+			if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				matches = append(matches, containsAttribute(cond.Name, f))
+			}
+			if s == "true" || s == "false" {
+				matches = append(matches, containsAttribute(cond.Name, s == "true"))
+			}
+			predicates = append(predicates, matches)
+		case OpGte, OpLte:
+			f, _ := cond.Value.(float64)
+			comparison := ">="
+			if cond.Op == OpLte {
+				comparison = "<="
+			}
+			name, _ := json.Marshal(cond.Name)
+			path := fmt.Sprintf("$.%s %s %s", name, comparison, strconv.FormatFloat(f, 'g', -1, 64))
+			predicates = append(predicates, sq.Expr("attributes @@ ?::jsonpath", path))
+		}
+	}
+
+	return predicates
+}
+
+func containsAttribute(name string, value any) sq.Sqlizer {
+	doc, _ := json.Marshal(map[string]any{name: value})
+	return sq.Expr("attributes @> ?::jsonb", string(doc))
+}
+
+// AttributeType is the type of the values of an attribute declared by a category.
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeInt     AttributeType = "int"
+	AttributeDecimal AttributeType = "decimal"
+	AttributeBool    AttributeType = "bool"
+)
+
+// AttributeTypes are the types an attribute can be declared with.
+var AttributeTypes = []AttributeType{
+	AttributeString,
+	AttributeInt,
+	AttributeDecimal,
+	AttributeBool,
+}
+
+// AttributeSpec declares an attribute of the products of a category. Values, when
+// set, are the only values the attribute may hold, written as the attribute is in
+// the query string, e.g. "16" or "true". Unit is the unit numbers are given in, for
+// the storefront to show.
+type AttributeSpec struct {
+	Type     AttributeType `json:"type"`
+	Required bool          `json:"required,omitempty"`
+	Values   []string      `json:"values,omitempty"`
+	Unit     string        `json:"unit,omitempty"`
+}
+
+// AttributeSchema declares the attributes of the products of a category by name.
+// Products may have attributes the schema does not declare.
+type AttributeSchema map[string]AttributeSpec
+
+// Value implements the driver.Valuer interface, the schema is stored as JSON.
+func (s AttributeSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for the JSONB attribute_schema column.
+func (s *AttributeSchema) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into AttributeSchema", src)
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return err
+	}
+	// An empty schema is read as nil so that it reads the same as no schema at all.
+	if len(*s) == 0 {
+		*s = nil
+	}
+	return nil
+}
+
+// format returns the value of an attribute in the form allowed values are written in.
+// It returns false if the value is not of type t.
+func (t AttributeType) format(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, t == AttributeString
+	case float64:
+		if t == AttributeInt && v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), t == AttributeDecimal
+	case bool:
+		return strconv.FormatBool(v), t == AttributeBool
+	default:
+		return "", false
+	}
+}
+
+// IsValue reports whether s is a value of type t written in the form attribute values
+// are compared in, e.g. "16" but not "16.0" for an int.
+func (t AttributeType) IsValue(s string) bool {
+	var value any = s
+	switch t {
+	case AttributeInt, AttributeDecimal:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return false
+		}
+		value = f
+	case AttributeBool:
+		value = s == "true"
+	}
+
+	formatted, ok := t.format(value)
+	return ok && formatted == s
+}
+
+// Validate checks the attributes of a product against the schema. It returns a
+// message for every declared attribute that is missing, of another type or not one of
+// the allowed values, keyed by the name of the attribute.
+func (s AttributeSchema) Validate(attributes Attributes) map[string]string {
+	errs := map[string]string{}
+	for name, spec := range s {
+		value, ok := attributes[name]
+		if !ok {
+			if spec.Required {
+				errs[name] = "is required"
+			}
+			continue
+		}
+
+		formatted, ok := spec.Type.format(value)
+		if !ok {
+			errs[name] = attributeTypeMessages[spec.Type]
+			continue
+		}
+		if len(spec.Values) > 0 && !slices.Contains(spec.Values, formatted) {
+			errs[name] = fmt.Sprintf("must be one of [%s]", strings.Join(spec.Values, " "))
+		}
+	}
+
+	return errs
+}
+
+var attributeTypeMessages = map[AttributeType]string{
+	AttributeString:  "must be a string",
+	AttributeInt:     "must be an integer",
+	AttributeDecimal: "must be a number",
+	AttributeBool:    "must be a boolean",
+}
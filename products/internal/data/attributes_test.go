@@ -0,0 +1,159 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributes_ValueAndScan(t *testing.T) {
+	value, err := Attributes(nil).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("{}"), value)
+
+	value, err = Attributes{"color": "red"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"color":"red"}`), value)
+
+	var attributes Attributes
+	assert.NoError(t, attributes.Scan([]byte(`{"color": "red", "wattage": 500}`)))
+	assert.Equal(t, Attributes{"color": "red", "wattage": float64(500)}, attributes)
+
+	assert.NoError(t, attributes.Scan(nil))
+	assert.Nil(t, attributes)
+
+	assert.EqualError(t, attributes.Scan(12), "cannot scan int into Attributes")
+}
+
+func TestAttributeConditions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		conds        []AttributeCondition
+		expectedSQL  string
+		expectedArgs []any
+	}{
+		{
+			name:         "string equality",
+			conds:        []AttributeCondition{{Name: "color", Op: OpEq, Value: "red"}},
+			expectedSQL:  "((attributes @> ?::jsonb))",
+			expectedArgs: []any{`{"color":"red"}`},
+		},
+		{
+			name:        "equality with a number",
+			conds:       []AttributeCondition{{Name: "wattage", Op: OpEq, Value: "500"}},
+			expectedSQL: "((attributes @> ?::jsonb OR attributes @> ?::jsonb))",
+			expectedArgs: []any{
+				`{"wattage":"500"}`,
+				`{"wattage":500}`,
+			},
+		},
+		{
+			name:        "equality with a boolean",
+			conds:       []AttributeCondition{{Name: "portable", Op: OpEq, Value: "true"}},
+			expectedSQL: "((attributes @> ?::jsonb OR attributes @> ?::jsonb))",
+			expectedArgs: []any{
+				`{"portable":"true"}`,
+				`{"portable":true}`,
+			},
+		},
+		{
+			name: "numeric range",
+			conds: []AttributeCondition{
+				{Name: "wattage", Op: OpGte, Value: float64(500)},
+				{Name: "wattage", Op: OpLte, Value: 1500.5},
+			},
+			expectedSQL: "(attributes @@ ?::jsonpath AND attributes @@ ?::jsonpath)",
+			expectedArgs: []any{
+				`$."wattage" >= 500`,
+				`$."wattage" <= 1500.5`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := attributeConditions(tc.conds).ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSQL, sql)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestAttributeSchema_Validate(t *testing.T) {
+	schema := AttributeSchema{
+		"capacity_gb": {Type: AttributeInt, Required: true, Values: []string{"8", "16"}},
+		"voltage":     {Type: AttributeDecimal, Unit: "V"},
+		"ecc":         {Type: AttributeBool},
+		"form_factor": {Type: AttributeString, Values: []string{"DIMM", "SO-DIMM"}},
+	}
+
+	testCases := []struct {
+		name       string
+		attributes Attributes
+		expected   map[string]string
+	}{
+		{
+			name:       "valid attributes",
+			attributes: Attributes{"capacity_gb": float64(16), "voltage": 1.1, "ecc": true},
+			expected:   map[string]string{},
+		},
+		{
+			name:       "undeclared attributes are allowed",
+			attributes: Attributes{"capacity_gb": float64(8), "color": "green"},
+			expected:   map[string]string{},
+		},
+		{
+			name:       "missing required attribute",
+			attributes: nil,
+			expected:   map[string]string{"capacity_gb": "is required"},
+		},
+		{
+			name: "wrong types",
+			attributes: Attributes{
+				"capacity_gb": 16.5,
+				"voltage":     "1.1",
+				"ecc":         "true",
+				"form_factor": float64(1),
+			},
+			expected: map[string]string{
+				"capacity_gb": "must be an integer",
+				"voltage":     "must be a number",
+				"ecc":         "must be a boolean",
+				"form_factor": "must be a string",
+			},
+		},
+		{
+			name:       "values not allowed",
+			attributes: Attributes{"capacity_gb": float64(12), "form_factor": "dimm"},
+			expected: map[string]string{
+				"capacity_gb": "must be one of [8 16]",
+				"form_factor": "must be one of [DIMM SO-DIMM]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, schema.Validate(tc.attributes))
+		})
+	}
+}
+
+func TestAttributeType_IsValue(t *testing.T) {
+	assert.True(t, AttributeInt.IsValue("16"))
+	assert.False(t, AttributeInt.IsValue("16.0"))
+	assert.False(t, AttributeInt.IsValue("1.5"))
+	assert.True(t, AttributeDecimal.IsValue("1.5"))
+	assert.False(t, AttributeDecimal.IsValue("abc"))
+	assert.True(t, AttributeBool.IsValue("false"))
+	assert.False(t, AttributeBool.IsValue("1"))
+	assert.True(t, AttributeString.IsValue("DIMM"))
+	assert.False(t, AttributeType("date").IsValue("2025-01-01"))
+
+	var schema AttributeSchema
+	assert.NoError(t, schema.Scan([]byte(`{}`)))
+	assert.Nil(t, schema)
+	assert.NoError(t, schema.Scan([]byte(`{"ecc": {"type": "bool"}}`)))
+	assert.Equal(t, AttributeSchema{"ecc": {Type: AttributeBool}}, schema)
+}
@@ -5,17 +5,33 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 )
 
+// Category is a node in the category hierarchy. Top level categories have no parent.
 type Category struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
 	Description string    `json:"description"`
+	ParentID    *int64    `json:"parent_id"`
 	Version     int       `json:"version"`
 	CreatedAt   time.Time `json:"-"`
+
+	// AttributeSchema declares the attributes of the products in the category. It is
+	// not loaded by GetTree and GetAncestors.
+	AttributeSchema AttributeSchema `json:"attribute_schema,omitempty"`
+
+	// DeletedAt is set once the category is deleted. Deleted categories are only read
+	// by GetAll when filters.IncludeDeleted is set.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Children is only filled in by GetTree.
+	Children []*Category `json:"children,omitempty"`
 }
 
 type CategoryModel struct {
@@ -25,40 +41,100 @@ type CategoryModel struct {
 type CategoryRepository interface {
 	Insert(ctx context.Context, category *Category) error
 	GetByID(ctx context.Context, id int64) (*Category, error)
+	LookupSlug(ctx context.Context, slug string) (int64, string, error)
 	GetAll(ctx context.Context, filters Filters) ([]*Category, Metadata, error)
+	Update(ctx context.Context, category *Category) error
+	Delete(ctx context.Context, id int64) error
+	DeleteAndReassign(ctx context.Context, id int64, toID int64) error
+	Restore(ctx context.Context, id int64) (*Category, error)
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+	GetTree(ctx context.Context) ([]*Category, error)
+	GetAncestors(ctx context.Context, id int64) ([]*Category, error)
 }
 
 func NewCategoryModel(db *sql.DB) *CategoryModel {
 	return &CategoryModel{db: db}
 }
 
+// Insert adds a category. It returns ErrInvalidParentId if the parent category does
+// not exist or has been deleted. Its slug is derived from the name unless
+// category.Slug is set, in which case it returns ErrDuplicateSlug if the slug is taken.
 func (c *CategoryModel) Insert(ctx context.Context, category *Category) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	if category.ParentID != nil {
+		if err = lockCategoryParents(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	category.Slug, err = categorySlugs.setSlug(ctx, tx, 0, category.Name, category.Slug)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO categories(name, description)
-		VALUES($1, $2)
+		INSERT INTO categories(name, description, parent_id, attribute_schema, slug)
+		SELECT $1, $2, $3, $4, $5
+		WHERE $3::bigint IS NULL
+			OR EXISTS(SELECT 1 FROM categories WHERE id = $3 AND deleted_at IS NULL)
 		RETURNING id, created_at, version
 	`
-	args := []any{category.Name, category.Description}
-	return c.db.QueryRowContext(ctx, query, args...).Scan(
+	args := []any{
+		category.Name,
+		category.Description,
+		category.ParentID,
+		category.AttributeSchema,
+		category.Slug,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&category.ID,
 		&category.CreatedAt,
 		&category.Version,
 	)
+	if err != nil {
+		return parentWriteError(err, category)
+	}
+
+	return tx.Commit()
+}
+
+// parentWriteError reports a foreign key violation on parent_id, or a parent that has
+// been deleted, as ErrInvalidParentId.
+func parentWriteError(err error, category *Category) error {
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) ||
+		errors.As(err, &pqErr) && pqErr.Code == ErrForeignKeyViolation {
+		return fmt.Errorf("parent_id %d does not exist: %w", *category.ParentID, ErrInvalidParentId)
+	}
+	return err
 }
 
 func (c *CategoryModel) GetByID(ctx context.Context, id int64) (*Category, error) {
 	query := `
-		SELECT id, name, description, created_at, version
+		SELECT id, name, slug, description, parent_id, created_at, version, attribute_schema
 		FROM categories
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	var category Category
 	err := c.db.QueryRowContext(ctx, query, id).Scan(
 		&category.ID,
 		&category.Name,
+		&category.Slug,
 		&category.Description,
+		&category.ParentID,
 		&category.CreatedAt,
 		&category.Version,
+		&category.AttributeSchema,
 	)
 
 	// Handle any errors. If there was no record found, Scan()
@@ -73,79 +149,484 @@ func (c *CategoryModel) GetByID(ctx context.Context, id int64) (*Category, error
 	return &category, nil
 }
 
+// LookupSlug returns the id and the current slug of the category that has the slug, or
+// had it before it was renamed. It returns ErrRecordNotFound if there is no such
+// category or it has been deleted.
+func (c *CategoryModel) LookupSlug(ctx context.Context, slug string) (int64, string, error) {
+	return categorySlugs.lookupSlug(ctx, c.db, slug)
+}
+
+// Update saves the category. A category can not be moved under itself or under one
+// of its own subcategories; such a move returns ErrCategoryCycle. Moving it under a
+// deleted category returns ErrInvalidParentId. An empty category.Slug derives the slug
+// from the name again; when the slug changes, the old one keeps leading to the category.
 func (c *CategoryModel) Update(ctx context.Context, category *Category) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	if category.ParentID != nil {
+		if err = c.checkParent(ctx, tx, category.ID, *category.ParentID); err != nil {
+			return err
+		}
+	}
+
+	category.Slug, err = categorySlugs.setSlug(
+		ctx,
+		tx,
+		category.ID,
+		category.Name,
+		category.Slug,
+	)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE categories 
-		SET name = $1, description = $2, version = version + 1
-		WHERE id = $3 AND version = $4
+		SET name = $1, description = $2, parent_id = $3, attribute_schema = $4, slug = $7,
+			version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version
 	`
 
 	// Version in the where clause is used for optimistic concurrency. if there is an
 	// edit conflict, it will result in sql.ErrNoRows
-	args := []any{category.Name, category.Description, category.ID, category.Version}
-	err := c.db.QueryRowContext(ctx, query, args...).Scan(&category.Version)
+	args := []any{
+		category.Name,
+		category.Description,
+		category.ParentID,
+		category.AttributeSchema,
+		category.ID,
+		category.Version,
+		category.Slug,
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&category.Version)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return parentWriteError(err, category)
 		}
 	}
 
+	return tx.Commit()
+}
+
+// lockCategoryParents takes the transaction level advisory lock that serializes the
+// writes that check the parent of a category or its subcategories: moves, inserts and
+// restores under a parent, and deletes. Otherwise two concurrent moves could each pass
+// the check and together create a cycle, and a category could be added under a parent
+// that is being deleted.
+func lockCategoryParents(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('categories.parent_id'))`)
+	return err
+}
+
+// checkParent returns ErrCategoryCycle if parentID is the category itself or one of its
+// subcategories, i.e. if the category is among the ancestors of parentID, and
+// ErrInvalidParentId if parentID has been deleted.
+func (c *CategoryModel) checkParent(ctx context.Context, tx *sql.Tx, id, parentID int64) error {
+	if err := lockCategoryParents(ctx, tx); err != nil {
+		return err
+	}
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT
+			EXISTS(SELECT 1 FROM ancestors WHERE id = $2),
+			EXISTS(SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)
+	`
+	var cycle, exists bool
+	if err := tx.QueryRowContext(ctx, query, parentID, id).Scan(&cycle, &exists); err != nil {
+		return err
+	}
+
+	switch {
+	case cycle:
+		return ErrCategoryCycle
+	case !exists:
+		return fmt.Errorf("parent_id %d does not exist: %w", parentID, ErrInvalidParentId)
+	}
 	return nil
 }
 
-// Method for deleting a specific category record.
+// Delete marks the category as deleted. The row is kept, so that it can be restored,
+// until it is purged. A category that still has products or subcategories that have
+// not been deleted can not be deleted and returns ErrCategoryHasProducts or
+// ErrCategoryHasChildren.
 func (c *CategoryModel) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM categories WHERE id = $1`
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = deleteCategory(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AttributeSchemaError is returned by DeleteAndReassign when products of the category
+// do not match the attribute schema of the category they would be moved to.
+type AttributeSchemaError struct {
+	CategoryID int64
+	ProductIDs []int64
+}
+
+func (e *AttributeSchemaError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return fmt.Sprintf(
+		"products %s do not match the attribute schema of category_id %d",
+		strings.Join(ids, ", "),
+		e.CategoryID,
+	)
+}
+
+// DeleteAndReassign moves every product in the category with the given id to the
+// category toID and then deletes the now empty category. Both steps run in a single
+// transaction so a failure leaves the products and the category untouched. Deleted
+// products are moved as well, so that they are restored into a category that exists.
+// It returns an *AttributeSchemaError if any of the products does not match the
+// attribute schema of the category toID.
+func (c *CategoryModel) DeleteAndReassign(ctx context.Context, id int64, toID int64) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	// Lock the target category so it cannot be deleted while products are being moved
+	// into it.
+	var schema AttributeSchema
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT attribute_schema FROM categories WHERE id = $1 AND deleted_at IS NULL FOR SHARE`,
+		toID,
+	).Scan(&schema)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("category_id %d does not exist: %w", toID, ErrInvalidCategoryId)
+		}
+		return err
+	}
+
+	if len(schema) > 0 {
+		if err = checkReassignedProducts(ctx, tx, id, toID, schema); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE products SET category_id = $1, version = version + 1 WHERE category_id = $2`,
+		toID,
+		id,
+	)
+	if err != nil {
+		return err
+	}
 
-	// Execute SQL query using the Exec() method, passing in the id variable as
-	// the value for the placeholder parameter. The Exec() method returns a sql.Result
-	// value
-	result, err := c.db.ExecContext(ctx, query, id)
+	if err = deleteCategory(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkReassignedProducts validates the products of the category with the given id
+// against the schema of the category toID they are about to be moved to. The category
+// and its products are locked until the transaction ends, so that no product is added
+// to it or changed before the move.
+func checkReassignedProducts(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	toID int64,
+	schema AttributeSchema,
+) error {
+	_, err := tx.ExecContext(ctx, `SELECT id FROM categories WHERE id = $1 FOR UPDATE`, id)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, attributes FROM products WHERE category_id = $1 ORDER BY id FOR UPDATE`,
+		id,
+	)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
+	var invalid []int64
+	for rows.Next() {
+		var productID int64
+		var attributes Attributes
+		if err = rows.Scan(&productID, &attributes); err != nil {
+			return err
+		}
+		if len(schema.Validate(attributes)) > 0 {
+			invalid = append(invalid, productID)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(invalid) > 0 {
+		return &AttributeSchemaError{CategoryID: toID, ProductIDs: invalid}
+	}
+	return nil
+}
+
+// deleteCategory marks the category as deleted unless it still has products or
+// subcategories that have not been deleted. The checks and the update are a single
+// statement, which reports why the category was left alone. It holds the lock of
+// lockCategoryParents, so that no subcategory is added in the meantime.
+func deleteCategory(ctx context.Context, tx *sql.Tx, id int64) error {
+	if err := lockCategoryParents(ctx, tx); err != nil {
+		return err
+	}
+
+	query := `
+		WITH target AS (
+			SELECT
+				id,
+				EXISTS(
+					SELECT 1 FROM products WHERE category_id = $1 AND deleted_at IS NULL
+				) AS has_products,
+				EXISTS(
+					SELECT 1 FROM categories WHERE parent_id = $1 AND deleted_at IS NULL
+				) AS has_children
+			FROM categories
+			WHERE id = $1 AND deleted_at IS NULL
+		), deleted AS (
+			UPDATE categories
+			SET deleted_at = NOW(), version = version + 1
+			WHERE id IN (SELECT id FROM target WHERE NOT has_products AND NOT has_children)
+		)
+		SELECT has_products, has_children FROM target
+	`
+
+	var hasProducts, hasChildren bool
+	err := tx.QueryRowContext(ctx, query, id).Scan(&hasProducts, &hasChildren)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
 		return ErrRecordNotFound
+	case err != nil:
+		return err
+	case hasProducts:
+		return ErrCategoryHasProducts
+	case hasChildren:
+		return ErrCategoryHasChildren
 	}
 
 	return nil
 }
 
+// Restore undeletes the category and returns it. It returns ErrRecordNotFound if there
+// is no deleted category with the id, and ErrInvalidParentId if its parent is still
+// deleted, so that the parent has to be restored first.
+func (c *CategoryModel) Restore(ctx context.Context, id int64) (*Category, error) {
+	query := `
+		UPDATE categories
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND (
+			parent_id IS NULL
+			OR EXISTS(
+				SELECT 1 FROM categories p
+				WHERE p.id = categories.parent_id AND p.deleted_at IS NULL
+			)
+		)
+		RETURNING id, name, slug, description, parent_id, created_at, version,
+			attribute_schema
+	`
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err = lockCategoryParents(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	var category Category
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.ParentID,
+		&category.CreatedAt,
+		&category.Version,
+		&category.AttributeSchema,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, c.restoreError(ctx, tx, id)
+		}
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// restoreError tells apart the two reasons a restore can fail: there is no deleted
+// category with the id or its parent is deleted too.
+func (c *CategoryModel) restoreError(ctx context.Context, tx *sql.Tx, id int64) error {
+	var parentID int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT parent_id FROM categories WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	).Scan(&parentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return fmt.Errorf("parent_id %d is deleted: %w", parentID, ErrInvalidParentId)
+}
+
+// Purge permanently removes up to limit categories deleted before the given time and
+// returns how many were removed. Categories still referenced by a product, deleted or
+// not, or by a subcategory are kept; a parent whose subcategories are purged is
+// removed by a later call. The revisions of the categories are kept.
+func (c *CategoryModel) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM categories
+		WHERE id IN (
+			SELECT c.id FROM categories c
+			WHERE c.deleted_at < $1
+				AND NOT EXISTS(SELECT 1 FROM products p WHERE p.category_id = c.id)
+				AND NOT EXISTS(SELECT 1 FROM categories s WHERE s.parent_id = c.id)
+			ORDER BY c.deleted_at
+			LIMIT $2
+		)
+	`
+
+	result, err := c.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 func (c *CategoryModel) GetAll(
 	ctx context.Context,
 	filters Filters,
 ) ([]*Category, Metadata, error) {
+	keys := CategoryFilterSpec.sortKeys(filters.Sorts)
+	cursor := filters.Cursor
+
+	total, limit, offset := filters.totalColumn(), filters.PageSize, filters.offset()
+	if cursor != nil {
+		// Keyset pages start at the cursor rather than an offset and read one extra row
+		// to learn whether there is a page beyond this one.
+		limit, offset = filters.PageSize+1, 0
+	}
+
+	args := []any{
+		pq.Array(filters.IDs),
+		filters.Name,
+		filters.DateFrom,
+		filters.DateTo,
+		limit,
+		offset,
+	}
+
+	// Any resource specific conditions are appended after the fixed placeholders.
+	conditions, conditionArgs := CategoryFilterSpec.whereSQL(filters.Conditions, len(args))
+	args = append(args, conditionArgs...)
+
+	extraColumns, keyset := "", ""
+	if cursor != nil {
+		extraColumns = ", " + strings.Join(cursorColumns(keys), ", ")
+
+		predicate, err := keysetPredicate(keys, cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		if predicate != nil {
+			predicateSQL, predicateArgs, _ := predicate.ToSql()
+			keyset = "AND (" + numberPlaceholders(predicateSQL, len(args)) + ")"
+			args = append(args, predicateArgs...)
+		}
+	}
+
+	// The name is matched in the language of the filters.
+	nameMatch := fmt.Sprintf(
+		"to_tsvector('%[1]s', name) @@ search_tsquery('%[1]s', $2)",
+		SearchConfig(filters.Language),
+	)
+
+	// Deleted categories are left out unless they are asked for.
+	notDeleted := "AND deleted_at IS NULL"
+	if filters.IncludeDeleted {
+		notDeleted = ""
+	}
+
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, name, description, created_at, version
+		SELECT %s, id, name, slug, description, parent_id, created_at, version,
+			attribute_schema, deleted_at%s
 		FROM categories
 		WHERE
 			(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-			AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+			AND ($2 = '' OR %s)
 			AND ($3::timestamp IS NULL OR created_at >= $3)
 			AND ($4::timestamp IS NULL OR created_at <= $4)
+			%s
+			%s
+			%s
 		ORDER BY %s
 		Limit $5 OFFSET $6`,
-		filters.sortColumns())
-
-	args := []any{
-		pq.Array(filters.IDs),
-		filters.Name,
-		filters.DateFrom,
-		filters.DateTo,
-		filters.PageSize,
-		filters.offset(),
-	}
+		total,
+		extraColumns,
+		nameMatch,
+		notDeleted,
+		conditions,
+		keyset,
+		orderBy(keys, cursor != nil && cursor.Backward))
 
 	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -153,38 +634,134 @@ func (c *CategoryModel) GetAll(
 	}
 	defer rows.Close()
 
-	categories := []*Category{}
-	totalRecords := 0
+	return collectPage(rows, CategoryFilterSpec, filters, func() (*Category, []any) {
+		var category Category
+		return &category, []any{
+			&category.ID,
+			&category.Name,
+			&category.Slug,
+			&category.Description,
+			&category.ParentID,
+			&category.CreatedAt,
+			&category.Version,
+			&category.AttributeSchema,
+			&category.DeletedAt,
+		}
+	})
+}
 
+// GetTree returns the whole category hierarchy as a list of top level categories with
+// their subcategories nested under Children. Siblings are ordered by name. Deleted
+// categories are left out; they never have subcategories that are not deleted.
+func (c *CategoryModel) GetTree(ctx context.Context) ([]*Category, error) {
+	query := `
+		SELECT id, name, slug, description, parent_id, created_at, version
+		FROM categories
+		WHERE deleted_at IS NULL
+		ORDER BY name, id
+	`
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*Category{}
 	for rows.Next() {
-		// Initialize an empty category struct to hold the data for an individual category.
 		var category Category
-
-		// Scan the values from the row into the categories struct.
 		err := rows.Scan(
-			&totalRecords,
 			&category.ID,
 			&category.Name,
+			&category.Slug,
 			&category.Description,
+			&category.ParentID,
 			&category.CreatedAt,
 			&category.Version,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return nil, err
 		}
-
-		// Add the category struct to the slice.
 		categories = append(categories, &category)
 	}
 
-	// After the rows.Next() loop has finished, call rows.Err() to retrieve any error
-	// that was encountered during the iteration.
 	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return nil, err
+	}
+
+	// Link every category to its parent. The rows are in name order, so the children of
+	// each category end up in name order as well.
+	byID := make(map[int64]*Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
 	}
 
-	// If everything went OK, then return the slice of categories.
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	// A category whose parent is missing is listed at the top level rather than left
+	// out.
+	roots := []*Category{}
+	for _, category := range categories {
+		var parent *Category
+		if category.ParentID != nil {
+			parent = byID[*category.ParentID]
+		}
+		if parent == nil {
+			roots = append(roots, category)
+			continue
+		}
+		parent.Children = append(parent.Children, category)
+	}
+
+	return roots, nil
+}
+
+// GetAncestors returns the breadcrumbs of a category: the path from its top level
+// category down to and including the category itself.
+func (c *CategoryModel) GetAncestors(ctx context.Context, id int64) ([]*Category, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, name, slug, description, parent_id, created_at, version, 0 AS depth
+			FROM categories
+			WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.name, c.slug, c.description, c.parent_id, c.created_at, c.version,
+				a.depth + 1
+			FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id, name, slug, description, parent_id, created_at, version
+		FROM ancestors
+		ORDER BY depth DESC
+	`
+	rows, err := c.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ancestors := []*Category{}
+	for rows.Next() {
+		var category Category
+		err := rows.Scan(
+			&category.ID,
+			&category.Name,
+			&category.Slug,
+			&category.Description,
+			&category.ParentID,
+			&category.CreatedAt,
+			&category.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, &category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ancestors) == 0 {
+		return nil, ErrRecordNotFound
+	}
 
-	return categories, metadata, nil
+	return ancestors, nil
 }
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryModel_Integration_Delete(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	categoryModel := NewCategoryModel(db)
+
+	categoryID, ids := seedProducts(t, db, []*Product{{Name: "Test Product"}})
+
+	target := Category{Name: "Target Category"}
+	assert.NoError(t, categoryModel.Insert(ctx, &target))
+
+	assert.Equal(t, ErrCategoryHasProducts, categoryModel.Delete(ctx, categoryID))
+
+	err := categoryModel.DeleteAndReassign(ctx, categoryID, 999_999)
+	assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+
+	assert.NoError(t, categoryModel.DeleteAndReassign(ctx, categoryID, target.ID))
+
+	product, err := NewProductModel(db).GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int(target.ID), product.CategoryID)
+	assert.Equal(t, 2, product.Version)
+
+	_, err = categoryModel.GetByID(ctx, categoryID)
+	assert.Equal(t, ErrRecordNotFound, err)
+}
+
+func TestCategoryModel_Integration_Hierarchy(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	categoryModel := NewCategoryModel(db)
+
+	root := Category{Name: "Electronics"}
+	assert.NoError(t, categoryModel.Insert(ctx, &root))
+	phones := Category{Name: "Phones", ParentID: &root.ID}
+	assert.NoError(t, categoryModel.Insert(ctx, &phones))
+	smartphones := Category{Name: "Smartphones", ParentID: &phones.ID}
+	assert.NoError(t, categoryModel.Insert(ctx, &smartphones))
+
+	missing := int64(999_999)
+	err := categoryModel.Insert(ctx, &Category{Name: "Orphan", ParentID: &missing})
+	assert.True(t, errors.Is(err, ErrInvalidParentId))
+
+	// Moving a category under its own subcategory would create a cycle.
+	root.ParentID = &smartphones.ID
+	assert.Equal(t, ErrCategoryCycle, categoryModel.Update(ctx, &root))
+	root.ParentID = nil
+
+	ancestors, err := categoryModel.GetAncestors(ctx, smartphones.ID)
+	assert.NoError(t, err)
+	assert.Len(t, ancestors, 3)
+	assert.Equal(t, root.ID, ancestors[0].ID)
+	assert.Equal(t, smartphones.ID, ancestors[2].ID)
+
+	assert.Equal(t, ErrCategoryHasChildren, categoryModel.Delete(ctx, phones.ID))
+
+	// Products of a subcategory are listed under the parent category.
+	_, ids := seedProducts(t, db, []*Product{
+		{Name: "Test Phone", CategoryID: int(smartphones.ID)},
+	})
+
+	products, _, err := NewProductModel(db).GetAll(ctx, Filters{
+		Conditions:           []Condition{{Field: "category_id", Op: OpEq, Value: []int64{root.ID}}},
+		IncludeSubcategories: true,
+		Page:                 1,
+		PageSize:             20,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, int(ids[0]), products[0].ID)
+}
+
+func TestCategoryModel_Integration_AttributeSchema(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	categoryModel := NewCategoryModel(db)
+
+	category := Category{
+		Name: "Memory",
+		AttributeSchema: AttributeSchema{
+			"capacity_gb": {Type: AttributeInt, Required: true, Values: []string{"8", "16"}, Unit: "GB"},
+		},
+	}
+	assert.NoError(t, categoryModel.Insert(ctx, &category))
+
+	stored, err := categoryModel.GetByID(ctx, category.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, category.AttributeSchema, stored.AttributeSchema)
+
+	stored.AttributeSchema = nil
+	assert.NoError(t, categoryModel.Update(ctx, stored))
+
+	stored, err = categoryModel.GetByID(ctx, category.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.AttributeSchema)
+}
@@ -25,8 +25,10 @@ func TestCategoryModel_Insert(t *testing.T) {
 	ctx := context.Background()
 
 	mockQuery := regexp.QuoteMeta(`
-		INSERT INTO categories(name, description)
-		VALUES($1, $2)
+		INSERT INTO categories(name, description, parent_id, attribute_schema, slug)
+		SELECT $1, $2, $3, $4, $5
+		WHERE $3::bigint IS NULL
+			OR EXISTS(SELECT 1 FROM categories WHERE id = $3 AND deleted_at IS NULL)
 		RETURNING id, created_at, version
 	`)
 
@@ -37,14 +39,20 @@ func TestCategoryModel_Insert(t *testing.T) {
 		}
 
 		createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
-		args := []driver.Value{category.Name, category.Description}
+		args := []driver.Value{
+			category.Name, category.Description, nil, []byte("{}"), "test-category",
+		}
 		mockCol := []string{"id", "created_at", "version"}
 		mockRow := sqlmock.NewRows(mockCol).AddRow(1, createdAt, 1)
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, categorySlugs, 0, "test-category")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnRows(mockRow)
+		sqlMock.ExpectCommit()
 
 		expectedCategory := Category{
 			ID:          1,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   createdAt,
@@ -64,8 +72,13 @@ func TestCategoryModel_Insert(t *testing.T) {
 		}
 
 		dbErr := errors.New("unexpected DB error")
-		args := []driver.Value{category.Name, category.Description}
+		args := []driver.Value{
+			category.Name, category.Description, nil, []byte("{}"), "test-category",
+		}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, categorySlugs, 0, "test-category")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(dbErr)
+		sqlMock.ExpectRollback()
 
 		err := categoryModel.Insert(ctx, &category)
 		assert.Equal(t, dbErr, err)
@@ -83,12 +96,14 @@ func TestCategoryModel_GetByID(t *testing.T) {
 	ctx := context.Background()
 
 	var mockQuery = regexp.QuoteMeta(`
-		SELECT id, name, description, created_at, version
+		SELECT id, name, slug, description, parent_id, created_at, version, attribute_schema
 		FROM categories
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`)
 
-	mockCol := []string{"id", "name", "description", "created_at", "version"}
+	mockCol := []string{
+		"id", "name", "slug", "description", "parent_id", "created_at", "version", "attribute_schema",
+	}
 
 	t.Run("returns category with the given id", func(t *testing.T) {
 		var id int64 = 23
@@ -96,12 +111,15 @@ func TestCategoryModel_GetByID(t *testing.T) {
 		expected := Category{
 			ID:          id,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   createdAt,
 		}
 
-		rowValues := []driver.Value{id, "Test Category", "A test category", createdAt, 1}
+		rowValues := []driver.Value{
+			id, "Test Category", "test-category", "A test category", nil, createdAt, 1, nil,
+		}
 		mockRow := sqlmock.NewRows(mockCol).AddRow(rowValues...)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(id).WillReturnRows(mockRow)
 
@@ -139,8 +157,9 @@ func TestUpdateCategoryModel_Update(t *testing.T) {
 
 	mockQuery := regexp.QuoteMeta(`
 		UPDATE categories 
-		SET name = $1, description = $2, version = version + 1
-		WHERE id = $3 AND version = $4
+		SET name = $1, description = $2, parent_id = $3, attribute_schema = $4, slug = $7,
+			version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version
 	`)
 
@@ -161,15 +180,27 @@ func TestUpdateCategoryModel_Update(t *testing.T) {
 			CreatedAt:   createdAt,
 		}
 
-		args := []driver.Value{category.Name, category.Description, category.ID, category.Version}
+		args := []driver.Value{
+			category.Name,
+			category.Description,
+			nil,
+			[]byte("{}"),
+			category.ID,
+			category.Version,
+			"test-category",
+		}
 		mockRow := sqlmock.NewRows([]string{"version"}).AddRow(2)
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, categorySlugs, 1, "test-category")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnRows(mockRow)
+		sqlMock.ExpectCommit()
 
 		err := categoryModel.Update(ctx, &category)
 
 		expectedCategory := Category{
 			ID:          1,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     2,
 			CreatedAt:   createdAt,
@@ -187,8 +218,19 @@ func TestUpdateCategoryModel_Update(t *testing.T) {
 			CreatedAt:   createdAt,
 		}
 
-		args := []driver.Value{category.Name, category.Description, category.ID, category.Version}
+		args := []driver.Value{
+			category.Name,
+			category.Description,
+			nil,
+			[]byte("{}"),
+			category.ID,
+			category.Version,
+			"test-category",
+		}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, categorySlugs, 1, "test-category")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
 
 		err := categoryModel.Update(ctx, &category)
 
@@ -206,9 +248,20 @@ func TestUpdateCategoryModel_Update(t *testing.T) {
 			CreatedAt:   createdAt,
 		}
 
-		args := []driver.Value{category.Name, category.Description, category.ID, category.Version}
+		args := []driver.Value{
+			category.Name,
+			category.Description,
+			nil,
+			[]byte("{}"),
+			category.ID,
+			category.Version,
+			"test-category",
+		}
 		mockError := errors.New("db update error")
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, categorySlugs, 1, "test-category")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
 
 		err := categoryModel.Update(ctx, &category)
 
@@ -218,6 +271,34 @@ func TestUpdateCategoryModel_Update(t *testing.T) {
 	})
 }
 
+// categoryDeleteQuery is the statement that marks a category as deleted unless it still
+// has products or subcategories.
+var categoryDeleteQuery = regexp.QuoteMeta(`
+	WITH target AS (
+		SELECT
+			id,
+			EXISTS(
+				SELECT 1 FROM products WHERE category_id = $1 AND deleted_at IS NULL
+			) AS has_products,
+			EXISTS(
+				SELECT 1 FROM categories WHERE parent_id = $1 AND deleted_at IS NULL
+			) AS has_children
+		FROM categories
+		WHERE id = $1 AND deleted_at IS NULL
+	), deleted AS (
+		UPDATE categories
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id IN (SELECT id FROM target WHERE NOT has_products AND NOT has_children)
+	)
+	SELECT has_products, has_children FROM target
+`)
+
+// expectParentLock expects the advisory lock of lockCategoryParents to be taken.
+func expectParentLock(sqlMock sqlmock.Sqlmock) {
+	lockQuery := regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext('categories.parent_id'))`)
+	sqlMock.ExpectExec(lockQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
 func TestCategoryModel_Delete(t *testing.T) {
 	t.Parallel()
 
@@ -228,40 +309,225 @@ func TestCategoryModel_Delete(t *testing.T) {
 
 	categoryModel := NewCategoryModel(db)
 	ctx := context.Background()
-
-	mockQuery := regexp.QuoteMeta(`DELETE FROM categories WHERE id = $1`)
+	mockCols := []string{"has_products", "has_children"}
 
 	t.Run("delete success", func(t *testing.T) {
-		sqlMock.ExpectExec(mockQuery).WithArgs(id).WillReturnResult(
-			sqlmock.NewResult(1, 1),
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(false, false),
 		)
+		sqlMock.ExpectCommit()
 		err := categoryModel.Delete(ctx, id)
 		assert.NoError(t, err)
 	})
 
 	t.Run("delete error", func(t *testing.T) {
-		sqlMock.ExpectExec(mockQuery).WithArgs(id).WillReturnError(
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnError(
 			errors.New("delete error"),
 		)
+		sqlMock.ExpectRollback()
 		err := categoryModel.Delete(ctx, id)
 		assert.Error(t, err)
 		assert.Equal(t, err.Error(), "delete error")
 	})
 
-	t.Run("zero rows affected", func(t *testing.T) {
-		mockResult := sqlmock.NewResult(1, 0)
-		sqlMock.ExpectExec(mockQuery).WithArgs(id).WillReturnResult(mockResult)
+	t.Run("category does not exist", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
 		err := categoryModel.Delete(ctx, id)
 		assert.Error(t, err)
 		assert.Equal(t, err.Error(), "record not found")
 	})
 
-	t.Run("rows affected error", func(t *testing.T) {
-		mockResult := sqlmock.NewErrorResult(errors.New("rows affected error"))
-		sqlMock.ExpectExec(mockQuery).WithArgs(id).WillReturnResult(mockResult)
+	t.Run("category still has products", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(true, true),
+		)
+		sqlMock.ExpectRollback()
 		err := categoryModel.Delete(ctx, id)
 		assert.Error(t, err)
-		assert.Equal(t, err.Error(), "rows affected error")
+		assert.Equal(t, ErrCategoryHasProducts, err)
+	})
+
+	t.Run("category still has subcategories", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(false, true),
+		)
+		sqlMock.ExpectRollback()
+		err := categoryModel.Delete(ctx, id)
+		assert.Equal(t, ErrCategoryHasChildren, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestCategoryModel_DeleteAndReassign(t *testing.T) {
+	t.Parallel()
+
+	var id int64 = 1
+	var toID int64 = 2
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	categoryModel := NewCategoryModel(db)
+	ctx := context.Background()
+
+	lockQuery := regexp.QuoteMeta(
+		`SELECT attribute_schema FROM categories WHERE id = $1 AND deleted_at IS NULL FOR SHARE`,
+	)
+	reassignQuery := regexp.QuoteMeta(
+		`UPDATE products SET category_id = $1, version = version + 1 WHERE category_id = $2`,
+	)
+	sourceLockQuery := regexp.QuoteMeta(`SELECT id FROM categories WHERE id = $1 FOR UPDATE`)
+	productsQuery := regexp.QuoteMeta(
+		`SELECT id, attributes FROM products WHERE category_id = $1 ORDER BY id FOR UPDATE`,
+	)
+	mockCols := []string{"has_products", "has_children"}
+	schema := []byte(`{"ram": {"type": "int", "required": true}}`)
+
+	t.Run("reassign and delete successfully", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(nil),
+		)
+		sqlMock.ExpectExec(reassignQuery).WithArgs(toID, id).WillReturnResult(
+			sqlmock.NewResult(0, 3),
+		)
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(false, false),
+		)
+		sqlMock.ExpectCommit()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.NoError(t, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("target category does not exist", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+		assert.Equal(t, "category_id 2 does not exist: invalid category_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("category does not exist", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(nil),
+		)
+		sqlMock.ExpectExec(reassignQuery).WithArgs(toID, id).WillReturnResult(
+			sqlmock.NewResult(0, 0),
+		)
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("category still has subcategories", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(nil),
+		)
+		sqlMock.ExpectExec(reassignQuery).WithArgs(toID, id).WillReturnResult(
+			sqlmock.NewResult(0, 3),
+		)
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(false, true),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.Equal(t, ErrCategoryHasChildren, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reassign error", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(nil),
+		)
+		sqlMock.ExpectExec(reassignQuery).WithArgs(toID, id).WillReturnError(
+			errors.New("update error"),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.Equal(t, "update error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("products match the schema of the target", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(schema),
+		)
+		sqlMock.ExpectExec(sourceLockQuery).WithArgs(id).WillReturnResult(
+			sqlmock.NewResult(0, 1),
+		)
+		sqlMock.ExpectQuery(productsQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "attributes"}).
+				AddRow(3, []byte(`{"ram": 8}`)).
+				AddRow(4, []byte(`{"ram": 16, "color": "black"}`)),
+		)
+		sqlMock.ExpectExec(reassignQuery).WithArgs(toID, id).WillReturnResult(
+			sqlmock.NewResult(0, 2),
+		)
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(false, false),
+		)
+		sqlMock.ExpectCommit()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		assert.NoError(t, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("products do not match the schema of the target", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(lockQuery).WithArgs(toID).WillReturnRows(
+			sqlmock.NewRows([]string{"attribute_schema"}).AddRow(schema),
+		)
+		sqlMock.ExpectExec(sourceLockQuery).WithArgs(id).WillReturnResult(
+			sqlmock.NewResult(0, 1),
+		)
+		sqlMock.ExpectQuery(productsQuery).WithArgs(id).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "attributes"}).
+				AddRow(3, nil).
+				AddRow(4, []byte(`{"ram": 16}`)).
+				AddRow(5, []byte(`{"ram": "8GB"}`)),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.DeleteAndReassign(ctx, id, toID)
+		var schemaErr *AttributeSchemaError
+		assert.True(t, errors.As(err, &schemaErr))
+		assert.Equal(t, []int64{3, 5}, schemaErr.ProductIDs)
+		assert.Equal(
+			t,
+			"products 3, 5 do not match the attribute schema of category_id 2",
+			err.Error(),
+		)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
 	})
 }
 
@@ -283,19 +549,26 @@ func TestCategoryModel_List(t *testing.T) {
 	t.Run("fetch all categories successfully", func(t *testing.T) {
 		createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY id ASC
 			Limit $5 OFFSET $6`,
 		)
 
-		rowVals := []driver.Value{10, 121, "Test Category", "A test category", createdAt, 1}
-		mockCols := []string{"total_pages", "id", "name", "description", "created_at", "version"}
+		rowVals := []driver.Value{
+			10, 121, "Test Category", "test-category", "A test category", nil, createdAt, 1, nil, nil,
+		}
+		mockCols := []string{
+			"total_pages", "id", "name", "slug", "description", "parent_id", "created_at", "version",
+			"attribute_schema", "deleted_at",
+		}
 		mockRow := sqlmock.NewRows(mockCols).AddRow(rowVals...)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(nil, "", nil, nil, 20, 0).WillReturnRows(mockRow)
 
@@ -304,6 +577,7 @@ func TestCategoryModel_List(t *testing.T) {
 		testCategory := Category{
 			ID:          121,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			CreatedAt:   createdAt,
 			Version:     1,
@@ -332,26 +606,32 @@ func TestCategoryModel_List(t *testing.T) {
 			Sorts:    []string{"created_at", "name", "-id"},
 			Page:     3,
 			PageSize: 100,
+			Language: "es",
 		}
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('spanish', name) @@ search_tsquery('spanish', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY created_at ASC, name ASC, id DESC
 			Limit $5 OFFSET $6`,
 		)
 
 		rowValues := []driver.Value{
-			68_028_108, 121, "Test Category", "A test category", createdAt1, 1,
+			68_028_108, 121, "Test Category", "test-category", "A test category", nil, createdAt1, 1, nil, nil,
 		}
 		args := []driver.Value{
 			pq.Array([]int64{121, 125, 126}), "test", createdAt1, createdAt2, 100, 200,
 		}
-		mockCols := []string{"total_pages", "id", "name", "description", "created_at", "version"}
+		mockCols := []string{
+			"total_pages", "id", "name", "slug", "description", "parent_id", "created_at", "version",
+			"attribute_schema", "deleted_at",
+		}
 		mockRow := sqlmock.NewRows(mockCols).AddRow(rowValues...)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnRows(mockRow)
 
@@ -360,6 +640,7 @@ func TestCategoryModel_List(t *testing.T) {
 		testCategory := Category{
 			ID:          121,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			CreatedAt:   createdAt1,
 			Version:     1,
@@ -379,18 +660,23 @@ func TestCategoryModel_List(t *testing.T) {
 
 	t.Run("no records", func(t *testing.T) {
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY id ASC
 			Limit $5 OFFSET $6`,
 		)
 
-		mockCols := []string{"total_pages", "id", "name", "description", "created_at", "version"}
+		mockCols := []string{
+			"total_pages", "id", "name", "slug", "description", "parent_id", "created_at", "version",
+			"attribute_schema", "deleted_at",
+		}
 		mockRow := sqlmock.NewRows(mockCols)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(nil, "", nil, nil, 20, 0).WillReturnRows(mockRow)
 
@@ -403,13 +689,15 @@ func TestCategoryModel_List(t *testing.T) {
 
 	t.Run("execute query error", func(t *testing.T) {
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY id ASC
 			Limit $5 OFFSET $6`,
 		)
@@ -427,13 +715,15 @@ func TestCategoryModel_List(t *testing.T) {
 
 	t.Run("scan error", func(t *testing.T) {
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY id ASC
 			Limit $5 OFFSET $6`,
 		)
@@ -446,26 +736,33 @@ func TestCategoryModel_List(t *testing.T) {
 
 		categories, metadata, err := categoryModel.GetAll(ctx, filters)
 		assert.Error(t, err)
-		assert.Equal(t, "sql: expected 5 destination arguments in Scan, not 6", err.Error())
+		assert.Equal(t, "sql: expected 5 destination arguments in Scan, not 10", err.Error())
 		assert.Nil(t, categories)
 		assert.Equal(t, Metadata{}, metadata)
 	})
 
 	t.Run("row error", func(t *testing.T) {
 		mockQuery := regexp.QuoteMeta(`
-			SELECT count(*) OVER(), id, name, description, created_at, version
+			SELECT count(*) OVER(), id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at
 			FROM categories
 			WHERE
 				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
-				AND ($2 = '' OR to_tsvector('simple', name) @@ plainto_tsquery('simple', $2))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
 				AND ($3::timestamp IS NULL OR created_at >= $3)
 				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
 			ORDER BY id ASC
 			Limit $5 OFFSET $6`,
 		)
 
-		rowValues := []driver.Value{1, 1, "Test", "Description", "2025-01-01", 1}
-		mockCols := []string{"total_pages", "id", "name", "description", "created_at", "version"}
+		rowValues := []driver.Value{
+			1, 1, "Test", "test", "Description", nil, "2025-01-01", 1, nil, nil,
+		}
+		mockCols := []string{
+			"total_pages", "id", "name", "slug", "description", "parent_id", "created_at", "version",
+			"attribute_schema", "deleted_at",
+		}
 		mockError := errors.New("rows iteration error")
 		mockRow := sqlmock.NewRows(mockCols).AddRow(rowValues...).RowError(0, mockError)
 		args := []driver.Value{nil, "", nil, nil, 20, 0}
@@ -478,3 +775,393 @@ func TestCategoryModel_List(t *testing.T) {
 		assert.Equal(t, Metadata{}, metadata)
 	})
 }
+
+func TestCategoryModel_List_Cursor(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	categoryModel := NewCategoryModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	mockCols := []string{
+		"count", "id", "name", "slug", "description", "parent_id", "created_at", "version",
+		"attribute_schema", "deleted_at", "name_text", "id_text",
+	}
+
+	t.Run("next page after the cursor", func(t *testing.T) {
+		cursor := Cursor{Sorts: []string{"-name"}, Values: []string{"Shoes", "12"}}
+		filters := Filters{
+			Sorts:    []string{"-name"},
+			Cursor:   &cursor,
+			Page:     1,
+			PageSize: 2,
+		}
+		mockQuery := regexp.QuoteMeta(`
+			SELECT 0, id, name, slug, description, parent_id, created_at, version, attribute_schema,
+				deleted_at, (name)::text, (id)::text
+			FROM categories
+			WHERE
+				(cardinality($1::bigint[]) = 0 OR id = ANY($1))
+				AND ($2 = '' OR to_tsvector('simple', name) @@ search_tsquery('simple', $2))
+				AND ($3::timestamp IS NULL OR created_at >= $3)
+				AND ($4::timestamp IS NULL OR created_at <= $4)
+				AND deleted_at IS NULL
+				AND (((name < $7) OR (name = $8 AND id > $9)))
+			ORDER BY name DESC, id ASC
+			Limit $5 OFFSET $6`,
+		)
+		mockRow := sqlmock.NewRows(mockCols).
+			AddRow(0, 3, "Hats", "hats", "Hats", nil, createdAt, 1, nil, nil, "Hats", "3").
+			AddRow(0, 9, "Bags", "bags", "Bags", nil, createdAt, 1, nil, nil, "Bags", "9").
+			AddRow(0, 4, "Belts", "belts", "Belts", nil, createdAt, 1, nil, nil, "Belts", "4")
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs(nil, "", nil, nil, 3, 0, "Shoes", "Shoes", "12").
+			WillReturnRows(mockRow)
+
+		categories, metadata, err := categoryModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+
+		next := Cursor{Sorts: []string{"-name"}, Values: []string{"Bags", "9"}}
+		prev := Cursor{Sorts: []string{"-name"}, Values: []string{"Hats", "3"}, Backward: true}
+		expectedMetadata := Metadata{
+			PageSize:   2,
+			NextCursor: next.Encode(),
+			PrevCursor: prev.Encode(),
+		}
+		assert.Equal(t, expectedMetadata, metadata)
+		assert.Len(t, categories, 2)
+		assert.Equal(t, int64(3), categories[0].ID)
+		assert.Equal(t, int64(9), categories[1].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("cursor values do not match the sort", func(t *testing.T) {
+		filters := Filters{
+			Cursor:   &Cursor{Values: []string{"12"}},
+			Sorts:    []string{"-name"},
+			Page:     1,
+			PageSize: 2,
+		}
+
+		categories, metadata, err := categoryModel.GetAll(ctx, filters)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, categories)
+		assert.Equal(t, Metadata{}, metadata)
+	})
+}
+
+func TestCategoryModel_Hierarchy(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	categoryModel := NewCategoryModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	parentID := int64(7)
+
+	cycleQuery := regexp.QuoteMeta(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT
+			EXISTS(SELECT 1 FROM ancestors WHERE id = $2),
+			EXISTS(SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)
+	`)
+	updateQuery := regexp.QuoteMeta(`
+		UPDATE categories 
+		SET name = $1, description = $2, parent_id = $3, attribute_schema = $4, slug = $7,
+			version = version + 1
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version
+	`)
+	mockCols := []string{"id", "name", "slug", "description", "parent_id", "created_at", "version"}
+
+	t.Run("inserts a subcategory of a missing parent", func(t *testing.T) {
+		category := Category{Name: "Phones", Description: "Phones", ParentID: &parentID}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		expectSlug(sqlMock, categorySlugs, 0, "phones")
+		sqlMock.ExpectQuery("INSERT INTO categories").
+			WithArgs("Phones", "Phones", parentID, []byte("{}"), "phones").
+			WillReturnError(&pq.Error{Code: ErrForeignKeyViolation})
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.Insert(ctx, &category)
+		assert.ErrorIs(t, err, ErrInvalidParentId)
+		assert.Equal(t, "parent_id 7 does not exist: invalid parent_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("inserts a subcategory of a deleted parent", func(t *testing.T) {
+		category := Category{Name: "Phones", Description: "Phones", ParentID: &parentID}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		expectSlug(sqlMock, categorySlugs, 0, "phones")
+		sqlMock.ExpectQuery("INSERT INTO categories").
+			WithArgs("Phones", "Phones", parentID, []byte("{}"), "phones").
+			WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.Insert(ctx, &category)
+		assert.ErrorIs(t, err, ErrInvalidParentId)
+		assert.Equal(t, "parent_id 7 does not exist: invalid parent_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("moves a category under another one", func(t *testing.T) {
+		category := Category{ID: 3, Name: "Phones", Description: "Phones", ParentID: &parentID, Version: 1}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(cycleQuery).WithArgs(parentID, 3).WillReturnRows(
+			sqlmock.NewRows([]string{"cycle", "exists"}).AddRow(false, true),
+		)
+		expectSlug(sqlMock, categorySlugs, 3, "phones")
+		sqlMock.ExpectQuery(updateQuery).
+			WithArgs("Phones", "Phones", parentID, []byte("{}"), 3, 1, "phones").
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		sqlMock.ExpectCommit()
+
+		err := categoryModel.Update(ctx, &category)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, category.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refuses to move a category under a deleted one", func(t *testing.T) {
+		category := Category{ID: 3, Name: "Phones", Description: "Phones", ParentID: &parentID, Version: 1}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(cycleQuery).WithArgs(parentID, 3).WillReturnRows(
+			sqlmock.NewRows([]string{"cycle", "exists"}).AddRow(false, false),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.Update(ctx, &category)
+		assert.ErrorIs(t, err, ErrInvalidParentId)
+		assert.Equal(t, "parent_id 7 does not exist: invalid parent_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refuses to move a category under its own subcategory", func(t *testing.T) {
+		category := Category{ID: 3, Name: "Phones", Description: "Phones", ParentID: &parentID, Version: 1}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(cycleQuery).WithArgs(parentID, 3).WillReturnRows(
+			sqlmock.NewRows([]string{"cycle", "exists"}).AddRow(true, true),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.Update(ctx, &category)
+		assert.Equal(t, ErrCategoryCycle, err)
+		assert.Equal(t, 1, category.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refuses to delete a category with subcategories", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(categoryDeleteQuery).WithArgs(3).WillReturnRows(
+			sqlmock.NewRows([]string{"has_products", "has_children"}).AddRow(false, true),
+		)
+		sqlMock.ExpectRollback()
+
+		err := categoryModel.Delete(ctx, 3)
+		assert.Equal(t, ErrCategoryHasChildren, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("builds the tree", func(t *testing.T) {
+		mockQuery := regexp.QuoteMeta(`
+			SELECT id, name, slug, description, parent_id, created_at, version
+			FROM categories
+			WHERE deleted_at IS NULL
+			ORDER BY name, id
+		`)
+		sqlMock.ExpectQuery(mockQuery).WillReturnRows(
+			sqlmock.NewRows(mockCols).
+				AddRow(8, "Clothing", "clothing", "Clothing", nil, createdAt, 1).
+				AddRow(7, "Electronics", "electronics", "Electronics", nil, createdAt, 1).
+				AddRow(4, "Laptops", "laptops", "Laptops", 7, createdAt, 1).
+				AddRow(3, "Phones", "phones", "Phones", 7, createdAt, 1).
+				AddRow(5, "Smartphones", "smartphones", "Smartphones", 3, createdAt, 1),
+		)
+
+		tree, err := categoryModel.GetTree(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, tree, 2)
+		assert.Equal(t, "Clothing", tree[0].Name)
+		assert.Empty(t, tree[0].Children)
+
+		electronics := tree[1]
+		assert.Len(t, electronics.Children, 2)
+		assert.Equal(t, "Laptops", electronics.Children[0].Name)
+		assert.Equal(t, "Phones", electronics.Children[1].Name)
+		assert.Equal(t, int64(5), electronics.Children[1].Children[0].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("lists a category whose parent is missing at the top level", func(t *testing.T) {
+		sqlMock.ExpectQuery("SELECT id, name, slug").WillReturnRows(
+			sqlmock.NewRows(mockCols).
+				AddRow(8, "Clothing", "clothing", "Clothing", nil, createdAt, 1).
+				AddRow(3, "Phones", "phones", "Phones", 7, createdAt, 1),
+		)
+
+		tree, err := categoryModel.GetTree(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, tree, 2)
+		assert.Equal(t, "Phones", tree[1].Name)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("returns the breadcrumbs", func(t *testing.T) {
+		sqlMock.ExpectQuery("WITH RECURSIVE ancestors AS").WithArgs(5).WillReturnRows(
+			sqlmock.NewRows(mockCols).
+				AddRow(7, "Electronics", "electronics", "Electronics", nil, createdAt, 1).
+				AddRow(3, "Phones", "phones", "Phones", 7, createdAt, 1).
+				AddRow(5, "Smartphones", "smartphones", "Smartphones", 3, createdAt, 1),
+		)
+
+		ancestors, err := categoryModel.GetAncestors(ctx, 5)
+		assert.NoError(t, err)
+		assert.Len(t, ancestors, 3)
+		assert.Equal(t, "Electronics", ancestors[0].Name)
+		assert.Equal(t, &parentID, ancestors[1].ParentID)
+		assert.Equal(t, int64(5), ancestors[2].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("breadcrumbs of a missing category", func(t *testing.T) {
+		sqlMock.ExpectQuery("WITH RECURSIVE ancestors AS").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(mockCols))
+
+		ancestors, err := categoryModel.GetAncestors(ctx, 5)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.Nil(t, ancestors)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestCategoryModel_Restore(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	categoryModel := NewCategoryModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	parentID := int64(7)
+
+	restoreQuery := regexp.QuoteMeta(`
+		UPDATE categories
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND (
+			parent_id IS NULL
+			OR EXISTS(
+				SELECT 1 FROM categories p
+				WHERE p.id = categories.parent_id AND p.deleted_at IS NULL
+			)
+		)
+		RETURNING id, name, slug, description, parent_id, created_at, version,
+			attribute_schema
+	`)
+	deletedQuery := regexp.QuoteMeta(
+		`SELECT parent_id FROM categories WHERE id = $1 AND deleted_at IS NOT NULL`,
+	)
+
+	t.Run("restores the category", func(t *testing.T) {
+		mockCols := []string{
+			"id", "name", "slug", "description", "parent_id", "created_at", "version",
+			"attribute_schema",
+		}
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(3).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(3, "Phones", "phones", "Phones", 7, createdAt, 3, nil),
+		)
+		sqlMock.ExpectCommit()
+
+		category, err := categoryModel.Restore(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &Category{
+			ID:          3,
+			Name:        "Phones",
+			Slug:        "phones",
+			Description: "Phones",
+			ParentID:    &parentID,
+			CreatedAt:   createdAt,
+			Version:     3,
+		}, category)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("category is not deleted", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(3).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(deletedQuery).WithArgs(3).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		category, err := categoryModel.Restore(ctx, 3)
+		assert.Nil(t, category)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("parent is deleted", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectParentLock(sqlMock)
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(3).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(deletedQuery).WithArgs(3).WillReturnRows(
+			sqlmock.NewRows([]string{"parent_id"}).AddRow(7),
+		)
+		sqlMock.ExpectRollback()
+
+		category, err := categoryModel.Restore(ctx, 3)
+		assert.Nil(t, category)
+		assert.ErrorIs(t, err, ErrInvalidParentId)
+		assert.Equal(t, "parent_id 7 is deleted: invalid parent_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestCategoryModel_Purge(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	categoryModel := NewCategoryModel(db)
+	ctx := context.Background()
+	before := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		DELETE FROM categories
+		WHERE id IN (
+			SELECT c.id FROM categories c
+			WHERE c.deleted_at < $1
+				AND NOT EXISTS(SELECT 1 FROM products p WHERE p.category_id = c.id)
+				AND NOT EXISTS(SELECT 1 FROM categories s WHERE s.parent_id = c.id)
+			ORDER BY c.deleted_at
+			LIMIT $2
+		)
+	`)
+	sqlMock.ExpectExec(mockQuery).WithArgs(before, 500).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	purged, err := categoryModel.Purge(ctx, before, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
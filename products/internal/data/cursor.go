@@ -0,0 +1,137 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a keyset paginated listing. Values holds the text form of
+// every sort column of the row the cursor points at, with id last, and Sorts the sort
+// keys the cursor was built for. A Cursor without values starts at the beginning of
+// the listing. A backward cursor reads the page that precedes the position.
+type Cursor struct {
+	Sorts    []string `json:"s,omitempty"`
+	Values   []string `json:"v,omitempty"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+// DecodeCursor parses an opaque cursor as returned in the next_cursor and prev_cursor
+// metadata. An empty string decodes to a cursor for the first page.
+func DecodeCursor(s string) (*Cursor, error) {
+	var cursor Cursor
+	if s == "" {
+		return &cursor, nil
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(js, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c Cursor) Encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// cursorColumns returns the select expressions that read the text form of every sort
+// column, used to build the cursors of a keyset page.
+func cursorColumns(keys []sortKey) []string {
+	columns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		columns = append(columns, fmt.Sprintf("(%s)::text", key.column))
+	}
+	return columns
+}
+
+// keysetPredicate returns the condition selecting the rows that come after the cursor
+// position in the order given by keys, or before it for a backward cursor. For keys
+// (a, b, id) it expands to a > $1 OR (a = $1 AND b > $2) OR (a = $1 AND b = $2 AND
+// id > $3), with each comparison flipped for descending keys. It returns nil for a
+// cursor without values.
+func keysetPredicate(keys []sortKey, cursor *Cursor) (sq.Sqlizer, error) {
+	if len(cursor.Values) == 0 {
+		return nil, nil
+	}
+
+	if len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	predicate := sq.Or{}
+	for i, key := range keys {
+		term := sq.And{}
+		for j := range i {
+			term = append(term, sq.Expr(keys[j].column+" = ?", cursor.Values[j]))
+		}
+
+		op := ">"
+		if key.desc != cursor.Backward {
+			op = "<"
+		}
+		term = append(term, sq.Expr(fmt.Sprintf("%s %s ?", key.column, op), cursor.Values[i]))
+		predicate = append(predicate, term)
+	}
+
+	return predicate, nil
+}
+
+// paginateKeyset trims a keyset page read with a limit of pageSize+1 down to pageSize
+// rows, puts rows read backwards back into display order and builds the next and
+// previous cursors. values holds the text form of the sort columns of every row.
+func paginateKeyset[T any](
+	items []T,
+	values [][]string,
+	pageSize int,
+	sorts []string,
+	cursor *Cursor,
+) ([]T, Metadata) {
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+		values = values[:pageSize]
+	}
+
+	if cursor.Backward {
+		slices.Reverse(items)
+		slices.Reverse(values)
+	}
+
+	metadata := Metadata{PageSize: pageSize}
+	if len(items) == 0 {
+		return items, metadata
+	}
+
+	// Moving forward there is a next page when the extra row was read, and a previous
+	// page whenever we did not start at the beginning. Moving backward it is the other
+	// way round.
+	hasNext, hasPrev := hasMore, len(cursor.Values) > 0
+	if cursor.Backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		next := Cursor{Sorts: sorts, Values: values[len(values)-1]}
+		metadata.NextCursor = next.Encode()
+	}
+	if hasPrev {
+		prev := Cursor{Sorts: sorts, Values: values[0], Backward: true}
+		metadata.PrevCursor = prev.Encode()
+	}
+
+	return items, metadata
+}
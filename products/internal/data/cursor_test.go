@@ -0,0 +1,171 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty cursor starts at the first page", func(t *testing.T) {
+		cursor, err := DecodeCursor("")
+		assert.NoError(t, err)
+		assert.Equal(t, &Cursor{}, cursor)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		expected := Cursor{
+			Sorts:    []string{"-price"},
+			Values:   []string{"10.990", "7"},
+			Backward: true,
+		}
+
+		cursor, err := DecodeCursor(expected.Encode())
+		assert.NoError(t, err)
+		assert.Equal(t, &expected, cursor)
+	})
+
+	t.Run("invalid encoding", func(t *testing.T) {
+		cursor, err := DecodeCursor("not a cursor!")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, cursor)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		cursor, err := DecodeCursor("bm90IGpzb24")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, cursor)
+	})
+}
+
+func TestKeysetPredicate(t *testing.T) {
+	t.Parallel()
+
+	keys := ProductFilterSpec.sortKeys([]string{"-price", "name"})
+
+	testCases := []struct {
+		name         string
+		cursor       Cursor
+		expectedSQL  string
+		expectedArgs []any
+	}{
+		{
+			name:        "forward",
+			cursor:      Cursor{Values: []string{"10.990", "shoe", "7"}},
+			expectedSQL: "((price < ?) OR (price = ? AND name > ?) OR (price = ? AND name = ? AND id > ?))",
+			expectedArgs: []any{
+				"10.990", "10.990", "shoe", "10.990", "shoe", "7",
+			},
+		},
+		{
+			name:        "backward",
+			cursor:      Cursor{Values: []string{"10.990", "shoe", "7"}, Backward: true},
+			expectedSQL: "((price > ?) OR (price = ? AND name < ?) OR (price = ? AND name = ? AND id < ?))",
+			expectedArgs: []any{
+				"10.990", "10.990", "shoe", "10.990", "shoe", "7",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			predicate, err := keysetPredicate(keys, &tc.cursor)
+			assert.NoError(t, err)
+
+			query, args, err := predicate.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSQL, query)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+
+	t.Run("first page has no predicate", func(t *testing.T) {
+		predicate, err := keysetPredicate(keys, &Cursor{})
+		assert.NoError(t, err)
+		assert.Nil(t, predicate)
+	})
+
+	t.Run("values do not match the sort", func(t *testing.T) {
+		predicate, err := keysetPredicate(keys, &Cursor{Values: []string{"7"}})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, predicate)
+	})
+}
+
+func TestPaginateKeyset(t *testing.T) {
+	t.Parallel()
+
+	sorts := []string{"name"}
+	values := [][]string{{"a", "1"}, {"b", "2"}, {"c", "3"}}
+
+	testCases := []struct {
+		name          string
+		items         []int
+		values        [][]string
+		cursor        Cursor
+		expectedItems []int
+		expectedNext  *Cursor
+		expectedPrev  *Cursor
+	}{
+		{
+			name:          "first page with more rows",
+			items:         []int{1, 2, 3},
+			values:        values,
+			cursor:        Cursor{},
+			expectedItems: []int{1, 2},
+			expectedNext:  &Cursor{Sorts: sorts, Values: []string{"b", "2"}},
+		},
+		{
+			name:          "last page",
+			items:         []int{1, 2},
+			values:        values[:2],
+			cursor:        Cursor{Sorts: sorts, Values: []string{"0", "0"}},
+			expectedItems: []int{1, 2},
+			expectedPrev:  &Cursor{Sorts: sorts, Values: []string{"a", "1"}, Backward: true},
+		},
+		{
+			name:          "backward page with more rows",
+			items:         []int{3, 2, 1},
+			values:        [][]string{{"c", "3"}, {"b", "2"}, {"a", "1"}},
+			cursor:        Cursor{Sorts: sorts, Values: []string{"d", "4"}, Backward: true},
+			expectedItems: []int{2, 3},
+			expectedNext:  &Cursor{Sorts: sorts, Values: []string{"c", "3"}},
+			expectedPrev:  &Cursor{Sorts: sorts, Values: []string{"b", "2"}, Backward: true},
+		},
+		{
+			name:          "backward to the first page",
+			items:         []int{2, 1},
+			values:        [][]string{{"b", "2"}, {"a", "1"}},
+			cursor:        Cursor{Sorts: sorts, Values: []string{"c", "3"}, Backward: true},
+			expectedItems: []int{1, 2},
+			expectedNext:  &Cursor{Sorts: sorts, Values: []string{"b", "2"}},
+		},
+		{
+			name:          "empty page",
+			items:         []int{},
+			values:        [][]string{},
+			cursor:        Cursor{Sorts: sorts, Values: []string{"z", "9"}},
+			expectedItems: []int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items, metadata := paginateKeyset(tc.items, tc.values, 2, sorts, &tc.cursor)
+			assert.Equal(t, tc.expectedItems, items)
+			assert.Equal(t, 2, metadata.PageSize)
+
+			expectedNext, expectedPrev := "", ""
+			if tc.expectedNext != nil {
+				expectedNext = tc.expectedNext.Encode()
+			}
+			if tc.expectedPrev != nil {
+				expectedPrev = tc.expectedPrev.Encode()
+			}
+			assert.Equal(t, expectedNext, metadata.NextCursor)
+			assert.Equal(t, expectedPrev, metadata.PrevCursor)
+		})
+	}
+}
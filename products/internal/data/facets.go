@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// FacetRequest selects the facets computed for a product listing. PriceBounds are the
+// ascending lower bounds of the price buckets; a 0 bound is implied before the first.
+type FacetRequest struct {
+	Category    bool
+	Price       bool
+	PriceBounds []Money
+	Stock       bool
+}
+
+// DefaultPriceBounds are the price buckets used when the client does not choose any.
+var DefaultPriceBounds = []Money{25_000, 50_000, 100_000, 250_000, 500_000}
+
+// Facets hold the number of products matching a listing's filters per category, per
+// price bucket and per stock state. Facets that were not requested are left empty.
+type Facets struct {
+	Category []CategoryFacet `json:"category,omitempty"`
+	Price    []PriceFacet    `json:"price,omitempty"`
+	Stock    *StockFacet     `json:"stock,omitempty"`
+}
+
+type CategoryFacet struct {
+	CategoryID int64  `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+// PriceFacet counts the products priced from Min up to, but excluding, Max. The last
+// bucket has no upper bound.
+type PriceFacet struct {
+	Min   Money  `json:"min"`
+	Max   *Money `json:"max"`
+	Count int    `json:"count"`
+}
+
+type StockFacet struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// GetFacets counts the products matching the filters for every requested facet. The
+// counts use the same predicates as GetAll, so they agree with its total; sorting and
+// paging do not apply.
+func (p *ProductModel) GetFacets(ctx context.Context, filters Filters, req FacetRequest) (*Facets, error) {
+	var facets Facets
+	var err error
+
+	if req.Category {
+		facets.Category, err = p.categoryFacet(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Price {
+		bounds := req.PriceBounds
+		if len(bounds) == 0 {
+			bounds = DefaultPriceBounds
+		}
+		facets.Price, err = p.priceFacet(ctx, filters, bounds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Stock {
+		facets.Stock, err = p.stockFacet(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &facets, nil
+}
+
+// categoryFacet counts the matching products per category, largest first.
+func (p *ProductModel) categoryFacet(ctx context.Context, filters Filters) ([]CategoryFacet, error) {
+	counts := p.buildFilters(
+		psql.Select("category_id", "count(*) AS count").From("products"),
+		filters,
+	).GroupBy("category_id")
+
+	query, args, _ := psql.Select("f.category_id", "c.name", "f.count").
+		FromSelect(counts, "f").
+		Join("categories c ON c.id = f.category_id").
+		OrderBy("f.count DESC", "c.name ASC").
+		ToSql()
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facet := []CategoryFacet{}
+	for rows.Next() {
+		var category CategoryFacet
+		if err := rows.Scan(&category.CategoryID, &category.Name, &category.Count); err != nil {
+			return nil, err
+		}
+		facet = append(facet, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return facet, nil
+}
+
+// priceFacet counts the matching products per price bucket. width_bucket numbers the
+// buckets from 1 for the thresholds 0, bounds[0], bounds[1], ...; every bucket is
+// reported, including the empty ones.
+func (p *ProductModel) priceFacet(
+	ctx context.Context,
+	filters Filters,
+	bounds []Money,
+) ([]PriceFacet, error) {
+	thresholds := make([]string, 0, len(bounds)+1)
+	facet := make([]PriceFacet, 0, len(bounds)+1)
+	for i, lower := range append([]Money{0}, bounds...) {
+		bucket := PriceFacet{Min: lower}
+		if i < len(bounds) {
+			bucket.Max = &bounds[i]
+		}
+		thresholds = append(thresholds, lower.fixed())
+		facet = append(facet, bucket)
+	}
+
+	builder := psql.Select().
+		Column(sq.Expr("width_bucket(price, ?::numeric[]) AS bucket", pq.Array(thresholds))).
+		Column("count(*)").
+		From("products")
+	query, args, _ := p.buildFilters(builder, filters).GroupBy("bucket").ToSql()
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket >= 1 && bucket <= len(facet) {
+			facet[bucket-1].Count = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return facet, nil
+}
+
+// stockFacet counts the matching products that can and cannot be sold right now.
+func (p *ProductModel) stockFacet(ctx context.Context, filters Filters) (*StockFacet, error) {
+	query, args, _ := p.buildFilters(
+		psql.Select(
+			"count(*) FILTER (WHERE quantity > reserved)",
+			"count(*) FILTER (WHERE quantity <= reserved)",
+		).From("products"),
+		filters,
+	).ToSql()
+
+	var facet StockFacet
+	err := p.db.QueryRowContext(ctx, query, args...).Scan(&facet.InStock, &facet.OutOfStock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &facet, nil
+}
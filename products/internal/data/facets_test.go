@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_GetFacets(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := NewProductModel(db)
+	ctx := context.Background()
+
+	filters := Filters{
+		Name:       "shoe",
+		Conditions: []Condition{{Field: "price", Op: OpLte, Value: Money(100_000)}},
+		Sorts:      []string{"-price"},
+		Page:       3,
+		PageSize:   20,
+	}
+	categoryQuery := regexp.QuoteMeta(`
+		SELECT f.category_id, c.name, f.count
+		FROM (SELECT category_id, count(*) AS count
+			FROM products
+			WHERE deleted_at IS NULL AND to_tsvector('simple', name) @@ search_tsquery('simple', $1) AND (price <= $2)
+			GROUP BY category_id) AS f
+		JOIN categories c ON c.id = f.category_id
+		ORDER BY f.count DESC, c.name ASC
+	`)
+	priceQuery := regexp.QuoteMeta(`
+		SELECT width_bucket(price, $1::numeric[]) AS bucket, count(*)
+		FROM products
+		WHERE deleted_at IS NULL AND to_tsvector('simple', name) @@ search_tsquery('simple', $2) AND (price <= $3)
+		GROUP BY bucket
+	`)
+	stockQuery := regexp.QuoteMeta(`
+		SELECT count(*) FILTER (WHERE quantity > reserved), count(*) FILTER (WHERE quantity <= reserved)
+		FROM products
+		WHERE deleted_at IS NULL AND to_tsvector('simple', name) @@ search_tsquery('simple', $1) AND (price <= $2)
+	`)
+
+	t.Run("computes every facet", func(t *testing.T) {
+		sqlMock.ExpectQuery(categoryQuery).WithArgs("shoe", "100.000").WillReturnRows(
+			sqlmock.NewRows([]string{"category_id", "name", "count"}).
+				AddRow(3, "Running", 12).
+				AddRow(4, "Hiking", 5),
+		)
+		sqlMock.ExpectQuery(priceQuery).
+			WithArgs(`{"0.000","50.000","80.000"}`, "shoe", "100.000").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"bucket", "count"}).
+					AddRow(1, 9).
+					AddRow(3, 8),
+			)
+		sqlMock.ExpectQuery(stockQuery).WithArgs("shoe", "100.000").WillReturnRows(
+			sqlmock.NewRows([]string{"in_stock", "out_of_stock"}).AddRow(15, 2),
+		)
+
+		facets, err := productModel.GetFacets(ctx, filters, FacetRequest{
+			Category:    true,
+			Price:       true,
+			PriceBounds: []Money{50_000, 80_000},
+			Stock:       true,
+		})
+		assert.NoError(t, err)
+
+		fifty, eighty := Money(50_000), Money(80_000)
+		assert.Equal(t, &Facets{
+			Category: []CategoryFacet{
+				{CategoryID: 3, Name: "Running", Count: 12},
+				{CategoryID: 4, Name: "Hiking", Count: 5},
+			},
+			Price: []PriceFacet{
+				{Min: 0, Max: &fifty, Count: 9},
+				{Min: fifty, Max: &eighty, Count: 0},
+				{Min: eighty, Count: 8},
+			},
+			Stock: &StockFacet{InStock: 15, OutOfStock: 2},
+		}, facets)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("default price buckets", func(t *testing.T) {
+		sqlMock.ExpectQuery(priceQuery).
+			WithArgs(`{"0.000","25.000","50.000","100.000","250.000","500.000"}`, "shoe", "100.000").
+			WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}))
+
+		facets, err := productModel.GetFacets(ctx, filters, FacetRequest{Price: true})
+		assert.NoError(t, err)
+		assert.Len(t, facets.Price, 6)
+		assert.Nil(t, facets.Category)
+		assert.Nil(t, facets.Stock)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(stockQuery).WillReturnError(errors.New("query error"))
+
+		facets, err := productModel.GetFacets(ctx, filters, FacetRequest{Stock: true})
+		assert.Nil(t, facets)
+		assert.EqualError(t, err, "query error")
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
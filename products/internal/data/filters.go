@@ -1,57 +1,264 @@
 package data
 
 import (
+	"database/sql"
+	"fmt"
+	"slices"
 	"strings"
 	"time"
+
+	sq "github.com/Masterminds/squirrel"
 )
 
 type Filters struct {
-	IDs      []int64
-	Name     string `validate:"omitempty,max=100"`
-	DateFrom *time.Time
-	DateTo   *time.Time
-	Sorts    []string `validate:"omitempty,max=4,dive,oneof=id created_at name -id -created_at -name"`
-	Page     int      `validate:"gte=1,lte=10_0000_000"`
-	PageSize int      `validate:"gte=1,lte=100"`
+	IDs        []int64
+	Name       string `validate:"omitempty,max=100"`
+	DateFrom   *time.Time
+	DateTo     *time.Time
+	Conditions []Condition
+	// Attributes filter products by their attributes.
+	Attributes   []AttributeCondition
+	Cursor       *Cursor
+	Sorts        []string `validate:"omitempty,max=4"`
+	SortSafelist []string
+	Page         int `validate:"gte=1,lte=10_0000_000"`
+	PageSize     int `validate:"gte=1,lte=100"`
+
+	// Language selects the text search configuration the name is matched with, see
+	// SearchConfig.
+	Language string
+
+	// IncludeSubcategories widens a category_id condition to the subcategories of the
+	// requested categories. Only products are filtered by category.
+	IncludeSubcategories bool
+
+	// IncludeDeleted lists deleted records alongside the others.
+	IncludeDeleted bool
+
+	// UpdatedSince keeps the records updated at or after the time. Only products are
+	// filtered by update time.
+	UpdatedSince *time.Time
+}
+
+// FieldType describes how the query string value of a filterable field is parsed.
+type FieldType int
+
+const (
+	IntField FieldType = iota
+	MoneyField
+	BoolField
+)
+
+// FilterOp is a comparison that a filterable field accepts. In the query string an
+// equality filter is written as field=value and any other operator as field_op=value,
+// for example price_gte=10.
+type FilterOp string
+
+const (
+	OpEq  FilterOp = "eq"
+	OpGte FilterOp = "gte"
+	OpLte FilterOp = "lte"
+)
+
+// FieldSpec declares how a single field of a resource can be used when listing that
+// resource. Column is the SQL expression the field maps to.
+type FieldSpec struct {
+	Name     string
+	Column   string
+	Type     FieldType
+	Sortable bool
+	Ops      []FilterOp
+}
+
+// FilterSpec declares, per resource, which fields can be sorted on and which can be
+// filtered with which operators. Fields are kept in declaration order so that the
+// sort safelist reported to clients is stable.
+type FilterSpec []FieldSpec
+
+// Condition is a single resource specific filter parsed from the query string. The
+// Value of an equality condition on an IntField holds a []int64 so that several
+// values can be matched at once.
+type Condition struct {
+	Field string
+	Op    FilterOp
+	Value any
+}
+
+var CategoryFilterSpec = FilterSpec{
+	{Name: "id", Column: "id", Sortable: true},
+	{Name: "created_at", Column: "created_at", Sortable: true},
+	{Name: "name", Column: "name", Sortable: true},
+	{Name: "parent_id", Column: "parent_id", Type: IntField, Ops: []FilterOp{OpEq}},
+}
+
+var ProductFilterSpec = FilterSpec{
+	{Name: "id", Column: "id", Sortable: true},
+	{Name: "created_at", Column: "created_at", Sortable: true},
+	{Name: "updated_at", Column: "updated_at", Sortable: true},
+	{Name: "name", Column: "name", Sortable: true},
+	{
+		Name:     "price",
+		Column:   "price",
+		Type:     MoneyField,
+		Sortable: true,
+		Ops:      []FilterOp{OpGte, OpLte},
+	},
+	{
+		Name:     "quantity",
+		Column:   "quantity",
+		Type:     IntField,
+		Sortable: true,
+		Ops:      []FilterOp{OpGte, OpLte},
+	},
+	{Name: "category_id", Column: "category_id", Type: IntField, Ops: []FilterOp{OpEq}},
+	{Name: "in_stock", Column: "(quantity > reserved)", Type: BoolField, Ops: []FilterOp{OpEq}},
 }
 
-func (f *Filters) sortColumns() string {
-	sortFieldMapping := map[string]string{
-		"created_at":  "created_at ASC",
-		"-created_at": "created_at DESC",
-		"id":          "id ASC",
-		"-id":         "id DESC",
-		"name":        "name ASC",
-		"-name":       "name DESC",
+// Field returns the declaration of the named field.
+func (s FilterSpec) Field(name string) (FieldSpec, bool) {
+	for _, field := range s {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FieldSpec{}, false
+}
+
+// SortSafelist returns every sort key the resource accepts: the sortable field names
+// in ascending order followed by the same names prefixed with "-" for descending
+// order.
+func (s FilterSpec) SortSafelist() []string {
+	var asc, desc []string
+	for _, field := range s {
+		if field.Sortable {
+			asc = append(asc, field.Name)
+			desc = append(desc, "-"+field.Name)
+		}
 	}
+	return append(asc, desc...)
+}
+
+// sortKey is a sort key resolved against a FilterSpec: the column to order by and
+// whether it is ordered in descending order.
+type sortKey struct {
+	column string
+	desc   bool
+}
 
+// sortKeys resolves the given sort keys to columns. The id column is always appended
+// as a tie-breaker so that pagination is deterministic. Keys that are not sortable
+// are skipped; they are rejected during validation.
+func (s FilterSpec) sortKeys(sorts []string) []sortKey {
 	hasId := false
-	var sortColumns = []string{}
-	for _, key := range f.Sorts {
-		if key == "id" || key == "-id" {
+	keys := []sortKey{}
+	for _, key := range sorts {
+		name := strings.TrimPrefix(key, "-")
+		field, ok := s.Field(name)
+		if !ok || !field.Sortable {
+			continue
+		}
+
+		if name == "id" {
 			hasId = true
 		}
-		sortColumns = append(sortColumns, sortFieldMapping[key])
+		keys = append(keys, sortKey{column: field.Column, desc: strings.HasPrefix(key, "-")})
 	}
 
 	if !hasId {
-		sortColumns = append(sortColumns, sortFieldMapping["id"])
+		keys = append(keys, sortKey{column: "id"})
+	}
+
+	return keys
+}
+
+// sortColumns builds the ORDER BY list for the given sort keys.
+func (s FilterSpec) sortColumns(sorts []string) string {
+	return orderBy(s.sortKeys(sorts), false)
+}
+
+// orderBy renders the ORDER BY list for the keys. When reverse is true every
+// direction is flipped, which is how a keyset page is read backwards.
+func orderBy(keys []sortKey, reverse bool) string {
+	sortColumns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		direction := "ASC"
+		if key.desc != reverse {
+			direction = "DESC"
+		}
+		sortColumns = append(sortColumns, fmt.Sprintf("%s %s", key.column, direction))
 	}
 
 	return strings.Join(sortColumns, ", ")
 }
 
+// conditions translates the parsed conditions into squirrel predicates. Conditions on
+// undeclared fields or with operators the field does not accept are skipped; they
+// are never produced by the handlers.
+func (s FilterSpec) conditions(conds []Condition) sq.And {
+	predicates := sq.And{}
+	for _, cond := range conds {
+		field, ok := s.Field(cond.Field)
+		if !ok || !slices.Contains(field.Ops, cond.Op) {
+			continue
+		}
+
+		switch cond.Op {
+		case OpEq:
+			predicates = append(predicates, sq.Eq{field.Column: cond.Value})
+		case OpGte:
+			predicates = append(predicates, sq.GtOrEq{field.Column: cond.Value})
+		case OpLte:
+			predicates = append(predicates, sq.LtOrEq{field.Column: cond.Value})
+		}
+	}
+
+	return predicates
+}
+
+// whereSQL renders the conditions as an "AND ..." fragment for hand written queries
+// that use numbered placeholders. Numbering starts after argOffset, the number of
+// arguments the query already uses.
+func (s FilterSpec) whereSQL(conds []Condition, argOffset int) (string, []any) {
+	predicates := s.conditions(conds)
+	if len(predicates) == 0 {
+		return "", nil
+	}
+
+	query, args, _ := predicates.ToSql()
+
+	return "AND " + numberPlaceholders(query, argOffset), args
+}
+
+// numberPlaceholders replaces the ? placeholders of a squirrel fragment with numbered
+// placeholders starting after argOffset.
+func numberPlaceholders(query string, argOffset int) string {
+	var sb strings.Builder
+	n := argOffset
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&sb, "$%d", n)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
 func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
 // Define a new Metadata struct for holding the pagination metadata.
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitzero"`
-	PageSize     int `json:"page_size,omitzero"`
-	FirstPage    int `json:"first_page,omitzero"`
-	LastPage     int `json:"last_page,omitzero"`
-	TotalRecords int `json:"total_records,omitzero"`
+	CurrentPage  int    `json:"current_page,omitzero"`
+	PageSize     int    `json:"page_size,omitzero"`
+	FirstPage    int    `json:"first_page,omitzero"`
+	LastPage     int    `json:"last_page,omitzero"`
+	TotalRecords int    `json:"total_records,omitzero"`
+	NextCursor   string `json:"next_cursor,omitzero"`
+	PrevCursor   string `json:"prev_cursor,omitzero"`
 }
 
 // The calculateMetadata() function calculates the appropriate pagination metadata
@@ -75,3 +282,93 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+// totalColumn returns the select expression for the total number of records matching
+// the filters. Keyset pages do not report a total, so the window is skipped for them.
+func (f Filters) totalColumn() string {
+	if f.Cursor != nil {
+		return "0"
+	}
+	return "count(*) OVER()"
+}
+
+// paginate adds the ordering and paging for the filters to builder. In cursor mode
+// it adds the keyset predicate and the cursor columns instead of an OFFSET, and reads
+// one extra row to learn whether there is a page beyond this one.
+func (s FilterSpec) paginate(builder sq.SelectBuilder, filters Filters) (sq.SelectBuilder, error) {
+	keys := s.sortKeys(filters.Sorts)
+	cursor := filters.Cursor
+
+	if cursor == nil {
+		return builder.
+			OrderBy(orderBy(keys, false)).
+			Limit(uint64(filters.PageSize)).
+			Offset(uint64(filters.offset())), nil
+	}
+
+	predicate, err := keysetPredicate(keys, cursor)
+	if err != nil {
+		return builder, err
+	}
+	if predicate != nil {
+		builder = builder.Where(predicate)
+	}
+
+	return builder.
+		Columns(cursorColumns(keys)...).
+		OrderBy(orderBy(keys, cursor.Backward)).
+		Limit(uint64(filters.PageSize + 1)), nil
+}
+
+// collectPage scans the rows of a page query and builds its metadata. Each row must
+// hold the total count, the columns of an item and, in cursor mode, the cursor
+// columns, in that order. scan returns a new item along with the scan destinations
+// of its columns.
+func collectPage[T any](
+	rows *sql.Rows,
+	spec FilterSpec,
+	filters Filters,
+	scan func() (*T, []any),
+) ([]*T, Metadata, error) {
+	keys := spec.sortKeys(filters.Sorts)
+	cursor := filters.Cursor
+
+	items := []*T{}
+	cursorValues := [][]string{}
+	totalRecords := 0
+	for rows.Next() {
+		item, columns := scan()
+		dest := append([]any{&totalRecords}, columns...)
+
+		var values []string
+		if cursor != nil {
+			values = make([]string, len(keys))
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, item)
+		cursorValues = append(cursorValues, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if cursor != nil {
+		items, metadata := paginateKeyset(
+			items,
+			cursorValues,
+			filters.PageSize,
+			filters.Sorts,
+			cursor,
+		)
+		return items, metadata, nil
+	}
+
+	return items, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
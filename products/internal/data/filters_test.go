@@ -0,0 +1,81 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSpec_SortSafelist(t *testing.T) {
+	t.Parallel()
+
+	expected := []string{"id", "created_at", "name", "-id", "-created_at", "-name"}
+	assert.Equal(t, expected, CategoryFilterSpec.SortSafelist())
+
+	expected = []string{
+		"id", "created_at", "updated_at", "name", "price", "quantity",
+		"-id", "-created_at", "-updated_at", "-name", "-price", "-quantity",
+	}
+	assert.Equal(t, expected, ProductFilterSpec.SortSafelist())
+}
+
+func TestFilterSpec_SortColumns(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		sorts    []string
+		expected string
+	}{
+		{"default sort", nil, "id ASC"},
+		{"appends id tie-breaker", []string{"-price", "name"}, "price DESC, name ASC, id ASC"},
+		{"keeps explicit id", []string{"-id", "quantity"}, "id DESC, quantity ASC"},
+		{"skips unknown keys", []string{"category_id", "-in_stock", "bogus"}, "id ASC"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ProductFilterSpec.sortColumns(tc.sorts))
+		})
+	}
+}
+
+func TestFilterSpec_Conditions(t *testing.T) {
+	t.Parallel()
+
+	conds := []Condition{
+		{Field: "category_id", Op: OpEq, Value: []int64{3, 4}},
+		{Field: "price", Op: OpGte, Value: 10.5},
+		{Field: "price", Op: OpLte, Value: 99.0},
+		{Field: "in_stock", Op: OpEq, Value: true},
+		{Field: "price", Op: OpEq, Value: 1.0},
+		{Field: "unknown", Op: OpEq, Value: 1},
+	}
+
+	t.Run("squirrel predicates", func(t *testing.T) {
+		query, args, err := ProductFilterSpec.conditions(conds).ToSql()
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			"(category_id IN (?,?) AND price >= ? AND price <= ? AND (quantity > reserved) = ?)",
+			query,
+		)
+		assert.Equal(t, []any{int64(3), int64(4), 10.5, 99.0, true}, args)
+	})
+
+	t.Run("numbered placeholders", func(t *testing.T) {
+		query, args := ProductFilterSpec.whereSQL(conds, 6)
+		assert.Equal(
+			t,
+			"AND (category_id IN ($7,$8) AND price >= $9 AND price <= $10 AND (quantity > reserved) = $11)",
+			query,
+		)
+		assert.Equal(t, []any{int64(3), int64(4), 10.5, 99.0, true}, args)
+	})
+
+	t.Run("no conditions", func(t *testing.T) {
+		query, args := CategoryFilterSpec.whereSQL(nil, 6)
+		assert.Equal(t, "", query)
+		assert.Nil(t, args)
+	})
+}
@@ -0,0 +1,321 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// The statuses of a job. A queued job waits for a worker, a running job is held by
+// one, and a job is done once it has succeeded or failed.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a catalog operation run in the background by the workers of the server,
+// because it takes longer than a request may. The job is stored with its payload, so
+// that it outlives the server that queued it.
+type Job struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	Actor     string    `json:"actor"`
+	// Payload is the input of the job, read by the runner of its kind.
+	Payload json.RawMessage `json:"-"`
+	Status  string          `json:"status"`
+	// Progress counts the steps of the job that are done, out of Total. A job that
+	// is claimed again resumes after them.
+	Progress int `json:"progress"`
+	Total    int `json:"total"`
+	// Result is set as the job runs, and Error once it has failed.
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+	Attempts   int             `json:"attempts"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+type JobModel struct {
+	db *sql.DB
+}
+
+type JobRepository interface {
+	Insert(ctx context.Context, job *Job) error
+	GetByID(ctx context.Context, id int64) (*Job, error)
+	Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error)
+	Report(ctx context.Context, job *Job, lease time.Duration) error
+	Finish(ctx context.Context, job *Job) error
+	Release(ctx context.Context, job *Job) error
+}
+
+func NewJobModel(db *sql.DB) *JobModel {
+	return &JobModel{db: db}
+}
+
+// jobColumns are the columns read for a job, in the order of jobDest.
+var jobColumns = []string{
+	"id",
+	"created_at",
+	"kind",
+	"actor",
+	"payload",
+	"status",
+	"progress",
+	"total",
+	"result",
+	"error",
+	"attempts",
+	"started_at",
+	"finished_at",
+}
+
+func jobDest(job *Job) []any {
+	return []any{
+		&job.ID,
+		&job.CreatedAt,
+		&job.Kind,
+		&job.Actor,
+		(*[]byte)(&job.Payload),
+		&job.Status,
+		&job.Progress,
+		&job.Total,
+		(*[]byte)(&job.Result),
+		&job.Error,
+		&job.Attempts,
+		&job.StartedAt,
+		&job.FinishedAt,
+	}
+}
+
+// Insert queues a job for the workers. The job runs for the actor of ctx.
+func (j *JobModel) Insert(ctx context.Context, job *Job) error {
+	job.Actor = ActorFromContext(ctx)
+
+	query := `
+		INSERT INTO jobs (kind, actor, payload, total)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status
+	`
+	args := []any{job.Kind, job.Actor, []byte(job.Payload), job.Total}
+	return j.db.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.CreatedAt, &job.Status)
+}
+
+func (j *JobModel) GetByID(ctx context.Context, id int64) (*Job, error) {
+	query, args, _ := psql.Select(jobColumns...).
+		From("jobs").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	var job Job
+	err := j.db.QueryRowContext(ctx, query, args...).Scan(jobDest(&job)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Claim takes the oldest job of one of the kinds that is queued, or was running on a
+// worker that let its lease run out, and holds it for the lease. Jobs held by other
+// workers are skipped rather than waited for. Every claim counts as an attempt, and
+// the attempt identifies the holder of the job to Report, Finish and Release. It
+// returns ErrRecordNotFound if there is no job to run.
+func (j *JobModel) Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error) {
+	query := fmt.Sprintf(`
+		UPDATE jobs
+		SET status = 'running',
+			attempts = attempts + 1,
+			started_at = COALESCE(started_at, NOW()),
+			lease_expires_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1)
+				AND (status = 'queued' OR (status = 'running' AND lease_expires_at < NOW()))
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s
+	`, strings.Join(jobColumns, ", "))
+
+	var job Job
+	err := j.db.QueryRowContext(ctx, query, pq.Array(kinds), lease.Milliseconds()).
+		Scan(jobDest(&job)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Report saves the progress and the result of a running job and renews its lease. A
+// report older than the progress saved by the last step, see JobStep, only renews
+// the lease. It returns ErrJobLeaseLost if the job is no longer held by this attempt.
+func (j *JobModel) Report(ctx context.Context, job *Job, lease time.Duration) error {
+	query := `
+		UPDATE jobs
+		SET progress = GREATEST(progress, $1), total = $2,
+			result = CASE WHEN progress > $1 THEN result ELSE $3 END,
+			lease_expires_at = NOW() + $4 * INTERVAL '1 millisecond'
+		WHERE id = $5 AND attempts = $6 AND status = 'running'
+	`
+	args := []any{
+		job.Progress,
+		job.Total,
+		nullJSON(job.Result),
+		lease.Milliseconds(),
+		job.ID,
+		job.Attempts,
+	}
+	return j.updateHeld(ctx, query, args)
+}
+
+// Finish records the end of a running job, with the status, progress, result and
+// error it ended with. It returns ErrJobLeaseLost if the job is no longer held by
+// this attempt.
+func (j *JobModel) Finish(ctx context.Context, job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, progress = $2, total = $3, result = $4, error = $5,
+			finished_at = NOW(), lease_expires_at = NULL
+		WHERE id = $6 AND attempts = $7 AND status = 'running'
+		RETURNING finished_at
+	`
+	args := []any{
+		job.Status,
+		job.Progress,
+		job.Total,
+		nullJSON(job.Result),
+		job.Error,
+		job.ID,
+		job.Attempts,
+	}
+
+	err := j.db.QueryRowContext(ctx, query, args...).Scan(&job.FinishedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrJobLeaseLost
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Release puts a running job that was interrupted, by the server shutting down, back
+// in the queue with the progress it made. The interrupted attempt is not counted. It
+// returns ErrJobLeaseLost if the job is no longer held by this attempt.
+func (j *JobModel) Release(ctx context.Context, job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = 'queued', attempts = attempts - 1, progress = $1, total = $2,
+			result = $3, lease_expires_at = NULL
+		WHERE id = $4 AND attempts = $5 AND status = 'running'
+	`
+	args := []any{job.Progress, job.Total, nullJSON(job.Result), job.ID, job.Attempts}
+	return j.updateHeld(ctx, query, args)
+}
+
+// updateHeld runs an update of a job that only applies while the job is held by the
+// attempt it names.
+func (j *JobModel) updateHeld(ctx context.Context, query string, args []any) error {
+	result, err := j.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// JobStep is a step of a running job that is done by a write. The progress the step
+// brings the job to, and its result, are saved in the transaction of the write, so
+// that a step that was written is not run again when the job resumes.
+type JobStep struct {
+	Job      *Job
+	Progress int
+	Result   any
+}
+
+type jobStepContextKey struct{}
+
+// ContextWithJobStep returns a copy of ctx carrying the step of a job that the write
+// made with it completes.
+func ContextWithJobStep(ctx context.Context, step *JobStep) context.Context {
+	return context.WithValue(ctx, jobStepContextKey{}, step)
+}
+
+// JobStepFromContext returns the step set by ContextWithJobStep, or nil if there is
+// none.
+func JobStepFromContext(ctx context.Context) *JobStep {
+	step, _ := ctx.Value(jobStepContextKey{}).(*JobStep)
+	return step
+}
+
+// saveJobStep saves the progress of the job step of ctx, if any, with the writes made
+// by the rest of the transaction. It returns ErrJobLeaseLost if the job is no longer
+// held by the attempt that runs the step.
+func saveJobStep(ctx context.Context, tx *sql.Tx) error {
+	step := JobStepFromContext(ctx)
+	if step == nil {
+		return nil
+	}
+
+	result, err := json.Marshal(step.Result)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jobs
+		SET progress = $1, result = $2
+		WHERE id = $3 AND attempts = $4 AND status = 'running'
+	`
+	res, err := tx.ExecContext(ctx, query, step.Progress, result, step.Job.ID, step.Job.Attempts)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// nullJSON returns raw as a JSONB parameter, NULL if it is empty.
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return []byte(raw)
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobModel_Integration_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	jobModel := NewJobModel(db)
+	kinds := []string{"import_products"}
+
+	job := Job{Kind: "import_products", Payload: []byte(`{"rows": [[]]}`), Total: 2}
+	assert.NoError(t, jobModel.Insert(ContextWithActor(ctx, "alice"), &job))
+	assert.Equal(t, JobQueued, job.Status)
+
+	// Jobs of other kinds are left to the workers that run them.
+	_, err := jobModel.Claim(ctx, []string{"rebuild_search"}, time.Minute)
+	assert.Equal(t, ErrRecordNotFound, err)
+
+	claimed, err := jobModel.Claim(ctx, kinds, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, claimed.ID)
+	assert.Equal(t, JobRunning, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+	assert.Equal(t, "alice", claimed.Actor)
+	assert.JSONEq(t, `{"rows": [[]]}`, string(claimed.Payload))
+
+	// A held job is not claimed again.
+	_, err = jobModel.Claim(ctx, kinds, time.Minute)
+	assert.Equal(t, ErrRecordNotFound, err)
+
+	claimed.Progress, claimed.Result = 1, []byte(`{"created": 1}`)
+	assert.NoError(t, jobModel.Report(ctx, claimed, time.Minute))
+
+	// A released job resumes from its progress, and the interruption is not counted.
+	assert.NoError(t, jobModel.Release(ctx, claimed))
+	resumed, err := jobModel.Claim(ctx, kinds, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resumed.Progress)
+	assert.Equal(t, 1, resumed.Attempts)
+	assert.JSONEq(t, `{"created": 1}`, string(resumed.Result))
+
+	resumed.Status, resumed.Progress = JobSucceeded, 2
+	assert.NoError(t, jobModel.Finish(ctx, resumed))
+
+	stored, err := jobModel.GetByID(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, JobSucceeded, stored.Status)
+	assert.Equal(t, 2, stored.Progress)
+	assert.NotNil(t, stored.FinishedAt)
+	assert.Nil(t, stored.Error)
+}
+
+func TestJobModel_Integration_ExpiredLease(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	jobModel := NewJobModel(db)
+	kinds := []string{"rebuild_search"}
+
+	job := Job{Kind: "rebuild_search", Payload: []byte(`{}`), Total: 1}
+	assert.NoError(t, jobModel.Insert(ctx, &job))
+
+	first, err := jobModel.Claim(ctx, kinds, time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	// The job of a worker that let its lease run out is claimed by another, and the
+	// first worker can no longer write it.
+	second, err := jobModel.Claim(ctx, kinds, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, second.Attempts)
+
+	assert.Equal(t, ErrJobLeaseLost, jobModel.Report(ctx, first, time.Minute))
+	first.Status = JobSucceeded
+	assert.Equal(t, ErrJobLeaseLost, jobModel.Finish(ctx, first))
+	assert.Equal(t, ErrJobLeaseLost, jobModel.Release(ctx, first))
+
+	second.Status = JobSucceeded
+	assert.NoError(t, jobModel.Finish(ctx, second))
+}
+
+func TestJobModel_Integration_Step(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	jobModel := NewJobModel(db)
+	kinds := []string{"import_products"}
+
+	categoryID, _ := seedProducts(t, db, nil)
+
+	job := Job{Kind: "import_products", Payload: []byte(`{"rows": [[], []]}`), Total: 2}
+	assert.NoError(t, jobModel.Insert(ctx, &job))
+
+	claimed, err := jobModel.Claim(ctx, kinds, time.Minute)
+	assert.NoError(t, err)
+
+	// The progress of a step is saved with the product it writes.
+	product := Product{Name: "Step Shoe", CategoryID: int(categoryID), Currency: "USD"}
+	stepCtx := ContextWithJobStep(ctx, &JobStep{
+		Job:      claimed,
+		Progress: 1,
+		Result:   map[string]int{"created": 1},
+	})
+	assert.NoError(t, NewProductModel(db).Insert(stepCtx, &product))
+
+	stored, err := jobModel.GetByID(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.Progress)
+	assert.JSONEq(t, `{"created": 1}`, string(stored.Result))
+
+	// A report taken before the step does not undo it.
+	assert.NoError(t, jobModel.Report(ctx, claimed, time.Minute))
+
+	stored, err = jobModel.GetByID(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.Progress)
+	assert.JSONEq(t, `{"created": 1}`, string(stored.Result))
+}
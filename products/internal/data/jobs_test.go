@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobModel(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	jobModel := NewJobModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(time.Minute)
+
+	jobRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows(jobColumns).AddRow(
+			12,
+			createdAt,
+			"import_products",
+			"alice",
+			[]byte(`{"rows": []}`),
+			JobRunning,
+			3,
+			10,
+			[]byte(`{"created": 3}`),
+			nil,
+			1,
+			startedAt,
+			nil,
+		)
+	}
+	expectedJob := &Job{
+		ID:        12,
+		CreatedAt: createdAt,
+		Kind:      "import_products",
+		Actor:     "alice",
+		Payload:   []byte(`{"rows": []}`),
+		Status:    JobRunning,
+		Progress:  3,
+		Total:     10,
+		Result:    []byte(`{"created": 3}`),
+		Attempts:  1,
+		StartedAt: &startedAt,
+	}
+
+	t.Run("inserts a job for the actor", func(t *testing.T) {
+		job := Job{Kind: "rebuild_search", Payload: []byte(`{}`), Total: 1}
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`
+			INSERT INTO jobs (kind, actor, payload, total)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, status
+		`)).
+			WithArgs("rebuild_search", "alice", []byte(`{}`), 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "created_at", "status"}).
+					AddRow(12, createdAt, JobQueued),
+			)
+
+		err := jobModel.Insert(ContextWithActor(ctx, "alice"), &job)
+		assert.NoError(t, err)
+		assert.Equal(t, Job{
+			ID:        12,
+			CreatedAt: createdAt,
+			Kind:      "rebuild_search",
+			Actor:     "alice",
+			Payload:   []byte(`{}`),
+			Status:    JobQueued,
+			Total:     1,
+		}, job)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("gets a job by id", func(t *testing.T) {
+		query := regexp.QuoteMeta(
+			`SELECT ` + strings.Join(jobColumns, ", ") + ` FROM jobs WHERE id = $1`,
+		)
+		sqlMock.ExpectQuery(query).WithArgs(12).WillReturnRows(jobRow())
+		sqlMock.ExpectQuery(query).WithArgs(13).WillReturnRows(sqlmock.NewRows(jobColumns))
+
+		job, err := jobModel.GetByID(ctx, 12)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedJob, job)
+
+		_, err = jobModel.GetByID(ctx, 13)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("claims the next job", func(t *testing.T) {
+		query := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET status = 'running',
+				attempts = attempts + 1,
+				started_at = COALESCE(started_at, NOW()),
+				lease_expires_at = NOW() + $2 * INTERVAL '1 millisecond'
+			WHERE id = (
+				SELECT id FROM jobs
+				WHERE kind = ANY($1)
+					AND (status = 'queued' OR (status = 'running' AND lease_expires_at < NOW()))
+				ORDER BY id
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING ` + strings.Join(jobColumns, ", "))
+		kinds := []string{"import_products", "rebuild_search"}
+		sqlMock.ExpectQuery(query).
+			WithArgs(pq.Array(kinds), int64(60_000)).
+			WillReturnRows(jobRow())
+		sqlMock.ExpectQuery(query).
+			WithArgs(pq.Array(kinds), int64(60_000)).
+			WillReturnRows(sqlmock.NewRows(jobColumns))
+
+		job, err := jobModel.Claim(ctx, kinds, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedJob, job)
+
+		_, err = jobModel.Claim(ctx, kinds, time.Minute)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reports the progress of a held job", func(t *testing.T) {
+		query := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET progress = GREATEST(progress, $1), total = $2,
+				result = CASE WHEN progress > $1 THEN result ELSE $3 END,
+				lease_expires_at = NOW() + $4 * INTERVAL '1 millisecond'
+			WHERE id = $5 AND attempts = $6 AND status = 'running'
+		`)
+		sqlMock.ExpectExec(query).
+			WithArgs(3, 10, []byte(`{"created": 3}`), int64(60_000), 12, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(query).
+			WithArgs(0, 10, nil, int64(60_000), 12, 1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, jobModel.Report(ctx, expectedJob, time.Minute))
+
+		lost := *expectedJob
+		lost.Progress, lost.Result = 0, nil
+		assert.Equal(t, ErrJobLeaseLost, jobModel.Report(ctx, &lost, time.Minute))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("finishes a held job", func(t *testing.T) {
+		query := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET status = $1, progress = $2, total = $3, result = $4, error = $5,
+				finished_at = NOW(), lease_expires_at = NULL
+			WHERE id = $6 AND attempts = $7 AND status = 'running'
+			RETURNING finished_at
+		`)
+		finishedAt := startedAt.Add(time.Minute)
+		message := "job was interrupted 3 times"
+		sqlMock.ExpectQuery(query).
+			WithArgs(JobFailed, 3, 10, []byte(`{"created": 3}`), &message, 12, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"finished_at"}).AddRow(finishedAt))
+		sqlMock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRows([]string{"finished_at"}))
+
+		job := *expectedJob
+		job.Status, job.Error = JobFailed, &message
+		assert.NoError(t, jobModel.Finish(ctx, &job))
+		assert.Equal(t, &finishedAt, job.FinishedAt)
+
+		assert.Equal(t, ErrJobLeaseLost, jobModel.Finish(ctx, &job))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("releases a held job", func(t *testing.T) {
+		query := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET status = 'queued', attempts = attempts - 1, progress = $1, total = $2,
+				result = $3, lease_expires_at = NULL
+			WHERE id = $4 AND attempts = $5 AND status = 'running'
+		`)
+		sqlMock.ExpectExec(query).
+			WithArgs(3, 10, []byte(`{"created": 3}`), 12, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, jobModel.Release(ctx, expectedJob))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
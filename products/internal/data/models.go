@@ -4,15 +4,43 @@ import (
 	"errors"
 )
 
-const ErrForeignKeyViolation = "23503"
+const (
+	ErrForeignKeyViolation    = "23503"
+	ErrCheckViolation         = "23514"
+	ErrNumericValueOutOfRange = "22003"
+	ErrUniqueViolation        = "23505"
+)
 
 var (
-	ErrRecordNotFound    = errors.New("record not found")
-	ErrEditConflict      = errors.New("edit conflict")
-	ErrInvalidCategoryId = errors.New("invalid category_id")
+	ErrRecordNotFound      = errors.New("record not found")
+	ErrEditConflict        = errors.New("edit conflict")
+	ErrInvalidCategoryId   = errors.New("invalid category_id")
+	ErrCategoryHasProducts = errors.New("category still has products")
+	ErrCategoryHasChildren = errors.New("category still has subcategories")
+	ErrInvalidParentId     = errors.New("invalid parent_id")
+	ErrCategoryCycle       = errors.New("category cannot be moved under itself or its subcategories")
+	ErrMoneyOutOfRange     = errors.New("amount out of range")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrNegativeQuantity    = errors.New("quantity must not be negative")
+	ErrReservationNotHeld  = errors.New("reservation is no longer held")
+	ErrDuplicateSKU        = errors.New("sku already exists")
+	ErrDuplicateVariant    = errors.New("a variant with the same options already exists")
+	ErrDuplicateTerm       = errors.New("synonyms for this term already exist")
+	ErrDuplicateStopWord   = errors.New("stop word already exists")
+	ErrDuplicateSlug       = errors.New("slug already exists")
+	ErrDuplicateExternalID = errors.New("external_id already exists")
+	ErrJobLeaseLost        = errors.New("job is no longer held by this worker")
 )
 
 type Models struct {
-	Product  ProductRepository
-	Category CategoryRepository
+	Product       ProductRepository
+	Category      CategoryRepository
+	Reservation   ReservationRepository
+	StockMovement StockMovementRepository
+	Variant       VariantRepository
+	Suggestion    SuggestionRepository
+	Synonym       SynonymRepository
+	StopWord      StopWordRepository
+	Revision      RevisionRepository
+	Job           JobRepository
 }
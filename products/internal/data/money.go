@@ -0,0 +1,133 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// moneyScale is the number of minor units in one unit of currency. It matches the
+// three decimal places of the NUMERIC(10, 3) price column.
+const moneyScale = 1000
+
+// MaxMoney is the largest amount that fits in a NUMERIC(10, 3) column, 9999999.999.
+const MaxMoney Money = 9_999_999_999
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an exact decimal amount stored as an integer number of thousandths. It is
+// written to JSON as a string, e.g. "19.99", so that clients never have to round trip
+// it through a binary floating point number.
+type Money int64
+
+// ParseMoney parses a decimal string such as "19.99" or "-0.125". At most three
+// decimal places are accepted. Amounts too large for an int64 saturate rather than
+// fail, which leaves range checking to validation.
+func ParseMoney(s string) (Money, error) {
+	units, frac, hasFrac := strings.Cut(s, ".")
+
+	negative := strings.HasPrefix(units, "-")
+	units = strings.TrimPrefix(units, "-")
+	if units == "" || (hasFrac && frac == "") || len(frac) > 3 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	digits := units + frac + strings.Repeat("0", 3-len(frac))
+	var amount int64
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+		}
+		if amount > (math.MaxInt64-9)/10 {
+			amount = math.MaxInt64
+			continue
+		}
+		amount = amount*10 + int64(r-'0')
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return Money(amount), nil
+}
+
+// String formats the amount with at least two decimal places, e.g. "19.99" or
+// "0.125".
+func (m Money) String() string {
+	s := m.fixed()
+	if strings.HasSuffix(s, "0") {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// fixed formats the amount with exactly three decimal places, the form used for the
+// database.
+func (m Money) fixed() string {
+	amount := int64(m)
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%03d", sign, amount/moneyScale, amount%moneyScale)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON accepts the amount either as a string or as a JSON number. A number
+// is parsed from its literal text, never through a float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = amount
+	return nil
+}
+
+// Scan reads a NUMERIC value, which the driver hands over in its text form.
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = Money(v * moneyScale)
+		return nil
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidMoney, src)
+	}
+
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = amount
+	return nil
+}
+
+// Value writes the amount as exact decimal text so that PostgreSQL reads it as a
+// NUMERIC without any rounding.
+func (m Money) Value() (driver.Value, error) {
+	return m.fixed(), nil
+}
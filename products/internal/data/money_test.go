@@ -0,0 +1,91 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input    string
+		expected Money
+	}{
+		{"19.99", 19_990},
+		{"0.125", 125},
+		{"10", 10_000},
+		{"-3.5", -3_500},
+		{"9999999.999", MaxMoney},
+		{"99999999999999999999", math.MaxInt64},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			amount, err := ParseMoney(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, amount)
+		})
+	}
+
+	for _, input := range []string{"", "abc", "1.2345", "1.", ".5", "-", "1e3", "+1", "1.2.3"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			_, err := ParseMoney(input)
+			assert.ErrorIs(t, err, ErrInvalidMoney)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "19.99", Money(19_990).String())
+	assert.Equal(t, "10.00", Money(10_000).String())
+	assert.Equal(t, "0.125", Money(125).String())
+	assert.Equal(t, "0.05", Money(50).String())
+	assert.Equal(t, "-3.50", Money(-3_500).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	t.Parallel()
+
+	js, err := json.Marshal(struct {
+		Price Money `json:"price"`
+	}{19_990})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"price":"19.99"}`, string(js))
+
+	var dst struct {
+		Price Money `json:"price"`
+	}
+	for _, input := range []string{`{"price":"19.99"}`, `{"price":19.99}`} {
+		assert.NoError(t, json.Unmarshal([]byte(input), &dst))
+		assert.Equal(t, Money(19_990), dst.Price)
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"price":null}`), &dst))
+	assert.Equal(t, Money(19_990), dst.Price)
+
+	err = json.Unmarshal([]byte(`{"price":"cheap"}`), &dst)
+	assert.ErrorIs(t, err, ErrInvalidMoney)
+}
+
+func TestMoney_ScanValue(t *testing.T) {
+	t.Parallel()
+
+	var amount Money
+	assert.NoError(t, amount.Scan([]byte("19.990")))
+	assert.Equal(t, Money(19_990), amount)
+
+	assert.NoError(t, amount.Scan(int64(3)))
+	assert.Equal(t, Money(3_000), amount)
+
+	assert.ErrorIs(t, amount.Scan(19.99), ErrInvalidMoney)
+
+	value, err := Money(19_990).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "19.990", value)
+}
@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// batchInsertRows is the number of products a single INSERT statement of InsertBatch
+// writes. Each row takes nine parameters, well within the 65535 PostgreSQL allows.
+const batchInsertRows = 1000
+
+// InsertBatch inserts the products in one transaction, using multi-row inserts. It
+// returns an error for every product that cannot be inserted and nil for the others:
+// ErrInvalidCategoryId if its category does not exist, ErrDuplicateExternalID if its
+// external id is taken or used by an earlier product of the batch, ErrDuplicateSlug if
+// its requested slug is taken. Unless partial is set, a single such error leaves every
+// product out. Any other error fails the whole batch.
+func (p *ProductModel) InsertBatch(
+	ctx context.Context,
+	products []*Product,
+	partial bool,
+) ([]error, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	errs, err := checkCategories(ctx, tx, products)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkExternalIDs(ctx, tx, products, errs); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(products))
+	slugs := make([]string, len(products))
+	for i, product := range products {
+		names[i], slugs[i] = product.Name, product.Slug
+	}
+
+	slugs, err = productSlugs.newSlugs(ctx, tx, names, slugs, errs)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := slices.ContainsFunc(errs, func(err error) bool { return err != nil })
+	if failed && !partial {
+		return errs, nil
+	}
+
+	inserts := []*Product{}
+	for i, product := range products {
+		if errs[i] == nil {
+			product.Slug = slugs[i]
+			inserts = append(inserts, product)
+		}
+	}
+
+	for chunk := range slices.Chunk(inserts, batchInsertRows) {
+		if err = insertProductRows(ctx, tx, chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	return errs, tx.Commit()
+}
+
+// checkCategories returns an ErrInvalidCategoryId for each product whose category does
+// not exist or is deleted. The categories that do are locked until the transaction
+// ends, so that they cannot be purged before the products are inserted.
+func checkCategories(ctx context.Context, tx *sql.Tx, products []*Product) ([]error, error) {
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		ids[i] = int64(product.CategoryID)
+	}
+
+	query := `SELECT id FROM categories WHERE id = ANY($1) AND deleted_at IS NULL FOR KEY SHARE`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exists := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		exists[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(products))
+	for i, product := range products {
+		if !exists[int64(product.CategoryID)] {
+			errs[i] = fmt.Errorf(
+				"category_id %d does not exist: %w",
+				product.CategoryID,
+				ErrInvalidCategoryId,
+			)
+		}
+	}
+
+	return errs, nil
+}
+
+// checkExternalIDs sets an ErrDuplicateExternalID for each product that has not failed
+// yet and whose external id is taken, or used by an earlier product of the batch. The
+// database is only queried when some product has an external id.
+func checkExternalIDs(ctx context.Context, tx *sql.Tx, products []*Product, errs []error) error {
+	externalIDs := []string{}
+	for i, product := range products {
+		if errs[i] == nil && product.ExternalID != nil {
+			externalIDs = append(externalIDs, *product.ExternalID)
+		}
+	}
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	query := `SELECT external_id FROM products WHERE external_id = ANY($1)`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(externalIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var externalID string
+		if err := rows.Scan(&externalID); err != nil {
+			return err
+		}
+		taken[externalID] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i, product := range products {
+		if errs[i] != nil || product.ExternalID == nil {
+			continue
+		}
+		if taken[*product.ExternalID] {
+			errs[i] = fmt.Errorf("external_id %q: %w", *product.ExternalID, ErrDuplicateExternalID)
+			continue
+		}
+		taken[*product.ExternalID] = true
+	}
+
+	return nil
+}
+
+// insertProductRows inserts the products with a single statement. The rows it returns
+// are matched to the products by their slugs, which are unique.
+func insertProductRows(ctx context.Context, tx *sql.Tx, products []*Product) error {
+	builder := psql.Insert("products").
+		Columns(
+			"name",
+			"slug",
+			"external_id",
+			"category_id",
+			"description",
+			"price",
+			"currency",
+			"quantity",
+			"attributes")
+
+	bySlug := make(map[string]*Product, len(products))
+	for _, product := range products {
+		builder = builder.Values(
+			product.Name,
+			product.Slug,
+			product.ExternalID,
+			product.CategoryID,
+			product.Description,
+			product.Price,
+			product.Currency,
+			product.Quantity,
+			product.Attributes)
+		bySlug[product.Slug] = product
+	}
+
+	query, args, _ := builder.Suffix("RETURNING id, slug, created_at, version").ToSql()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        int
+			slug      string
+			createdAt time.Time
+			version   int
+		)
+		if err := rows.Scan(&id, &slug, &createdAt, &version); err != nil {
+			return err
+		}
+
+		if product, ok := bySlug[slug]; ok {
+			product.ID, product.CreatedAt, product.Version = id, createdAt, version
+		}
+	}
+
+	return rows.Err()
+}
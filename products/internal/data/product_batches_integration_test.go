@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_Integration_InsertBatch(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	categoryID, _ := seedProducts(t, db, []*Product{{Name: "Blue Shoe"}})
+
+	newProducts := func() []*Product {
+		return []*Product{
+			{Name: "Blue Shoe", CategoryID: int(categoryID), Currency: "USD"},
+			{Name: "Blue Shoe", CategoryID: int(categoryID), Currency: "USD"},
+			{Name: "Red Shoe", CategoryID: int(categoryID) + 1000, Currency: "USD"},
+		}
+	}
+
+	products := newProducts()
+	errs, err := productModel.InsertBatch(ctx, products, false)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(errs[2], ErrInvalidCategoryId))
+	assert.Equal(t, 0, products[0].ID)
+
+	products = newProducts()
+	errs, err = productModel.InsertBatch(ctx, products, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, errs[:2])
+	assert.Equal(t, "blue-shoe-2", products[0].Slug)
+	assert.Equal(t, "blue-shoe-3", products[1].Slug)
+
+	for _, product := range products[:2] {
+		stored, err := productModel.GetByID(ctx, int64(product.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, product.Slug, stored.Slug)
+	}
+
+	// A deleted category is as invalid as one that does not exist.
+	categoryModel := NewCategoryModel(db)
+	deleted := Category{Name: "Deleted Category", Description: "Deleted by tests"}
+	assert.NoError(t, categoryModel.Insert(ctx, &deleted))
+	assert.NoError(t, categoryModel.Delete(ctx, deleted.ID))
+
+	products = []*Product{{Name: "Green Shoe", CategoryID: int(deleted.ID), Currency: "USD"}}
+	errs, err = productModel.InsertBatch(ctx, products, true)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(errs[0], ErrInvalidCategoryId))
+	assert.Equal(t, 0, products[0].ID)
+}
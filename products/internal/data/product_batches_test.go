@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_InsertBatch(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := ProductModel{db: db}
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	categoryQuery := regexp.QuoteMeta(
+		`SELECT id FROM categories WHERE id = ANY($1) AND deleted_at IS NULL FOR KEY SHARE`,
+	)
+	slugQuery := regexp.QuoteMeta(`
+		WITH bases AS (
+			SELECT DISTINCT * FROM unnest($1::text[], $2::text[]) AS b(base, pattern)
+		)
+		SELECT slug FROM products, bases WHERE slug = base OR slug LIKE pattern
+		UNION
+		SELECT slug FROM product_slug_redirects, bases WHERE slug = base OR slug LIKE pattern
+	`)
+	insertQuery := regexp.QuoteMeta(`
+		INSERT INTO products (name,slug,external_id,category_id,description,price,currency,quantity,attributes)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9),($10,$11,$12,$13,$14,$15,$16,$17,$18)
+		RETURNING id, slug, created_at, version
+	`)
+
+	newProducts := func() []*Product {
+		return []*Product{
+			{Name: "Blue Shoe", CategoryID: 1, Price: 10_990, Currency: "USD"},
+			{Name: "Blue Shoe", CategoryID: 1, Price: 12_990, Currency: "USD"},
+			{Name: "Red Shoe", Slug: "shoe", CategoryID: 9, Currency: "USD"},
+		}
+	}
+
+	expectBatch := func(categories []int64, taken ...string) {
+		categoryRows := sqlMock.NewRows([]string{"id"})
+		for _, id := range categories {
+			categoryRows.AddRow(id)
+		}
+		sqlMock.ExpectQuery(categoryQuery).
+			WithArgs(pq.Array([]int64{1, 1, 9})).
+			WillReturnRows(categoryRows)
+
+		sqlMock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)).
+			WithArgs("products.slug").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		slugRows := sqlMock.NewRows([]string{"slug"})
+		for _, slug := range taken {
+			slugRows.AddRow(slug)
+		}
+		sqlMock.ExpectQuery(slugQuery).
+			WithArgs(
+				pq.Array([]string{"blue-shoe", "blue-shoe", "shoe"}),
+				pq.Array([]string{"blue-shoe-%", "blue-shoe-%", "shoe-%"}),
+			).
+			WillReturnRows(slugRows)
+	}
+
+	t.Run("partial batch inserts the products that can be", func(t *testing.T) {
+		products := newProducts()
+
+		expectAudit(sqlMock, "")
+		expectBatch([]int64{1}, "blue-shoe")
+		sqlMock.ExpectQuery(insertQuery).
+			WithArgs(
+				"Blue Shoe", "blue-shoe-2", nil, 1, "", Money(10_990), "USD", 0, []byte("{}"),
+				"Blue Shoe", "blue-shoe-3", nil, 1, "", Money(12_990), "USD", 0, []byte("{}"),
+			).
+			WillReturnRows(sqlMock.NewRows([]string{"id", "slug", "created_at", "version"}).
+				AddRow(8, "blue-shoe-3", createdAt, 1).
+				AddRow(7, "blue-shoe-2", createdAt, 1))
+		sqlMock.ExpectCommit()
+
+		errs, err := productModel.InsertBatch(ctx, products, true)
+		assert.NoError(t, err)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.True(t, errors.Is(errs[2], ErrInvalidCategoryId))
+		assert.Equal(t, "category_id 9 does not exist: invalid category_id", errs[2].Error())
+
+		assert.Equal(t, 7, products[0].ID)
+		assert.Equal(t, "blue-shoe-2", products[0].Slug)
+		assert.Equal(t, 8, products[1].ID)
+		assert.Equal(t, "blue-shoe-3", products[1].Slug)
+		assert.Equal(t, 0, products[2].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("all or nothing batch inserts nothing on failure", func(t *testing.T) {
+		products := newProducts()
+
+		expectAudit(sqlMock, "")
+		expectBatch([]int64{1, 9}, "shoe")
+		sqlMock.ExpectRollback()
+
+		errs, err := productModel.InsertBatch(ctx, products, false)
+		assert.NoError(t, err)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.True(t, errors.Is(errs[2], ErrDuplicateSlug))
+		assert.Equal(t, 0, products[0].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("external ids must be unused", func(t *testing.T) {
+		erp1, erp2 := "ERP-1", "ERP-2"
+		products := newProducts()
+		products[0].ExternalID = &erp1
+		products[1].ExternalID = &erp1
+		products[2].ExternalID = &erp2
+
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(categoryQuery).
+			WithArgs(pq.Array([]int64{1, 1, 9})).
+			WillReturnRows(sqlMock.NewRows([]string{"id"}).AddRow(1).AddRow(9))
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT external_id FROM products WHERE external_id = ANY($1)`)).
+			WithArgs(pq.Array([]string{"ERP-1", "ERP-1", "ERP-2"})).
+			WillReturnRows(sqlMock.NewRows([]string{"external_id"}).AddRow("ERP-2"))
+		sqlMock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectQuery("SELECT slug").WillReturnRows(sqlMock.NewRows([]string{"slug"}))
+		sqlMock.ExpectRollback()
+
+		errs, err := productModel.InsertBatch(ctx, products, false)
+		assert.NoError(t, err)
+		assert.NoError(t, errs[0])
+		assert.True(t, errors.Is(errs[1], ErrDuplicateExternalID))
+		assert.Equal(t, `external_id "ERP-2": external_id already exists`, errs[2].Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("insert error", func(t *testing.T) {
+		products := newProducts()[:2]
+
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(categoryQuery).
+			WithArgs(pq.Array([]int64{1, 1})).
+			WillReturnRows(sqlMock.NewRows([]string{"id"}).AddRow(1))
+		sqlMock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectQuery("SELECT slug").WillReturnRows(sqlMock.NewRows([]string{"slug"}))
+		sqlMock.ExpectQuery(insertQuery).WillReturnError(errors.New("insert error"))
+		sqlMock.ExpectRollback()
+
+		errs, err := productModel.InsertBatch(ctx, products, false)
+		assert.Nil(t, errs)
+		assert.Equal(t, "insert error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
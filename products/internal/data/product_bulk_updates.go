@@ -0,0 +1,252 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// The operations of a bulk update.
+const (
+	BulkSetPrice      = "set_price"
+	BulkAdjustPrice   = "adjust_price"
+	BulkSetQuantity   = "set_quantity"
+	BulkSetAttributes = "set_attributes"
+)
+
+// The directions an adjusted price is rounded in, to a multiple of the increment.
+const (
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// bulkPreviewSize is the number of changes a dry run of a bulk update returns.
+const bulkPreviewSize = 20
+
+// BulkOperation is a change made to every product matching a filter. The operation
+// selects the fields it reads: Price for set_price; Percent, or Amount when Percent is
+// nil, for adjust_price, the adjusted price being rounded to a multiple of RoundTo;
+// Quantity for set_quantity; Attributes for set_attributes, which are merged into
+// those of the products.
+type BulkOperation struct {
+	Operation  string
+	Price      Money
+	Percent    *float64
+	Amount     Money
+	RoundTo    Money
+	Rounding   string
+	Quantity   int
+	Attributes Attributes
+}
+
+// BulkChange is the change a bulk update makes to a product: the value of the field
+// it writes before and after, and the version of the product after.
+type BulkChange struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Before  any    `json:"before"`
+	After   any    `json:"after"`
+}
+
+// BulkUpdateResult counts the products a bulk update matched, and those it changed.
+// The changes are only kept by a dry run.
+type BulkUpdateResult struct {
+	Matched int          `json:"matched"`
+	Updated int          `json:"updated"`
+	Preview []BulkChange `json:"preview,omitempty"`
+}
+
+// BulkUpdate applies the operation to every product matching the filters, in one
+// transaction. Only the products whose field changes are written, and have their
+// version bumped. A dry run makes the same changes and rolls them back, returning the
+// first of them in id order. It returns ErrMoneyOutOfRange if a price would fall
+// below 0 or exceed MaxMoney, ErrNegativeQuantity if a quantity would fall below 0 and
+// ErrInsufficientStock if it would fall below the units reserved, in which case no
+// product is written. The job step of ctx, if any, is saved with the products and the
+// result, so that a job claimed again after the commit does not apply it twice.
+func (p *ProductModel) BulkUpdate(
+	ctx context.Context,
+	filters Filters,
+	op BulkOperation,
+	dryRun bool,
+) (*BulkUpdateResult, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	// Lock the matching products, so that none is written between the count and the
+	// update.
+	query, args, _ := p.buildFilters(psql.Select("id").From("products"), filters).
+		OrderBy("id").
+		Suffix("FOR UPDATE").
+		ToSql()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &BulkUpdateResult{Matched: len(ids), Preview: []BulkChange{}}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	changes, err := op.apply(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Updated = len(changes)
+	if dryRun {
+		slices.SortFunc(changes, func(a, b BulkChange) int { return a.ID - b.ID })
+		result.Preview = changes[:min(len(changes), bulkPreviewSize)]
+		return result, nil
+	}
+
+	if step := JobStepFromContext(ctx); step != nil {
+		step.Result = result
+		if err = saveJobStep(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// apply writes the operation to the products whose field it changes, among those of
+// ids, and returns the changes. The products are joined to themselves as old, which
+// holds the values from before the update.
+func (op BulkOperation) apply(ctx context.Context, tx *sql.Tx, ids []int64) ([]BulkChange, error) {
+	column, value, args := op.expression()
+
+	query, args, _ := psql.Update("products").
+		Set(column, sq.Expr(value, args...)).
+		Set("version", sq.Expr("old.version + 1")).
+		From("products AS old").
+		Where("products.id = old.id").
+		Where("products.id = ANY(?)", pq.Array(ids)).
+		Where(fmt.Sprintf("old.%s <> (%s)", column, value), args...).
+		Suffix(fmt.Sprintf(
+			"RETURNING products.id, products.name, products.version, old.%[1]s, products.%[1]s",
+			column,
+		)).
+		ToSql()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, bulkWriteError(err)
+	}
+	defer rows.Close()
+
+	changes := []BulkChange{}
+	for rows.Next() {
+		change := BulkChange{Before: op.newValue(), After: op.newValue()}
+		err := rows.Scan(&change.ID, &change.Name, &change.Version, change.Before, change.After)
+		if err != nil {
+			return nil, err
+		}
+
+		if price, ok := change.After.(*Money); ok && *price < 0 {
+			return nil, fmt.Errorf(
+				"price %s of product %d: %w",
+				*price,
+				change.ID,
+				ErrMoneyOutOfRange,
+			)
+		}
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, bulkWriteError(err)
+	}
+
+	return changes, nil
+}
+
+// expression returns the column the operation writes, and the SQL expression of its
+// new value in terms of the old one, with its arguments.
+func (op BulkOperation) expression() (string, string, []any) {
+	switch op.Operation {
+	case BulkSetPrice:
+		return "price", "?", []any{op.Price}
+	case BulkAdjustPrice:
+		value, args := "old.price + ?", []any{op.Amount}
+		if op.Percent != nil {
+			value, args = "old.price * (1 + ?::numeric / 100)", []any{*op.Percent}
+		}
+
+		round := "round"
+		switch op.Rounding {
+		case RoundUp:
+			round = "ceil"
+		case RoundDown:
+			round = "floor"
+		}
+		value = fmt.Sprintf("%s((%s) / ?) * ?", round, value)
+		return "price", value, append(args, op.RoundTo, op.RoundTo)
+	case BulkSetQuantity:
+		return "quantity", "?", []any{op.Quantity}
+	default:
+		return "attributes", "old.attributes || ?", []any{op.Attributes}
+	}
+}
+
+// newValue returns a destination for a value of the column the operation writes.
+func (op BulkOperation) newValue() any {
+	switch op.Operation {
+	case BulkSetPrice, BulkAdjustPrice:
+		return new(Money)
+	case BulkSetQuantity:
+		return new(int)
+	default:
+		return new(Attributes)
+	}
+}
+
+// bulkWriteError translates the constraint violations of a bulk update into the
+// errors of this package. Other errors are returned as they are.
+func bulkWriteError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case pqErr.Code == ErrNumericValueOutOfRange:
+		return fmt.Errorf("price: %w", ErrMoneyOutOfRange)
+	case pqErr.Code == ErrCheckViolation && pqErr.Constraint == "products_reserved_check":
+		// The quantity was lowered below the units currently held.
+		return fmt.Errorf("quantity: %w", ErrInsufficientStock)
+	case pqErr.Code == ErrCheckViolation && pqErr.Constraint == "products_quantity_check":
+		return fmt.Errorf("quantity: %w", ErrNegativeQuantity)
+	default:
+		return err
+	}
+}
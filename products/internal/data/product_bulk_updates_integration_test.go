@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_Integration_BulkUpdate(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	categoryID, ids := seedProducts(t, db, []*Product{
+		{Name: "Blue Shoe", Price: 10_000, Quantity: 4},
+		{Name: "Red Shoe", Price: 19_990, Quantity: 2},
+		{Name: "Green Shoe", Price: 11_000, Quantity: 0},
+	})
+	filters := Filters{
+		Conditions: []Condition{{Field: "category_id", Op: OpEq, Value: []int64{categoryID}}},
+	}
+
+	percent := 10.0
+	op := BulkOperation{
+		Operation: BulkAdjustPrice,
+		Percent:   &percent,
+		RoundTo:   1_000,
+		Rounding:  RoundNearest,
+	}
+
+	// A dry run previews the changes without writing them.
+	result, err := productModel.BulkUpdate(ctx, filters, op, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Matched)
+	assert.Equal(t, 3, result.Updated)
+	before, after := Money(10_000), Money(11_000)
+	assert.Equal(t, BulkChange{
+		ID:      int(ids[0]),
+		Name:    "Blue Shoe",
+		Version: 2,
+		Before:  &before,
+		After:   &after,
+	}, result.Preview[0])
+
+	stored, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, Money(10_000), stored.Price)
+	assert.Equal(t, 1, stored.Version)
+
+	result, err = productModel.BulkUpdate(ctx, filters, op, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Updated)
+
+	for i, price := range []Money{11_000, 22_000, 12_000} {
+		stored, err := productModel.GetByID(ctx, ids[i])
+		assert.NoError(t, err)
+		assert.Equal(t, price, stored.Price)
+		assert.Equal(t, 2, stored.Version)
+	}
+
+	// Only the products whose quantity changes are written.
+	result, err = productModel.BulkUpdate(
+		ctx,
+		filters,
+		BulkOperation{Operation: BulkSetQuantity, Quantity: 2},
+		false,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Updated)
+
+	stored, err = productModel.GetByID(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stored.Version)
+
+	// A price that would fall below 0 writes nothing.
+	_, err = productModel.BulkUpdate(
+		ctx,
+		filters,
+		BulkOperation{Operation: BulkAdjustPrice, Amount: -11_500, RoundTo: 10},
+		false,
+	)
+	assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+
+	stored, err = productModel.GetByID(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, Money(22_000), stored.Price)
+}
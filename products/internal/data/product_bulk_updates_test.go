@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_BulkUpdate(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := ProductModel{db: db}
+	ctx := context.Background()
+	filters := Filters{
+		Conditions: []Condition{{Field: "category_id", Op: OpEq, Value: []int64{3}}},
+	}
+
+	expectLock := func(ids ...int64) {
+		rows := sqlMock.NewRows([]string{"id"})
+		for _, id := range ids {
+			rows.AddRow(id)
+		}
+		sqlMock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT id FROM products WHERE deleted_at IS NULL AND (category_id IN ($1)) ORDER BY id FOR UPDATE`,
+		)).
+			WithArgs(int64(3)).
+			WillReturnRows(rows)
+	}
+	updateQuery := func(column, value string) string {
+		return regexp.QuoteMeta(`
+			UPDATE products
+			SET ` + column + ` = ` + value + `, version = old.version + 1
+			FROM products AS old
+			WHERE products.id = old.id AND products.id = ANY($`)
+	}
+	changeColumns := []string{"id", "name", "version", "before", "after"}
+
+	t.Run("adjusts prices by a percentage and commits", func(t *testing.T) {
+		percent := 10.0
+		op := BulkOperation{
+			Operation: BulkAdjustPrice,
+			Percent:   &percent,
+			RoundTo:   10,
+			Rounding:  RoundUp,
+		}
+
+		expectAudit(sqlMock, "alice")
+		expectLock(4, 7)
+		sqlMock.ExpectQuery(updateQuery(
+			"price",
+			"ceil((old.price * (1 + $1::numeric / 100)) / $2) * $3",
+		)).
+			WithArgs(10.0, Money(10), Money(10), pq.Array([]int64{4, 7}), 10.0, Money(10), Money(10)).
+			WillReturnRows(sqlMock.NewRows(changeColumns).AddRow(7, "Red Shoe", 3, "10.000", "11.000"))
+		sqlMock.ExpectCommit()
+
+		result, err := productModel.BulkUpdate(ContextWithActor(ctx, "alice"), filters, op, false)
+		assert.NoError(t, err)
+		assert.Equal(t, &BulkUpdateResult{Matched: 2, Updated: 1, Preview: []BulkChange{}}, result)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("saves the job step with the products", func(t *testing.T) {
+		stepCtx := ContextWithJobStep(ctx, &JobStep{Job: &Job{ID: 12, Attempts: 2}, Progress: 1})
+		stepQuery := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET progress = $1, result = $2
+			WHERE id = $3 AND attempts = $4 AND status = 'running'
+		`)
+
+		expectAudit(sqlMock, "")
+		expectLock(4, 7)
+		sqlMock.ExpectQuery(updateQuery("quantity", "$1")).
+			WithArgs(5, pq.Array([]int64{4, 7}), 5).
+			WillReturnRows(sqlMock.NewRows(changeColumns).AddRow(7, "Red Shoe", 3, 2, 5))
+		sqlMock.ExpectExec(stepQuery).
+			WithArgs(1, []byte(`{"matched":2,"updated":1}`), 12, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		result, err := productModel.BulkUpdate(
+			stepCtx,
+			filters,
+			BulkOperation{Operation: BulkSetQuantity, Quantity: 5},
+			false,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Updated)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("writes nothing when the job is claimed by another worker", func(t *testing.T) {
+		stepCtx := ContextWithJobStep(ctx, &JobStep{Job: &Job{ID: 12, Attempts: 2}, Progress: 1})
+
+		expectAudit(sqlMock, "")
+		expectLock(4)
+		sqlMock.ExpectQuery(updateQuery("quantity", "$1")).
+			WithArgs(5, pq.Array([]int64{4}), 5).
+			WillReturnRows(sqlMock.NewRows(changeColumns).AddRow(4, "Blue Shoe", 3, 2, 5))
+		sqlMock.ExpectExec("UPDATE jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectRollback()
+
+		_, err := productModel.BulkUpdate(
+			stepCtx,
+			filters,
+			BulkOperation{Operation: BulkSetQuantity, Quantity: 5},
+			false,
+		)
+		assert.ErrorIs(t, err, ErrJobLeaseLost)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("dry run previews the changes and rolls back", func(t *testing.T) {
+		op := BulkOperation{Operation: BulkSetQuantity, Quantity: 5}
+
+		expectAudit(sqlMock, "")
+		expectLock(4, 7, 9)
+		sqlMock.ExpectQuery(updateQuery("quantity", "$1")).
+			WithArgs(5, pq.Array([]int64{4, 7, 9}), 5).
+			WillReturnRows(sqlMock.NewRows(changeColumns).
+				AddRow(9, "Green Shoe", 2, 0, 5).
+				AddRow(4, "Blue Shoe", 6, 8, 5))
+		sqlMock.ExpectRollback()
+
+		result, err := productModel.BulkUpdate(ctx, filters, op, true)
+		assert.NoError(t, err)
+
+		before, after := []int{8, 0}, 5
+		assert.Equal(t, &BulkUpdateResult{
+			Matched: 3,
+			Updated: 2,
+			Preview: []BulkChange{
+				{ID: 4, Name: "Blue Shoe", Version: 6, Before: &before[0], After: &after},
+				{ID: 9, Name: "Green Shoe", Version: 2, Before: &before[1], After: &after},
+			},
+		}, result)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("merges attributes", func(t *testing.T) {
+		op := BulkOperation{Operation: BulkSetAttributes, Attributes: Attributes{"color": "red"}}
+
+		expectAudit(sqlMock, "")
+		expectLock(4)
+		sqlMock.ExpectQuery(updateQuery("attributes", "old.attributes || $1")).
+			WithArgs([]byte(`{"color":"red"}`), pq.Array([]int64{4}), []byte(`{"color":"red"}`)).
+			WillReturnRows(sqlMock.NewRows(changeColumns).
+				AddRow(4, "Blue Shoe", 2, []byte(`{"size":"9"}`), []byte(`{"color":"red","size":"9"}`)))
+		sqlMock.ExpectRollback()
+
+		result, err := productModel.BulkUpdate(ctx, filters, op, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []BulkChange{{
+			ID:      4,
+			Name:    "Blue Shoe",
+			Version: 2,
+			Before:  &Attributes{"size": "9"},
+			After:   &Attributes{"color": "red", "size": "9"},
+		}}, result.Preview)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("writes nothing when no product matches", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectLock()
+		sqlMock.ExpectRollback()
+
+		result, err := productModel.BulkUpdate(
+			ctx,
+			filters,
+			BulkOperation{Operation: BulkSetPrice, Price: 5_000},
+			false,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, &BulkUpdateResult{Preview: []BulkChange{}}, result)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("prices must not fall below 0", func(t *testing.T) {
+		op := BulkOperation{Operation: BulkAdjustPrice, Amount: -5_000, RoundTo: 10}
+
+		expectAudit(sqlMock, "")
+		expectLock(4)
+		sqlMock.ExpectQuery(updateQuery("price", "round((old.price + $1) / $2) * $3")).
+			WillReturnRows(sqlMock.NewRows(changeColumns).AddRow(4, "Blue Shoe", 2, "3.000", "-2.000"))
+		sqlMock.ExpectRollback()
+
+		_, err := productModel.BulkUpdate(ctx, filters, op, false)
+		assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+		assert.Equal(t, "price -2.00 of product 4: amount out of range", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("maps constraint violations", func(t *testing.T) {
+		currencyErr := &pq.Error{Code: ErrCheckViolation, Constraint: "products_currency_check"}
+		testCases := []struct {
+			err      *pq.Error
+			expected error
+		}{
+			{err: &pq.Error{Code: ErrNumericValueOutOfRange}, expected: ErrMoneyOutOfRange},
+			{
+				err:      &pq.Error{Code: ErrCheckViolation, Constraint: "products_reserved_check"},
+				expected: ErrInsufficientStock,
+			},
+			{
+				err:      &pq.Error{Code: ErrCheckViolation, Constraint: "products_quantity_check"},
+				expected: ErrNegativeQuantity,
+			},
+			{err: currencyErr, expected: currencyErr},
+		}
+
+		for _, tc := range testCases {
+			expectAudit(sqlMock, "")
+			expectLock(4)
+			sqlMock.ExpectQuery("UPDATE products").WillReturnError(tc.err)
+			sqlMock.ExpectRollback()
+
+			_, err := productModel.BulkUpdate(
+				ctx,
+				filters,
+				BulkOperation{Operation: BulkSetQuantity},
+				false,
+			)
+			assert.True(t, errors.Is(err, tc.expected))
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		}
+	})
+}
@@ -3,8 +3,10 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -12,85 +14,220 @@ import (
 )
 
 type Product struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	CategoryID  int       `json:"category_id"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Quantity    int       `json:"quantity"`
-	Version     int       `json:"version"`
-	CreatedAt   time.Time `json:"-"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	ExternalID  *string    `json:"external_id,omitempty"`
+	CategoryID  int        `json:"category_id"`
+	Description string     `json:"description"`
+	Price       Money      `json:"price"`
+	Currency    string     `json:"currency"`
+	Quantity    int        `json:"quantity"`
+	Attributes  Attributes `json:"attributes,omitempty"`
+	Version     int        `json:"version"`
+	CreatedAt   time.Time  `json:"-"`
+
+	// UpdatedAt is when the version of the product last changed. It is only read by
+	// Stream.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+
+	// DeletedAt is set once the product is deleted. Deleted products are only read by
+	// GetAll, Search and GetFacets when filters.IncludeDeleted is set.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Variants is only loaded by GetByIDWithVariants.
+	Variants []*Variant `json:"variants,omitempty"`
 }
 
+// psql builds statements with the numbered placeholders PostgreSQL expects.
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
 type ProductModel struct {
 	db *sql.DB
 }
 
 type ProductRepository interface {
 	Insert(ctx context.Context, product *Product) error
+	InsertBatch(ctx context.Context, products []*Product, partial bool) ([]error, error)
+	GetByID(ctx context.Context, id int64) (*Product, error)
+	GetByExternalID(ctx context.Context, externalID string) (*Product, error)
+	GetByIDWithVariants(ctx context.Context, id int64) (*Product, error)
+	LookupSlug(ctx context.Context, slug string) (int64, string, error)
+	GetAll(ctx context.Context, filters Filters) ([]*Product, Metadata, error)
+	Stream(ctx context.Context, filters Filters, fn func(*Product) error) error
+	Search(ctx context.Context, q string, filters Filters) ([]*SearchResult, Metadata, error)
+	GetFacets(ctx context.Context, filters Filters, req FacetRequest) (*Facets, error)
+	Update(ctx context.Context, product *Product) error
+	BulkUpdate(
+		ctx context.Context,
+		filters Filters,
+		op BulkOperation,
+		dryRun bool,
+	) (*BulkUpdateResult, error)
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) (*Product, error)
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 func NewProductModel(db *sql.DB) *ProductModel {
 	return &ProductModel{db: db}
 }
 
+// Insert adds a product. Its slug is derived from the name unless product.Slug is set,
+// in which case it returns ErrDuplicateSlug if the slug is taken. The job step of ctx,
+// if any, is saved with the product.
 func (p *ProductModel) Insert(ctx context.Context, product *Product) error {
-	query, args, _ := sq.Insert("products").
-		Columns("name", "category_id", "description", "price", "quantity").
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = saveJobStep(ctx, tx); err != nil {
+		return err
+	}
+
+	product.Slug, err = productSlugs.setSlug(ctx, tx, 0, product.Name, product.Slug)
+	if err != nil {
+		return err
+	}
+
+	query, args, _ := psql.Insert("products").
+		Columns(
+			"name",
+			"slug",
+			"external_id",
+			"category_id",
+			"description",
+			"price",
+			"currency",
+			"quantity",
+			"attributes").
 		Values(
 			product.Name,
+			product.Slug,
+			product.ExternalID,
 			product.CategoryID,
 			product.Description,
 			product.Price,
-			product.Quantity).
+			product.Currency,
+			product.Quantity,
+			product.Attributes).
 		Suffix("RETURNING id, created_at, version").
 		ToSql()
-	err := p.db.QueryRowContext(ctx, query, args...).Scan(
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&product.ID,
 		&product.CreatedAt,
 		&product.Version,
 	)
-
 	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) && pqErr.Code == ErrForeignKeyViolation {
-			return fmt.Errorf(
-				"category_id %d does not exist: %w",
-				product.CategoryID,
-				ErrInvalidCategoryId,
-			)
-		}
-		return err
+		return productWriteError(err, product)
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (p *ProductModel) GetByID(ctx context.Context, id int64) (*Product, error) {
-	query, _, _ := sq.Select(
+	return p.getBy(ctx, sq.Eq{"id": id})
+}
+
+// GetByExternalID returns the product with the given external id, the id it is known
+// by in the system it was imported from.
+func (p *ProductModel) GetByExternalID(ctx context.Context, externalID string) (*Product, error) {
+	return p.getBy(ctx, sq.Eq{"external_id": externalID})
+}
+
+// getBy returns the product that is not deleted and matches the predicate, which must
+// be on a unique column.
+func (p *ProductModel) getBy(ctx context.Context, pred sq.Eq) (*Product, error) {
+	query, args, _ := psql.Select(
 		"id",
 		"name",
+		"slug",
+		"external_id",
 		"category_id",
 		"description",
 		"price",
+		"currency",
 		"quantity",
 		"created_at",
 		"version",
+		"attributes",
 	).
 		From("products").
-		Where(sq.Eq{"id": id}).
+		Where(pred).
+		Where("deleted_at IS NULL").
 		ToSql()
 
 	var product Product
+	err := p.db.QueryRowContext(ctx, query, args...).Scan(
+		&product.ID,
+		&product.Name,
+		&product.Slug,
+		&product.ExternalID,
+		&product.CategoryID,
+		&product.Description,
+		&product.Price,
+		&product.Currency,
+		&product.Quantity,
+		&product.CreatedAt,
+		&product.Version,
+		&product.Attributes,
+	)
+
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetByIDWithVariants returns the product together with its variants. The variants are
+// aggregated into a JSON array by a subquery so that both are read in one round trip.
+func (p *ProductModel) GetByIDWithVariants(ctx context.Context, id int64) (*Product, error) {
+	query := `
+		SELECT id, name, slug, external_id, category_id, description, price, currency, quantity,
+			created_at, version, attributes,
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'id', v.id,
+					'product_id', v.product_id,
+					'sku', v.sku,
+					'options', v.options,
+					'price', v.price::text,
+					'quantity', v.quantity,
+					'version', v.version
+				) ORDER BY v.id)
+				FROM product_variants v
+				WHERE v.product_id = products.id
+			), '[]')
+		FROM products
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var product Product
+	var variants []byte
 	err := p.db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
 		&product.Name,
+		&product.Slug,
+		&product.ExternalID,
 		&product.CategoryID,
 		&product.Description,
 		&product.Price,
+		&product.Currency,
 		&product.Quantity,
 		&product.CreatedAt,
 		&product.Version,
+		&product.Attributes,
+		&variants,
 	)
 
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
@@ -99,22 +236,51 @@ func (p *ProductModel) GetByID(ctx context.Context, id int64) (*Product, error)
 		return nil, err
 	}
 
+	if err = json.Unmarshal(variants, &product.Variants); err != nil {
+		return nil, err
+	}
+
 	return &product, nil
 }
 
+// LookupSlug returns the id and the current slug of the product that has the slug, or
+// had it before it was renamed. It returns ErrRecordNotFound if there is no such
+// product or it has been deleted.
+func (p *ProductModel) LookupSlug(ctx context.Context, slug string) (int64, string, error) {
+	return productSlugs.lookupSlug(ctx, p.db, slug)
+}
+
+// GetAll returns a page of products matching the filters. The total number of
+// matching records is computed in the same query with a count(*) OVER() window so
+// that it reflects the filters but not the LIMIT and OFFSET.
+//
+// When filters.Cursor is set the page is read with a keyset predicate on the sort
+// columns instead of an OFFSET, and the metadata carries the next and previous
+// cursors rather than page numbers and totals.
 func (p *ProductModel) GetAll(ctx context.Context, filters Filters) ([]*Product, Metadata, error) {
-	builder := sq.Select(
+	builder := psql.Select(
+		filters.totalColumn(),
 		"id",
 		"name",
+		"slug",
+		"external_id",
 		"category_id",
 		"description",
 		"price",
+		"currency",
 		"quantity",
 		"created_at",
 		"version",
+		"attributes",
+		"deleted_at",
 	).From("products")
-
 	builder = p.buildFilters(builder, filters)
+
+	builder, err := ProductFilterSpec.paginate(builder, filters)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	query, args, _ := builder.ToSql()
 	rows, err := p.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -122,103 +288,252 @@ func (p *ProductModel) GetAll(ctx context.Context, filters Filters) ([]*Product,
 	}
 	defer rows.Close()
 
-	products := []*Product{}
-	for rows.Next() {
+	return collectPage(rows, ProductFilterSpec, filters, func() (*Product, []any) {
 		var product Product
-		if err := rows.Scan(
+		return &product, []any{
 			&product.ID,
 			&product.Name,
+			&product.Slug,
+			&product.ExternalID,
 			&product.CategoryID,
 			&product.Description,
 			&product.Price,
+			&product.Currency,
 			&product.Quantity,
 			&product.CreatedAt,
 			&product.Version,
-		); err != nil {
-			return nil, Metadata{}, err
+			&product.Attributes,
+			&product.DeletedAt,
 		}
-		products = append(products, &product)
-	}
+	})
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, Metadata{}, err
-	}
+// Stream calls fn for every product matching the filters, in the order of
+// filters.Sorts, as the rows are read from the database. Paging is ignored, so the
+// products are never held in memory together. It stops at the first error fn returns
+// and returns it.
+func (p *ProductModel) Stream(ctx context.Context, filters Filters, fn func(*Product) error) error {
+	builder := psql.Select(
+		"id",
+		"name",
+		"slug",
+		"external_id",
+		"category_id",
+		"description",
+		"price",
+		"currency",
+		"quantity",
+		"created_at",
+		"version",
+		"attributes",
+		"deleted_at",
+		"updated_at",
+	).From("products")
+	builder = p.buildFilters(builder, filters)
 
-	totalRecords, err := p.countProducts(ctx, filters)
+	query, args, _ := builder.
+		OrderBy(ProductFilterSpec.sortColumns(filters.Sorts)).
+		ToSql()
+	rows, err := p.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, Metadata{}, err
+		return err
 	}
+	defer rows.Close()
 
-	metadata := calculateMetadata(int(totalRecords), filters.Page, filters.PageSize)
-	return products, metadata, nil
+	for rows.Next() {
+		var product Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Slug,
+			&product.ExternalID,
+			&product.CategoryID,
+			&product.Description,
+			&product.Price,
+			&product.Currency,
+			&product.Quantity,
+			&product.CreatedAt,
+			&product.Version,
+			&product.Attributes,
+			&product.DeletedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
+// buildFilters adds the WHERE clause for the filters to the builder. Ordering and
+// paging are left to the caller so the same predicates can back other queries.
 func (p *ProductModel) buildFilters(builder sq.SelectBuilder, filters Filters) sq.SelectBuilder {
+	if !filters.IncludeDeleted {
+		builder = builder.Where("deleted_at IS NULL")
+	}
 	if len(filters.IDs) > 0 {
-		builder = builder.Where(sq.Eq{"ids": filters.IDs})
+		builder = builder.Where(sq.Eq{"id": filters.IDs})
 	}
 	if filters.Name != "" {
 		builder = builder.Where(
-			"to_tsvector('simple', name) @@ plainto_tsquery('simple', ?)",
+			fmt.Sprintf(
+				"to_tsvector('%[1]s', name) @@ search_tsquery('%[1]s', ?)",
+				SearchConfig(filters.Language),
+			),
 			filters.Name,
 		)
 	}
 	if filters.DateFrom != nil {
 		builder = builder.Where(sq.GtOrEq{"created_at": filters.DateFrom})
 	}
-	if filters.DateFrom != nil {
+	if filters.DateTo != nil {
 		builder = builder.Where(sq.LtOrEq{"created_at": filters.DateTo})
 	}
+	if filters.UpdatedSince != nil {
+		builder = builder.Where(sq.GtOrEq{"updated_at": filters.UpdatedSince})
+	}
+	conditions := filters.Conditions
+	if filters.IncludeSubcategories {
+		// Match the requested categories and every category below them instead.
+		conditions = slices.DeleteFunc(slices.Clone(conditions), func(cond Condition) bool {
+			if cond.Field != "category_id" || cond.Op != OpEq {
+				return false
+			}
+			builder = builder.Where(`category_id IN (
+				WITH RECURSIVE subcategories AS (
+					SELECT id FROM categories WHERE id = ANY(?)
+					UNION
+					SELECT c.id FROM categories c JOIN subcategories s ON c.parent_id = s.id
+				)
+				SELECT id FROM subcategories
+			)`, pq.Array(cond.Value))
+			return true
+		})
+	}
+	if len(conditions) > 0 {
+		builder = builder.Where(ProductFilterSpec.conditions(conditions))
+	}
+	if len(filters.Attributes) > 0 {
+		builder = builder.Where(attributeConditions(filters.Attributes))
+	}
 
-	builder = builder.OrderBy(filters.sortColumns())
-	builder = builder.Limit(uint64(filters.PageSize)).Offset(uint64(filters.offset()))
 	return builder
 }
 
-func (p *ProductModel) countProducts(ctx context.Context, filters Filters) (int64, error) {
-	builder := sq.Select("COUNT(*)").From("products")
-	builder = p.buildFilters(builder, filters)
+// Update saves the product. An empty product.Slug derives the slug from the name again.
+// When the slug changes, the old one keeps leading to the product. The job step of ctx,
+// if any, is saved with the product.
+func (p *ProductModel) Update(ctx context.Context, product *Product) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	query, args, _ := builder.ToSql()
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
 
-	var totalRecords int64
-	err := p.db.QueryRowContext(ctx, query, args...).Scan(&totalRecords)
-	if err != nil {
-		return 0, err
+	if err = saveJobStep(ctx, tx); err != nil {
+		return err
 	}
 
-	return totalRecords, nil
-}
+	product.Slug, err = productSlugs.setSlug(
+		ctx,
+		tx,
+		int64(product.ID),
+		product.Name,
+		product.Slug,
+	)
+	if err != nil {
+		return err
+	}
 
-func (p *ProductModel) Update(ctx context.Context, product *Product) error {
-	query, args, _ := sq.Update("products").
+	query, args, _ := psql.Update("products").
 		Set("name", product.Name).
+		Set("slug", product.Slug).
+		Set("external_id", product.ExternalID).
 		Set("category_id", product.CategoryID).
 		Set("description", product.Description).
 		Set("price", product.Price).
+		Set("currency", product.Currency).
 		Set("quantity", product.Quantity).
+		Set("attributes", product.Attributes).
 		Set("version", product.Version+1).
 		Where(sq.Eq{"id": product.ID}).
 		Where(sq.Eq{"version": product.Version}).
+		Where("deleted_at IS NULL").
 		Suffix("RETURNING version").
 		ToSql()
 
-	err := p.db.QueryRowContext(ctx, query, args...).Scan(&product.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&product.Version)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
+		if errors.Is(err, sql.ErrNoRows) {
 			return ErrEditConflict
-		default:
-			return err
 		}
+		return productWriteError(err, product)
 	}
 
-	return nil
+	return tx.Commit()
 }
 
+// productWriteError maps the constraint errors raised when a product is written to
+// the errors of this package.
+func productWriteError(err error, product *Product) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case pqErr.Code == ErrForeignKeyViolation:
+		return fmt.Errorf(
+			"category_id %d does not exist: %w",
+			product.CategoryID,
+			ErrInvalidCategoryId,
+		)
+	case pqErr.Code == ErrNumericValueOutOfRange:
+		return fmt.Errorf("price %s: %w", product.Price, ErrMoneyOutOfRange)
+	case pqErr.Code == ErrUniqueViolation && pqErr.Constraint == "products_external_id_key":
+		return fmt.Errorf("external_id %q: %w", *product.ExternalID, ErrDuplicateExternalID)
+	case pqErr.Code == ErrCheckViolation && pqErr.Constraint == "products_reserved_check":
+		// The quantity was lowered below the units currently held.
+		return fmt.Errorf(
+			"quantity %d is less than the reserved stock: %w",
+			product.Quantity,
+			ErrInsufficientStock,
+		)
+	default:
+		return err
+	}
+}
+
+// Delete marks the product as deleted. The row is kept, so that orders can still refer
+// to it and it can be restored, until it is purged.
 func (p *ProductModel) Delete(ctx context.Context, id int64) error {
-	query, _, _ := sq.Delete("products").Where(sq.Eq{"id": id}).ToSql()
-	result, err := p.db.ExecContext(ctx, query, id)
+	query, args, _ := psql.Update("products").
+		Set("deleted_at", sq.Expr("NOW()")).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": id}).
+		Where("deleted_at IS NULL").
+		ToSql()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -232,5 +547,100 @@ func (p *ProductModel) Delete(ctx context.Context, id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// Restore undeletes the product and returns it. It returns ErrRecordNotFound if there
+// is no deleted product with the id, and ErrInvalidCategoryId if its category is
+// deleted, so that the category has to be restored first.
+func (p *ProductModel) Restore(ctx context.Context, id int64) (*Product, error) {
+	query := `
+		UPDATE products
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND EXISTS(
+			SELECT 1 FROM categories c
+			WHERE c.id = products.category_id AND c.deleted_at IS NULL
+		)
+		RETURNING id, name, slug, external_id, category_id, description, price, currency,
+			quantity, created_at, version, attributes
+	`
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	var product Product
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&product.ID,
+		&product.Name,
+		&product.Slug,
+		&product.ExternalID,
+		&product.CategoryID,
+		&product.Description,
+		&product.Price,
+		&product.Currency,
+		&product.Quantity,
+		&product.CreatedAt,
+		&product.Version,
+		&product.Attributes,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, p.restoreError(ctx, tx, id)
+		}
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// restoreError tells apart the two reasons a restore can fail: there is no deleted
+// product with the id or its category is deleted.
+func (p *ProductModel) restoreError(ctx context.Context, tx *sql.Tx, id int64) error {
+	var categoryID int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT category_id FROM products WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	).Scan(&categoryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	return fmt.Errorf("category_id %d is deleted: %w", categoryID, ErrInvalidCategoryId)
+}
+
+// Purge permanently removes up to limit products deleted before the given time, along
+// with their variants, reservations and stock movements, and returns how many were
+// removed. Their revisions are kept.
+func (p *ProductModel) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM products
+		WHERE id IN (
+			SELECT id FROM products
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)
+	`
+
+	result, err := p.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
 }
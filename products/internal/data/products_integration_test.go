@@ -0,0 +1,494 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedProducts inserts a category and the given products into the test database and
+// returns the category id along with the ids of the inserted products.
+func seedProducts(t *testing.T, db *sql.DB, products []*Product) (int64, []int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	category := Category{Name: "Seed Category", Description: "Seeded by tests"}
+	if err := NewCategoryModel(db).Insert(ctx, &category); err != nil {
+		t.Fatalf("failed to insert category: %v", err)
+	}
+
+	productModel := NewProductModel(db)
+	ids := make([]int64, 0, len(products))
+	for _, product := range products {
+		if product.CategoryID == 0 {
+			product.CategoryID = int(category.ID)
+		}
+		if product.Currency == "" {
+			product.Currency = "USD"
+		}
+		if err := productModel.Insert(ctx, product); err != nil {
+			t.Fatalf("failed to insert product: %v", err)
+		}
+		ids = append(ids, int64(product.ID))
+	}
+
+	return category.ID, ids
+}
+
+func TestProductModel_Integration_GetAll(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	categoryID, ids := seedProducts(t, db, []*Product{
+		{Name: "Wireless Headphones", Price: 99_990, Quantity: 3},
+		{Name: "Wired Headphones", Price: 19_990, Quantity: 0},
+		{Name: "Phone Case", Price: 9_500, Quantity: 12},
+		{Name: "Phone Charger", Price: 24_750, Quantity: 7},
+		{Name: "Laptop Stand", Price: 45_000, Quantity: 0},
+	})
+
+	otherCategoryID, otherIDs := seedProducts(t, db, []*Product{
+		{Name: "Desk Lamp", Price: 30_000, Quantity: 2},
+	})
+
+	names := func(products []*Product) []string {
+		result := make([]string, 0, len(products))
+		for _, product := range products {
+			result = append(result, product.Name)
+		}
+		return result
+	}
+
+	t.Run("totals are correct past the first page", func(t *testing.T) {
+		filters := Filters{Page: 2, PageSize: 2}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Phone Case", "Phone Charger"}, names(products))
+		assert.Equal(t, Metadata{
+			CurrentPage:  2,
+			PageSize:     2,
+			FirstPage:    1,
+			LastPage:     3,
+			TotalRecords: 6,
+		}, metadata)
+	})
+
+	t.Run("filters by id", func(t *testing.T) {
+		filters := Filters{Page: 1, PageSize: 20, IDs: []int64{ids[0], otherIDs[0]}}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Wireless Headphones", "Desk Lamp"}, names(products))
+		assert.Equal(t, 2, metadata.TotalRecords)
+	})
+
+	t.Run("filters by name", func(t *testing.T) {
+		filters := Filters{Page: 1, PageSize: 1, Name: "headphones"}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Wireless Headphones"}, names(products))
+		assert.Equal(t, 2, metadata.TotalRecords)
+		assert.Equal(t, 2, metadata.LastPage)
+	})
+
+	t.Run("applies date to without date from", func(t *testing.T) {
+		past := time.Now().Add(-24 * time.Hour)
+		filters := Filters{Page: 1, PageSize: 20, DateTo: &past}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Empty(t, products)
+		assert.Equal(t, Metadata{}, metadata)
+
+		future := time.Now().Add(24 * time.Hour)
+		filters = Filters{Page: 1, PageSize: 20, DateFrom: &past, DateTo: &future}
+		_, metadata, err = productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, metadata.TotalRecords)
+	})
+
+	t.Run("applies resource specific conditions and sorts", func(t *testing.T) {
+		filters := Filters{
+			Page:     1,
+			PageSize: 20,
+			Conditions: []Condition{
+				{Field: "category_id", Op: OpEq, Value: []int64{categoryID}},
+				{Field: "price", Op: OpGte, Value: Money(10_000)},
+				{Field: "in_stock", Op: OpEq, Value: true},
+			},
+			Sorts: []string{"-price"},
+		}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Wireless Headphones", "Phone Charger"}, names(products))
+		assert.Equal(t, 2, metadata.TotalRecords)
+	})
+
+	t.Run("filters by another category", func(t *testing.T) {
+		filters := Filters{
+			Page:       1,
+			PageSize:   20,
+			Conditions: []Condition{{Field: "category_id", Op: OpEq, Value: []int64{otherCategoryID}}},
+		}
+		products, _, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Desk Lamp"}, names(products))
+	})
+
+	t.Run("walks every product with a cursor", func(t *testing.T) {
+		sorts := []string{"-price"}
+		filters := Filters{Page: 1, PageSize: 4, Sorts: sorts, Cursor: &Cursor{}}
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			[]string{"Wireless Headphones", "Laptop Stand", "Desk Lamp", "Phone Charger"},
+			names(products),
+		)
+		assert.Empty(t, metadata.PrevCursor)
+
+		filters.Cursor, err = DecodeCursor(metadata.NextCursor)
+		assert.NoError(t, err)
+		products, metadata, err = productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Wired Headphones", "Phone Case"}, names(products))
+		assert.Empty(t, metadata.NextCursor)
+
+		filters.Cursor, err = DecodeCursor(metadata.PrevCursor)
+		assert.NoError(t, err)
+		products, metadata, err = productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			[]string{"Wireless Headphones", "Laptop Stand", "Desk Lamp", "Phone Charger"},
+			names(products),
+		)
+		assert.Empty(t, metadata.PrevCursor)
+		assert.NotEmpty(t, metadata.NextCursor)
+	})
+}
+
+func TestProductModel_Integration_CRUD(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	categoryID, ids := seedProducts(t, db, []*Product{
+		{Name: "Test Product", Description: "A test product", Price: 10_990, Quantity: 5},
+	})
+
+	product, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Product", product.Name)
+	assert.Equal(t, int(categoryID), product.CategoryID)
+	assert.Equal(t, Money(10_990), product.Price)
+	assert.Equal(t, "USD", product.Currency)
+	assert.Equal(t, 1, product.Version)
+
+	product.Quantity = 8
+	assert.NoError(t, productModel.Update(ctx, product))
+	assert.Equal(t, 2, product.Version)
+
+	stale := *product
+	stale.Version = 1
+	assert.Equal(t, ErrEditConflict, productModel.Update(ctx, &stale))
+
+	product.Price = MaxMoney + 1
+	err = productModel.Update(ctx, product)
+	assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+	product.Price = 10_990
+
+	product.CategoryID = 999_999
+	err = productModel.Update(ctx, product)
+	assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+
+	err = productModel.Insert(ctx, &Product{Name: "Orphan", CategoryID: 999_999, Currency: "USD"})
+	assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+	assert.Equal(t, ErrRecordNotFound, productModel.Delete(ctx, ids[0]))
+
+	_, err = productModel.GetByID(ctx, ids[0])
+	assert.Equal(t, ErrRecordNotFound, err)
+}
+
+func TestProductModel_Integration_SoftDelete(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	categoryModel := NewCategoryModel(db)
+
+	categoryID, ids := seedProducts(t, db, []*Product{{Name: "Deleted Product"}})
+	filters := Filters{IDs: ids, Page: 1, PageSize: 20}
+
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+
+	products, _, err := productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	filters.IncludeDeleted = true
+	products, _, err = productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.NotNil(t, products[0].DeletedAt)
+
+	// A product cannot be restored into a deleted category.
+	assert.NoError(t, categoryModel.Delete(ctx, categoryID))
+	_, err = productModel.Restore(ctx, ids[0])
+	assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+
+	category, err := categoryModel.Restore(ctx, categoryID)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, category.Version)
+
+	product, err := productModel.Restore(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Nil(t, product.DeletedAt)
+	assert.Equal(t, 3, product.Version)
+
+	_, err = productModel.Restore(ctx, ids[0])
+	assert.Equal(t, ErrRecordNotFound, err)
+
+	// Only records deleted before the retention cut off are purged.
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+	_, err = productModel.Purge(ctx, time.Now().Add(-time.Hour), 500)
+	assert.NoError(t, err)
+	_, err = productModel.Restore(ctx, ids[0])
+	assert.NoError(t, err)
+
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+	_, err = db.ExecContext(
+		ctx,
+		`UPDATE products SET deleted_at = NOW() - INTERVAL '2 hours' WHERE id = $1`,
+		ids[0],
+	)
+	assert.NoError(t, err)
+	purged, err := productModel.Purge(ctx, time.Now().Add(-time.Hour), 500)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+	_, err = productModel.Restore(ctx, ids[0])
+	assert.Equal(t, ErrRecordNotFound, err)
+}
+
+func TestProductModel_Integration_Search(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{
+		{Name: "Studio Monitor", Description: "Wireless headphones for the studio"},
+		{Name: "Wireless Headphones", Description: "Over-ear"},
+		{Name: "Phone Case", Description: "Fits most phones"},
+	})
+
+	filters := Filters{Page: 1, PageSize: 20}
+
+	results, metadata, err := productModel.Search(ctx, "wireless headphones", filters)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, metadata.TotalRecords)
+	// A match in the name ranks above a match in the description.
+	assert.Equal(t, int(ids[1]), results[0].ID)
+	assert.Equal(t, int(ids[0]), results[1].ID)
+	assert.Greater(t, results[0].Rank, results[1].Rank)
+	assert.Equal(t, "<mark>Wireless</mark> <mark>Headphones</mark>", results[0].Highlights.Name)
+	assert.Contains(t, results[1].Highlights.Description, "<mark>Wireless</mark>")
+
+	// Partially typed words match by prefix.
+	results, _, err = productModel.Search(ctx, "pho", filters)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int(ids[2]), results[0].ID)
+}
+
+func TestProductModel_Integration_Language(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{
+		{Name: "Run Shoe", Description: "Light trainer"},
+		{Name: "Zapatilla de correr", Description: "Ligera"},
+	})
+
+	// Without a language the words must match exactly.
+	filters := Filters{IDs: ids, Name: "running shoes", Page: 1, PageSize: 20}
+	products, _, err := productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	filters.Language = "en"
+	products, _, err = productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, int(ids[0]), products[0].ID)
+
+	results, _, err := productModel.Search(ctx, "zapatillas", Filters{
+		IDs:      ids,
+		Language: "es",
+		Page:     1,
+		PageSize: 20,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int(ids[1]), results[0].ID)
+}
+
+func TestProductModel_Integration_Attributes(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{
+		{Name: "Red Heater", Attributes: Attributes{"color": "red", "wattage": 1500}},
+		{Name: "Blue Heater", Attributes: Attributes{"color": "blue", "wattage": 750}},
+		{Name: "Red Lamp", Attributes: Attributes{"color": "red", "wattage": "60"}},
+	})
+
+	filters := Filters{
+		IDs: ids,
+		Attributes: []AttributeCondition{
+			{Name: "color", Op: OpEq, Value: "red"},
+			{Name: "wattage", Op: OpGte, Value: float64(500)},
+		},
+		Page:     1,
+		PageSize: 20,
+	}
+	products, _, err := productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, int(ids[0]), products[0].ID)
+	assert.Equal(t, Attributes{"color": "red", "wattage": float64(1500)}, products[0].Attributes)
+
+	// An equality filter matches both the string and the number it spells.
+	filters.Attributes = []AttributeCondition{{Name: "wattage", Op: OpEq, Value: "60"}}
+	products, _, err = productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, int(ids[2]), products[0].ID)
+
+	filters.Attributes = []AttributeCondition{{Name: "wattage", Op: OpEq, Value: "750"}}
+	products, _, err = productModel.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, int(ids[1]), products[0].ID)
+}
+
+func TestProductModel_Integration_GetFacets(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	categoryID, _ := seedProducts(t, db, []*Product{
+		{Name: "Trail Shoe", Price: 45_000, Quantity: 3},
+		{Name: "Road Shoe", Price: 120_000, Quantity: 0},
+	})
+	otherCategoryID, _ := seedProducts(t, db, []*Product{
+		{Name: "Hiking Shoe", Price: 60_000, Quantity: 1},
+		{Name: "Desk Lamp", Price: 30_000, Quantity: 2},
+	})
+
+	filters := Filters{Name: "shoe", Page: 1, PageSize: 1}
+	facets, err := productModel.GetFacets(ctx, filters, FacetRequest{
+		Category:    true,
+		Price:       true,
+		PriceBounds: []Money{50_000, 100_000},
+		Stock:       true,
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, facets.Category, 2)
+	assert.Equal(t, categoryID, facets.Category[0].CategoryID)
+	assert.Equal(t, 2, facets.Category[0].Count)
+	assert.Equal(t, otherCategoryID, facets.Category[1].CategoryID)
+	assert.Equal(t, 1, facets.Category[1].Count)
+
+	counts := []int{}
+	for _, bucket := range facets.Price {
+		counts = append(counts, bucket.Count)
+	}
+	assert.Equal(t, []int{1, 1, 1}, counts)
+
+	assert.Equal(t, &StockFacet{InStock: 2, OutOfStock: 1}, facets.Stock)
+}
+
+func TestProductModel_Integration_ExternalID(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	erp1 := "ERP-1"
+	categoryID, ids := seedProducts(t, db, []*Product{
+		{Name: "Trail Shoe", ExternalID: &erp1, Price: 45_000},
+		{Name: "Road Shoe", Price: 120_000},
+	})
+
+	product, err := productModel.GetByExternalID(ctx, erp1)
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], int64(product.ID))
+
+	duplicate := Product{Name: "Hiking Shoe", ExternalID: &erp1, CategoryID: int(categoryID), Currency: "USD"}
+	err = productModel.Insert(ctx, &duplicate)
+	assert.True(t, errors.Is(err, ErrDuplicateExternalID))
+
+	streamed := []int64{}
+	filters := Filters{Sorts: []string{"-price"}}
+	err = productModel.Stream(ctx, filters, func(product *Product) error {
+		streamed = append(streamed, int64(product.ID))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{ids[1], ids[0]}, streamed)
+}
+
+func TestProductModel_Integration_UpdatedSince(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "Trail Shoe"}, {Name: "Road Shoe"}})
+
+	product, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	product.Quantity = 5
+	assert.NoError(t, productModel.Update(ctx, product))
+
+	updatedAt := map[int64]time.Time{}
+	err = productModel.Stream(ctx, Filters{Sorts: []string{"updated_at"}}, func(product *Product) error {
+		updatedAt[int64(product.ID)] = product.UpdatedAt
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, updatedAt[ids[0]].After(updatedAt[ids[1]]))
+
+	streamed := []int64{}
+	since := updatedAt[ids[0]]
+	filters := Filters{UpdatedSince: &since}
+	err = productModel.Stream(ctx, filters, func(product *Product) error {
+		streamed = append(streamed, int64(product.ID))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{ids[0]}, streamed)
+}
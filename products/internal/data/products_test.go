@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"testing"
 	"time"
 
@@ -30,21 +31,26 @@ func TestProductModel_Insert(t *testing.T) {
 		Name:        "Test Product",
 		CategoryID:  999,
 		Description: "A test product",
-		Price:       10.99,
+		Price:       10_990,
+		Currency:    "USD",
 		Quantity:    5,
 	}
 
 	args := []driver.Value{
 		product.Name,
+		"test-product",
+		nil,
 		product.CategoryID,
 		product.Description,
 		product.Price,
+		product.Currency,
 		product.Quantity,
+		[]byte("{}"),
 	}
 
 	var expectedQuery = regexp.QuoteMeta(`
-		INSERT INTO products (name,category_id,description,price,quantity) 
-		VALUES (?,?,?,?,?)
+		INSERT INTO products (name,slug,external_id,category_id,description,price,currency,quantity,attributes) 
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 		RETURNING id, created_at, version
 	`)
 
@@ -53,21 +59,27 @@ func TestProductModel_Insert(t *testing.T) {
 			Name:        "Test Product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 		}
 
 		createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
 		mockCols := []string{"id", "created_at", "version"}
 		mockRow := sqlmock.NewRows(mockCols).AddRow(1, createdAt, 1)
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
 		sqlMock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnRows(mockRow)
+		sqlMock.ExpectCommit()
 
 		expectedProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 			CreatedAt:   createdAt,
@@ -79,9 +91,47 @@ func TestProductModel_Insert(t *testing.T) {
 		assert.Equal(t, 1, productInsert.Version)
 	})
 
+	t.Run("saves the job step with the product", func(t *testing.T) {
+		productInsert := product
+		job := &Job{ID: 12, Attempts: 2}
+		stepCtx := ContextWithJobStep(ctx, &JobStep{
+			Job:      job,
+			Progress: 4,
+			Result:   map[string]int{"created": 4},
+		})
+		stepQuery := regexp.QuoteMeta(`
+			UPDATE jobs
+			SET progress = $1, result = $2
+			WHERE id = $3 AND attempts = $4 AND status = 'running'
+		`)
+
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectExec(stepQuery).
+			WithArgs(4, []byte(`{"created":4}`), 12, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
+		sqlMock.ExpectQuery(expectedQuery).WithArgs(args...).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(1, time.Now(), 1))
+		sqlMock.ExpectCommit()
+
+		assert.NoError(t, productModel.Insert(stepCtx, &productInsert))
+
+		// The product of a job held by another attempt is not written.
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectExec(stepQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectRollback()
+
+		productInsert = product
+		assert.Equal(t, ErrJobLeaseLost, productModel.Insert(stepCtx, &productInsert))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
 	t.Run("foreign key violation", func(t *testing.T) {
 		mockError := &pq.Error{Code: "23503"}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
 		sqlMock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
 
 		err := productModel.Insert(ctx, &product)
 		assert.True(t, errors.Is(err, ErrInvalidCategoryId))
@@ -92,9 +142,44 @@ func TestProductModel_Insert(t *testing.T) {
 		)
 	})
 
+	t.Run("numeric value out of range", func(t *testing.T) {
+		mockError := &pq.Error{Code: "22003"}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
+		sqlMock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
+
+		err := productModel.Insert(ctx, &product)
+		assert.True(t, errors.Is(err, ErrMoneyOutOfRange))
+		assert.Equal(t, "price 10.99: amount out of range", err.Error())
+	})
+
+	t.Run("external id is taken", func(t *testing.T) {
+		externalID := "ERP-1"
+		product := product
+		product.ExternalID = &externalID
+
+		externalArgs := slices.Clone(args)
+		externalArgs[2] = externalID
+
+		mockError := &pq.Error{Code: ErrUniqueViolation, Constraint: "products_external_id_key"}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
+		sqlMock.ExpectQuery(expectedQuery).WithArgs(externalArgs...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
+
+		err := productModel.Insert(ctx, &product)
+		assert.True(t, errors.Is(err, ErrDuplicateExternalID))
+		assert.Equal(t, `external_id "ERP-1": external_id already exists`, err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
 	t.Run("other error", func(t *testing.T) {
 		dbErr := errors.New("unexpected DB error")
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 0, "test-product")
 		sqlMock.ExpectQuery(expectedQuery).WithArgs(args...).WillReturnError(dbErr)
+		sqlMock.ExpectRollback()
 
 		err := productModel.Insert(ctx, &product)
 		assert.Equal(t, dbErr, err)
@@ -113,18 +198,20 @@ func TestProductModel_GetById(t *testing.T) {
 	ctx := context.Background()
 
 	var mockQuery = regexp.QuoteMeta(`
-		SELECT id, name, category_id, description, price, quantity, created_at, version
+		SELECT id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes
 		FROM products
-		WHERE id = ?
+		WHERE id = $1 AND deleted_at IS NULL
 	`)
 
 	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
 	expectedProduct := Product{
 		ID:          1,
 		Name:        "Test Product",
+		Slug:        "test-product",
 		CategoryID:  999,
 		Description: "A test product",
-		Price:       10.99,
+		Price:       10_990,
+		Currency:    "USD",
 		Quantity:    5,
 		CreatedAt:   createdAt,
 		Version:     1,
@@ -135,14 +222,20 @@ func TestProductModel_GetById(t *testing.T) {
 		mockCols := []string{
 			"id",
 			"name",
+			"slug",
+			"external_id",
 			"category_id",
 			"description",
 			"price",
+			"currency",
 			"quantity",
 			"created_at",
 			"version",
+			"attributes",
+		}
+		rowValues := []driver.Value{
+			id, "Test Product", "test-product", nil, 999, "A test product", "10.990", "USD", 5, createdAt, 1, nil,
 		}
-		rowValues := []driver.Value{id, "Test Product", 999, "A test product", 10.99, 5, createdAt, 1}
 		mockRow := sqlMock.NewRows(mockCols).AddRow(rowValues...)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(id).WillReturnRows(mockRow)
 
@@ -156,12 +249,16 @@ func TestProductModel_GetById(t *testing.T) {
 		mockCols := []string{
 			"id",
 			"name",
+			"slug",
+			"external_id",
 			"category_id",
 			"description",
 			"price",
+			"currency",
 			"quantity",
 			"created_at",
 			"version",
+			"attributes",
 		}
 		mockRow := sqlMock.NewRows(mockCols)
 		sqlMock.ExpectQuery(mockQuery).WithArgs(id).WillReturnRows(mockRow)
@@ -183,6 +280,143 @@ func TestProductModel_GetById(t *testing.T) {
 	})
 }
 
+func TestProductModel_GetByExternalID(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := ProductModel{db: db}
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		SELECT id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes
+		FROM products
+		WHERE external_id = $1 AND deleted_at IS NULL
+	`)
+	mockCols := []string{
+		"id", "name", "slug", "external_id", "category_id", "description", "price", "currency",
+		"quantity", "created_at", "version", "attributes",
+	}
+
+	t.Run("returns product with the given external id", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs("ERP-1").
+			WillReturnRows(sqlMock.NewRows(mockCols).AddRow(
+				1, "Test Product", "test-product", "ERP-1", 999, "", "10.990", "USD", 5, createdAt, 1, nil,
+			))
+
+		product, err := productModel.GetByExternalID(ctx, "ERP-1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, product.ID)
+		assert.Equal(t, "ERP-1", *product.ExternalID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no rows returned", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WithArgs("ERP-2").WillReturnRows(sqlMock.NewRows(mockCols))
+
+		product, err := productModel.GetByExternalID(ctx, "ERP-2")
+		assert.Nil(t, product)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestProductModel_GetByIDWithVariants(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := ProductModel{db: db}
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		SELECT id, name, slug, external_id, category_id, description, price, currency, quantity, created_at,
+			version, attributes,
+			COALESCE((
+				SELECT json_agg(json_build_object(`)
+	mockCols := []string{
+		"id",
+		"name",
+		"slug",
+		"external_id",
+		"category_id",
+		"description",
+		"price",
+		"currency",
+		"quantity",
+		"created_at",
+		"version",
+		"attributes",
+		"variants",
+	}
+
+	t.Run("returns product with its variants", func(t *testing.T) {
+		variants := `[
+			{"id": 3, "product_id": 1, "sku": "TS-M", "options": {"size": "M"},
+				"price": "12.500", "quantity": 4, "version": 1},
+			{"id": 4, "product_id": 1, "sku": "TS-L", "options": {"size": "L"},
+				"price": null, "quantity": 0, "version": 2}
+		]`
+		mockRow := sqlMock.NewRows(mockCols).AddRow(
+			1, "T-Shirt", "t-shirt", nil, 999, "A T-Shirt", "10.990", "USD", 5, createdAt, 1, nil, variants,
+		)
+		sqlMock.ExpectQuery(mockQuery).WithArgs(1).WillReturnRows(mockRow)
+
+		product, err := productModel.GetByIDWithVariants(ctx, 1)
+		assert.NoError(t, err)
+
+		price := Money(12_500)
+		assert.Equal(t, []*Variant{
+			{
+				ID:        3,
+				ProductID: 1,
+				SKU:       "TS-M",
+				Options:   VariantOptions{"size": "M"},
+				Price:     &price,
+				Quantity:  4,
+				Version:   1,
+			},
+			{
+				ID:        4,
+				ProductID: 1,
+				SKU:       "TS-L",
+				Options:   VariantOptions{"size": "L"},
+				Version:   2,
+			},
+		}, product.Variants)
+		assert.Equal(t, "T-Shirt", product.Name)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("returns product without variants", func(t *testing.T) {
+		mockRow := sqlMock.NewRows(mockCols).AddRow(
+			1, "T-Shirt", "t-shirt", nil, 999, "A T-Shirt", "10.990", "USD", 5, createdAt, 1, nil, "[]",
+		)
+		sqlMock.ExpectQuery(mockQuery).WithArgs(1).WillReturnRows(mockRow)
+
+		product, err := productModel.GetByIDWithVariants(ctx, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, product.Variants)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no rows returned", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WithArgs(1).WillReturnRows(sqlMock.NewRows(mockCols))
+
+		product, err := productModel.GetByIDWithVariants(ctx, 1)
+		assert.Nil(t, product)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
 func TestProductModel_GetAll(t *testing.T) {
 	t.Parallel()
 
@@ -194,11 +428,29 @@ func TestProductModel_GetAll(t *testing.T) {
 	ctx := context.Background()
 
 	var mockQuery = regexp.QuoteMeta(`
-		SELECT id, name, category_id, description, price, quantity, created_at, version
+		SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at
 		FROM products
+		WHERE deleted_at IS NULL
 		ORDER BY id ASC LIMIT 20 OFFSET 0
 	`)
 
+	mockCols := []string{
+		"count",
+		"id",
+		"name",
+		"slug",
+		"external_id",
+		"category_id",
+		"description",
+		"price",
+		"currency",
+		"quantity",
+		"created_at",
+		"version",
+		"attributes",
+		"deleted_at",
+	}
+
 	filters := Filters{
 		Page:     1,
 		PageSize: 20,
@@ -207,21 +459,27 @@ func TestProductModel_GetAll(t *testing.T) {
 
 	t.Run("fetch all products with filters successfully", func(t *testing.T) {
 		testFilters := Filters{
-			Page:     1,
+			Page:     2,
 			PageSize: 2,
 			IDs:      []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 			Name:     "test",
 			DateFrom: &createdAt,
 			DateTo:   &createdAt,
-			Sorts:    []string{"-created_at", "name", "id"},
+			Conditions: []Condition{
+				{Field: "price", Op: OpGte, Value: Money(10_000)},
+				{Field: "category_id", Op: OpEq, Value: []int64{12, 999}},
+			},
+			Sorts: []string{"-created_at", "name", "id"},
 		}
 		expectedProducts := []*Product{
 			{
 				ID:          1,
 				Name:        "Test Product1",
+				Slug:        "test-product1",
 				CategoryID:  999,
 				Description: "Test product1 description",
-				Price:       10.99,
+				Price:       10_990,
+				Currency:    "USD",
 				Quantity:    5,
 				CreatedAt:   createdAt,
 				Version:     1,
@@ -229,93 +487,128 @@ func TestProductModel_GetAll(t *testing.T) {
 			{
 				ID:          13,
 				Name:        "Test Product2",
+				Slug:        "test-product2",
 				CategoryID:  12,
 				Description: "Test product2 description",
-				Price:       25.73,
+				Price:       25_730,
+				Currency:    "USD",
 				Quantity:    16,
 				CreatedAt:   createdAt,
 				Version:     1,
 			},
 		}
 		expectedMetadata := Metadata{
-			CurrentPage:  1,
+			CurrentPage:  2,
 			PageSize:     2,
 			FirstPage:    1,
 			LastPage:     5,
 			TotalRecords: 10,
 		}
 
-		mockCols := []string{
-			"id",
-			"name",
-			"category_id",
-			"description",
-			"price",
-			"quantity",
-			"created_at",
-			"version",
-		}
 		mockRow := sqlMock.NewRows(mockCols)
 		mockRow.AddRow(
-			1, "Test Product1", 999, "Test product1 description", 10.99, 5, createdAt, 1,
+			10, 1, "Test Product1", "test-product1", nil, 999, "Test product1 description",
+			"10.990", "USD", 5, createdAt, 1, nil, nil,
 		)
 		mockRow.AddRow(
-			13, "Test Product2", 12, "Test product2 description", 25.73, 16, createdAt, 1,
+			10, 13, "Test Product2", "test-product2", nil, 12, "Test product2 description",
+			"25.730", "USD", 16, createdAt, 1, nil, nil,
 		)
 
 		testQuery := regexp.QuoteMeta(
 			`
-			SELECT id, name, category_id, description, price, quantity, created_at, version
+			SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at
 			FROM products
-			WHERE ids IN (?,?,?,?,?,?,?,?,?,?) 
-				AND to_tsvector('simple', name) @@ plainto_tsquery('simple', ?)
-				AND created_at >= ? 
-				AND created_at <= ? 
+			WHERE deleted_at IS NULL AND id IN ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) 
+				AND to_tsvector('simple', name) @@ search_tsquery('simple', $11)
+				AND created_at >= $12 
+				AND created_at <= $13 
+				AND (price >= $14 AND category_id IN ($15,$16))
 			ORDER BY created_at DESC, name ASC, id ASC
-			LIMIT 2 OFFSET 0`,
+			LIMIT 2 OFFSET 2`,
 		)
 		args := []driver.Value{
-			1, 2, 3, 4, 5, 6, 7, 8, 9, 10, "test", createdAt, createdAt,
+			1, 2, 3, 4, 5, 6, 7, 8, 9, 10, "test", createdAt, createdAt, "10.000", 12, 999,
 		}
 		sqlMock.ExpectQuery(testQuery).WithArgs(args...).WillReturnRows(mockRow)
 
-		countQuery := regexp.QuoteMeta(
-			`SELECT COUNT(*) 
-			FROM products 
-			WHERE ids IN (?,?,?,?,?,?,?,?,?,?) 
-				AND to_tsvector('simple', name) @@ plainto_tsquery('simple', ?) 
-				AND created_at >= ? AND created_at <= ? 
-			ORDER BY created_at DESC, name ASC, id ASC 
-			LIMIT 2 OFFSET 0`,
-		)
-		countRows := sqlmock.NewRows([]string{"count"}).AddRow(10)
-		sqlMock.ExpectQuery(countQuery).WithArgs(args...).WillReturnRows(countRows)
-
 		actualProducts, metadata, err := productModel.GetAll(ctx, testFilters)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedProducts, actualProducts)
 		assert.Equal(t, expectedMetadata, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
 	})
 
-	t.Run("no rows returned", func(t *testing.T) {
-		mockCols := []string{
-			"id",
-			"name",
-			"category_id",
-			"description",
-			"price",
-			"quantity",
-			"created_at",
-			"version",
+	t.Run("date to without date from", func(t *testing.T) {
+		testFilters := Filters{Page: 1, PageSize: 20, DateTo: &createdAt}
+		testQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at
+			FROM products
+			WHERE deleted_at IS NULL AND created_at <= $1
+			ORDER BY id ASC LIMIT 20 OFFSET 0
+		`)
+		sqlMock.ExpectQuery(testQuery).WithArgs(createdAt).WillReturnRows(sqlMock.NewRows(mockCols))
+
+		actualProducts, metadata, err := productModel.GetAll(ctx, testFilters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Product{}, actualProducts)
+		assert.Equal(t, Metadata{}, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("include subcategories", func(t *testing.T) {
+		testFilters := Filters{
+			Conditions: []Condition{
+				{Field: "category_id", Op: OpEq, Value: []int64{7}},
+				{Field: "in_stock", Op: OpEq, Value: true},
+			},
+			IncludeSubcategories: true,
+			Page:                 1,
+			PageSize:             20,
 		}
+		testQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at
+			FROM products
+			WHERE deleted_at IS NULL AND category_id IN (
+				WITH RECURSIVE subcategories AS (
+					SELECT id FROM categories WHERE id = ANY($1)
+					UNION
+					SELECT c.id FROM categories c JOIN subcategories s ON c.parent_id = s.id
+				)
+				SELECT id FROM subcategories
+			) AND ((quantity > reserved) = $2)
+			ORDER BY id ASC LIMIT 20 OFFSET 0
+		`)
+		sqlMock.ExpectQuery(testQuery).WithArgs("{7}", true).WillReturnRows(sqlMock.NewRows(mockCols))
+
+		actualProducts, _, err := productModel.GetAll(ctx, testFilters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Product{}, actualProducts)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("matches the name in the language of the filters", func(t *testing.T) {
+		testFilters := Filters{Name: "running shoes", Language: "en", Page: 1, PageSize: 20}
+		testQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at
+			FROM products
+			WHERE deleted_at IS NULL AND to_tsvector('english', name) @@ search_tsquery('english', $1)
+			ORDER BY id ASC LIMIT 20 OFFSET 0
+		`)
+		sqlMock.ExpectQuery(testQuery).
+			WithArgs("running shoes").
+			WillReturnRows(sqlMock.NewRows(mockCols))
+
+		actualProducts, _, err := productModel.GetAll(ctx, testFilters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Product{}, actualProducts)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no rows returned", func(t *testing.T) {
 		mockRow := sqlMock.NewRows(mockCols)
 		sqlMock.ExpectQuery(mockQuery).WillReturnRows(mockRow)
 
-		const countStmt = `SELECT COUNT(*) FROM products ORDER BY id ASC LIMIT 20 OFFSET 0`
-		countQuery := regexp.QuoteMeta(countStmt)
-		countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
-		sqlMock.ExpectQuery(countQuery).WillReturnRows(countRows)
-
 		actualProducts, metadata, err := productModel.GetAll(ctx, filters)
 		assert.NoError(t, err)
 		assert.Equal(t, []*Product{}, actualProducts)
@@ -334,45 +627,24 @@ func TestProductModel_GetAll(t *testing.T) {
 	})
 
 	t.Run("row scan error", func(t *testing.T) {
-		mockCols := []string{
-			"id",
-			"name",
-			"category_id",
-			"description",
-			"price",
-			"quantity",
-			"created_at",
-			"version",
-			"add_col",
-		}
-		mockRow := sqlMock.NewRows(mockCols)
+		mockRow := sqlMock.NewRows(append(mockCols, "add_col"))
 		mockRow.AddRow(
-			1, "Test Product", 999, "A test product", 10.99, 5, createdAt, 1, 10,
+			1, 1, "Test Product", "test-product", nil, 999, "A test product", "10.990", "USD", 5, createdAt, 1, nil, nil, 10,
 		)
 
 		sqlMock.ExpectQuery(mockQuery).WillReturnRows(mockRow)
 
 		actualProducts, metadata, err := productModel.GetAll(ctx, filters)
 		assert.Error(t, err)
-		assert.Equal(t, err.Error(), "sql: expected 9 destination arguments in Scan, not 8")
+		assert.Equal(t, err.Error(), "sql: expected 15 destination arguments in Scan, not 14")
 		assert.Nil(t, actualProducts)
 		assert.Equal(t, Metadata{}, metadata)
 	})
 
 	t.Run("row error", func(t *testing.T) {
-		mockCols := []string{
-			"id",
-			"name",
-			"category_id",
-			"description",
-			"price",
-			"quantity",
-			"created_at",
-			"version",
-		}
 		mockRow := sqlMock.NewRows(mockCols)
 		mockRow.AddRow(
-			1, "Test Product", 999, "A test product", 10.99, 5, createdAt, 1,
+			1, 1, "Test Product", "test-product", nil, 999, "A test product", "10.990", "USD", 5, createdAt, 1, nil, nil,
 		)
 		mockRow.RowError(0, errors.New("rows iteration error"))
 
@@ -384,35 +656,188 @@ func TestProductModel_GetAll(t *testing.T) {
 		assert.Nil(t, actualProducts)
 		assert.Equal(t, Metadata{}, metadata)
 	})
+}
 
-	t.Run("count product error", func(t *testing.T) {
-		mockCols := []string{
-			"id",
-			"name",
-			"category_id",
-			"description",
-			"price",
-			"quantity",
-			"created_at",
-			"version",
+func TestProductModel_GetAll_Cursor(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := ProductModel{db: db}
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockCols := []string{
+		"count",
+		"id",
+		"name",
+		"slug",
+		"external_id",
+		"category_id",
+		"description",
+		"price",
+		"currency",
+		"quantity",
+		"created_at",
+		"version",
+		"attributes",
+		"deleted_at",
+		"price_text",
+		"id_text",
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		filters := Filters{
+			Conditions: []Condition{{Field: "category_id", Op: OpEq, Value: []int64{12}}},
+			Sorts:      []string{"-price"},
+			Cursor:     &Cursor{},
+			Page:       1,
+			PageSize:   1,
 		}
-		mockRow := sqlMock.NewRows(mockCols)
+		testQuery := regexp.QuoteMeta(`
+			SELECT 0, id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at,
+				(price)::text, (id)::text
+			FROM products
+			WHERE deleted_at IS NULL AND (category_id IN ($1))
+			ORDER BY price DESC, id ASC LIMIT 2
+		`)
+		mockRow := sqlMock.NewRows(mockCols).
+			AddRow(0, 7, "Boots", "boots", nil, 12, "Boots", "99.500", "USD", 3, createdAt, 1, nil, nil, "99.500", "7").
+			AddRow(0, 4, "Shoes", "shoes", nil, 12, "Shoes", "10.990", "USD", 5, createdAt, 1, nil, nil, "10.990", "4")
+		sqlMock.ExpectQuery(testQuery).WithArgs(12).WillReturnRows(mockRow)
+
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
 
-		sqlMock.ExpectQuery(mockQuery).WillReturnRows(mockRow)
+		next := Cursor{Sorts: []string{"-price"}, Values: []string{"99.500", "7"}}
+		assert.Equal(t, Metadata{PageSize: 1, NextCursor: next.Encode()}, metadata)
+		assert.Len(t, products, 1)
+		assert.Equal(t, 7, products[0].ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
 
-		countQuery := regexp.QuoteMeta(
-			`SELECT COUNT(*) FROM products ORDER BY id ASC LIMIT 20 OFFSET 0`,
-		)
-		sqlMock.ExpectQuery(countQuery).WillReturnError(errors.New("sql error"))
+	t.Run("previous page", func(t *testing.T) {
+		filters := Filters{
+			Sorts:    []string{"-price"},
+			Cursor:   &Cursor{Sorts: []string{"-price"}, Values: []string{"10.990", "4"}, Backward: true},
+			Page:     1,
+			PageSize: 1,
+		}
+		testQuery := regexp.QuoteMeta(`
+			SELECT 0, id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at,
+				(price)::text, (id)::text
+			FROM products
+			WHERE deleted_at IS NULL AND ((price > $1) OR (price = $2 AND id < $3))
+			ORDER BY price ASC, id DESC LIMIT 2
+		`)
+		mockRow := sqlMock.NewRows(mockCols).
+			AddRow(0, 7, "Boots", "boots", nil, 12, "Boots", "99.500", "USD", 3, createdAt, 1, nil, nil, "99.500", "7")
+		sqlMock.ExpectQuery(testQuery).
+			WithArgs("10.990", "10.990", "4").
+			WillReturnRows(mockRow)
+
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
 
-		actualProducts, metadata, err := productModel.GetAll(ctx, filters)
-		assert.Error(t, err)
-		assert.Equal(t, "sql error", err.Error())
-		assert.Nil(t, actualProducts)
+		next := Cursor{Sorts: []string{"-price"}, Values: []string{"99.500", "7"}}
+		assert.Equal(t, Metadata{PageSize: 1, NextCursor: next.Encode()}, metadata)
+		assert.Len(t, products, 1)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("cursor values do not match the sort", func(t *testing.T) {
+		filters := Filters{
+			Cursor:   &Cursor{Values: []string{"4"}},
+			Sorts:    []string{"-price"},
+			Page:     1,
+			PageSize: 1,
+		}
+
+		products, metadata, err := productModel.GetAll(ctx, filters)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Nil(t, products)
 		assert.Equal(t, Metadata{}, metadata)
 	})
 }
 
+func TestProductModel_Stream(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := NewProductModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		SELECT id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at, updated_at
+		FROM products
+		WHERE deleted_at IS NULL AND updated_at >= $1 AND (category_id = $2)
+		ORDER BY price DESC, id ASC
+	`)
+	mockCols := []string{
+		"id", "name", "slug", "external_id", "category_id", "description", "price", "currency",
+		"quantity", "created_at", "version", "attributes", "deleted_at", "updated_at",
+	}
+	updatedSince := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2023, time.August, 2, 10, 0, 0, 0, time.UTC)
+	filters := Filters{
+		Conditions:   []Condition{{Field: "category_id", Op: OpEq, Value: int64(12)}},
+		Sorts:        []string{"-price"},
+		Page:         3,
+		PageSize:     1,
+		UpdatedSince: &updatedSince,
+	}
+
+	t.Run("calls fn for every matching product", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs(updatedSince, 12).
+			WillReturnRows(sqlMock.NewRows(mockCols).
+				AddRow(7, "Boots", "boots", "ERP-7", 12, "", "99.500", "USD", 3, createdAt, 1, nil, nil, updatedAt).
+				AddRow(4, "Shoes", "shoes", nil, 12, "", "10.990", "USD", 5, createdAt, 1, nil, nil, updatedAt))
+
+		ids := []int{}
+		err := productModel.Stream(ctx, filters, func(product *Product) error {
+			ids = append(ids, product.ID)
+			assert.Equal(t, updatedAt, product.UpdatedAt)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{7, 4}, ids)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("stops at the first error of fn", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs(updatedSince, 12).
+			WillReturnRows(sqlMock.NewRows(mockCols).
+				AddRow(7, "Boots", "boots", "ERP-7", 12, "", "99.500", "USD", 3, createdAt, 1, nil, nil, updatedAt).
+				AddRow(4, "Shoes", "shoes", nil, 12, "", "10.990", "USD", 5, createdAt, 1, nil, nil, updatedAt))
+
+		calls := 0
+		fnErr := errors.New("write error")
+		err := productModel.Stream(ctx, filters, func(product *Product) error {
+			calls++
+			return fnErr
+		})
+		assert.Equal(t, fnErr, err)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WillReturnError(errors.New("query error"))
+
+		err := productModel.Stream(ctx, filters, func(*Product) error { return nil })
+		assert.Equal(t, "query error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
 func TestProductModel_Update(t *testing.T) {
 	t.Parallel()
 
@@ -424,10 +849,14 @@ func TestProductModel_Update(t *testing.T) {
 	ctx := context.Background()
 	args := []driver.Value{
 		"Test Product",
+		"test-product",
+		nil,
 		999,
 		"A test product",
-		10.99,
+		"10.990",
+		"USD",
 		5,
+		[]byte("{}"),
 		2,
 		1,
 		1,
@@ -435,58 +864,72 @@ func TestProductModel_Update(t *testing.T) {
 
 	var mockQuery = regexp.QuoteMeta(
 		`UPDATE products 
-		SET name = ?, category_id = ?, description = ?, price = ?, quantity = ?, version = ? WHERE id = ? AND version = ? RETURNING version`,
+		SET name = $1, slug = $2, external_id = $3, category_id = $4, description = $5, price = $6, currency = $7, quantity = $8, attributes = $9, version = $10 WHERE id = $11 AND version = $12 AND deleted_at IS NULL RETURNING version`,
 	)
 
 	t.Run("updates product successfully", func(t *testing.T) {
 		mockRows := sqlmock.NewRows([]string{"version"}).AddRow(2)
+		expectAudit(sqlMock, "alice")
+		expectSlug(sqlMock, productSlugs, 1, "test-product")
 		sqlMock.ExpectQuery(mockQuery).
 			WithArgs(args...).
 			WillReturnRows(mockRows)
+		sqlMock.ExpectCommit()
 
 		actualProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 		}
 		expectedProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     2,
 		}
 
-		err := productModel.Update(ctx, &actualProduct)
+		err := productModel.Update(ContextWithActor(ctx, "alice"), &actualProduct)
 		assert.Nil(t, err)
 		assert.Equal(t, expectedProduct, actualProduct)
 	})
 
 	t.Run("query update error", func(t *testing.T) {
 		mockError := errors.New("query update error")
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 1, "test-product")
 		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
 
 		actualProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 		}
 		expectedProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 		}
@@ -498,25 +941,32 @@ func TestProductModel_Update(t *testing.T) {
 	})
 
 	t.Run("edit conflict", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 1, "test-product")
 		sqlMock.ExpectQuery(mockQuery).
 			WithArgs(args...).
 			WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
 
 		actualProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 		}
 		expectedProduct := Product{
 			ID:          1,
 			Name:        "Test Product",
+			Slug:        "test-product",
 			CategoryID:  999,
 			Description: "A test product",
-			Price:       10.99,
+			Price:       10_990,
+			Currency:    "USD",
 			Quantity:    5,
 			Version:     1,
 		}
@@ -526,6 +976,70 @@ func TestProductModel_Update(t *testing.T) {
 		assert.Equal(t, ErrEditConflict, err)
 		assert.Equal(t, expectedProduct, actualProduct)
 	})
+
+	t.Run("foreign key violation", func(t *testing.T) {
+		mockError := &pq.Error{Code: "23503"}
+		expectAudit(sqlMock, "")
+		expectSlug(sqlMock, productSlugs, 1, "test-product")
+		sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
+
+		actualProduct := Product{
+			ID:          1,
+			Name:        "Test Product",
+			Slug:        "test-product",
+			CategoryID:  999,
+			Description: "A test product",
+			Price:       10_990,
+			Currency:    "USD",
+			Quantity:    5,
+			Version:     1,
+		}
+
+		err := productModel.Update(ctx, &actualProduct)
+		assert.True(t, errors.Is(err, ErrInvalidCategoryId))
+		assert.Equal(t, "category_id 999 does not exist: invalid category_id", err.Error())
+		assert.Equal(t, 1, actualProduct.Version)
+	})
+
+	t.Run("check violations", func(t *testing.T) {
+		reservedErr := &pq.Error{Code: ErrCheckViolation, Constraint: "products_reserved_check"}
+		currencyErr := &pq.Error{Code: ErrCheckViolation, Constraint: "products_currency_check"}
+
+		for _, mockError := range []*pq.Error{reservedErr, currencyErr} {
+			expectAudit(sqlMock, "")
+			expectSlug(sqlMock, productSlugs, 1, "test-product")
+			sqlMock.ExpectQuery(mockQuery).WithArgs(args...).WillReturnError(mockError)
+			sqlMock.ExpectRollback()
+		}
+
+		newProduct := func() Product {
+			return Product{
+				ID:          1,
+				Name:        "Test Product",
+				Slug:        "test-product",
+				CategoryID:  999,
+				Description: "A test product",
+				Price:       10_990,
+				Currency:    "USD",
+				Quantity:    5,
+				Version:     1,
+			}
+		}
+
+		// Only the reserved stock check is a stock conflict, the others are returned
+		// as they are.
+		product := newProduct()
+		err := productModel.Update(ctx, &product)
+		assert.True(t, errors.Is(err, ErrInsufficientStock))
+		assert.Equal(t, "quantity 5 is less than the reserved stock: insufficient stock", err.Error())
+
+		product = newProduct()
+		err = productModel.Update(ctx, &product)
+		assert.False(t, errors.Is(err, ErrInsufficientStock))
+		assert.Equal(t, currencyErr, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
 }
 
 func TestProductModel_Delete(t *testing.T) {
@@ -539,19 +1053,25 @@ func TestProductModel_Delete(t *testing.T) {
 
 	productModel := NewProductModel(db)
 
-	const deleteQuery = `DELETE FROM products WHERE id = ?`
+	const deleteQuery = `
+		UPDATE products SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
 	var mockQuery = regexp.QuoteMeta(deleteQuery)
 
 	t.Run("delete product successfully", func(t *testing.T) {
 		mockResult := sqlmock.NewResult(1, 1)
+		expectAudit(sqlMock, "")
 		sqlMock.ExpectExec(mockQuery).WithArgs(1).WillReturnResult(mockResult)
+		sqlMock.ExpectCommit()
 		err := productModel.Delete(ctx, 1)
 		assert.NoError(t, err)
 	})
 
 	t.Run("delete query error", func(t *testing.T) {
 		mockError := errors.New("delete query error")
+		expectAudit(sqlMock, "")
 		sqlMock.ExpectExec(mockQuery).WithArgs(1).WillReturnError(mockError)
+		sqlMock.ExpectRollback()
 		err := productModel.Delete(ctx, 1)
 		assert.Error(t, err)
 		assert.Equal(t, "delete query error", err.Error())
@@ -559,7 +1079,9 @@ func TestProductModel_Delete(t *testing.T) {
 
 	t.Run("zero rows affected", func(t *testing.T) {
 		mockResult := sqlmock.NewResult(0, 0)
+		expectAudit(sqlMock, "")
 		sqlMock.ExpectExec(mockQuery).WithArgs(1).WillReturnResult(mockResult)
+		sqlMock.ExpectRollback()
 		err := productModel.Delete(ctx, 1)
 		assert.Error(t, err)
 		assert.Equal(t, ErrRecordNotFound, err)
@@ -567,9 +1089,121 @@ func TestProductModel_Delete(t *testing.T) {
 
 	t.Run("rows affected error", func(t *testing.T) {
 		mockResult := sqlmock.NewErrorResult(errors.New("rows affected error"))
+		expectAudit(sqlMock, "")
 		sqlMock.ExpectExec(mockQuery).WithArgs(1).WillReturnResult(mockResult)
+		sqlMock.ExpectRollback()
 		err := productModel.Delete(ctx, 1)
 		assert.Error(t, err)
 		assert.Equal(t, "rows affected error", err.Error())
 	})
 }
+
+func TestProductModel_Restore(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	restoreQuery := regexp.QuoteMeta(`
+		UPDATE products
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND EXISTS(
+			SELECT 1 FROM categories c
+			WHERE c.id = products.category_id AND c.deleted_at IS NULL
+		)
+		RETURNING id, name, slug, external_id, category_id, description, price, currency, quantity, created_at,
+			version, attributes
+	`)
+	deletedQuery := regexp.QuoteMeta(
+		`SELECT category_id FROM products WHERE id = $1 AND deleted_at IS NOT NULL`,
+	)
+
+	t.Run("restores the product", func(t *testing.T) {
+		mockCols := []string{
+			"id", "name", "slug", "external_id", "category_id", "description", "price", "currency", "quantity",
+			"created_at", "version", "attributes",
+		}
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(1).WillReturnRows(
+			sqlmock.NewRows(mockCols).
+				AddRow(1, "Boots", "boots", nil, 12, "Boots", "99.500", "USD", 3, createdAt, 3, nil),
+		)
+		sqlMock.ExpectCommit()
+
+		product, err := productModel.Restore(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &Product{
+			ID:          1,
+			Name:        "Boots",
+			Slug:        "boots",
+			CategoryID:  12,
+			Description: "Boots",
+			Price:       99_500,
+			Currency:    "USD",
+			Quantity:    3,
+			CreatedAt:   createdAt,
+			Version:     3,
+		}, product)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product is not deleted", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(1).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(deletedQuery).WithArgs(1).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		product, err := productModel.Restore(ctx, 1)
+		assert.Nil(t, product)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("category is deleted", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(restoreQuery).WithArgs(1).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(deletedQuery).WithArgs(1).WillReturnRows(
+			sqlmock.NewRows([]string{"category_id"}).AddRow(12),
+		)
+		sqlMock.ExpectRollback()
+
+		product, err := productModel.Restore(ctx, 1)
+		assert.Nil(t, product)
+		assert.ErrorIs(t, err, ErrInvalidCategoryId)
+		assert.Equal(t, "category_id 12 is deleted: invalid category_id", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestProductModel_Purge(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	before := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		DELETE FROM products
+		WHERE id IN (
+			SELECT id FROM products
+			WHERE deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+		)
+	`)
+	sqlMock.ExpectExec(mockQuery).WithArgs(before, 500).WillReturnResult(sqlmock.NewResult(0, 7))
+
+	purged, err := productModel.Purge(ctx, before, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), purged)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
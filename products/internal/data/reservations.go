@@ -0,0 +1,278 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReservationStatus is the state of a stock reservation. A reservation starts out held
+// and ends up committed, released or expired.
+type ReservationStatus string
+
+const (
+	ReservationHeld      ReservationStatus = "held"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds units of a product's stock for a limited time, e.g. while a
+// checkout is in progress. Held units are counted in products.reserved and are not
+// available to other reservations.
+type Reservation struct {
+	ID        int64             `json:"id"`
+	ProductID int64             `json:"product_id"`
+	Quantity  int               `json:"quantity"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"-"`
+}
+
+type ReservationModel struct {
+	db *sql.DB
+}
+
+type ReservationRepository interface {
+	Insert(ctx context.Context, reservation *Reservation, ttl time.Duration) error
+	GetByID(ctx context.Context, id int64) (*Reservation, error)
+	Commit(ctx context.Context, id int64) (*Reservation, error)
+	Release(ctx context.Context, id int64) (*Reservation, error)
+	ExpireHolds(ctx context.Context, limit int) (int64, error)
+}
+
+func NewReservationModel(db *sql.DB) *ReservationModel {
+	return &ReservationModel{db: db}
+}
+
+// Insert holds reservation.Quantity units of the product for ttl. The units are taken
+// from the available stock, quantity - reserved, in the same statement that records
+// the reservation, so concurrent reservations can never hold more than is in stock.
+// It returns ErrRecordNotFound if the product does not exist or has been deleted and
+// ErrInsufficientStock if not enough units are available.
+func (r *ReservationModel) Insert(
+	ctx context.Context,
+	reservation *Reservation,
+	ttl time.Duration,
+) error {
+	query := `
+		WITH hold AS (
+			UPDATE products
+			SET reserved = reserved + $2
+			WHERE id = $1 AND deleted_at IS NULL AND quantity - reserved >= $2
+			RETURNING id
+		)
+		INSERT INTO reservations (product_id, quantity, expires_at)
+		SELECT id, $2, NOW() + make_interval(secs => $3) FROM hold
+		RETURNING id, status, expires_at, created_at, version
+	`
+	args := []any{reservation.ProductID, reservation.Quantity, ttl.Seconds()}
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&reservation.ID,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.holdError(ctx, reservation.ProductID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// holdError tells apart the two reasons a hold can fail: the product does not exist
+// or it does not have enough available stock.
+func (r *ReservationModel) holdError(ctx context.Context, productID int64) error {
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)`,
+		productID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRecordNotFound
+	}
+	return ErrInsufficientStock
+}
+
+func (r *ReservationModel) GetByID(ctx context.Context, id int64) (*Reservation, error) {
+	query := `
+		SELECT id, product_id, quantity, status, expires_at, created_at, version
+		FROM reservations
+		WHERE id = $1
+	`
+	var reservation Reservation
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.ProductID,
+		&reservation.Quantity,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.Version,
+	)
+
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// Commit finishes a held reservation: the reserved units leave the stock for good.
+// The product version is bumped since its quantity changes. A reservation whose hold
+// has lapsed can no longer be committed, even if the sweeper has not expired it yet.
+func (r *ReservationModel) Commit(ctx context.Context, id int64) (*Reservation, error) {
+	return r.finish(ctx, id, ReservationCommitted, `
+		UPDATE products
+		SET quantity = quantity - $1, reserved = reserved - $1, version = version + 1
+		WHERE id = $2
+	`)
+}
+
+// Release cancels a held reservation and returns its units to the available stock.
+func (r *ReservationModel) Release(ctx context.Context, id int64) (*Reservation, error) {
+	return r.finish(ctx, id, ReservationReleased, `
+		UPDATE products
+		SET reserved = reserved - $1
+		WHERE id = $2
+	`)
+}
+
+// finish moves a held reservation to status and applies stockQuery, which receives the
+// reserved quantity and the product id, in the same transaction. The reservation row
+// is locked by the first update so a reservation can only be finished once. It
+// returns ErrRecordNotFound if there is no such reservation and ErrReservationNotHeld
+// if it is no longer held.
+func (r *ReservationModel) finish(
+	ctx context.Context,
+	id int64,
+	status ReservationStatus,
+	stockQuery string,
+) (*Reservation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Committing a reservation bumps the version of the product, which is recorded in
+	// its revisions.
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE reservations
+		SET status = $2, version = version + 1
+		WHERE id = $1 AND status = 'held' AND ($2 <> 'committed' OR expires_at > NOW())
+		RETURNING id, product_id, quantity, status, expires_at, created_at, version
+	`
+	var reservation Reservation
+	err = tx.QueryRowContext(ctx, query, id, status).Scan(
+		&reservation.ID,
+		&reservation.ProductID,
+		&reservation.Quantity,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, r.finishError(ctx, tx, id)
+		}
+		return nil, err
+	}
+
+	// A committed reservation is recorded in the stock movements as a sale.
+	if status == ReservationCommitted {
+		reference := fmt.Sprintf("reservation %d", reservation.ID)
+		if err = setStockContext(ctx, tx, StockSold, reference); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, stockQuery, reservation.Quantity, reservation.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// finishError tells apart a reservation that does not exist from one that is no
+// longer held.
+func (r *ReservationModel) finishError(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM reservations WHERE id = $1)`,
+		id,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRecordNotFound
+	}
+	return ErrReservationNotHeld
+}
+
+// ExpireHolds expires up to limit reservations whose hold has lapsed and returns their
+// units to the available stock. Rows locked by a concurrent commit or release are
+// skipped; they are either finished by that call or picked up by the next sweep. It
+// returns the number of reservations expired.
+func (r *ReservationModel) ExpireHolds(ctx context.Context, limit int) (int64, error) {
+	query := `
+		WITH expired AS (
+			UPDATE reservations
+			SET status = 'expired', version = version + 1
+			WHERE id IN (
+				SELECT id FROM reservations
+				WHERE status = 'held' AND expires_at <= NOW()
+				ORDER BY expires_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING product_id, quantity
+		), released AS (
+			UPDATE products p
+			SET reserved = p.reserved - e.quantity
+			FROM (
+				SELECT product_id, SUM(quantity) AS quantity
+				FROM expired
+				GROUP BY product_id
+			) e
+			WHERE p.id = e.product_id
+		)
+		SELECT count(*) FROM expired
+	`
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, limit).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservationModel_Integration_Oversell(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	reservationModel := NewReservationModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "Limited Edition", Quantity: 5}})
+
+	// Twenty concurrent checkouts compete for five units. Exactly five may succeed.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	held, insufficient := 0, 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservation := Reservation{ProductID: ids[0], Quantity: 1}
+			err := reservationModel.Insert(ctx, &reservation, time.Minute)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				held++
+			case errors.Is(err, ErrInsufficientStock):
+				insufficient++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, held)
+	assert.Equal(t, 15, insufficient)
+}
+
+func TestReservationModel_Integration_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	reservationModel := NewReservationModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "Widget", Quantity: 10}})
+
+	stock := func() (int, int) {
+		var quantity, reserved int
+		err := db.QueryRowContext(
+			ctx,
+			`SELECT quantity, reserved FROM products WHERE id = $1`,
+			ids[0],
+		).Scan(&quantity, &reserved)
+		assert.NoError(t, err)
+		return quantity, reserved
+	}
+
+	committed := Reservation{ProductID: ids[0], Quantity: 4}
+	assert.NoError(t, reservationModel.Insert(ctx, &committed, time.Minute))
+	released := Reservation{ProductID: ids[0], Quantity: 3}
+	assert.NoError(t, reservationModel.Insert(ctx, &released, time.Minute))
+	lapsed := Reservation{ProductID: ids[0], Quantity: 2}
+	assert.NoError(t, reservationModel.Insert(ctx, &lapsed, time.Millisecond))
+
+	quantity, reserved := stock()
+	assert.Equal(t, 10, quantity)
+	assert.Equal(t, 9, reserved)
+
+	// Lowering the quantity below what is held is rejected.
+	product, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	product.Quantity = 5
+	assert.True(t, errors.Is(productModel.Update(ctx, product), ErrInsufficientStock))
+
+	_, err = reservationModel.Commit(ctx, committed.ID)
+	assert.NoError(t, err)
+	_, err = reservationModel.Release(ctx, released.ID)
+	assert.NoError(t, err)
+
+	_, err = reservationModel.Commit(ctx, committed.ID)
+	assert.Equal(t, ErrReservationNotHeld, err)
+
+	time.Sleep(10 * time.Millisecond)
+	_, err = reservationModel.Commit(ctx, lapsed.ID)
+	assert.Equal(t, ErrReservationNotHeld, err)
+
+	expired, err := reservationModel.ExpireHolds(ctx, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+
+	quantity, reserved = stock()
+	assert.Equal(t, 6, quantity)
+	assert.Equal(t, 0, reserved)
+
+	reservation, err := reservationModel.GetByID(ctx, lapsed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ReservationExpired, reservation.Status)
+}
@@ -0,0 +1,299 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservationModel_Insert(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	reservationModel := NewReservationModel(db)
+	ctx := context.Background()
+	expiresAt := time.Date(2023, time.July, 1, 10, 15, 0, 0, time.UTC)
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	holdQuery := regexp.QuoteMeta(`
+		WITH hold AS (
+			UPDATE products
+			SET reserved = reserved + $2
+			WHERE id = $1 AND deleted_at IS NULL AND quantity - reserved >= $2
+			RETURNING id
+		)
+		INSERT INTO reservations (product_id, quantity, expires_at)
+		SELECT id, $2, NOW() + make_interval(secs => $3) FROM hold
+		RETURNING id, status, expires_at, created_at, version
+	`)
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)`)
+
+	t.Run("holds stock successfully", func(t *testing.T) {
+		mockRow := sqlmock.NewRows([]string{"id", "status", "expires_at", "created_at", "version"}).
+			AddRow(5, "held", expiresAt, createdAt, 1)
+		sqlMock.ExpectQuery(holdQuery).WithArgs(12, 3, 900.0).WillReturnRows(mockRow)
+
+		reservation := Reservation{ProductID: 12, Quantity: 3}
+		err := reservationModel.Insert(ctx, &reservation, 15*time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, Reservation{
+			ID:        5,
+			ProductID: 12,
+			Quantity:  3,
+			Status:    ReservationHeld,
+			ExpiresAt: expiresAt,
+			CreatedAt: createdAt,
+			Version:   1,
+		}, reservation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("insufficient stock", func(t *testing.T) {
+		sqlMock.ExpectQuery(holdQuery).WithArgs(12, 30, 60.0).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(existsQuery).WithArgs(12).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(true),
+		)
+
+		reservation := Reservation{ProductID: 12, Quantity: 30}
+		err := reservationModel.Insert(ctx, &reservation, time.Minute)
+		assert.Equal(t, ErrInsufficientStock, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product does not exist", func(t *testing.T) {
+		sqlMock.ExpectQuery(holdQuery).WithArgs(99, 1, 60.0).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(existsQuery).WithArgs(99).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(false),
+		)
+
+		reservation := Reservation{ProductID: 99, Quantity: 1}
+		err := reservationModel.Insert(ctx, &reservation, time.Minute)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(holdQuery).WithArgs(12, 1, 60.0).WillReturnError(
+			errors.New("hold error"),
+		)
+
+		reservation := Reservation{ProductID: 12, Quantity: 1}
+		err := reservationModel.Insert(ctx, &reservation, time.Minute)
+		assert.Equal(t, "hold error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestReservationModel_Finish(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	reservationModel := NewReservationModel(db)
+	ctx := context.Background()
+	expiresAt := time.Date(2023, time.July, 1, 10, 15, 0, 0, time.UTC)
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	finishQuery := regexp.QuoteMeta(`
+		UPDATE reservations
+		SET status = $2, version = version + 1
+		WHERE id = $1 AND status = 'held' AND ($2 <> 'committed' OR expires_at > NOW())
+		RETURNING id, product_id, quantity, status, expires_at, created_at, version
+	`)
+	commitQuery := regexp.QuoteMeta(`
+		UPDATE products
+		SET quantity = quantity - $1, reserved = reserved - $1, version = version + 1
+		WHERE id = $2
+	`)
+	releaseQuery := regexp.QuoteMeta(`
+		UPDATE products
+		SET reserved = reserved - $1
+		WHERE id = $2
+	`)
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM reservations WHERE id = $1)`)
+	stockContextQuery := regexp.QuoteMeta(
+		`SELECT set_config('stock.reason', $1, true), set_config('stock.reference', $2, true)`,
+	)
+	mockCols := []string{
+		"id", "product_id", "quantity", "status", "expires_at", "created_at", "version",
+	}
+
+	t.Run("commits successfully", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(finishQuery).WithArgs(5, "committed").WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(5, 12, 3, "committed", expiresAt, createdAt, 2),
+		)
+		sqlMock.ExpectExec(stockContextQuery).
+			WithArgs("sold", "reservation 5").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(commitQuery).WithArgs(3, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		reservation, err := reservationModel.Commit(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, &Reservation{
+			ID:        5,
+			ProductID: 12,
+			Quantity:  3,
+			Status:    ReservationCommitted,
+			ExpiresAt: expiresAt,
+			CreatedAt: createdAt,
+			Version:   2,
+		}, reservation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("releases successfully", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(finishQuery).WithArgs(5, "released").WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(5, 12, 3, "released", expiresAt, createdAt, 2),
+		)
+		sqlMock.ExpectExec(releaseQuery).WithArgs(3, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		reservation, err := reservationModel.Release(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, ReservationReleased, reservation.Status)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reservation is no longer held", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(finishQuery).WithArgs(5, "committed").WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(existsQuery).WithArgs(5).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(true),
+		)
+		sqlMock.ExpectRollback()
+
+		reservation, err := reservationModel.Commit(ctx, 5)
+		assert.Equal(t, ErrReservationNotHeld, err)
+		assert.Nil(t, reservation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reservation does not exist", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(finishQuery).WithArgs(7, "released").WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectQuery(existsQuery).WithArgs(7).WillReturnRows(
+			sqlmock.NewRows([]string{"exists"}).AddRow(false),
+		)
+		sqlMock.ExpectRollback()
+
+		reservation, err := reservationModel.Release(ctx, 7)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.Nil(t, reservation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("stock update error", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectQuery(finishQuery).WithArgs(5, "committed").WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(5, 12, 3, "committed", expiresAt, createdAt, 2),
+		)
+		sqlMock.ExpectExec(stockContextQuery).
+			WithArgs("sold", "reservation 5").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(commitQuery).WithArgs(3, 12).WillReturnError(errors.New("update error"))
+		sqlMock.ExpectRollback()
+
+		reservation, err := reservationModel.Commit(ctx, 5)
+		assert.Equal(t, "update error", err.Error())
+		assert.Nil(t, reservation)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestReservationModel_ExpireHolds(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	reservationModel := NewReservationModel(db)
+	ctx := context.Background()
+
+	query := regexp.QuoteMeta(`
+		WITH expired AS (
+			UPDATE reservations
+			SET status = 'expired', version = version + 1
+			WHERE id IN (
+				SELECT id FROM reservations
+				WHERE status = 'held' AND expires_at <= NOW()
+				ORDER BY expires_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING product_id, quantity
+		)`)
+
+	t.Run("expires lapsed holds", func(t *testing.T) {
+		sqlMock.ExpectQuery(query).WithArgs(100).WillReturnRows(
+			sqlmock.NewRows([]string{"count"}).AddRow(4),
+		)
+
+		count, err := reservationModel.ExpireHolds(ctx, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(query).WithArgs(100).WillReturnError(errors.New("sweep error"))
+
+		count, err := reservationModel.ExpireHolds(ctx, 100)
+		assert.Equal(t, "sweep error", err.Error())
+		assert.Equal(t, int64(0), count)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestReservationModel_GetByID(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	reservationModel := NewReservationModel(db)
+	ctx := context.Background()
+	expiresAt := time.Date(2023, time.July, 1, 10, 15, 0, 0, time.UTC)
+
+	query := regexp.QuoteMeta(`
+		SELECT id, product_id, quantity, status, expires_at, created_at, version
+		FROM reservations
+		WHERE id = $1
+	`)
+
+	t.Run("found", func(t *testing.T) {
+		sqlMock.ExpectQuery(query).WithArgs(5).WillReturnRows(
+			sqlmock.NewRows([]string{
+				"id", "product_id", "quantity", "status", "expires_at", "created_at", "version",
+			}).AddRow(5, 12, 3, "held", expiresAt, expiresAt, 1),
+		)
+
+		reservation, err := reservationModel.GetByID(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), reservation.ProductID)
+		assert.Equal(t, ReservationHeld, reservation.Status)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		sqlMock.ExpectQuery(query).WithArgs(5).WillReturnError(sql.ErrNoRows)
+
+		reservation, err := reservationModel.GetByID(ctx, 5)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.Nil(t, reservation)
+	})
+}
@@ -0,0 +1,311 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// RevisionEntity is the kind of record a revision belongs to.
+type RevisionEntity string
+
+const (
+	ProductRevision  RevisionEntity = "product"
+	CategoryRevision RevisionEntity = "category"
+)
+
+// Revision is the state of a product or category after one of its writes. Revisions
+// are recorded by the products_revision and categories_revision triggers for every
+// write that bumps the version, so the history is complete whichever endpoint made
+// the change. Purging a record closes its history with a purge revision.
+type Revision struct {
+	ID        int64           `json:"id"`
+	Version   int             `json:"version"`
+	Operation string          `json:"operation"`
+	Actor     string          `json:"actor"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// PreviousVersion and Changes are only set by GetByVersion. Changes lists the
+	// fields that differ from the previous revision, or every field for the first one.
+	PreviousVersion *int     `json:"previous_version,omitempty"`
+	Changes         []Change `json:"changes,omitempty"`
+}
+
+// Change is a field whose value differs between two revisions. Fields of nested
+// objects, such as the attributes of a product, are compared one by one and named
+// with a dotted path, e.g. attributes.color. A field missing on one side is null.
+type Change struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+var RevisionFilterSpec = FilterSpec{
+	{Name: "id", Column: "id", Sortable: true},
+	{Name: "created_at", Column: "created_at", Sortable: true},
+	{
+		Name:     "version",
+		Column:   "version",
+		Type:     IntField,
+		Sortable: true,
+		Ops:      []FilterOp{OpGte, OpLte},
+	},
+}
+
+type RevisionModel struct {
+	db *sql.DB
+}
+
+type RevisionRepository interface {
+	GetAll(
+		ctx context.Context,
+		entity RevisionEntity,
+		id int64,
+		filters Filters,
+	) ([]*Revision, Metadata, error)
+	GetByVersion(
+		ctx context.Context,
+		entity RevisionEntity,
+		id int64,
+		version int,
+	) (*Revision, error)
+}
+
+func NewRevisionModel(db *sql.DB) *RevisionModel {
+	return &RevisionModel{db: db}
+}
+
+type actorContextKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor the revisions written with
+// it are recorded for.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by ContextWithActor, or "" if there is none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// setAuditContext sets the actor the revision triggers record for the writes made by
+// the rest of the transaction.
+func setAuditContext(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`SELECT set_config('audit.actor', $1, true)`,
+		ActorFromContext(ctx),
+	)
+	return err
+}
+
+// GetAll returns a page of the revisions of a product or category. Deleted and purged
+// records keep their history, so it returns ErrRecordNotFound only if the record
+// never existed. The name filter does not apply to revisions and is ignored.
+func (m *RevisionModel) GetAll(
+	ctx context.Context,
+	entity RevisionEntity,
+	id int64,
+	filters Filters,
+) ([]*Revision, Metadata, error) {
+	builder := psql.Select(
+		filters.totalColumn(),
+		"id",
+		"version",
+		"operation",
+		"actor",
+		"snapshot",
+		"created_at",
+	).From("revisions").Where(sq.Eq{"entity": entity, "entity_id": id})
+
+	if len(filters.IDs) > 0 {
+		builder = builder.Where(sq.Eq{"id": filters.IDs})
+	}
+	if filters.DateFrom != nil {
+		builder = builder.Where(sq.GtOrEq{"created_at": filters.DateFrom})
+	}
+	if filters.DateTo != nil {
+		builder = builder.Where(sq.LtOrEq{"created_at": filters.DateTo})
+	}
+	if len(filters.Conditions) > 0 {
+		builder = builder.Where(RevisionFilterSpec.conditions(filters.Conditions))
+	}
+
+	builder, err := RevisionFilterSpec.paginate(builder, filters)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	query, args, _ := builder.ToSql()
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	revisions, metadata, err := collectPage(
+		rows,
+		RevisionFilterSpec,
+		filters,
+		func() (*Revision, []any) {
+			var revision Revision
+			return &revision, []any{
+				&revision.ID,
+				&revision.Version,
+				&revision.Operation,
+				&revision.Actor,
+				(*[]byte)(&revision.Snapshot),
+				&revision.CreatedAt,
+			}
+		},
+	)
+	if err != nil || len(revisions) > 0 {
+		return revisions, metadata, err
+	}
+
+	// An empty page is only a not found if the record has no history at all.
+	var exists bool
+	err = m.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM revisions WHERE entity = $1 AND entity_id = $2)`,
+		entity,
+		id,
+	).Scan(&exists)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	if !exists {
+		return nil, Metadata{}, ErrRecordNotFound
+	}
+
+	return revisions, metadata, nil
+}
+
+// GetByVersion returns a single revision of a product or category along with the
+// changes it made to the revision before it. It returns ErrRecordNotFound if there is
+// no such revision.
+func (m *RevisionModel) GetByVersion(
+	ctx context.Context,
+	entity RevisionEntity,
+	id int64,
+	version int,
+) (*Revision, error) {
+	// Versions are not always consecutive in the history of rows written before it
+	// was recorded, so the previous revision is the latest one below the version.
+	query := `
+		SELECT id, version, operation, actor, snapshot, created_at
+		FROM revisions
+		WHERE entity = $1 AND entity_id = $2 AND version <= $3
+		ORDER BY version DESC
+		LIMIT 2
+	`
+	rows, err := m.db.QueryContext(ctx, query, entity, id, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		// The snapshot is scanned as a []byte so that it is copied out of the buffer of
+		// the driver.
+		var revision Revision
+		err := rows.Scan(
+			&revision.ID,
+			&revision.Version,
+			&revision.Operation,
+			&revision.Actor,
+			(*[]byte)(&revision.Snapshot),
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 || revisions[0].Version != version {
+		return nil, ErrRecordNotFound
+	}
+
+	revision := revisions[0]
+	var previous json.RawMessage
+	if len(revisions) > 1 {
+		revision.PreviousVersion = &revisions[1].Version
+		previous = revisions[1].Snapshot
+	}
+
+	revision.Changes, err = diffSnapshots("", previous, revision.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// diffSnapshots returns the fields that differ between two JSON objects, in field
+// order, prefixing their names with prefix. Objects nested in both are compared field
+// by field. The version and the update time are left out since they differ between
+// any two revisions.
+func diffSnapshots(prefix string, from, to json.RawMessage) ([]Change, error) {
+	var fromFields, toFields map[string]json.RawMessage
+	if len(from) > 0 {
+		if err := json.Unmarshal(from, &fromFields); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(to, &toFields); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range fromFields {
+		names = append(names, name)
+	}
+	for name := range toFields {
+		if _, ok := fromFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []Change{}
+	for _, name := range names {
+		if prefix == "" && (name == "version" || name == "updated_at") {
+			continue
+		}
+
+		fromValue, toValue := fromFields[name], toFields[name]
+		if isJSONObject(fromValue) && isJSONObject(toValue) {
+			nested, err := diffSnapshots(prefix+name+".", fromValue, toValue)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, nested...)
+			continue
+		}
+
+		if !bytes.Equal(fromValue, toValue) {
+			changes = append(changes, Change{Field: prefix + name, From: fromValue, To: toValue})
+		}
+	}
+
+	return changes, nil
+}
+
+// isJSONObject reports whether value holds a JSON object.
+func isJSONObject(value json.RawMessage) bool {
+	value = bytes.TrimSpace(value)
+	return len(value) > 0 && value[0] == '{'
+}
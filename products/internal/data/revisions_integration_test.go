@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevisionModel_Integration_History(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := ContextWithActor(context.Background(), "alice")
+	productModel := NewProductModel(db)
+	revisionModel := NewRevisionModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "Tracked Product", Price: Money(10_990)}})
+
+	product, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	product.Price = Money(9_990)
+	assert.NoError(t, productModel.Update(ctx, product))
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+
+	filters := Filters{Sorts: []string{"version"}, Page: 1, PageSize: 20}
+	revisions, _, err := revisionModel.GetAll(ctx, ProductRevision, ids[0], filters)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+
+	operations := []string{}
+	for _, revision := range revisions {
+		operations = append(operations, revision.Operation)
+	}
+	assert.Equal(t, []string{"insert", "update", "delete"}, operations)
+	assert.Equal(t, "", revisions[0].Actor)
+	assert.Equal(t, "alice", revisions[1].Actor)
+
+	revision, err := revisionModel.GetByVersion(ctx, ProductRevision, ids[0], 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *revision.PreviousVersion)
+	assert.Len(t, revision.Changes, 1)
+	assert.Equal(t, "price", revision.Changes[0].Field)
+
+	var snapshot map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(revision.Snapshot, &snapshot))
+	assert.NotContains(t, snapshot, "search_vector")
+
+	// Purging the product keeps its history and closes it with a purge revision.
+	_, err = db.ExecContext(
+		ctx,
+		`UPDATE products SET deleted_at = NOW() - INTERVAL '2 hours' WHERE id = $1`,
+		ids[0],
+	)
+	assert.NoError(t, err)
+	_, err = productModel.Purge(ctx, time.Now().Add(-time.Hour), 500)
+	assert.NoError(t, err)
+
+	revisions, _, err = revisionModel.GetAll(ctx, ProductRevision, ids[0], filters)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 4)
+	assert.Equal(t, "purge", revisions[3].Operation)
+	assert.Equal(t, 4, revisions[3].Version)
+}
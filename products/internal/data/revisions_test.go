@@ -0,0 +1,214 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectAudit expects a product or category write to begin its transaction by setting
+// the actor of the revisions it records.
+func expectAudit(sqlMock sqlmock.Sqlmock, actor string) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('audit.actor', $1, true)`)).
+		WithArgs(actor).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestActorContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", ActorFromContext(ctx))
+	assert.Equal(t, "alice", ActorFromContext(ContextWithActor(ctx, "alice")))
+}
+
+func TestRevisionModel_GetAll(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	revisionModel := NewRevisionModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockCols := []string{"count", "id", "version", "operation", "actor", "snapshot", "created_at"}
+	existsQuery := regexp.QuoteMeta(
+		`SELECT EXISTS(SELECT 1 FROM revisions WHERE entity = $1 AND entity_id = $2)`,
+	)
+
+	t.Run("lists a page of revisions", func(t *testing.T) {
+		filters := Filters{
+			Conditions: []Condition{{Field: "version", Op: OpGte, Value: int64(2)}},
+			Sorts:      []string{"-version"},
+			Page:       1,
+			PageSize:   20,
+		}
+		testQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, version, operation, actor, snapshot, created_at
+			FROM revisions
+			WHERE entity = $1 AND entity_id = $2 AND (version >= $3)
+			ORDER BY version DESC, id ASC LIMIT 20 OFFSET 0
+		`)
+		mockRow := sqlMock.NewRows(mockCols).
+			AddRow(1, 7, 2, "update", "alice", []byte(`{"id": 12, "price": 9.990}`), createdAt)
+		sqlMock.ExpectQuery(testQuery).
+			WithArgs(ProductRevision, 12, 2).
+			WillReturnRows(mockRow)
+
+		revisions, metadata, err := revisionModel.GetAll(ctx, ProductRevision, 12, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Revision{{
+			ID:        7,
+			Version:   2,
+			Operation: "update",
+			Actor:     "alice",
+			Snapshot:  json.RawMessage(`{"id": 12, "price": 9.990}`),
+			CreatedAt: createdAt,
+		}}, revisions)
+		assert.Equal(t, calculateMetadata(1, 1, 20), metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("record without history", func(t *testing.T) {
+		filters := Filters{Page: 1, PageSize: 20}
+		sqlMock.ExpectQuery("SELECT count").
+			WithArgs(CategoryRevision, 12).
+			WillReturnRows(sqlMock.NewRows(mockCols))
+		sqlMock.ExpectQuery(existsQuery).
+			WithArgs(CategoryRevision, 12).
+			WillReturnRows(sqlMock.NewRows([]string{"exists"}).AddRow(false))
+
+		revisions, metadata, err := revisionModel.GetAll(ctx, CategoryRevision, 12, filters)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.Nil(t, revisions)
+		assert.Equal(t, Metadata{}, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("empty page of a record with history", func(t *testing.T) {
+		filters := Filters{Page: 3, PageSize: 20}
+		sqlMock.ExpectQuery("SELECT count").
+			WithArgs(ProductRevision, 12).
+			WillReturnRows(sqlMock.NewRows(mockCols))
+		sqlMock.ExpectQuery(existsQuery).
+			WithArgs(ProductRevision, 12).
+			WillReturnRows(sqlMock.NewRows([]string{"exists"}).AddRow(true))
+
+		revisions, _, err := revisionModel.GetAll(ctx, ProductRevision, 12, filters)
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		filters := Filters{Page: 1, PageSize: 20}
+		sqlMock.ExpectQuery("SELECT").WillReturnError(errors.New("query error"))
+
+		revisions, metadata, err := revisionModel.GetAll(ctx, ProductRevision, 12, filters)
+		assert.Equal(t, "query error", err.Error())
+		assert.Nil(t, revisions)
+		assert.Equal(t, Metadata{}, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestRevisionModel_GetByVersion(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	revisionModel := NewRevisionModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockCols := []string{"id", "version", "operation", "actor", "snapshot", "created_at"}
+	testQuery := regexp.QuoteMeta(`
+		SELECT id, version, operation, actor, snapshot, created_at
+		FROM revisions
+		WHERE entity = $1 AND entity_id = $2 AND version <= $3
+		ORDER BY version DESC
+		LIMIT 2
+	`)
+
+	t.Run("diffs against the previous revision", func(t *testing.T) {
+		current := `{"id": 12, "price": 9.990, "version": 3, "updated_at": "2023-07-03T10:00:00Z",
+			"attributes": {"color": "red", "size": "M"}}`
+		previous := `{"id": 12, "price": 10.990, "version": 2, "updated_at": "2023-07-02T10:00:00Z",
+			"attributes": {"color": "blue"}}`
+		mockRows := sqlMock.NewRows(mockCols).
+			AddRow(8, 3, "update", "alice", []byte(current), createdAt).
+			AddRow(7, 2, "update", "bob", []byte(previous), createdAt)
+		sqlMock.ExpectQuery(testQuery).WithArgs(ProductRevision, 12, 3).WillReturnRows(mockRows)
+
+		revision, err := revisionModel.GetByVersion(ctx, ProductRevision, 12, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, revision.Version)
+		assert.Equal(t, "alice", revision.Actor)
+		assert.Equal(t, 2, *revision.PreviousVersion)
+		assert.Equal(t, []Change{
+			{
+				Field: "attributes.color",
+				From:  json.RawMessage(`"blue"`),
+				To:    json.RawMessage(`"red"`),
+			},
+			{Field: "attributes.size", To: json.RawMessage(`"M"`)},
+			{Field: "price", From: json.RawMessage(`10.990`), To: json.RawMessage(`9.990`)},
+		}, revision.Changes)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("first revision lists every field", func(t *testing.T) {
+		mockRows := sqlMock.NewRows(mockCols).
+			AddRow(5, 1, "insert", "", []byte(`{"id": 4, "name": "Phones", "version": 1}`), createdAt)
+		sqlMock.ExpectQuery(testQuery).WithArgs(CategoryRevision, 4, 1).WillReturnRows(mockRows)
+
+		revision, err := revisionModel.GetByVersion(ctx, CategoryRevision, 4, 1)
+		assert.NoError(t, err)
+		assert.Nil(t, revision.PreviousVersion)
+		assert.Equal(t, []Change{
+			{Field: "id", To: json.RawMessage(`4`)},
+			{Field: "name", To: json.RawMessage(`"Phones"`)},
+		}, revision.Changes)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("revision does not exist", func(t *testing.T) {
+		mockRows := sqlMock.NewRows(mockCols).
+			AddRow(8, 3, "update", "alice", []byte(`{}`), createdAt)
+		sqlMock.ExpectQuery(testQuery).WithArgs(ProductRevision, 12, 9).WillReturnRows(mockRows)
+
+		revision, err := revisionModel.GetByVersion(ctx, ProductRevision, 12, 9)
+		assert.Nil(t, revision)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("record does not exist", func(t *testing.T) {
+		sqlMock.ExpectQuery(testQuery).
+			WithArgs(ProductRevision, 12, 1).
+			WillReturnRows(sqlMock.NewRows(mockCols))
+
+		revision, err := revisionModel.GetByVersion(ctx, ProductRevision, 12, 1)
+		assert.Nil(t, revision)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(testQuery).WillReturnError(errors.New("query error"))
+
+		revision, err := revisionModel.GetByVersion(ctx, ProductRevision, 12, 1)
+		assert.Nil(t, revision)
+		assert.Equal(t, "query error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// searchConfigs maps the languages the catalog is sold in to the PostgreSQL text search
+// configuration that stems their words, so "running shoes" also finds "run shoe". Every
+// configuration has expression indexes of its own.
+var searchConfigs = map[string]string{
+	"en": "english",
+	"es": "spanish",
+}
+
+// DefaultSearchConfig is the text search configuration of the languages without one
+// of their own. It matches whole words only.
+const DefaultSearchConfig = "simple"
+
+// SearchLanguages returns the languages with a text search configuration, sorted.
+func SearchLanguages() []string {
+	languages := make([]string, 0, len(searchConfigs))
+	for language := range searchConfigs {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// SearchConfig returns the text search configuration of a language, falling back to
+// DefaultSearchConfig. Only the names it returns are ever written into a query.
+func SearchConfig(language string) string {
+	if config, ok := searchConfigs[strings.ToLower(language)]; ok {
+		return config
+	}
+	return DefaultSearchConfig
+}
+
+// searchVector returns the weighted search document of a product for a text search
+// configuration. The simple one is stored in the search_vector column, the others are
+// computed by the same expression as their indexes so that the indexes are used.
+func searchVector(config string) string {
+	if config == DefaultSearchConfig {
+		return "search_vector"
+	}
+	return fmt.Sprintf(
+		"(setweight(to_tsvector('%[1]s', name), 'A') || "+
+			"setweight(to_tsvector('%[1]s', description), 'B'))",
+		config,
+	)
+}
+
+// SearchResult is a product matching a full-text search together with its rank and
+// the matching parts of its name and description.
+type SearchResult struct {
+	*Product
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights hold the name and a description snippet of a search result with
+// the matching words wrapped in <mark> tags.
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// The ts_headline options of the highlights. The name is short enough to be
+// highlighted in full, the description is cut down to the fragments around the
+// matches.
+const (
+	nameHeadlineOptions        = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+	descriptionHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+)
+
+// Search returns a page of the products matching q, best match first. Matches in the
+// name rank above matches in the description, and every word of q also matches the
+// words it is a prefix of, so partially typed words are found. The query is built by
+// search_tsquery, which applies the synonyms and stop words. Filters narrow the
+// results like they do for GetAll, but the sort and cursor are ignored. Words are
+// stemmed in the language of the filters.
+func (p *ProductModel) Search(
+	ctx context.Context,
+	q string,
+	filters Filters,
+) ([]*SearchResult, Metadata, error) {
+	config := SearchConfig(filters.Language)
+	builder := psql.Select(
+		"count(*) OVER()",
+		"id",
+		"name",
+		"slug",
+		"external_id",
+		"category_id",
+		"description",
+		"price",
+		"currency",
+		"quantity",
+		"created_at",
+		"version",
+		"attributes",
+		"deleted_at",
+		"ts_rank_cd("+searchVector(config)+", query) AS rank",
+		"ts_headline('"+config+"', name, query, '"+nameHeadlineOptions+"')",
+		"ts_headline('"+config+"', description, query, '"+descriptionHeadlineOptions+"')",
+	).
+		From("products").
+		JoinClause("CROSS JOIN search_tsquery('"+config+"', ?, true) AS query", q).
+		Where(searchVector(config) + " @@ query")
+	builder = p.buildFilters(builder, filters)
+
+	query, args, _ := builder.
+		OrderBy("rank DESC", "id ASC").
+		Limit(uint64(filters.PageSize)).
+		Offset(uint64(filters.offset())).
+		ToSql()
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	totalRecords := 0
+	for rows.Next() {
+		result := SearchResult{Product: &Product{}}
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&result.Name,
+			&result.Slug,
+			&result.ExternalID,
+			&result.CategoryID,
+			&result.Description,
+			&result.Price,
+			&result.Currency,
+			&result.Quantity,
+			&result.CreatedAt,
+			&result.Version,
+			&result.Attributes,
+			&result.DeletedAt,
+			&result.Rank,
+			&result.Highlights.Name,
+			&result.Highlights.Description,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		results = append(results, &result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
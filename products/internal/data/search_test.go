@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductModel_Search(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	productModel := NewProductModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	mockQuery := regexp.QuoteMeta(`
+		SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at,
+			ts_rank_cd(search_vector, query) AS rank,
+			ts_headline('simple', name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('simple', description, query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')
+		FROM products
+		CROSS JOIN search_tsquery('simple', $1, true) AS query
+		WHERE search_vector @@ query AND deleted_at IS NULL AND (price <= $2)
+		ORDER BY rank DESC, id ASC LIMIT 20 OFFSET 0
+	`)
+	mockCols := []string{
+		"count", "id", "name", "slug", "external_id", "category_id", "description", "price", "currency",
+		"quantity", "created_at", "version", "attributes", "deleted_at", "rank", "name_headline", "description_headline",
+	}
+	filters := Filters{
+		Conditions: []Condition{{Field: "price", Op: OpLte, Value: Money(100_000)}},
+		Page:       1,
+		PageSize:   20,
+	}
+
+	t.Run("returns ranked results", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs("Wireless headph", "100.000").
+			WillReturnRows(sqlMock.NewRows(mockCols).AddRow(
+				1, 4, "Studio Headphones", "studio-headphones", nil, 1, "Wireless headphones", "99.500", "USD", 3, createdAt, 1, nil, nil,
+				0.2, "Studio <mark>Headphones</mark>", "<mark>Wireless</mark> <mark>headphones</mark>",
+			))
+
+		results, metadata, err := productModel.Search(ctx, "Wireless headph", filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*SearchResult{{
+			Product: &Product{
+				ID:          4,
+				Name:        "Studio Headphones",
+				Slug:        "studio-headphones",
+				CategoryID:  1,
+				Description: "Wireless headphones",
+				Price:       Money(99_500),
+				Currency:    "USD",
+				Quantity:    3,
+				CreatedAt:   createdAt,
+				Version:     1,
+			},
+			Rank: 0.2,
+			Highlights: SearchHighlights{
+				Name:        "Studio <mark>Headphones</mark>",
+				Description: "<mark>Wireless</mark> <mark>headphones</mark>",
+			},
+		}}, results)
+		assert.Equal(t, calculateMetadata(1, 1, 20), metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("stems the words of the language", func(t *testing.T) {
+		vector := "(setweight(to_tsvector('spanish', name), 'A') || " +
+			"setweight(to_tsvector('spanish', description), 'B'))"
+		mockQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, name, slug, external_id, category_id, description, price, currency, quantity, created_at, version, attributes, deleted_at,
+				ts_rank_cd(` + vector + `, query) AS rank,
+				ts_headline('spanish', name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+				ts_headline('spanish', description, query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')
+			FROM products
+			CROSS JOIN search_tsquery('spanish', $1, true) AS query
+			WHERE ` + vector + ` @@ query AND deleted_at IS NULL
+			ORDER BY rank DESC, id ASC LIMIT 20 OFFSET 0
+		`)
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs("zapatillas").
+			WillReturnRows(sqlMock.NewRows(mockCols))
+
+		results, _, err := productModel.Search(
+			ctx,
+			"zapatillas",
+			Filters{Language: "es", Page: 1, PageSize: 20},
+		)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WillReturnError(errors.New("query error"))
+
+		results, _, err := productModel.Search(ctx, "headphones", filters)
+		assert.Nil(t, results)
+		assert.EqualError(t, err, "query error")
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestSearchConfig(t *testing.T) {
+	assert.Equal(t, []string{"en", "es"}, SearchLanguages())
+	assert.Equal(t, "english", SearchConfig("en"))
+	assert.Equal(t, "spanish", SearchConfig("ES"))
+	assert.Equal(t, "simple", SearchConfig("fr"))
+	assert.Equal(t, "simple", SearchConfig(""))
+}
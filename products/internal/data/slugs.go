@@ -0,0 +1,272 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// MaxSlugLength is the number of letters, digits and hyphens a slug has at most. A slug
+// derived from a name is cut to it, and a collision suffix replaces the end of a slug
+// that would grow past it.
+const MaxSlugLength = 100
+
+// maxSlugSuffixLength is the length of the longest collision suffix the slug queries
+// account for, -99999999.
+const maxSlugSuffixLength = 9
+
+// Slugify returns the slug of a name: its letters and digits in lower case, with every
+// run of other characters in between replaced by a single hyphen.
+func Slugify(name string) string {
+	var b strings.Builder
+	length, hyphen := 0, false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			hyphen = length > 0
+			continue
+		}
+
+		if hyphen {
+			if length+2 > MaxSlugLength {
+				break
+			}
+			b.WriteByte('-')
+			length, hyphen = length+1, false
+		}
+		if length+1 > MaxSlugLength {
+			break
+		}
+		b.WriteRune(unicode.ToLower(r))
+		length++
+	}
+	return b.String()
+}
+
+// IsSlug reports whether s is a slug that Slugify leaves as it is.
+func IsSlug(s string) bool {
+	return s != "" && Slugify(s) == s
+}
+
+// cutSlug returns the first n letters, digits and hyphens of slug, without a hyphen at
+// the end.
+func cutSlug(slug string, n int) string {
+	runes := []rune(slug)
+	if len(runes) > n {
+		runes = runes[:n]
+	}
+	return strings.TrimRight(string(runes), "-")
+}
+
+// slugPattern returns the LIKE pattern of the slugs pickSlug may give base with a
+// collision suffix. A base too long to take the suffix whole is matched by the part
+// of it that every such slug starts with.
+func slugPattern(base string) string {
+	if utf8.RuneCountInString(base) <= MaxSlugLength-maxSlugSuffixLength {
+		return base + "-%"
+	}
+	return cutSlug(base, MaxSlugLength-maxSlugSuffixLength) + "%"
+}
+
+// slugTable describes where the slugs of products or categories are kept: the current
+// slug of a row is in the slug column of its table, the slugs it had before are in the
+// redirects table.
+type slugTable struct {
+	table     string
+	redirects string
+	column    string
+
+	// fallback is the slug of a name without letters or digits.
+	fallback string
+}
+
+var (
+	productSlugs  = slugTable{"products", "product_slug_redirects", "product_id", "product"}
+	categorySlugs = slugTable{"categories", "category_slug_redirects", "category_id", "category"}
+)
+
+// base returns the slug a row is given before any collision suffix: the requested
+// slug, else the slug of its name.
+func (s slugTable) base(name, slug string) string {
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	if slug == "" {
+		slug = s.fallback
+	}
+	return slug
+}
+
+// setSlug returns the slug to save for the row with the given id, or 0 for a row that
+// is being inserted. A slug that was asked for is kept as it is and returns
+// ErrDuplicateSlug if another row has it, now or before it was renamed. Otherwise the
+// slug is derived from the name, with the first free -2, -3, ... suffix if it is taken.
+// When the slug of an existing row changes, its current slug is kept as a redirect.
+//
+// Slugs are assigned under a transaction level advisory lock, otherwise two concurrent
+// writes could pick the same free slug.
+func (s slugTable) setSlug(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int64,
+	name, slug string,
+) (string, error) {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, s.table+".slug")
+	if err != nil {
+		return "", err
+	}
+
+	base := s.base(name, slug)
+
+	query := fmt.Sprintf(`
+		SELECT slug FROM %[1]s WHERE id <> $1 AND (slug = $2 OR slug LIKE $3)
+		UNION
+		SELECT slug FROM %[2]s WHERE %[3]s <> $1 AND (slug = $2 OR slug LIKE $3)
+	`, s.table, s.redirects, s.column)
+	taken, err := takenSlugs(ctx, tx, query, id, base, slugPattern(base))
+	if err != nil {
+		return "", err
+	}
+
+	candidate, err := pickSlug(base, slug, taken)
+	if err != nil {
+		return "", err
+	}
+
+	if id == 0 {
+		return candidate, nil
+	}
+
+	// The new slug may be one the row had before, which then stops being a redirect.
+	query = fmt.Sprintf(`
+		WITH reclaimed AS (
+			DELETE FROM %[2]s WHERE slug = $2
+		)
+		INSERT INTO %[2]s (slug, %[3]s)
+		SELECT slug, id FROM %[1]s WHERE id = $1 AND slug <> $2
+		ON CONFLICT (slug) DO NOTHING
+	`, s.table, s.redirects, s.column)
+	if _, err = tx.ExecContext(ctx, query, id, candidate); err != nil {
+		return "", err
+	}
+
+	return candidate, nil
+}
+
+// newSlugs returns the slugs to insert rows with the given names and requested slugs,
+// as setSlug does for a single row. The slugs are also kept apart from each other. A
+// requested slug that is taken gets an ErrDuplicateSlug in errs instead. Rows whose
+// errs entry is already set when newSlugs is called are skipped.
+func (s slugTable) newSlugs(
+	ctx context.Context,
+	tx *sql.Tx,
+	names, slugs []string,
+	errs []error,
+) ([]string, error) {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, s.table+".slug")
+	if err != nil {
+		return nil, err
+	}
+
+	bases, patterns := make([]string, len(names)), make([]string, len(names))
+	for i, name := range names {
+		bases[i] = s.base(name, slugs[i])
+		patterns[i] = slugPattern(bases[i])
+	}
+
+	query := fmt.Sprintf(`
+		WITH bases AS (
+			SELECT DISTINCT * FROM unnest($1::text[], $2::text[]) AS b(base, pattern)
+		)
+		SELECT slug FROM %[1]s, bases WHERE slug = base OR slug LIKE pattern
+		UNION
+		SELECT slug FROM %[2]s, bases WHERE slug = base OR slug LIKE pattern
+	`, s.table, s.redirects)
+	taken, err := takenSlugs(ctx, tx, query, pq.Array(bases), pq.Array(patterns))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, len(names))
+	for i, base := range bases {
+		if errs[i] != nil {
+			continue
+		}
+
+		result[i], errs[i] = pickSlug(base, slugs[i], taken)
+		if errs[i] == nil {
+			taken[result[i]] = true
+		}
+	}
+
+	return result, nil
+}
+
+// takenSlugs returns the set of slugs the query reads.
+func takenSlugs(ctx context.Context, tx *sql.Tx, query string, args ...any) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		taken[slug] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return taken, nil
+}
+
+// pickSlug returns the requested slug, or ErrDuplicateSlug if it is taken. Without a
+// requested slug it returns base with the first -2, -3, ... suffix that is not taken,
+// cutting base short where the suffix would take it past MaxSlugLength.
+func pickSlug(base, slug string, taken map[string]bool) (string, error) {
+	if slug != "" && taken[slug] {
+		return "", fmt.Errorf("slug %s: %w", slug, ErrDuplicateSlug)
+	}
+
+	candidate := base
+	for n := 2; taken[candidate]; n++ {
+		suffix := fmt.Sprintf("-%d", n)
+		candidate = cutSlug(base, MaxSlugLength-len(suffix)) + suffix
+	}
+	return candidate, nil
+}
+
+// lookupSlug returns the id and the current slug of the row that has, or had, the
+// slug. Deleted rows are left out. It returns ErrRecordNotFound if there is no such
+// row.
+func (s slugTable) lookupSlug(ctx context.Context, db *sql.DB, slug string) (int64, string, error) {
+	query := fmt.Sprintf(`
+		SELECT id, slug FROM %[1]s
+		WHERE deleted_at IS NULL AND (
+			slug = $1 OR id = (SELECT %[3]s FROM %[2]s WHERE slug = $1)
+		)
+	`, s.table, s.redirects, s.column)
+
+	var id int64
+	var current string
+	err := db.QueryRowContext(ctx, query, slug).Scan(&id, &current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", ErrRecordNotFound
+		}
+		return 0, "", err
+	}
+
+	return id, current, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugTable_Integration_Renames(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{
+		{Name: "Blue Shoe", Price: Money(10_990)},
+		{Name: "Blue Shoe", Price: Money(12_990)},
+	})
+
+	first, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "blue-shoe", first.Slug)
+
+	second, err := productModel.GetByID(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "blue-shoe-2", second.Slug)
+
+	// Renaming the product keeps its old slug as a redirect.
+	first.Name, first.Slug = "Red Shoe", ""
+	assert.NoError(t, productModel.Update(ctx, first))
+	assert.Equal(t, "red-shoe", first.Slug)
+
+	id, slug, err := productModel.LookupSlug(ctx, "blue-shoe")
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], id)
+	assert.Equal(t, "red-shoe", slug)
+
+	// The old slug stays reserved for the renamed product.
+	second.Slug = "blue-shoe"
+	err = productModel.Update(ctx, second)
+	assert.True(t, errors.Is(err, ErrDuplicateSlug))
+
+	// Renaming it back reclaims the slug.
+	first.Name, first.Slug = "Blue Shoe", ""
+	assert.NoError(t, productModel.Update(ctx, first))
+	assert.Equal(t, "blue-shoe", first.Slug)
+
+	id, slug, err = productModel.LookupSlug(ctx, "red-shoe")
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], id)
+	assert.Equal(t, "blue-shoe", slug)
+}
@@ -0,0 +1,244 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectSlug expects a product or category write to assign a slug derived from base,
+// given the slugs of other rows that start with it. Writes to an existing row keep its
+// old slug as a redirect.
+func expectSlug(sqlMock sqlmock.Sqlmock, slugs slugTable, id int64, base string, taken ...string) {
+	sqlMock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)).
+		WithArgs(slugs.table + ".slug").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlMock.NewRows([]string{"slug"})
+	for _, slug := range taken {
+		rows.AddRow(slug)
+	}
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT slug FROM `+slugs.table+` WHERE id <> $1`)).
+		WithArgs(id, base, slugPattern(base)).
+		WillReturnRows(rows)
+
+	if id != 0 {
+		sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO `+slugs.redirects)).
+			WithArgs(id, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Blue Running Shoe", expected: "blue-running-shoe"},
+		{name: "  T-Shirt (XL) & Cap!  ", expected: "t-shirt-xl-cap"},
+		{name: "Café Crème 2000", expected: "café-crème-2000"},
+		{name: "手机 壳", expected: "手机-壳"},
+		{name: "!!!", expected: ""},
+		{name: strings.Repeat("ab ", 60), expected: strings.Repeat("ab-", 33) + "a"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			slug := Slugify(tc.name)
+			assert.Equal(t, tc.expected, slug)
+			assert.LessOrEqual(t, len([]rune(slug)), MaxSlugLength)
+			assert.True(t, slug == "" || IsSlug(slug))
+		})
+	}
+
+	assert.False(t, IsSlug("Blue-Shoe"))
+	assert.False(t, IsSlug("blue--shoe"))
+	assert.False(t, IsSlug("-blue"))
+	assert.False(t, IsSlug(""))
+}
+
+func TestPickSlug(t *testing.T) {
+	long := strings.Repeat("a", 97) + "-bc"
+	testCases := []struct {
+		name     string
+		base     string
+		taken    []string
+		expected string
+	}{
+		{name: "free", base: "shoe", expected: "shoe"},
+		{name: "taken", base: "shoe", taken: []string{"shoe", "shoe-2"}, expected: "shoe-3"},
+		{name: "long", base: long, taken: []string{long}, expected: strings.Repeat("a", 97) + "-2"},
+		{
+			name:     "long with a longer suffix",
+			base:     long,
+			taken:    append([]string{long}, suffixed(strings.Repeat("a", 97), 2, 10)...),
+			expected: strings.Repeat("a", 97) + "-10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for _, slug := range tc.taken {
+				taken[slug] = true
+			}
+
+			slug, err := pickSlug(tc.base, "", taken)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, slug)
+			assert.True(t, IsSlug(slug))
+			assert.LessOrEqual(t, len([]rune(slug)), MaxSlugLength)
+		})
+	}
+}
+
+// suffixed returns base with the suffixes from -from up to, but not including, -to.
+func suffixed(base string, from, to int) []string {
+	slugs := []string{}
+	for n := from; n < to; n++ {
+		slugs = append(slugs, fmt.Sprintf("%s-%d", base, n))
+	}
+	return slugs
+}
+
+func TestSlugTable_SetSlug(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	setSlug := func(id int64, name, slug string) (string, error) {
+		tx, err := db.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		return productSlugs.setSlug(ctx, tx, id, name, slug)
+	}
+
+	t.Run("derives the slug from the name", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 0, "blue-shoe")
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, "Blue Shoe", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "blue-shoe", slug)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("adds the first free suffix", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 0, "blue-shoe", "blue-shoe", "blue-shoe-2", "blue-shoe-box")
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, "Blue Shoe", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "blue-shoe-3", slug)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("cuts a long slug short to add the suffix", func(t *testing.T) {
+		base := strings.Repeat("a", 95) + "-bcde"
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 0, base, base)
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, strings.Repeat("a", 95)+" bcde", "")
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("a", 95)+"-bc-2", slug)
+		assert.True(t, IsSlug(slug))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("falls back to the kind of the row", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 0, "product", "product")
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, "???", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "product-2", slug)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("keeps the old slug as a redirect", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 7, "red-shoe")
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(7, "Red Shoe", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "red-shoe", slug)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("slug is taken", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		expectSlug(sqlMock, productSlugs, 0, "shoe", "shoe")
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, "Blue Shoe", "shoe")
+		assert.Equal(t, "", slug)
+		assert.True(t, errors.Is(err, ErrDuplicateSlug))
+		assert.Equal(t, "slug shoe: slug already exists", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec("SELECT pg_advisory_xact_lock").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectQuery("SELECT slug").WillReturnError(errors.New("query error"))
+		sqlMock.ExpectRollback()
+
+		slug, err := setSlug(0, "Blue Shoe", "")
+		assert.Equal(t, "", slug)
+		assert.Equal(t, "query error", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestSlugTable_LookupSlug(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	testQuery := regexp.QuoteMeta(`
+		SELECT id, slug FROM categories
+		WHERE deleted_at IS NULL AND (
+			slug = $1 OR id = (SELECT category_id FROM category_slug_redirects WHERE slug = $1)
+		)
+	`)
+
+	t.Run("finds the current slug of a redirect", func(t *testing.T) {
+		mockRow := sqlMock.NewRows([]string{"id", "slug"}).AddRow(4, "phones")
+		sqlMock.ExpectQuery(testQuery).WithArgs("mobile-phones").WillReturnRows(mockRow)
+
+		id, slug, err := NewCategoryModel(db).LookupSlug(ctx, "mobile-phones")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), id)
+		assert.Equal(t, "phones", slug)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("slug not found", func(t *testing.T) {
+		sqlMock.ExpectQuery(testQuery).
+			WithArgs("tablets").
+			WillReturnRows(sqlMock.NewRows([]string{"id", "slug"}))
+
+		_, _, err := NewCategoryModel(db).LookupSlug(ctx, "tablets")
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// StockReason is why the quantity of a product changed.
+type StockReason string
+
+const (
+	StockReceived   StockReason = "received"
+	StockSold       StockReason = "sold"
+	StockDamaged    StockReason = "damaged"
+	StockCorrection StockReason = "correction"
+)
+
+// StockMovement is a single change to the quantity of a product. Movements are
+// recorded by the products_stock_movement trigger for every statement that changes
+// products.quantity, so the history is complete whichever endpoint made the change.
+type StockMovement struct {
+	ID            int64       `json:"id"`
+	ProductID     int64       `json:"product_id"`
+	Delta         int         `json:"delta"`
+	Reason        StockReason `json:"reason"`
+	Reference     string      `json:"reference"`
+	QuantityAfter int         `json:"quantity_after"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+var StockMovementFilterSpec = FilterSpec{
+	{Name: "id", Column: "id", Sortable: true},
+	{Name: "created_at", Column: "created_at", Sortable: true},
+	{
+		Name:     "delta",
+		Column:   "delta",
+		Type:     IntField,
+		Sortable: true,
+		Ops:      []FilterOp{OpGte, OpLte},
+	},
+}
+
+type StockMovementModel struct {
+	db *sql.DB
+}
+
+type StockMovementRepository interface {
+	Adjust(
+		ctx context.Context,
+		productID int64,
+		delta int,
+		reason StockReason,
+		reference string,
+	) (*StockMovement, error)
+	GetAll(ctx context.Context, productID int64, filters Filters) ([]*StockMovement, Metadata, error)
+}
+
+func NewStockMovementModel(db *sql.DB) *StockMovementModel {
+	return &StockMovementModel{db: db}
+}
+
+// setStockContext sets the reason and reference the stock movement trigger records for
+// the quantity changes made by the rest of the transaction.
+func setStockContext(ctx context.Context, tx *sql.Tx, reason StockReason, reference string) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`SELECT set_config('stock.reason', $1, true), set_config('stock.reference', $2, true)`,
+		reason,
+		reference,
+	)
+	return err
+}
+
+// Adjust changes the quantity of a product by delta and returns the movement that
+// records the change. It returns ErrRecordNotFound if the product does not exist or has
+// been deleted and ErrInsufficientStock if the adjustment would take the quantity below
+// zero or below the units currently reserved.
+func (s *StockMovementModel) Adjust(
+	ctx context.Context,
+	productID int64,
+	delta int,
+	reason StockReason,
+	reference string,
+) (*StockMovement, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = setAuditContext(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err = setStockContext(ctx, tx, reason, reference); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE products
+		SET quantity = quantity + $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, delta, productID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == ErrCheckViolation {
+			return nil, fmt.Errorf("delta %d: %w", delta, ErrInsufficientStock)
+		}
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	// The product row stays locked until the transaction ends, so the latest movement
+	// of the product is the one recorded by the update above.
+	query := `
+		SELECT id, product_id, delta, reason, reference, quantity_after, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	var movement StockMovement
+	err = tx.QueryRowContext(ctx, query, productID).Scan(
+		&movement.ID,
+		&movement.ProductID,
+		&movement.Delta,
+		&movement.Reason,
+		&movement.Reference,
+		&movement.QuantityAfter,
+		&movement.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
+// GetAll returns a page of the stock movements of a product. The name filter does not
+// apply to movements and is ignored.
+func (s *StockMovementModel) GetAll(
+	ctx context.Context,
+	productID int64,
+	filters Filters,
+) ([]*StockMovement, Metadata, error) {
+	builder := psql.Select(
+		filters.totalColumn(),
+		"id",
+		"product_id",
+		"delta",
+		"reason",
+		"reference",
+		"quantity_after",
+		"created_at",
+	).From("stock_movements").Where(sq.Eq{"product_id": productID})
+
+	if len(filters.IDs) > 0 {
+		builder = builder.Where(sq.Eq{"id": filters.IDs})
+	}
+	if filters.DateFrom != nil {
+		builder = builder.Where(sq.GtOrEq{"created_at": filters.DateFrom})
+	}
+	if filters.DateTo != nil {
+		builder = builder.Where(sq.LtOrEq{"created_at": filters.DateTo})
+	}
+	if len(filters.Conditions) > 0 {
+		builder = builder.Where(StockMovementFilterSpec.conditions(filters.Conditions))
+	}
+
+	builder, err := StockMovementFilterSpec.paginate(builder, filters)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	query, args, _ := builder.ToSql()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	return collectPage(rows, StockMovementFilterSpec, filters, func() (*StockMovement, []any) {
+		var movement StockMovement
+		return &movement, []any{
+			&movement.ID,
+			&movement.ProductID,
+			&movement.Delta,
+			&movement.Reason,
+			&movement.Reference,
+			&movement.QuantityAfter,
+			&movement.CreatedAt,
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockMovementModel_Integration_History(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	reservationModel := NewReservationModel(db)
+	stockMovementModel := NewStockMovementModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "Widget", Quantity: 5}})
+
+	movement, err := stockMovementModel.Adjust(ctx, ids[0], 10, StockReceived, "PO-1001")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, movement.Delta)
+	assert.Equal(t, 15, movement.QuantityAfter)
+
+	reservation := Reservation{ProductID: ids[0], Quantity: 4}
+	assert.NoError(t, reservationModel.Insert(ctx, &reservation, time.Minute))
+	_, err = reservationModel.Commit(ctx, reservation.ID)
+	assert.NoError(t, err)
+
+	// A product update that changes the quantity is recorded as a correction.
+	product, err := productModel.GetByID(ctx, ids[0])
+	assert.NoError(t, err)
+	product.Quantity = 9
+	assert.NoError(t, productModel.Update(ctx, product))
+
+	// Adjustments can not take away units that are not in stock.
+	_, err = stockMovementModel.Adjust(ctx, ids[0], -10, StockDamaged, "")
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	movements, metadata, err := stockMovementModel.GetAll(
+		ctx,
+		ids[0],
+		Filters{Page: 1, PageSize: 20},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, metadata.TotalRecords)
+
+	type entry struct {
+		Delta         int
+		Reason        StockReason
+		Reference     string
+		QuantityAfter int
+	}
+	got := make([]entry, 0, len(movements))
+	for _, m := range movements {
+		got = append(got, entry{m.Delta, m.Reason, m.Reference, m.QuantityAfter})
+	}
+	assert.Equal(t, []entry{
+		{5, StockReceived, "", 5},
+		{10, StockReceived, "PO-1001", 15},
+		{-4, StockSold, fmt.Sprintf("reservation %d", reservation.ID), 11},
+		{-2, StockCorrection, "", 9},
+	}, got)
+}
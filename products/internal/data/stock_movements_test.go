@@ -0,0 +1,194 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockMovementModel_Adjust(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	stockMovementModel := NewStockMovementModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	stockContextQuery := regexp.QuoteMeta(
+		`SELECT set_config('stock.reason', $1, true), set_config('stock.reference', $2, true)`,
+	)
+	adjustQuery := regexp.QuoteMeta(`
+		UPDATE products
+		SET quantity = quantity + $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL
+	`)
+	movementQuery := regexp.QuoteMeta(`
+		SELECT id, product_id, delta, reason, reference, quantity_after, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`)
+
+	t.Run("adjusts stock successfully", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectExec(stockContextQuery).
+			WithArgs("received", "PO-1001").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(adjustQuery).WithArgs(10, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectQuery(movementQuery).WithArgs(12).WillReturnRows(
+			sqlmock.NewRows([]string{
+				"id", "product_id", "delta", "reason", "reference", "quantity_after", "created_at",
+			}).AddRow(3, 12, 10, "received", "PO-1001", 15, createdAt),
+		)
+		sqlMock.ExpectCommit()
+
+		movement, err := stockMovementModel.Adjust(ctx, 12, 10, StockReceived, "PO-1001")
+		assert.NoError(t, err)
+		assert.Equal(t, &StockMovement{
+			ID:            3,
+			ProductID:     12,
+			Delta:         10,
+			Reason:        StockReceived,
+			Reference:     "PO-1001",
+			QuantityAfter: 15,
+			CreatedAt:     createdAt,
+		}, movement)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product does not exist", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectExec(stockContextQuery).
+			WithArgs("damaged", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(adjustQuery).WithArgs(-1, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectRollback()
+
+		movement, err := stockMovementModel.Adjust(ctx, 7, -1, StockDamaged, "")
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.Nil(t, movement)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough stock", func(t *testing.T) {
+		expectAudit(sqlMock, "")
+		sqlMock.ExpectExec(stockContextQuery).
+			WithArgs("damaged", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(adjustQuery).
+			WithArgs(-20, 12).
+			WillReturnError(&pq.Error{Code: ErrCheckViolation})
+		sqlMock.ExpectRollback()
+
+		movement, err := stockMovementModel.Adjust(ctx, 12, -20, StockDamaged, "")
+		assert.True(t, errors.Is(err, ErrInsufficientStock))
+		assert.Equal(t, "delta -20: insufficient stock", err.Error())
+		assert.Nil(t, movement)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		sqlMock.ExpectBegin().WillReturnError(errors.New("begin error"))
+
+		movement, err := stockMovementModel.Adjust(ctx, 12, 1, StockCorrection, "")
+		assert.Equal(t, "begin error", err.Error())
+		assert.Nil(t, movement)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestStockMovementModel_List(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	stockMovementModel := NewStockMovementModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	dateFrom := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	mockCols := []string{
+		"count", "id", "product_id", "delta", "reason", "reference", "quantity_after", "created_at",
+	}
+
+	t.Run("lists a page of movements", func(t *testing.T) {
+		filters := Filters{
+			DateFrom:   &dateFrom,
+			Conditions: []Condition{{Field: "delta", Op: OpLte, Value: int64(-1)}},
+			Sorts:      []string{"-created_at"},
+			Page:       2,
+			PageSize:   1,
+		}
+		testQuery := regexp.QuoteMeta(`
+			SELECT count(*) OVER(), id, product_id, delta, reason, reference, quantity_after, created_at
+			FROM stock_movements
+			WHERE product_id = $1 AND created_at >= $2 AND (delta <= $3)
+			ORDER BY created_at DESC, id ASC LIMIT 1 OFFSET 1
+		`)
+		mockRow := sqlMock.NewRows(mockCols).
+			AddRow(3, 5, 12, -2, "sold", "reservation 9", 8, createdAt)
+		sqlMock.ExpectQuery(testQuery).WithArgs(12, dateFrom, -1).WillReturnRows(mockRow)
+
+		movements, metadata, err := stockMovementModel.GetAll(ctx, 12, filters)
+		assert.NoError(t, err)
+		assert.Equal(t, []*StockMovement{{
+			ID:            5,
+			ProductID:     12,
+			Delta:         -2,
+			Reason:        StockSold,
+			Reference:     "reservation 9",
+			QuantityAfter: 8,
+			CreatedAt:     createdAt,
+		}}, movements)
+		assert.Equal(t, calculateMetadata(3, 2, 1), metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("lists with a cursor", func(t *testing.T) {
+		filters := Filters{
+			Cursor:   &Cursor{Values: []string{"5"}},
+			Page:     1,
+			PageSize: 1,
+		}
+		testQuery := regexp.QuoteMeta(`
+			SELECT 0, id, product_id, delta, reason, reference, quantity_after, created_at, (id)::text
+			FROM stock_movements
+			WHERE product_id = $1 AND ((id > $2))
+			ORDER BY id ASC LIMIT 2
+		`)
+		mockRow := sqlMock.NewRows(append(mockCols, "id_text")).
+			AddRow(0, 6, 12, 4, "received", "", 12, createdAt, "6")
+		sqlMock.ExpectQuery(testQuery).WithArgs(12, "5").WillReturnRows(mockRow)
+
+		movements, metadata, err := stockMovementModel.GetAll(ctx, 12, filters)
+		assert.NoError(t, err)
+		assert.Len(t, movements, 1)
+
+		prev := Cursor{Values: []string{"6"}, Backward: true}
+		assert.Equal(t, Metadata{PageSize: 1, PrevCursor: prev.Encode()}, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		filters := Filters{Page: 1, PageSize: 20}
+		sqlMock.ExpectQuery("SELECT").WillReturnError(errors.New("query error"))
+
+		movements, metadata, err := stockMovementModel.GetAll(ctx, 12, filters)
+		assert.Equal(t, "query error", err.Error())
+		assert.Nil(t, movements)
+		assert.Equal(t, Metadata{}, metadata)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
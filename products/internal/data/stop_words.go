@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StopWord is a word that search_tsquery leaves out of searches, such as "the" or
+// "with", so that it does not keep otherwise matching products out of the results.
+type StopWord struct {
+	Word      string    `json:"word"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type StopWordModel struct {
+	db *sql.DB
+}
+
+type StopWordRepository interface {
+	Insert(ctx context.Context, stopWord *StopWord) error
+	GetAll(ctx context.Context) ([]*StopWord, error)
+	Delete(ctx context.Context, word string) error
+}
+
+func NewStopWordModel(db *sql.DB) *StopWordModel {
+	return &StopWordModel{db: db}
+}
+
+// Insert adds a stop word. Stop words are read by every search, so the change applies
+// to the next search.
+func (s *StopWordModel) Insert(ctx context.Context, stopWord *StopWord) error {
+	query := `
+		INSERT INTO search_stop_words (word)
+		VALUES ($1)
+		RETURNING created_at
+	`
+	err := s.db.QueryRowContext(ctx, query, stopWord.Word).Scan(&stopWord.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == ErrUniqueViolation {
+			return fmt.Errorf("word %q: %w", stopWord.Word, ErrDuplicateStopWord)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *StopWordModel) GetAll(ctx context.Context) ([]*StopWord, error) {
+	query := `SELECT word, created_at FROM search_stop_words ORDER BY word ASC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stopWords := []*StopWord{}
+	for rows.Next() {
+		var stopWord StopWord
+		if err := rows.Scan(&stopWord.Word, &stopWord.CreatedAt); err != nil {
+			return nil, err
+		}
+		stopWords = append(stopWords, &stopWord)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stopWords, nil
+}
+
+func (s *StopWordModel) Delete(ctx context.Context, word string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM search_stop_words WHERE word = $1`, word)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
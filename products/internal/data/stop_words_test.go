@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopWordModel(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	stopWordModel := NewStopWordModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	insertQuery := regexp.QuoteMeta(`
+		INSERT INTO search_stop_words (word)
+		VALUES ($1)
+		RETURNING created_at
+	`)
+
+	t.Run("inserts a stop word", func(t *testing.T) {
+		stopWord := StopWord{Word: "with"}
+		sqlMock.ExpectQuery(insertQuery).
+			WithArgs("with").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+
+		err := stopWordModel.Insert(ctx, &stopWord)
+		assert.NoError(t, err)
+		assert.Equal(t, createdAt, stopWord.CreatedAt)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("stop word already exists", func(t *testing.T) {
+		sqlMock.ExpectQuery(insertQuery).WillReturnError(&pq.Error{Code: ErrUniqueViolation})
+
+		err := stopWordModel.Insert(ctx, &StopWord{Word: "with"})
+		assert.True(t, errors.Is(err, ErrDuplicateStopWord))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("lists the stop words", func(t *testing.T) {
+		sqlMock.ExpectQuery(
+			regexp.QuoteMeta(`SELECT word, created_at FROM search_stop_words ORDER BY word ASC`),
+		).WillReturnRows(
+			sqlmock.NewRows([]string{"word", "created_at"}).
+				AddRow("the", createdAt).
+				AddRow("with", createdAt),
+		)
+
+		stopWords, err := stopWordModel.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []*StopWord{
+			{Word: "the", CreatedAt: createdAt},
+			{Word: "with", CreatedAt: createdAt},
+		}, stopWords)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("deletes a stop word", func(t *testing.T) {
+		deleteQuery := regexp.QuoteMeta(`DELETE FROM search_stop_words WHERE word = $1`)
+		sqlMock.ExpectExec(deleteQuery).WithArgs("with").WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(deleteQuery).WithArgs("with").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, stopWordModel.Delete(ctx, "with"))
+		assert.Equal(t, ErrRecordNotFound, stopWordModel.Delete(ctx, "with"))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SuggestionType tells whether a suggestion names a product or a category.
+type SuggestionType string
+
+const (
+	SuggestProduct  SuggestionType = "product"
+	SuggestCategory SuggestionType = "category"
+)
+
+// Suggestion is a product or category name offered while the user types a search.
+type Suggestion struct {
+	Type SuggestionType `json:"type"`
+	ID   int64          `json:"id"`
+	Name string         `json:"name"`
+}
+
+type SuggestionModel struct {
+	db *sql.DB
+}
+
+type SuggestionRepository interface {
+	Suggest(ctx context.Context, q string, limit int) ([]*Suggestion, error)
+}
+
+func NewSuggestionModel(db *sql.DB) *SuggestionModel {
+	return &SuggestionModel{db: db}
+}
+
+// Suggest returns up to limit product and category names that resemble q, closest
+// first. Names are compared with the pg_trgm word similarity of q to the best matching
+// part of the name, so partially typed and misspelt words still match. The <%
+// operator keeps the lookups on the trigram indexes of both tables.
+func (s *SuggestionModel) Suggest(ctx context.Context, q string, limit int) ([]*Suggestion, error) {
+	query := `
+		SELECT type, id, name
+		FROM (
+			(SELECT 'product' AS type, id, name, word_similarity($1, name) AS score
+			FROM products
+			WHERE $1 <% name AND deleted_at IS NULL
+			ORDER BY score DESC, name ASC
+			LIMIT $2)
+			UNION ALL
+			(SELECT 'category' AS type, id, name, word_similarity($1, name) AS score
+			FROM categories
+			WHERE $1 <% name AND deleted_at IS NULL
+			ORDER BY score DESC, name ASC
+			LIMIT $2)
+		) AS suggestions
+		ORDER BY score DESC, name ASC, type ASC
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		if err := rows.Scan(&suggestion.Type, &suggestion.ID, &suggestion.Name); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestionModel_Integration_Suggest(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	seedProducts(t, db, []*Product{
+		{Name: "Wireless Headphones"},
+		{Name: "Phone Case"},
+	})
+	category := Category{Name: "Headphones"}
+	assert.NoError(t, NewCategoryModel(db).Insert(ctx, &category))
+
+	// A misspelt, partially typed word still finds both names.
+	suggestions, err := NewSuggestionModel(db).Suggest(ctx, "headphnes", 10)
+	assert.NoError(t, err)
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, &Suggestion{Type: SuggestCategory, ID: category.ID, Name: "Headphones"}, suggestions[0])
+	assert.Equal(t, SuggestProduct, suggestions[1].Type)
+
+	suggestions, err = NewSuggestionModel(db).Suggest(ctx, "headphnes", 1)
+	assert.NoError(t, err)
+	assert.Len(t, suggestions, 1)
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestionModel_Suggest(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	suggestionModel := NewSuggestionModel(db)
+	ctx := context.Background()
+
+	mockQuery := regexp.QuoteMeta(`
+		SELECT type, id, name
+		FROM (
+			(SELECT 'product' AS type, id, name, word_similarity($1, name) AS score
+			FROM products
+			WHERE $1 <% name AND deleted_at IS NULL
+			ORDER BY score DESC, name ASC
+			LIMIT $2)
+			UNION ALL
+			(SELECT 'category' AS type, id, name, word_similarity($1, name) AS score
+			FROM categories
+			WHERE $1 <% name AND deleted_at IS NULL
+			ORDER BY score DESC, name ASC
+			LIMIT $2)
+		) AS suggestions
+		ORDER BY score DESC, name ASC, type ASC
+		LIMIT $2
+	`)
+
+	t.Run("returns product and category names", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WithArgs("haedph", 5).WillReturnRows(
+			sqlmock.NewRows([]string{"type", "id", "name"}).
+				AddRow("category", 3, "Headphones").
+				AddRow("product", 4, "Wireless Headphones"),
+		)
+
+		suggestions, err := suggestionModel.Suggest(ctx, "haedph", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Suggestion{
+			{Type: SuggestCategory, ID: 3, Name: "Headphones"},
+			{Type: SuggestProduct, ID: 4, Name: "Wireless Headphones"},
+		}, suggestions)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs("zzz", 5).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "name"}))
+
+		suggestions, err := suggestionModel.Suggest(ctx, "zzz", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Suggestion{}, suggestions)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		sqlMock.ExpectQuery(mockQuery).WillReturnError(errors.New("query error"))
+
+		suggestions, err := suggestionModel.Suggest(ctx, "head", 5)
+		assert.Nil(t, suggestions)
+		assert.EqualError(t, err, "query error")
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
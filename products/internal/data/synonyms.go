@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Synonym lists the words and phrases a search for Term also matches, e.g. "tv" =>
+// ["television", "flat screen"]. Synonyms work both ways, so a search for
+// "television" also matches "tv".
+type Synonym struct {
+	ID        int64     `json:"id"`
+	Term      string    `json:"term"`
+	Synonyms  []string  `json:"synonyms"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"-"`
+}
+
+type SynonymModel struct {
+	db *sql.DB
+}
+
+type SynonymRepository interface {
+	Insert(ctx context.Context, synonym *Synonym) error
+	GetByID(ctx context.Context, id int64) (*Synonym, error)
+	GetAll(ctx context.Context) ([]*Synonym, error)
+	Update(ctx context.Context, synonym *Synonym) error
+	Delete(ctx context.Context, id int64) error
+	Rebuild(ctx context.Context) error
+}
+
+func NewSynonymModel(db *sql.DB) *SynonymModel {
+	return &SynonymModel{db: db}
+}
+
+// refreshExpansionsQuery rebuilds the search_expansions view that search_tsquery reads
+// the synonyms from. It is refreshed concurrently so searches are not blocked.
+const refreshExpansionsQuery = `REFRESH MATERIALIZED VIEW CONCURRENTLY search_expansions`
+
+// Insert adds the synonyms of a term. Every write refreshes the search expansions in
+// the same transaction, so the change applies to the next search.
+func (s *SynonymModel) Insert(ctx context.Context, synonym *Synonym) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO search_synonyms (term, synonyms)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version
+	`
+	err = tx.QueryRowContext(ctx, query, synonym.Term, pq.Array(synonym.Synonyms)).Scan(
+		&synonym.ID,
+		&synonym.CreatedAt,
+		&synonym.Version,
+	)
+	if err != nil {
+		return synonymWriteError(err, synonym)
+	}
+
+	if _, err = tx.ExecContext(ctx, refreshExpansionsQuery); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SynonymModel) GetByID(ctx context.Context, id int64) (*Synonym, error) {
+	query := `
+		SELECT id, term, synonyms, created_at, version
+		FROM search_synonyms
+		WHERE id = $1
+	`
+
+	var synonym Synonym
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&synonym.ID,
+		&synonym.Term,
+		pq.Array(&synonym.Synonyms),
+		&synonym.CreatedAt,
+		&synonym.Version,
+	)
+
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &synonym, nil
+}
+
+// GetAll returns every synonym entry ordered by term. The dictionary is small and
+// edited by hand, so it is not paginated.
+func (s *SynonymModel) GetAll(ctx context.Context) ([]*Synonym, error) {
+	query := `
+		SELECT id, term, synonyms, created_at, version
+		FROM search_synonyms
+		ORDER BY term ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synonyms := []*Synonym{}
+	for rows.Next() {
+		var synonym Synonym
+		err := rows.Scan(
+			&synonym.ID,
+			&synonym.Term,
+			pq.Array(&synonym.Synonyms),
+			&synonym.CreatedAt,
+			&synonym.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		synonyms = append(synonyms, &synonym)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return synonyms, nil
+}
+
+func (s *SynonymModel) Update(ctx context.Context, synonym *Synonym) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE search_synonyms
+		SET term = $1, synonyms = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+	`
+	args := []any{synonym.Term, pq.Array(synonym.Synonyms), synonym.ID, synonym.Version}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&synonym.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return synonymWriteError(err, synonym)
+	}
+
+	if _, err = tx.ExecContext(ctx, refreshExpansionsQuery); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SynonymModel) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM search_synonyms WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if _, err = tx.ExecContext(ctx, refreshExpansionsQuery); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Rebuild refreshes the search expansions from the synonyms table. The API keeps them
+// current on every write; Rebuild is for changes made to the table directly.
+func (s *SynonymModel) Rebuild(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, refreshExpansionsQuery)
+	return err
+}
+
+// synonymWriteError reports a term that already has an entry as ErrDuplicateTerm.
+func synonymWriteError(err error, synonym *Synonym) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == ErrUniqueViolation {
+		return fmt.Errorf("term %q: %w", synonym.Term, ErrDuplicateTerm)
+	}
+	return err
+}
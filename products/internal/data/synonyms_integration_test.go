@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymModel_Integration_Search(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	synonymModel := NewSynonymModel(db)
+
+	seedProducts(t, db, []*Product{
+		{Name: "Television 55 inch"},
+		{Name: "Flat Screen Monitor"},
+		{Name: "Phone Case"},
+	})
+
+	names := func(filters Filters) []string {
+		products, _, err := productModel.GetAll(ctx, filters)
+		assert.NoError(t, err)
+		result := []string{}
+		for _, product := range products {
+			result = append(result, product.Name)
+		}
+		return result
+	}
+	filters := Filters{Name: "tv", Page: 1, PageSize: 20}
+
+	assert.Empty(t, names(filters))
+
+	synonym := Synonym{Term: "tv", Synonyms: []string{"television", "flat screen"}}
+	assert.NoError(t, synonymModel.Insert(ctx, &synonym))
+	assert.Equal(t, []string{"Television 55 inch", "Flat Screen Monitor"}, names(filters))
+
+	// Synonyms work both ways.
+	results, _, err := productModel.Search(ctx, "television", Filters{Page: 1, PageSize: 20})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	synonym.Synonyms = []string{"television"}
+	assert.NoError(t, synonymModel.Update(ctx, &synonym))
+	assert.Equal(t, []string{"Television 55 inch"}, names(filters))
+
+	// Stop words are left out of the search instead of requiring a match.
+	filters.Name = "case for phone"
+	assert.Empty(t, names(filters))
+	assert.NoError(t, NewStopWordModel(db).Insert(ctx, &StopWord{Word: "for"}))
+	assert.Equal(t, []string{"Phone Case"}, names(filters))
+
+	assert.NoError(t, synonymModel.Delete(ctx, synonym.ID))
+	filters.Name = "tv"
+	assert.Empty(t, names(filters))
+}
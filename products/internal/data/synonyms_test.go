@@ -0,0 +1,223 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynonymModel_Insert(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	synonymModel := NewSynonymModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	insertQuery := regexp.QuoteMeta(`
+		INSERT INTO search_synonyms (term, synonyms)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version
+	`)
+	refreshQuery := regexp.QuoteMeta(`REFRESH MATERIALIZED VIEW CONCURRENTLY search_expansions`)
+
+	t.Run("inserts the synonyms and refreshes the expansions", func(t *testing.T) {
+		synonym := Synonym{Term: "tv", Synonyms: []string{"television", "flat screen"}}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(insertQuery).
+			WithArgs("tv", `{"television","flat screen"}`).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(3, createdAt, 1),
+			)
+		sqlMock.ExpectExec(refreshQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectCommit()
+
+		err := synonymModel.Insert(ctx, &synonym)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), synonym.ID)
+		assert.Equal(t, 1, synonym.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("term already exists", func(t *testing.T) {
+		synonym := Synonym{Term: "tv", Synonyms: []string{"television"}}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(insertQuery).WillReturnError(&pq.Error{Code: ErrUniqueViolation})
+		sqlMock.ExpectRollback()
+
+		err := synonymModel.Insert(ctx, &synonym)
+		assert.True(t, errors.Is(err, ErrDuplicateTerm))
+		assert.Equal(t, `term "tv": synonyms for this term already exist`, err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refresh fails", func(t *testing.T) {
+		synonym := Synonym{Term: "tv", Synonyms: []string{"television"}}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(insertQuery).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(3, createdAt, 1),
+		)
+		sqlMock.ExpectExec(refreshQuery).WillReturnError(errors.New("refresh error"))
+		sqlMock.ExpectRollback()
+
+		err := synonymModel.Insert(ctx, &synonym)
+		assert.EqualError(t, err, "refresh error")
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestSynonymModel_Get(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	synonymModel := NewSynonymModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	mockCols := []string{"id", "term", "synonyms", "created_at", "version"}
+
+	t.Run("returns the synonym", func(t *testing.T) {
+		sqlMock.ExpectQuery("FROM search_synonyms WHERE id = \\$1").WithArgs(3).WillReturnRows(
+			sqlmock.NewRows(mockCols).AddRow(3, "tv", `{television,"flat screen"}`, createdAt, 1),
+		)
+
+		synonym, err := synonymModel.GetByID(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &Synonym{
+			ID:        3,
+			Term:      "tv",
+			Synonyms:  []string{"television", "flat screen"},
+			Version:   1,
+			CreatedAt: createdAt,
+		}, synonym)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("synonym not found", func(t *testing.T) {
+		sqlMock.ExpectQuery("FROM search_synonyms").WithArgs(3).WillReturnError(sql.ErrNoRows)
+
+		synonym, err := synonymModel.GetByID(ctx, 3)
+		assert.Nil(t, synonym)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("lists the synonyms", func(t *testing.T) {
+		mockQuery := regexp.QuoteMeta(`
+			SELECT id, term, synonyms, created_at, version
+			FROM search_synonyms
+			ORDER BY term ASC
+		`)
+		sqlMock.ExpectQuery(mockQuery).WillReturnRows(
+			sqlmock.NewRows(mockCols).
+				AddRow(4, "sneaker", `{trainer}`, createdAt, 2).
+				AddRow(3, "tv", `{television}`, createdAt, 1),
+		)
+
+		synonyms, err := synonymModel.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, synonyms, 2)
+		assert.Equal(t, []string{"trainer"}, synonyms[0].Synonyms)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestSynonymModel_Update(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	synonymModel := NewSynonymModel(db)
+	ctx := context.Background()
+
+	updateQuery := regexp.QuoteMeta(`
+		UPDATE search_synonyms
+		SET term = $1, synonyms = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+	`)
+	refreshQuery := regexp.QuoteMeta(`REFRESH MATERIALIZED VIEW CONCURRENTLY search_expansions`)
+
+	t.Run("updates the synonyms", func(t *testing.T) {
+		synonym := Synonym{ID: 3, Term: "tv", Synonyms: []string{"telly"}, Version: 1}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(updateQuery).
+			WithArgs("tv", `{"telly"}`, 3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		sqlMock.ExpectExec(refreshQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectCommit()
+
+		err := synonymModel.Update(ctx, &synonym)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, synonym.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		synonym := Synonym{ID: 3, Term: "tv", Synonyms: []string{"telly"}, Version: 1}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery(updateQuery).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		err := synonymModel.Update(ctx, &synonym)
+		assert.Equal(t, ErrEditConflict, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestSynonymModel_Delete(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	synonymModel := NewSynonymModel(db)
+	ctx := context.Background()
+
+	deleteQuery := regexp.QuoteMeta(`DELETE FROM search_synonyms WHERE id = $1`)
+	refreshQuery := regexp.QuoteMeta(`REFRESH MATERIALIZED VIEW CONCURRENTLY search_expansions`)
+
+	t.Run("deletes the synonym", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec(deleteQuery).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec(refreshQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectCommit()
+
+		err := synonymModel.Delete(ctx, 3)
+		assert.NoError(t, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("synonym not found", func(t *testing.T) {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec(deleteQuery).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectRollback()
+
+		err := synonymModel.Delete(ctx, 3)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("rebuild", func(t *testing.T) {
+		sqlMock.ExpectExec(refreshQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := synonymModel.Rebuild(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newTestDB returns a connection pool to the PostgreSQL database named by the
+// PRODUCTS_TEST_DB_DSN environment variable, with every up migration applied to a
+// fresh schema. The schema is dropped when the test finishes. Tests that need a real
+// database are skipped when the variable is not set.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("PRODUCTS_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("PRODUCTS_TEST_DB_DSN is not set, skipping PostgreSQL integration test")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	// Keep a single connection open so that the search_path set below applies to every
+	// statement the test runs.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		db.Close()
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		db.Close()
+	})
+
+	if _, err := db.Exec(fmt.Sprintf("SET search_path TO %s, public", schema)); err != nil {
+		t.Fatalf("failed to set search_path: %v", err)
+	}
+
+	migrations, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to list migrations: %v", err)
+	}
+	sort.Strings(migrations)
+
+	for _, migration := range migrations {
+		stmts, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatalf("failed to read migration %s: %v", migration, err)
+		}
+
+		if _, err := db.Exec(string(stmts)); err != nil {
+			t.Fatalf("failed to apply migration %s: %v", migration, err)
+		}
+	}
+
+	return db
+}
@@ -0,0 +1,298 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// VariantOptions are the option values that set a variant apart from the other
+// variants of its product, e.g. {"size": "M", "color": "red"}. They are stored as a
+// JSONB object.
+type VariantOptions map[string]string
+
+// Variant is a sellable version of a product, identified by its SKU. A variant has
+// its own stock. Its price overrides the product price when set.
+type Variant struct {
+	ID        int64          `json:"id"`
+	ProductID int64          `json:"product_id"`
+	SKU       string         `json:"sku"`
+	Options   VariantOptions `json:"options"`
+	Price     *Money         `json:"price,omitempty"`
+	Quantity  int            `json:"quantity"`
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"-"`
+}
+
+type VariantModel struct {
+	db *sql.DB
+}
+
+type VariantRepository interface {
+	Insert(ctx context.Context, variant *Variant) error
+	GetByID(ctx context.Context, productID, id int64) (*Variant, error)
+	GetAll(ctx context.Context, productID int64) ([]*Variant, error)
+	Update(ctx context.Context, variant *Variant) error
+	Delete(ctx context.Context, productID, id int64) error
+}
+
+func NewVariantModel(db *sql.DB) *VariantModel {
+	return &VariantModel{db: db}
+}
+
+// Value implements the driver.Valuer interface, the options are stored as JSON.
+func (o VariantOptions) Value() (driver.Value, error) {
+	if o == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(o)
+}
+
+// Scan implements the sql.Scanner interface for the JSONB options column.
+func (o *VariantOptions) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into VariantOptions", src)
+	}
+
+	return json.Unmarshal(b, o)
+}
+
+// variantColumns are the columns read for a variant, in the order of variantDest.
+var variantColumns = []string{
+	"id",
+	"product_id",
+	"sku",
+	"options",
+	"price",
+	"quantity",
+	"created_at",
+	"version",
+}
+
+func variantDest(variant *Variant) []any {
+	return []any{
+		&variant.ID,
+		&variant.ProductID,
+		&variant.SKU,
+		&variant.Options,
+		&variant.Price,
+		&variant.Quantity,
+		&variant.CreatedAt,
+		&variant.Version,
+	}
+}
+
+// Insert adds a variant to its product. It returns ErrRecordNotFound if the product
+// does not exist or is deleted.
+func (v *VariantModel) Insert(ctx context.Context, variant *Variant) error {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockVariantProduct(ctx, tx, variant.ProductID); err != nil {
+		return err
+	}
+
+	query, args, _ := psql.Insert("product_variants").
+		Columns("product_id", "sku", "options", "price", "quantity").
+		Values(
+			variant.ProductID,
+			variant.SKU,
+			variant.Options,
+			variant.Price,
+			variant.Quantity).
+		Suffix("RETURNING id, created_at, version").
+		ToSql()
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&variant.ID,
+		&variant.CreatedAt,
+		&variant.Version,
+	)
+
+	if err != nil {
+		return variantWriteError(err, variant)
+	}
+
+	return tx.Commit()
+}
+
+// GetByID returns a variant of the product. A variant of another product, or of a
+// deleted product, is reported as not found.
+func (v *VariantModel) GetByID(ctx context.Context, productID, id int64) (*Variant, error) {
+	query, args, _ := psql.Select(variantColumns...).
+		From("product_variants").
+		Where(sq.Eq{"id": id, "product_id": productID}).
+		Where(`EXISTS(
+			SELECT 1 FROM products
+			WHERE products.id = product_variants.product_id AND products.deleted_at IS NULL
+		)`).
+		ToSql()
+
+	var variant Variant
+	err := v.db.QueryRowContext(ctx, query, args...).Scan(variantDest(&variant)...)
+
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// GetAll returns every variant of the product. Products have a handful of variants, so
+// they are not paginated.
+func (v *VariantModel) GetAll(ctx context.Context, productID int64) ([]*Variant, error) {
+	query, args, _ := psql.Select(variantColumns...).
+		From("product_variants").
+		Where(sq.Eq{"product_id": productID}).
+		OrderBy("id ASC").
+		ToSql()
+
+	rows, err := v.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []*Variant{}
+	for rows.Next() {
+		var variant Variant
+		if err := rows.Scan(variantDest(&variant)...); err != nil {
+			return nil, err
+		}
+		variants = append(variants, &variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+// Update saves the variant. It returns ErrRecordNotFound if its product does not exist
+// or is deleted, and ErrEditConflict if the variant changed since it was read.
+func (v *VariantModel) Update(ctx context.Context, variant *Variant) error {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockVariantProduct(ctx, tx, variant.ProductID); err != nil {
+		return err
+	}
+
+	query, args, _ := psql.Update("product_variants").
+		Set("sku", variant.SKU).
+		Set("options", variant.Options).
+		Set("price", variant.Price).
+		Set("quantity", variant.Quantity).
+		Set("version", variant.Version+1).
+		Where(sq.Eq{"id": variant.ID}).
+		Where(sq.Eq{"product_id": variant.ProductID}).
+		Where(sq.Eq{"version": variant.Version}).
+		Suffix("RETURNING version").
+		ToSql()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&variant.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return variantWriteError(err, variant)
+	}
+
+	return tx.Commit()
+}
+
+// lockVariantProduct locks the product of a variant that is written until the
+// transaction ends, so that it cannot be deleted in the meantime. It returns
+// ErrRecordNotFound if the product does not exist or is deleted.
+func lockVariantProduct(ctx context.Context, tx *sql.Tx, productID int64) error {
+	var id int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR SHARE`,
+		productID,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("product %d: %w", productID, ErrRecordNotFound)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// variantWriteError translates the constraint violations of a variant insert or update
+// into the errors of this package.
+func variantWriteError(err error, variant *Variant) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case pqErr.Code == ErrForeignKeyViolation:
+		return fmt.Errorf("product %d: %w", variant.ProductID, ErrRecordNotFound)
+	case pqErr.Code == ErrNumericValueOutOfRange:
+		return fmt.Errorf("price %s: %w", variant.Price, ErrMoneyOutOfRange)
+	case pqErr.Code == ErrUniqueViolation && pqErr.Constraint == "product_variants_sku_key":
+		return fmt.Errorf("sku %q: %w", variant.SKU, ErrDuplicateSKU)
+	case pqErr.Code == ErrUniqueViolation && pqErr.Constraint == "product_variants_options_key":
+		return ErrDuplicateVariant
+	default:
+		return err
+	}
+}
+
+// Delete removes a variant of the product. It returns ErrRecordNotFound if the
+// product does not exist or is deleted, or has no such variant.
+func (v *VariantModel) Delete(ctx context.Context, productID, id int64) error {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = lockVariantProduct(ctx, tx, productID); err != nil {
+		return err
+	}
+
+	query, args, _ := psql.Delete("product_variants").
+		Where(sq.Eq{"id": id, "product_id": productID}).
+		ToSql()
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariantModel_Integration_Variants(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	productModel := NewProductModel(db)
+	variantModel := NewVariantModel(db)
+
+	_, ids := seedProducts(t, db, []*Product{{Name: "T-Shirt", Price: 19_990}})
+
+	price := Money(24_990)
+	medium := Variant{ProductID: ids[0], SKU: "TS-M", Options: VariantOptions{"size": "M"}}
+	large := Variant{
+		ProductID: ids[0],
+		SKU:       "TS-L",
+		Options:   VariantOptions{"size": "L"},
+		Price:     &price,
+		Quantity:  3,
+	}
+	assert.NoError(t, variantModel.Insert(ctx, &medium))
+	assert.NoError(t, variantModel.Insert(ctx, &large))
+
+	duplicateSKU := Variant{ProductID: ids[0], SKU: "TS-M", Options: VariantOptions{"size": "S"}}
+	assert.ErrorIs(t, variantModel.Insert(ctx, &duplicateSKU), ErrDuplicateSKU)
+
+	duplicateOptions := Variant{ProductID: ids[0], SKU: "TS-M2", Options: VariantOptions{"size": "M"}}
+	assert.ErrorIs(t, variantModel.Insert(ctx, &duplicateOptions), ErrDuplicateVariant)
+
+	missingProduct := Variant{ProductID: ids[0] + 1000, SKU: "TS-XL"}
+	assert.ErrorIs(t, variantModel.Insert(ctx, &missingProduct), ErrRecordNotFound)
+
+	product, err := productModel.GetByIDWithVariants(ctx, ids[0])
+	assert.NoError(t, err)
+	assert.Len(t, product.Variants, 2)
+	assert.Equal(t, "TS-M", product.Variants[0].SKU)
+	assert.Nil(t, product.Variants[0].Price)
+	assert.Equal(t, VariantOptions{"size": "L"}, product.Variants[1].Options)
+	assert.Equal(t, &price, product.Variants[1].Price)
+	assert.Equal(t, 3, product.Variants[1].Quantity)
+
+	// A variant can only be read through its own product.
+	_, err = variantModel.GetByID(ctx, ids[0]+1000, medium.ID)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	// The variants of a deleted product can no longer be read or written.
+	assert.NoError(t, productModel.Delete(ctx, ids[0]))
+	_, err = variantModel.GetByID(ctx, ids[0], medium.ID)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+
+	small := Variant{ProductID: ids[0], SKU: "TS-S", Options: VariantOptions{"size": "S"}}
+	assert.ErrorIs(t, variantModel.Insert(ctx, &small), ErrRecordNotFound)
+
+	medium.Quantity = 5
+	assert.ErrorIs(t, variantModel.Update(ctx, &medium), ErrRecordNotFound)
+	assert.ErrorIs(t, variantModel.Delete(ctx, ids[0], medium.ID), ErrRecordNotFound)
+}
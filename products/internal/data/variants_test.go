@@ -0,0 +1,344 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectProductLock expects the product of a variant write to be locked, and found
+// unless it does not exist or is deleted.
+func expectProductLock(sqlMock sqlmock.Sqlmock, productID int64, found bool) {
+	sqlMock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"id"})
+	if found {
+		rows.AddRow(productID)
+	}
+	sqlMock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR SHARE`,
+	)).
+		WithArgs(productID).
+		WillReturnRows(rows)
+}
+
+func TestVariantModel_Insert(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	variantModel := NewVariantModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	price := Money(12_500)
+
+	mockQuery := regexp.QuoteMeta(`
+		INSERT INTO product_variants (product_id,sku,options,price,quantity)
+		VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, created_at, version
+	`)
+
+	t.Run("inserts a variant", func(t *testing.T) {
+		variant := Variant{
+			ProductID: 12,
+			SKU:       "TS-M",
+			Options:   VariantOptions{"size": "M"},
+			Price:     &price,
+			Quantity:  4,
+		}
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs(12, "TS-M", []byte(`{"size":"M"}`), "12.500", 4).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(3, createdAt, 1),
+			)
+		sqlMock.ExpectCommit()
+
+		err := variantModel.Insert(ctx, &variant)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), variant.ID)
+		assert.Equal(t, createdAt, variant.CreatedAt)
+		assert.Equal(t, 1, variant.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("inserts a variant without a price", func(t *testing.T) {
+		variant := Variant{ProductID: 12, SKU: "TS-L"}
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs(12, "TS-L", []byte(`{}`), nil, 0).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(4, createdAt, 1),
+			)
+		sqlMock.ExpectCommit()
+
+		err := variantModel.Insert(ctx, &variant)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), variant.ID)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product is deleted", func(t *testing.T) {
+		variant := Variant{ProductID: 12, SKU: "TS-L"}
+		expectProductLock(sqlMock, 12, false)
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Insert(ctx, &variant)
+		assert.True(t, errors.Is(err, ErrRecordNotFound))
+		assert.Equal(t, "product 12: record not found", err.Error())
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	testCases := []struct {
+		name        string
+		dbErr       error
+		expectedErr error
+		message     string
+	}{
+		{
+			name:        "product does not exist",
+			dbErr:       &pq.Error{Code: ErrForeignKeyViolation},
+			expectedErr: ErrRecordNotFound,
+			message:     "product 12: record not found",
+		},
+		{
+			name:        "sku already exists",
+			dbErr:       &pq.Error{Code: ErrUniqueViolation, Constraint: "product_variants_sku_key"},
+			expectedErr: ErrDuplicateSKU,
+			message:     `sku "TS-M": sku already exists`,
+		},
+		{
+			name: "options already exist",
+			dbErr: &pq.Error{
+				Code:       ErrUniqueViolation,
+				Constraint: "product_variants_options_key",
+			},
+			expectedErr: ErrDuplicateVariant,
+			message:     "a variant with the same options already exists",
+		},
+		{
+			name:        "price out of range",
+			dbErr:       &pq.Error{Code: ErrNumericValueOutOfRange},
+			expectedErr: ErrMoneyOutOfRange,
+			message:     "price 12.50: amount out of range",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			variant := Variant{
+				ProductID: 12,
+				SKU:       "TS-M",
+				Options:   VariantOptions{"size": "M"},
+				Price:     &price,
+			}
+			expectProductLock(sqlMock, 12, true)
+			sqlMock.ExpectQuery(mockQuery).WillReturnError(tc.dbErr)
+			sqlMock.ExpectRollback()
+
+			err := variantModel.Insert(ctx, &variant)
+			assert.True(t, errors.Is(err, tc.expectedErr))
+			assert.Equal(t, tc.message, err.Error())
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestVariantModel_Get(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	variantModel := NewVariantModel(db)
+	ctx := context.Background()
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	mockCols := []string{
+		"id", "product_id", "sku", "options", "price", "quantity", "created_at", "version",
+	}
+
+	t.Run("returns a variant of the product", func(t *testing.T) {
+		mockQuery := regexp.QuoteMeta(`
+			SELECT id, product_id, sku, options, price, quantity, created_at, version
+			FROM product_variants
+			WHERE id = $1 AND product_id = $2 AND EXISTS(
+				SELECT 1 FROM products
+				WHERE products.id = product_variants.product_id AND products.deleted_at IS NULL
+			)
+		`)
+		sqlMock.ExpectQuery(mockQuery).WithArgs(3, 12).WillReturnRows(
+			sqlMock.NewRows(mockCols).
+				AddRow(3, 12, "TS-M", []byte(`{"size": "M"}`), "12.500", 4, createdAt, 1),
+		)
+
+		variant, err := variantModel.GetByID(ctx, 12, 3)
+		assert.NoError(t, err)
+
+		price := Money(12_500)
+		assert.Equal(t, &Variant{
+			ID:        3,
+			ProductID: 12,
+			SKU:       "TS-M",
+			Options:   VariantOptions{"size": "M"},
+			Price:     &price,
+			Quantity:  4,
+			CreatedAt: createdAt,
+			Version:   1,
+		}, variant)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("variant not found", func(t *testing.T) {
+		sqlMock.ExpectQuery("SELECT").WithArgs(3, 12).WillReturnError(sql.ErrNoRows)
+
+		variant, err := variantModel.GetByID(ctx, 12, 3)
+		assert.Nil(t, variant)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("lists the variants of the product", func(t *testing.T) {
+		mockQuery := regexp.QuoteMeta(`
+			SELECT id, product_id, sku, options, price, quantity, created_at, version
+			FROM product_variants
+			WHERE product_id = $1
+			ORDER BY id ASC
+		`)
+		sqlMock.ExpectQuery(mockQuery).WithArgs(12).WillReturnRows(
+			sqlMock.NewRows(mockCols).
+				AddRow(3, 12, "TS-M", []byte(`{"size": "M"}`), nil, 4, createdAt, 1).
+				AddRow(4, 12, "TS-L", []byte(`{"size": "L"}`), nil, 0, createdAt, 1),
+		)
+
+		variants, err := variantModel.GetAll(ctx, 12)
+		assert.NoError(t, err)
+		assert.Len(t, variants, 2)
+		assert.Nil(t, variants[0].Price)
+		assert.Equal(t, "TS-L", variants[1].SKU)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestVariantModel_Update(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	variantModel := NewVariantModel(db)
+	ctx := context.Background()
+
+	mockQuery := regexp.QuoteMeta(`
+		UPDATE product_variants
+		SET sku = $1, options = $2, price = $3, quantity = $4, version = $5
+		WHERE id = $6 AND product_id = $7 AND version = $8
+		RETURNING version
+	`)
+	variant := func() *Variant {
+		return &Variant{
+			ID:        3,
+			ProductID: 12,
+			SKU:       "TS-M",
+			Options:   VariantOptions{"size": "M"},
+			Quantity:  6,
+			Version:   1,
+		}
+	}
+
+	t.Run("updates the variant", func(t *testing.T) {
+		v := variant()
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectQuery(mockQuery).
+			WithArgs("TS-M", []byte(`{"size":"M"}`), nil, 6, 2, 3, 12, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		sqlMock.ExpectCommit()
+
+		err := variantModel.Update(ctx, v)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, v.Version)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product is deleted", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, false)
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Update(ctx, variant())
+		assert.True(t, errors.Is(err, ErrRecordNotFound))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectQuery(mockQuery).WillReturnError(sql.ErrNoRows)
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Update(ctx, variant())
+		assert.Equal(t, ErrEditConflict, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("sku already exists", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectQuery(mockQuery).WillReturnError(
+			&pq.Error{Code: ErrUniqueViolation, Constraint: "product_variants_sku_key"},
+		)
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Update(ctx, variant())
+		assert.True(t, errors.Is(err, ErrDuplicateSKU))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestVariantModel_Delete(t *testing.T) {
+	t.Parallel()
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	variantModel := NewVariantModel(db)
+	ctx := context.Background()
+
+	mockQuery := regexp.QuoteMeta(`DELETE FROM product_variants WHERE id = $1 AND product_id = $2`)
+
+	t.Run("deletes the variant", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectExec(mockQuery).WithArgs(3, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+
+		err := variantModel.Delete(ctx, 12, 3)
+		assert.NoError(t, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("variant not found", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, true)
+		sqlMock.ExpectExec(mockQuery).WithArgs(3, 12).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Delete(ctx, 12, 3)
+		assert.Equal(t, ErrRecordNotFound, err)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("product is deleted", func(t *testing.T) {
+		expectProductLock(sqlMock, 12, false)
+		sqlMock.ExpectRollback()
+
+		err := variantModel.Delete(ctx, 12, 3)
+		assert.True(t, errors.Is(err, ErrRecordNotFound))
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
@@ -10,9 +10,29 @@ import (
 	"github.com/chlovec/go-ecommerce/products/internal/data"
 )
 
+// categoryDTO holds the fields of a new category. A category without a parent_id is
+// created at the top level. A category without a slug gets one derived from its name.
 type categoryDTO struct {
-	Name        string `json:"name"        validate:"required,min=3,max=100"`
-	Description string `json:"description" validate:"omitempty"`
+	Name            string               `json:"name"             validate:"required,min=3,max=100"`
+	Slug            string               `json:"slug"             validate:"omitempty,slug"`
+	Description     string               `json:"description"      validate:"omitempty"`
+	ParentID        *int64               `json:"parent_id"        validate:"omitempty,gte=1"`
+	AttributeSchema data.AttributeSchema `json:"attribute_schema" validate:"omitempty,max=50,dive,keys,attrname,endkeys,attrspec"`
+}
+
+// updateCategoryDTO holds the fields that may be changed by a PATCH request. The
+// client must send back the version it last read so concurrent edits are detected.
+// A parent_id of 0 moves the category to the top level. An attribute_schema replaces
+// the whole schema; products already in the category are not checked against it.
+// Renaming a category derives its slug from the new name unless a slug is sent with
+// it; the old slug keeps redirecting to it.
+type updateCategoryDTO struct {
+	Name            *string               `json:"name"             validate:"omitempty,min=3,max=100"`
+	Slug            *string               `json:"slug"             validate:"omitempty,slug"`
+	Description     *string               `json:"description"      validate:"omitempty"`
+	ParentID        *int64                `json:"parent_id"        validate:"omitempty,gte=0"`
+	AttributeSchema *data.AttributeSchema `json:"attribute_schema" validate:"omitempty,max=50,dive,keys,attrname,endkeys,attrspec"`
+	Version         int                   `json:"version"          validate:"required,gte=1"`
 }
 
 // POST v1/api/categories
@@ -38,17 +58,27 @@ func (h *Handlers) CreateCategoryHandler(w http.ResponseWriter, r *http.Request)
 	// Save category to db. If category id does not exist, send 400 Bad Request to the
 	// client. For any other error, respond send 500 Internal Server Error.
 	category := data.Category{
-		Name:        payload.Name,
-		Description: payload.Description,
+		Name:            payload.Name,
+		Slug:            payload.Slug,
+		Description:     payload.Description,
+		ParentID:        payload.ParentID,
+		AttributeSchema: payload.AttributeSchema,
 	}
 
 	// Create a context with a 5-second timeout deadline.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
 	defer cancel()
 
 	err = h.models.Category.Insert(ctx, &category)
 	if err != nil {
-		h.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidParentId):
+			h.badRequestResponse(w, r, err)
+		case errors.Is(err, data.ErrDuplicateSlug):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -65,10 +95,7 @@ func (h *Handlers) CreateCategoryHandler(w http.ResponseWriter, r *http.Request)
 func (h *Handlers) GetCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	// Read and validate id param.
 	id, err := h.readIDParam(r)
-	if err != nil || id < 1 {
-		if id < 1 && err == nil {
-			err = fmt.Errorf("%w: %d", ErrInvalidIDParam, id)
-		}
+	if err != nil {
 		h.badRequestResponse(w, r, err)
 		return
 	}
@@ -90,20 +117,58 @@ func (h *Handlers) GetCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, r, http.StatusOK, env, nil)
 }
 
-// GET /v1/api/categories?name={name}&page={page}&page_size={page_size}&sort={sort}
+// GET v1/api/categories/by-slug/{slug}
+func (h *Handlers) GetCategoryBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate slug param.
+	slug, err := h.readSlugParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, current, err := h.models.Category.LookupSlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// An old slug of a renamed category redirects to its current one.
+	if current != slug {
+		h.slugRedirectResponse(w, r, "/v1/api/categories/by-slug/", current)
+		return
+	}
+
+	category, err := h.models.Category.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"category": category}, nil)
+}
+
+// GET /v1/api/categories?name={name}&lang={lang}&page={page}&page_size={page_size}&sort={sort}
+// &include_deleted={bool}
 func (h *Handlers) ListCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	// parse query params
-	var filters data.Filters
 	qs := r.URL.Query()
 	valErrs := map[string]string{}
 
-	filters.DateFrom = h.readTime(qs, "date_from", nil, valErrs)
-	filters.DateTo = h.readTime(qs, "date_to", nil, valErrs)
-	filters.IDs = h.readInt64Slice(qs, "id", []int64{}, valErrs)
-	filters.Name = qs.Get("name")
-	filters.Sorts = h.readCSV(qs, "sort", []string{})
-	filters.Page = h.readInt(qs, "page", 1, valErrs)
-	filters.PageSize = h.readInt(qs, "page_size", 20, valErrs)
+	filters := h.readFilters(qs, data.CategoryFilterSpec, valErrs)
+	filters.Language = h.readLanguage(r, qs, valErrs)
+	filters.IncludeDeleted = h.readBool(qs, "include_deleted", false, valErrs)
 
 	if len(valErrs) > 0 {
 		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
@@ -124,7 +189,12 @@ func (h *Handlers) ListCategoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	categories, metadata, err := h.models.Category.GetAll(ctx, filters)
 	if err != nil {
-		h.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			h.badRequestResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -132,3 +202,228 @@ func (h *Handlers) ListCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	env := envelope{"categories": categories, "metadata": metadata}
 	h.writeJSON(w, r, http.StatusOK, env, nil)
 }
+
+// GET v1/api/categories/tree
+func (h *Handlers) GetCategoryTreeHandler(w http.ResponseWriter, r *http.Request) {
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tree, err := h.models.Category.GetTree(ctx)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"categories": tree}, nil)
+}
+
+// GET v1/api/categories/{id}/ancestors
+func (h *Handlers) GetCategoryAncestorsHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The ancestors are ordered from the root down to the category itself, ready to be
+	// rendered as breadcrumbs.
+	ancestors, err := h.models.Category.GetAncestors(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"ancestors": ancestors}, nil)
+}
+
+// PATCH v1/api/categories/{id}
+func (h *Handlers) UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Parse and validate the request body before touching the database.
+	var payload updateCategoryDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	category, err := h.models.Category.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only copy over the fields that were present in the request body. The version
+	// always comes from the client so that the update fails if the category has been
+	// changed since the client last read it.
+	if payload.Name != nil && *payload.Name != category.Name {
+		category.Name = *payload.Name
+		category.Slug = ""
+	}
+	if payload.Slug != nil {
+		category.Slug = *payload.Slug
+	}
+	if payload.Description != nil {
+		category.Description = *payload.Description
+	}
+	if payload.ParentID != nil {
+		category.ParentID = payload.ParentID
+		if *payload.ParentID == 0 {
+			category.ParentID = nil
+		}
+	}
+	if payload.AttributeSchema != nil {
+		category.AttributeSchema = *payload.AttributeSchema
+	}
+	category.Version = payload.Version
+
+	err = h.models.Category.Update(ctx, category)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			h.editConflictResponse(w, r, err)
+		case errors.Is(err, data.ErrCategoryCycle), errors.Is(err, data.ErrDuplicateSlug):
+			h.conflictResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidParentId):
+			h.badRequestResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"category": category}, nil)
+}
+
+// DELETE v1/api/categories/{id}?cascade=reassign&to={id}
+func (h *Handlers) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	cascade := qs.Get("cascade")
+	to := int64(h.readInt(qs, "to", 0, valErrs))
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate the cascade options. Reassigning requires a target category other than
+	// the one being deleted.
+	switch {
+	case cascade != "" && cascade != "reassign":
+		valErrs["cascade"] = "must be one of [reassign]"
+	case cascade == "reassign" && to < 1:
+		valErrs["to"] = "must be a valid category id when cascade is reassign"
+	case cascade == "reassign" && to == id:
+		valErrs["to"] = "must be different from the category being deleted"
+	}
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	if cascade == "reassign" {
+		err = h.models.Category.DeleteAndReassign(ctx, id, to)
+	} else {
+		err = h.models.Category.Delete(ctx, id)
+	}
+
+	var schemaErr *data.AttributeSchemaError
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrCategoryHasProducts),
+			errors.Is(err, data.ErrCategoryHasChildren):
+			h.conflictResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidCategoryId):
+			h.badRequestResponse(w, r, err)
+		case errors.As(err, &schemaErr):
+			h.errorResponse(w, r, http.StatusUnprocessableEntity, envelope{
+				"to":          schemaErr.Error(),
+				"product_ids": schemaErr.ProductIDs,
+			}, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "category successfully deleted"}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// POST v1/api/categories/{id}/restore
+func (h *Handlers) RestoreCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	// A subcategory can only be restored under a parent that is not deleted itself.
+	category, err := h.models.Category.Restore(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidParentId):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"category": category}, nil)
+}
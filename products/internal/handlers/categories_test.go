@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -16,8 +17,6 @@ import (
 	"testing"
 
 	"github.com/chlovec/go-ecommerce/products/internal/data"
-	"github.com/go-playground/validator/v10"
-	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -47,6 +46,61 @@ func (m *MockCategoryRepository) GetAll(
 	return categories, metadata, args.Error(2)
 }
 
+func (m *MockCategoryRepository) Update(ctx context.Context, category *data.Category) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepository) Restore(ctx context.Context, id int64) (*data.Category, error) {
+	args := m.Called(ctx, id)
+	category, _ := args.Get(0).(*data.Category)
+	return category, args.Error(1)
+}
+
+func (m *MockCategoryRepository) Purge(
+	ctx context.Context,
+	before time.Time,
+	limit int,
+) (int64, error) {
+	args := m.Called(ctx, before, limit)
+	purged, _ := args.Get(0).(int64)
+	return purged, args.Error(1)
+}
+
+func (m *MockCategoryRepository) DeleteAndReassign(
+	ctx context.Context,
+	id int64,
+	toID int64,
+) error {
+	args := m.Called(ctx, id, toID)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepository) GetTree(ctx context.Context) ([]*data.Category, error) {
+	args := m.Called(ctx)
+	tree, _ := args.Get(0).([]*data.Category)
+	return tree, args.Error(1)
+}
+
+func (m *MockCategoryRepository) GetAncestors(
+	ctx context.Context,
+	id int64,
+) ([]*data.Category, error) {
+	args := m.Called(ctx, id)
+	ancestors, _ := args.Get(0).([]*data.Category)
+	return ancestors, args.Error(1)
+}
+
+func (m *MockCategoryRepository) LookupSlug(ctx context.Context, slug string) (int64, string, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(int64), args.String(1), args.Error(2)
+}
+
 func setupCategoryHandlerTest(
 	t *testing.T,
 	w io.Writer,
@@ -63,7 +117,7 @@ func setupCategoryHandlerTest(
 
 	handlers := Handlers{
 		logger:    logger,
-		validator: validator.New(),
+		validator: newValidator(),
 		models: data.Models{
 			Category: mockCategoryRepo,
 		},
@@ -105,6 +159,7 @@ func TestCategoryHandler_CreateCategoryHandler(t *testing.T) {
 			Run(func(args mock.Arguments) {
 				p := args.Get(1).(*data.Category)
 				p.ID = 123
+				p.Slug = "test-category"
 				p.Version = 1
 				p.CreatedAt = time.Now()
 			}).
@@ -121,7 +176,9 @@ func TestCategoryHandler_CreateCategoryHandler(t *testing.T) {
 			"category": {
 				"id": 123,
 				"name": "Test Category",
+				"slug": "test-category",
 				"description": "A test category",
+				"parent_id": null,
 				"version": 1
 			}
 		}`
@@ -152,6 +209,7 @@ func TestCategoryHandler_CreateCategoryHandler(t *testing.T) {
 			Run(func(args mock.Arguments) {
 				p := args.Get(1).(*data.Category)
 				p.ID = 123
+				p.Slug = "test-category"
 				p.Version = 1
 				p.CreatedAt = time.Now()
 			}).
@@ -168,7 +226,9 @@ func TestCategoryHandler_CreateCategoryHandler(t *testing.T) {
 			"category": {
 				"id": 123,
 				"name": "Test Category",
+				"slug": "test-category",
 				"description":"",
+				"parent_id": null,
 				"version": 1
 			}
 		}`
@@ -386,21 +446,135 @@ func TestCategoryHandler_CreateCategoryHandler(t *testing.T) {
 		assert.Equal(t, 5, len(logData), "expected 5 entries, got %d", len(logData))
 		buf.Reset()
 	})
+
+	t.Run("slug is taken", func(t *testing.T) {
+		payload := `{"name": "Test Category", "slug": "phones"}`
+		categoryToInsert := data.Category{Name: "Test Category", Slug: "phones"}
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPost,
+			"/categories",
+		)
+		mockCategoryRepo.On("Insert", mock.Anything, &categoryToInsert).
+			Return(fmt.Errorf("slug phones: %w", data.ErrDuplicateSlug))
+
+		h.CreateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error": "slug phones: slug already exists"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("create category with an attribute schema", func(t *testing.T) {
+		payload := `{
+			"name": "Memory",
+			"slug": "memory",
+			"attribute_schema": {
+				"capacity_gb": {"type": "int", "required": true, "values": ["8", "16"], "unit": "GB"}
+			}
+		}`
+		categoryToInsert := data.Category{
+			Name: "Memory",
+			Slug: "memory",
+			AttributeSchema: data.AttributeSchema{
+				"capacity_gb": {
+					Type:     data.AttributeInt,
+					Required: true,
+					Values:   []string{"8", "16"},
+					Unit:     "GB",
+				},
+			},
+		}
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPost,
+			"/categories",
+		)
+		mockCategoryRepo.On("Insert", mock.Anything, &categoryToInsert).Return(nil)
+
+		h.CreateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"category": {
+				"id": 0,
+				"name": "Memory",
+				"slug": "memory",
+				"description": "",
+				"parent_id": null,
+				"version": 0,
+				"attribute_schema": {
+					"capacity_gb": {"type": "int", "required": true, "values": ["8", "16"], "unit": "GB"}
+				}
+			}
+		}`
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("invalid attribute schema", func(t *testing.T) {
+		payload := `{
+			"name": "Memory",
+			"attribute_schema": {
+				"Speed": {"type": "int"},
+				"capacity_gb": {"type": "int", "values": ["8", "16.0"]},
+				"released": {"type": "date"}
+			}
+		}`
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPost,
+			"/categories",
+		)
+
+		h.CreateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		spec := "must have a type of [string int decimal bool], at most 100 values of " +
+			"that type and a unit of at most 20 characters"
+		expectedResponse := fmt.Sprintf(`{
+			"error": {
+				"AttributeSchema[Speed]": "must be lower case letters, digits and underscores starting with a letter",
+				"AttributeSchema[capacity_gb]": %[1]q,
+				"AttributeSchema[released]": %[1]q
+			}
+		}`, spec)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockCategoryRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
 }
 
 func TestCategoryHandler_GetByID(t *testing.T) {
 	var id int64 = 23
 	var buf bytes.Buffer
 
-	// Inject httprouter params into request context
-	params := httprouter.Params{
-		httprouter.Param{Key: "id", Value: "23"},
-	}
-
 	t.Run("fetch category successfully", func(t *testing.T) {
 		category := data.Category{
 			ID:          id,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   time.Now(),
@@ -413,7 +587,7 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			http.MethodGet,
 			"/categories/23",
 		)
-		req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+		req.SetPathValue("id", "23")
 		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(&category, nil)
 
 		h.GetCategoryHandler(rw, req)
@@ -430,7 +604,9 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			"category": {
 				"id": 23,
 				"name": "Test Category",
+				"slug": "test-category",
 				"description": "A test category",
+				"parent_id": null,
 				"version": 1
 			}
 		}`
@@ -448,12 +624,7 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			"/categories/-1",
 		)
 
-		invalidParams := httprouter.Params{
-			httprouter.Param{Key: "id", Value: "-1"},
-		}
-		req = req.WithContext(
-			context.WithValue(req.Context(), httprouter.ParamsKey, invalidParams),
-		)
+		req.SetPathValue("id", "-1")
 		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(
 			nil, data.ErrRecordNotFound,
 		)
@@ -489,12 +660,7 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			"/categories/abc",
 		)
 
-		invalidParams := httprouter.Params{
-			httprouter.Param{Key: "id", Value: "abc"},
-		}
-		req = req.WithContext(
-			context.WithValue(req.Context(), httprouter.ParamsKey, invalidParams),
-		)
+		req.SetPathValue("id", "abc")
 		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(
 			nil, data.ErrRecordNotFound,
 		)
@@ -529,7 +695,7 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			http.MethodGet,
 			"/categories/23",
 		)
-		req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+		req.SetPathValue("id", "23")
 		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(
 			nil, data.ErrRecordNotFound,
 		)
@@ -565,7 +731,7 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 			http.MethodGet,
 			"/categories/23",
 		)
-		req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+		req.SetPathValue("id", "23")
 		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(
 			nil, errors.New("error processing record"),
 		)
@@ -594,6 +760,90 @@ func TestCategoryHandler_GetByID(t *testing.T) {
 	})
 }
 
+func TestCategoryHandler_GetBySlug(t *testing.T) {
+	var buf bytes.Buffer
+	category := &data.Category{ID: 4, Name: "Phones", Slug: "phones", Version: 1}
+
+	testCases := []struct {
+		name             string
+		slug             string
+		current          string
+		lookupErr        error
+		getErr           error
+		expectedStatus   int
+		expectedLocation string
+		expectedResponse string
+	}{
+		{
+			name:           "fetch category by its slug",
+			slug:           "phones",
+			current:        "phones",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"category": {
+					"id": 4,
+					"name": "Phones",
+					"slug": "phones",
+					"description": "",
+					"parent_id": null,
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "old slug redirects to the current one",
+			slug:             "mobile-phones",
+			current:          "phones",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/v1/api/categories/by-slug/phones",
+			expectedResponse: `{"slug": "phones"}`,
+		},
+		{
+			name:             "slug not found",
+			slug:             "tablets",
+			lookupErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "server error",
+			slug:             "phones",
+			current:          "phones",
+			getErr:           errors.New("query error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				"/categories/by-slug/"+tc.slug,
+			)
+			req.SetPathValue("slug", tc.slug)
+			mockCategoryRepo.On("LookupSlug", mock.Anything, tc.slug).
+				Return(int64(4), tc.current, tc.lookupErr)
+			mockCategoryRepo.On("GetByID", mock.Anything, int64(4)).Return(category, tc.getErr)
+
+			h.GetCategoryBySlugHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.Equal(t, tc.expectedLocation, res.Header.Get("Location"))
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
 func TestCategoryHandler_List(t *testing.T) {
 	var buf bytes.Buffer
 
@@ -601,6 +851,7 @@ func TestCategoryHandler_List(t *testing.T) {
 		category := data.Category{
 			ID:          123,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   time.Now(),
@@ -614,7 +865,13 @@ func TestCategoryHandler_List(t *testing.T) {
 			"/categories",
 		)
 
-		filters := data.Filters{IDs: []int64{}, Page: 1, PageSize: 20, Sorts: []string{}}
+		filters := data.Filters{
+			IDs:          []int64{},
+			Page:         1,
+			PageSize:     20,
+			Sorts:        []string{},
+			SortSafelist: data.CategoryFilterSpec.SortSafelist(),
+		}
 		metadata := data.Metadata{
 			CurrentPage:  1,
 			PageSize:     20,
@@ -639,7 +896,9 @@ func TestCategoryHandler_List(t *testing.T) {
 			"categories": [{
 				"id": 123,
 				"name": "Test Category",
+				"slug": "test-category",
 				"description": "A test category",
+				"parent_id": null,
 				"version": 1
 			}],
 			"metadata":{
@@ -660,6 +919,7 @@ func TestCategoryHandler_List(t *testing.T) {
 		category := data.Category{
 			ID:          123,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   time.Now(),
@@ -686,13 +946,14 @@ func TestCategoryHandler_List(t *testing.T) {
 		dateFrom := time.Date(2020, time.January, 30, 0, 0, 0, 0, time.UTC)
 		dateTo := time.Date(2025, time.August, 10, 15, 4, 5, 0, time.UTC)
 		filters := data.Filters{
-			IDs:      []int64{23, 92, 48, 54},
-			Name:     "test",
-			DateFrom: &dateFrom,
-			DateTo:   &dateTo,
-			Page:     92,
-			PageSize: 100,
-			Sorts:    []string{"id", "-created_at", "-name"},
+			IDs:          []int64{23, 92, 48, 54},
+			Name:         "test",
+			DateFrom:     &dateFrom,
+			DateTo:       &dateTo,
+			Page:         92,
+			PageSize:     100,
+			Sorts:        []string{"id", "-created_at", "-name"},
+			SortSafelist: data.CategoryFilterSpec.SortSafelist(),
 		}
 		metadata := data.Metadata{
 			CurrentPage: 92, PageSize: 100, FirstPage: 1, LastPage: 98, TotalRecords: 9701,
@@ -715,7 +976,9 @@ func TestCategoryHandler_List(t *testing.T) {
 			"categories": [{
 				"id": 123,
 				"name": "Test Category",
+				"slug": "test-category",
 				"description": "A test category",
+				"parent_id": null,
 				"version": 1
 			}],
 			"metadata":{
@@ -736,6 +999,7 @@ func TestCategoryHandler_List(t *testing.T) {
 		category := data.Category{
 			ID:          123,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   time.Now(),
@@ -827,6 +1091,7 @@ func TestCategoryHandler_List(t *testing.T) {
 		category := data.Category{
 			ID:          123,
 			Name:        "Test Category",
+			Slug:        "test-category",
 			Description: "A test category",
 			Version:     1,
 			CreatedAt:   time.Now(),
@@ -893,7 +1158,7 @@ func TestCategoryHandler_List(t *testing.T) {
 		assert.JSONEq(t, expectedResponse, string(body))
 
 		uri := "/categories?date_from=2020-01-30T00%3A00%3A00Z&date_to=2025-08-10T15%3A04%3A05Z&id=23%2C92%2C48&name=testyyhhhhanbgdrsebdbdbdbdbdbdbdbd+testyyhhhhanbgdrsebdbdbdbdbdbdbdbd+testyyhhhhanbgdrsebdbdbdbdbdbdbdbd&page=-10&page_size=103&sort=id%2C-test%2C-name"
-		msg := "Key: 'Filters.Name' Error:Field validation for 'Name' failed on the 'max' tag\nKey: 'Filters.Sorts[1]' Error:Field validation for 'Sorts[1]' failed on the 'oneof' tag\nKey: 'Filters.Page' Error:Field validation for 'Page' failed on the 'gte' tag\nKey: 'Filters.PageSize' Error:Field validation for 'PageSize' failed on the 'lte' tag"
+		msg := "Key: 'Filters.Name' Error:Field validation for 'Name' failed on the 'max' tag\nKey: 'Filters.Page' Error:Field validation for 'Page' failed on the 'gte' tag\nKey: 'Filters.PageSize' Error:Field validation for 'PageSize' failed on the 'lte' tag\nKey: 'Filters.Sorts[1]' Error:Field validation for 'Sorts[1]' failed on the 'oneof' tag"
 
 		logData := ParseLog(t, &buf)
 		assert.Equal(t, "ERROR", logData["level"])
@@ -914,7 +1179,13 @@ func TestCategoryHandler_List(t *testing.T) {
 			"/categories",
 		)
 
-		filters := data.Filters{IDs: []int64{}, Page: 1, PageSize: 20, Sorts: []string{}}
+		filters := data.Filters{
+			IDs:          []int64{},
+			Page:         1,
+			PageSize:     20,
+			Sorts:        []string{},
+			SortSafelist: data.CategoryFilterSpec.SortSafelist(),
+		}
 		mockCategoryRepo.On("GetAll", mock.Anything, filters).
 			Return([]*data.Category{}, data.Metadata{}, errors.New("db error"))
 
@@ -941,3 +1212,788 @@ func TestCategoryHandler_List(t *testing.T) {
 		buf.Reset()
 	})
 }
+
+func TestCategoryHandler_Update(t *testing.T) {
+	var id int64 = 23
+	var buf bytes.Buffer
+
+	newCategory := func() *data.Category {
+		return &data.Category{
+			ID:          id,
+			Name:        "Test Category",
+			Slug:        "test-category",
+			Description: "A test category",
+			Version:     2,
+		}
+	}
+
+	t.Run("update category successfully", func(t *testing.T) {
+		payload := `{"description": "An updated category", "version": 2}`
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPatch,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+
+		expectedUpdate := newCategory()
+		expectedUpdate.Description = "An updated category"
+
+		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(newCategory(), nil)
+		mockCategoryRepo.On("Update", mock.Anything, expectedUpdate).
+			Run(func(args mock.Arguments) {
+				c := args.Get(1).(*data.Category)
+				c.Version = 3
+			}).
+			Return(nil)
+
+		h.UpdateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"category": {
+				"id": 23,
+				"name": "Test Category",
+				"slug": "test-category",
+				"description": "An updated category",
+				"parent_id": null,
+				"version": 3
+			}
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t, expectedResponse, string(body))
+		assert.Equal(t, buf.String(), "")
+		mockCategoryRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("version is required", func(t *testing.T) {
+		payload := `{"name": "Updated Category"}`
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPatch,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+
+		h.UpdateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, `{"error": {"version": "is required"}}`, string(body))
+		mockCategoryRepo.AssertNotCalled(t, "GetByID", mock.Anything, id)
+		buf.Reset()
+	})
+
+	t.Run("record not found", func(t *testing.T) {
+		payload := `{"name": "Updated Category", "version": 2}`
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPatch,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(nil, data.ErrRecordNotFound)
+
+		h.UpdateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		logData := ParseLog(t, &buf)
+		assert.Equal(t, "record not found", logData["msg"])
+		buf.Reset()
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		payload := `{"name": "Updated Category", "version": 1}`
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(payload),
+			http.MethodPatch,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+
+		expectedUpdate := newCategory()
+		expectedUpdate.Name = "Updated Category"
+		expectedUpdate.Slug = ""
+		expectedUpdate.Version = 1
+
+		mockCategoryRepo.On("GetByID", mock.Anything, id).Return(newCategory(), nil)
+		mockCategoryRepo.On("Update", mock.Anything, expectedUpdate).Return(data.ErrEditConflict)
+
+		h.UpdateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": "unable to update the record due to an edit conflict, please try again"
+		}`
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+
+		logData := ParseLog(t, &buf)
+		assert.Equal(t, "ERROR", logData["level"])
+		assert.Equal(t, "PATCH", logData["method"])
+		assert.Equal(t, "edit conflict", logData["msg"])
+		buf.Reset()
+	})
+}
+
+func TestCategoryHandler_Delete(t *testing.T) {
+	var id int64 = 23
+	var buf bytes.Buffer
+
+	t.Run("delete category successfully", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("Delete", mock.Anything, id).Return(nil)
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"message": "category successfully deleted"}`, string(body))
+		assert.Equal(t, buf.String(), "")
+		buf.Reset()
+	})
+
+	t.Run("category still has products", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("Delete", mock.Anything, id).Return(data.ErrCategoryHasProducts)
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error": "category still has products"}`, string(body))
+
+		logData := ParseLog(t, &buf)
+		assert.Equal(t, "DELETE", logData["method"])
+		assert.Equal(t, "category still has products", logData["msg"])
+		buf.Reset()
+	})
+
+	t.Run("record not found", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("Delete", mock.Anything, id).Return(data.ErrRecordNotFound)
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("delete and reassign products", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23?cascade=reassign&to=7",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("DeleteAndReassign", mock.Anything, id, int64(7)).Return(nil)
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockCategoryRepo.AssertNotCalled(t, "Delete", mock.Anything, id)
+		mockCategoryRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("reassign target does not exist", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23?cascade=reassign&to=7",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("DeleteAndReassign", mock.Anything, id, int64(7)).
+			Return(fmt.Errorf("category_id 7 does not exist: %w", data.ErrInvalidCategoryId))
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{"error": "category_id 7 does not exist: invalid category_id"}`,
+			string(body),
+		)
+		buf.Reset()
+	})
+
+	t.Run("reassigned products do not match the schema of the target", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23?cascade=reassign&to=7",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("DeleteAndReassign", mock.Anything, id, int64(7)).
+			Return(&data.AttributeSchemaError{CategoryID: 7, ProductIDs: []int64{3, 5}})
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{"error": {
+				"to": "products 3, 5 do not match the attribute schema of category_id 7",
+				"product_ids": [3, 5]
+			}}`,
+			string(body),
+		)
+		buf.Reset()
+	})
+
+	t.Run("invalid cascade options", func(t *testing.T) {
+		testCases := []struct {
+			target   string
+			expected string
+		}{
+			{
+				"/categories/23?cascade=delete",
+				`{"error": {"cascade": "must be one of [reassign]"}}`,
+			},
+			{
+				"/categories/23?cascade=reassign",
+				`{"error": {"to": "must be a valid category id when cascade is reassign"}}`,
+			},
+			{
+				"/categories/23?cascade=reassign&to=23",
+				`{"error": {"to": "must be different from the category being deleted"}}`,
+			},
+		}
+
+		for _, tc := range testCases {
+			rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+				t,
+				&buf,
+				nil,
+				http.MethodDelete,
+				tc.target,
+			)
+			req.SetPathValue("id", "23")
+
+			h.DeleteCategoryHandler(rw, req)
+			res := rw.Result()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			res.Body.Close()
+
+			assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+			assert.JSONEq(t, tc.expected, string(body))
+			mockCategoryRepo.AssertNotCalled(t, "Delete", mock.Anything, id)
+			buf.Reset()
+		}
+	})
+
+	t.Run("invalid to parameter", func(t *testing.T) {
+		rw, req, h, _ := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/23?cascade=reassign&to=abc",
+		)
+		req.SetPathValue("id", "23")
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error": {"to": "must be an integer value: abc"}}`, string(body))
+		buf.Reset()
+	})
+}
+
+func TestCategoryHandler_Restore(t *testing.T) {
+	var id int64 = 23
+	var buf bytes.Buffer
+
+	t.Run("restore category successfully", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodPost,
+			"/categories/23/restore",
+		)
+		req.SetPathValue("id", "23")
+		category := &data.Category{ID: id, Name: "Phones", Slug: "phones", Description: "Phones", Version: 3}
+		mockCategoryRepo.On("Restore", mock.Anything, id).Return(category, nil)
+
+		h.RestoreCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		expectedResponse := `{
+			"category": {
+				"id": 23,
+				"name": "Phones",
+				"slug": "phones",
+				"description": "Phones",
+				"parent_id": null,
+				"version": 3
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		assert.Equal(t, buf.String(), "")
+		buf.Reset()
+	})
+
+	t.Run("category is not deleted", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodPost,
+			"/categories/23/restore",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("Restore", mock.Anything, id).Return(nil, data.ErrRecordNotFound)
+
+		h.RestoreCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("parent is deleted", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodPost,
+			"/categories/23/restore",
+		)
+		req.SetPathValue("id", "23")
+		err := fmt.Errorf("parent_id 7 is deleted: %w", data.ErrInvalidParentId)
+		mockCategoryRepo.On("Restore", mock.Anything, id).Return(nil, err)
+
+		h.RestoreCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error": "parent_id 7 is deleted: invalid parent_id"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodPost,
+			"/categories/23/restore",
+		)
+		req.SetPathValue("id", "23")
+		mockCategoryRepo.On("Restore", mock.Anything, id).Return(nil, errors.New("restore error"))
+
+		h.RestoreCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		logData := ParseLog(t, &buf)
+		assert.Equal(t, "POST", logData["method"])
+		assert.Equal(t, "restore error", logData["msg"])
+		buf.Reset()
+	})
+
+	t.Run("lists deleted categories", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/categories?include_deleted=true",
+		)
+
+		filters := data.Filters{
+			IDs:            []int64{},
+			Page:           1,
+			PageSize:       20,
+			Sorts:          []string{},
+			SortSafelist:   data.CategoryFilterSpec.SortSafelist(),
+			IncludeDeleted: true,
+		}
+		mockCategoryRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Category{}, data.Metadata{}, nil)
+
+		h.ListCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockCategoryRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+}
+
+func TestCategoryHandler_Hierarchy(t *testing.T) {
+	var buf bytes.Buffer
+	parentID := int64(7)
+
+	t.Run("create a subcategory of a missing parent", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			strings.NewReader(`{"name": "Phones", "parent_id": 7}`),
+			http.MethodPost,
+			"/categories",
+		)
+		mockCategoryRepo.On("Insert", mock.Anything, &data.Category{Name: "Phones", ParentID: &parentID}).
+			Return(fmt.Errorf("parent_id 7 does not exist: %w", data.ErrInvalidParentId))
+
+		h.CreateCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error": "parent_id 7 does not exist: invalid parent_id"}`, string(body))
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name             string
+		payload          string
+		expectedParentID *int64
+		updateErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "move under another category",
+			payload:          `{"parent_id": 7, "version": 1}`,
+			expectedParentID: &parentID,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{
+				"category": {
+					"id": 3,
+					"name": "Phones",
+					"slug": "phones",
+					"description": "",
+					"parent_id": 7,
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:           "move to the top level",
+			payload:        `{"parent_id": 0, "version": 1}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"category": {
+					"id": 3,
+					"name": "Phones",
+					"slug": "phones",
+					"description": "",
+					"parent_id": null,
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "move under a subcategory",
+			payload:          `{"parent_id": 7, "version": 1}`,
+			expectedParentID: &parentID,
+			updateErr:        data.ErrCategoryCycle,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{
+				"error": "category cannot be moved under itself or its subcategories"
+			}`,
+		},
+		{
+			name:             "negative parent_id",
+			payload:          `{"parent_id": -1, "version": 1}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"parent_id": "must be greater than or equal to 0"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPatch,
+				"/categories/3",
+			)
+			req.SetPathValue("id", "3")
+			mockCategoryRepo.On("GetByID", mock.Anything, int64(3)).
+				Return(&data.Category{ID: 3, Name: "Phones", Slug: "phones", ParentID: &parentID, Version: 1}, nil)
+			mockCategoryRepo.On("Update", mock.Anything, &data.Category{
+				ID:       3,
+				Name:     "Phones",
+				Slug:     "phones",
+				ParentID: tc.expectedParentID,
+				Version:  1,
+			}).Return(tc.updateErr)
+
+			h.UpdateCategoryHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+
+	t.Run("delete a category with subcategories", func(t *testing.T) {
+		rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/categories/7",
+		)
+		req.SetPathValue("id", "7")
+		mockCategoryRepo.On("Delete", mock.Anything, int64(7)).Return(data.ErrCategoryHasChildren)
+
+		h.DeleteCategoryHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error": "category still has subcategories"}`, string(body))
+		buf.Reset()
+	})
+}
+
+func TestCategoryHandler_GetTree(t *testing.T) {
+	var buf bytes.Buffer
+	parentID := int64(7)
+	tree := []*data.Category{{
+		ID:   7,
+		Name: "Electronics",
+		Slug: "electronics",
+		Children: []*data.Category{
+			{ID: 3, Name: "Phones", Slug: "phones", ParentID: &parentID, Children: []*data.Category{}},
+		},
+	}}
+
+	testCases := []struct {
+		name             string
+		treeErr          error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "returns the tree",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"categories": [{
+					"id": 7,
+					"name": "Electronics",
+					"slug": "electronics",
+					"description": "",
+					"parent_id": null,
+					"version": 0,
+					"children": [{
+						"id": 3,
+						"name": "Phones",
+						"slug": "phones",
+						"description": "",
+						"parent_id": 7,
+						"version": 0
+					}]
+				}]
+			}`,
+		},
+		{
+			name:             "server error",
+			treeErr:          errors.New("tree error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				"/categories/tree",
+			)
+			mockCategoryRepo.On("GetTree", mock.Anything).Return(tree, tc.treeErr)
+
+			h.GetCategoryTreeHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestCategoryHandler_GetAncestors(t *testing.T) {
+	var buf bytes.Buffer
+	parentID := int64(7)
+	ancestors := []*data.Category{
+		{ID: 7, Name: "Electronics", Slug: "electronics"},
+		{ID: 3, Name: "Phones", Slug: "phones", ParentID: &parentID},
+	}
+
+	testCases := []struct {
+		name             string
+		id               string
+		ancestorsErr     error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "returns the breadcrumbs",
+			id:             "3",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"ancestors": [
+					{"id": 7, "name": "Electronics", "slug": "electronics", "description": "", "parent_id": null, "version": 0},
+					{"id": 3, "name": "Phones", "slug": "phones", "description": "", "parent_id": 7, "version": 0}
+				]
+			}`,
+		},
+		{
+			name:             "category not found",
+			id:               "3",
+			ancestorsErr:     data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "abc",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: abc"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockCategoryRepo := setupCategoryHandlerTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				"/categories/"+tc.id+"/ancestors",
+			)
+			req.SetPathValue("id", tc.id)
+			mockCategoryRepo.On("GetAncestors", mock.Anything, int64(3)).
+				Return(ancestors, tc.ancestorsErr)
+
+			h.GetCategoryAncestorsHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
@@ -4,25 +4,60 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
+	"github.com/chlovec/go-ecommerce/products/internal/data"
 	"github.com/go-playground/validator/v10"
 )
 
-var ErrInvalidIDParam = errors.New("invalid id parameter")
+var (
+	ErrInvalidIDParam   = errors.New("invalid id parameter")
+	ErrInvalidSlugParam = errors.New("invalid slug parameter")
+)
+
+var moneyRangeMessage = fmt.Sprintf("must be an amount between 0 and %s", data.MaxMoney)
+
+// The messages of the error responses whose error is not shown to the client. They are
+// also reported for the rows of an import that fail the same way.
+const (
+	notFoundMessage     = "the requested resource could not be found"
+	editConflictMessage = "unable to update the record due to an edit conflict, please try again"
+	serverErrorMessage  = "the server encountered a problem and could not process your request"
+)
 
 var fieldJSONMap = map[string]string{
-	"CreatedAt":   "created_at",
-	"CategoryID":  "category_id",
-	"Description": "description",
-	"ID":          "id",
-	"Name":        "name",
-	"Page":        "page",
-	"PageSize":    "page_size",
-	"Price":       "price",
-	"Quantity":    "quantity",
-	"Version":     "version",
-	"Sorts":       "sort",
+	"CreatedAt":       "created_at",
+	"CategoryID":      "category_id",
+	"Description":     "description",
+	"ID":              "id",
+	"Name":            "name",
+	"Page":            "page",
+	"PageSize":        "page_size",
+	"Price":           "price",
+	"Currency":        "currency",
+	"Quantity":        "quantity",
+	"Version":         "version",
+	"Sorts":           "sort",
+	"TTLSeconds":      "ttl_seconds",
+	"Delta":           "delta",
+	"Reason":          "reason",
+	"Reference":       "reference",
+	"SKU":             "sku",
+	"Term":            "term",
+	"Synonyms":        "synonyms",
+	"Word":            "word",
+	"Options":         "options",
+	"ParentID":        "parent_id",
+	"Attributes":      "attributes",
+	"AttributeSchema": "attribute_schema",
+	"Slug":            "slug",
+	"ExternalID":      "external_id",
+	"IDs":             "ids",
+	"Type":            "type",
+	"Percent":         "percent",
+	"RoundTo":         "round_to",
+	"Rounding":        "rounding",
 }
 
 func (h *Handlers) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -41,8 +76,27 @@ func (h *Handlers) failedValidationResponse(
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (h *Handlers) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
-	message := "the requested resource could not be found"
-	h.errorResponse(w, r, http.StatusNotFound, message, err)
+	h.errorResponse(w, r, http.StatusNotFound, notFoundMessage, err)
+}
+
+// The editConflictResponse() method will be used to send a 409 Conflict status code and
+// JSON response to the client when an optimistic concurrency check fails.
+func (h *Handlers) editConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	h.errorResponse(w, r, http.StatusConflict, editConflictMessage, err)
+}
+
+// The conflictResponse() method will be used to send a 409 Conflict status code when a
+// request cannot be completed because of the current state of the resource. The
+// error message is sent to the client as is.
+func (h *Handlers) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	h.errorResponse(w, r, http.StatusConflict, err.Error(), err)
+}
+
+// The priceOutOfRangeResponse() method will be used to send a 422 Unprocessable Entity
+// status code when the database rejects a price that does not fit its column.
+func (h *Handlers) priceOutOfRangeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	message := map[string]string{"price": moneyRangeMessage}
+	h.errorResponse(w, r, http.StatusUnprocessableEntity, message, err)
 }
 
 // The serverErrorResponse() method will be used when our handlers encounter an
@@ -50,8 +104,7 @@ func (h *Handlers) notFoundResponse(w http.ResponseWriter, r *http.Request, err
 // errorResponse() helper to send a 500 Internal Server Error status code and JSON
 // response (containing a generic error message) to the client.
 func (h *Handlers) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	message := "the server encountered a problem and could not process your request"
-	h.errorResponse(w, r, http.StatusInternalServerError, message, err)
+	h.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage, err)
 }
 
 // The errorResponse() method is a helper for sending JSON-formatted error
@@ -109,8 +162,14 @@ func getFieldErrorMessage(fe validator.FieldError) string {
 	case "email":
 		return "is not a valid email address"
 	case "min":
+		if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+			return fmt.Sprintf("must have at least %s items", fe.Param())
+		}
 		return fmt.Sprintf("must be at least %s characters long", fe.Param())
 	case "max":
+		if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
 		return fmt.Sprintf("must be at most %s characters long", fe.Param())
 	case "len":
 		return fmt.Sprintf("must be exactly %s characters long", fe.Param())
@@ -140,6 +199,26 @@ func getFieldErrorMessage(fe validator.FieldError) string {
 		return "must be a boolean value"
 	case "datetime":
 		return fmt.Sprintf("must be a valid datetime format (%s)", fe.Param())
+	case "money":
+		return moneyRangeMessage
+	case "iso4217":
+		return "must be a valid ISO 4217 currency code"
+	case "slug":
+		return fmt.Sprintf(
+			"must be at most %d lower case letters and digits separated by single hyphens",
+			data.MaxSlugLength,
+		)
+	case "searchword":
+		return "must be a single word of letters and digits"
+	case "searchphrase":
+		return "must be words of letters and digits"
+	case "attrname":
+		return "must be lower case letters, digits and underscores starting with a letter"
+	case "attrvalue":
+		return "must be a string of at most 200 characters, a number or a boolean"
+	case "attrspec":
+		return "must have a type of [string int decimal bool], at most 100 values of " +
+			"that type and a unit of at most 20 characters"
 	default:
 		return fmt.Sprintf("failed validation: %s", fe.Error())
 	}
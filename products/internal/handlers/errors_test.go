@@ -50,7 +50,7 @@ func TestLogError(t *testing.T) {
 
 	testError := "request error"
 	req := httptest.NewRequest(http.MethodGet, "/test/endpoint", nil)
-	h := NewHandlers(logger, &sql.DB{})
+	h := NewHandlers(logger, &sql.DB{}, "")
 	h.logError(req, errors.New(testError))
 
 	assert.Contains(t, buf.String(), testError)
@@ -62,7 +62,7 @@ func TestErrorResponse(t *testing.T) {
 		var buf bytes.Buffer
 		logger := slog.New(slog.NewTextHandler(&buf, nil))
 
-		h := NewHandlers(logger, &db)
+		h := NewHandlers(logger, &db, "")
 		req := httptest.NewRequest(http.MethodGet, "/test/endpoint", nil)
 		rw := httptest.NewRecorder()
 
@@ -89,7 +89,7 @@ func TestErrorResponse(t *testing.T) {
 		var buf bytes.Buffer
 		logger := slog.New(slog.NewTextHandler(&buf, nil))
 
-		h := NewHandlers(logger, &db)
+		h := NewHandlers(logger, &db, "")
 		req := httptest.NewRequest(http.MethodGet, "/test/endpoint", nil)
 		rw := httptest.NewRecorder()
 
@@ -222,6 +222,16 @@ func TestGetValidationMessage(t *testing.T) {
 			mockFieldError{"CreatedAt", "datetime", "2006-01-02", ""},
 			"must be a valid datetime format (2006-01-02)",
 		},
+		{
+			"Money",
+			mockFieldError{"Price", "money", "", ""},
+			"must be an amount between 0 and 9999999.999",
+		},
+		{
+			"Currency",
+			mockFieldError{"Currency", "iso4217", "", ""},
+			"must be a valid ISO 4217 currency code",
+		},
 		{
 			"Unknown Tag",
 			mockFieldError{"Custom", "custom_rule", "", "Custom validation error"},
@@ -2,7 +2,12 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/chlovec/go-ecommerce/products/internal/data"
 	"github.com/go-playground/validator/v10"
@@ -12,15 +17,139 @@ type Handlers struct {
 	logger    *slog.Logger
 	validator *validator.Validate
 	models    data.Models
+	// searchLanguage is the language names are searched in when a request does not
+	// ask for one.
+	searchLanguage string
 }
 
-func NewHandlers(logger *slog.Logger, db *sql.DB) *Handlers {
+func NewHandlers(logger *slog.Logger, db *sql.DB, searchLanguage string) *Handlers {
 	return &Handlers{
-		logger:    logger,
-		validator: validator.New(),
+		logger:         logger,
+		validator:      newValidator(),
+		searchLanguage: searchLanguage,
 		models: data.Models{
-			Product:  data.NewProductModel(db),
-			Category: data.NewCategoryModel(db),
+			Product:       data.NewProductModel(db),
+			Category:      data.NewCategoryModel(db),
+			Reservation:   data.NewReservationModel(db),
+			StockMovement: data.NewStockMovementModel(db),
+			Variant:       data.NewVariantModel(db),
+			Suggestion:    data.NewSuggestionModel(db),
+			Synonym:       data.NewSynonymModel(db),
+			StopWord:      data.NewStopWordModel(db),
+			Revision:      data.NewRevisionModel(db),
+			Job:           data.NewJobModel(db),
 		},
 	}
 }
+
+// newValidator returns a validator with the struct level validations used by the
+// handlers registered on it.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(validateFilters, data.Filters{})
+	v.RegisterCustomTypeFunc(validatedNullableMoney, nullableMoney{})
+	_ = v.RegisterValidation("money", validateMoney)
+	_ = v.RegisterValidation("slug", validateSlug)
+	_ = v.RegisterValidation("searchword", validateSearchWord)
+	_ = v.RegisterValidation("searchphrase", validateSearchPhrase)
+	_ = v.RegisterValidation("attrname", validateAttributeName)
+	_ = v.RegisterValidation("attrvalue", validateAttributeValue)
+	_ = v.RegisterValidation("attrspec", validateAttributeSpec)
+	return v
+}
+
+// validateMoney checks that an amount is not negative and fits the price column.
+func validateMoney(fl validator.FieldLevel) bool {
+	amount := data.Money(fl.Field().Int())
+	return amount >= 0 && amount <= data.MaxMoney
+}
+
+// validateSlug checks that a slug is in the form Slugify gives the names of products
+// and categories.
+func validateSlug(fl validator.FieldLevel) bool {
+	return data.IsSlug(fl.Field().String())
+}
+
+// validateSearchWord checks that a search dictionary entry is a single word of letters
+// and digits, the units search_tsquery splits a search into.
+func validateSearchWord(fl validator.FieldLevel) bool {
+	return isSearchWord(fl.Field().String())
+}
+
+// validateSearchPhrase checks that a synonym is one or more words of letters and digits
+// separated by single spaces.
+func validateSearchPhrase(fl validator.FieldLevel) bool {
+	for word := range strings.SplitSeq(fl.Field().String(), " ") {
+		if !isSearchWord(word) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSearchWord(s string) bool {
+	return s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// validateAttributeName checks that a product attribute is named with lower case
+// letters, digits and underscores, starting with a letter, so that it can be used as
+// an attr. query parameter.
+func validateAttributeName(fl validator.FieldLevel) bool {
+	return isAttributeName(fl.Field().String())
+}
+
+// validateAttributeValue checks that a product attribute holds a string of at most
+// 200 characters, a number or a boolean. Nested objects, arrays and null are rejected.
+func validateAttributeValue(fl validator.FieldLevel) bool {
+	switch v := fl.Field().Interface().(type) {
+	case string:
+		return utf8.RuneCountInString(v) <= 200
+	case float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateAttributeSpec checks an attribute declared by a category schema: the type
+// must be known, the allowed values must be values of that type and the unit short.
+func validateAttributeSpec(fl validator.FieldLevel) bool {
+	spec, ok := fl.Field().Interface().(data.AttributeSpec)
+	if !ok || !slices.Contains(data.AttributeTypes, spec.Type) {
+		return false
+	}
+	if len(spec.Values) > 100 || utf8.RuneCountInString(spec.Unit) > 20 {
+		return false
+	}
+	for _, value := range spec.Values {
+		if !spec.Type.IsValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAttributeName(s string) bool {
+	if s == "" || len(s) > 50 || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_'
+	})
+}
+
+// validateFilters checks every requested sort key against the safelist of the resource
+// being listed. Failures are reported as oneof errors so they are rendered like any
+// other enumerated value.
+func validateFilters(sl validator.StructLevel) {
+	filters := sl.Current().Interface().(data.Filters)
+	for i, sort := range filters.Sorts {
+		if !slices.Contains(filters.SortSafelist, sort) {
+			field := fmt.Sprintf("Sorts[%d]", i)
+			param := strings.Join(filters.SortSafelist, " ")
+			sl.ReportError(sort, field, field, "oneof", param)
+		}
+	}
+}
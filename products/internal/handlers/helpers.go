@@ -1,26 +1,40 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/chlovec/go-ecommerce/products/internal/data"
 )
 
 type envelope map[string]any
 
 func (h *Handlers) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
-	// Use http.MaxBytesReader() to limit the size of the request body to 1,048,576
-	// bytes (1MB).
-	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	// Limit the size of the request body to 1,048,576 bytes (1MB).
+	return h.readJSONLimit(w, r, dst, 1_048_576)
+}
+
+// The readJSONLimit() helper reads the JSON request body like readJSON() does, with a
+// size limit of maxBytes instead of 1MB.
+func (h *Handlers) readJSONLimit(
+	w http.ResponseWriter,
+	r *http.Request,
+	dst any,
+	maxBytes int64,
+) error {
+	// Use http.MaxBytesReader() to limit the size of the request body.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 
 	// Initialize the json.Decoder, and call the DisallowUnknownFields() method on it
 	// before decoding. If the JSON from the client includes any field that cannot be
@@ -114,17 +128,53 @@ func (h *Handlers) writeJSON(
 	}
 }
 
+// The readIDParam() helper reads the id parameter from the request URL and converts it
+// to a positive int64. Anything that is not a positive integer is reported as an
+// ErrInvalidIDParam.
 func (h *Handlers) readIDParam(r *http.Request) (int64, error) {
-	params := httprouter.ParamsFromContext(r.Context())
-	idString := params.ByName("id")
+	return h.readIDParamNamed(r, "id")
+}
+
+// The readIDParamNamed() helper is readIDParam for routes that carry more than one id,
+// such as /products/{id}/variants/{variant_id}.
+func (h *Handlers) readIDParamNamed(r *http.Request, name string) (int64, error) {
+	idString := r.PathValue(name)
 	id, err := strconv.ParseInt(idString, 10, 64)
-	if err != nil {
+	if err != nil || id < 1 {
 		return 0, fmt.Errorf("%w: %s", ErrInvalidIDParam, idString)
 	}
 
 	return id, nil
 }
 
+// The readSlugParam() helper reads the slug parameter from the request URL. Anything
+// that is not in the form of a slug is reported as an ErrInvalidSlugParam.
+func (h *Handlers) readSlugParam(r *http.Request) (string, error) {
+	slug := r.PathValue("slug")
+	if !data.IsSlug(slug) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSlugParam, slug)
+	}
+
+	return slug, nil
+}
+
+// The slugRedirectResponse() helper responds with 301 Moved Permanently to the resource
+// at the current slug, keeping the query string of the request.
+func (h *Handlers) slugRedirectResponse(w http.ResponseWriter, r *http.Request, path, slug string) {
+	location := url.URL{Path: path + slug, RawQuery: r.URL.RawQuery}
+
+	headers := make(http.Header)
+	headers.Set("Location", location.String())
+	h.writeJSON(w, r, http.StatusMovedPermanently, envelope{"slug": slug}, headers)
+}
+
+// The actorContext() helper returns the context writes are made with: a background
+// context carrying the actor named by the X-Actor header, which the revisions of the
+// written products and categories record.
+func (h *Handlers) actorContext(r *http.Request) context.Context {
+	return data.ContextWithActor(context.Background(), strings.TrimSpace(r.Header.Get("X-Actor")))
+}
+
 // The readCSV() helper reads a string value from the query string and then splits it
 // into a slice on the comma character. If no matching key could be found, it returns
 // the provided default value.
@@ -171,6 +221,29 @@ func (h *Handlers) readInt(
 	return i
 }
 
+// The readBool() helper reads a boolean value from the query string. If no matching key
+// could be found it returns the provided default value, and if the value couldn't be
+// parsed the error message is recorded in valErrs.
+func (h *Handlers) readBool(
+	qs url.Values,
+	key string,
+	defaultValue bool,
+	valErrs map[string]string,
+) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		valErrs[key] = fmt.Sprintf("must be a boolean value: %s", s)
+		return defaultValue
+	}
+
+	return b
+}
+
 func (h *Handlers) readInt64Slice(
 	qs url.Values,
 	key string,
@@ -186,7 +259,7 @@ func (h *Handlers) readInt64Slice(
 	for _, idString := range ids {
 		id, err := strconv.ParseInt(strings.TrimSpace(idString), 10, 64)
 		if err != nil {
-			valErrs["id"] = fmt.Sprintf("invalid id: %q", idString)
+			valErrs[key] = fmt.Sprintf("invalid id: %q", idString)
 			return nil
 		}
 
@@ -215,3 +288,216 @@ func (h *Handlers) readTime(
 
 	return &timeVal
 }
+
+// The readFilters() helper reads the list query parameters shared by every resource
+// (id, name, date range, sort and paging) together with the resource specific
+// conditions declared in spec. Parse errors are recorded in valErrs.
+func (h *Handlers) readFilters(
+	qs url.Values,
+	spec data.FilterSpec,
+	valErrs map[string]string,
+) data.Filters {
+	var filters data.Filters
+
+	filters.DateFrom = h.readTime(qs, "date_from", nil, valErrs)
+	filters.DateTo = h.readTime(qs, "date_to", nil, valErrs)
+	filters.IDs = h.readInt64Slice(qs, "id", []int64{}, valErrs)
+	filters.Name = qs.Get("name")
+	filters.Conditions = h.readConditions(qs, spec, valErrs)
+	filters.Sorts = h.readCSV(qs, "sort", []string{})
+	filters.SortSafelist = spec.SortSafelist()
+	filters.Page = h.readInt(qs, "page", 1, valErrs)
+	filters.PageSize = h.readInt(qs, "page_size", 20, valErrs)
+	filters.Cursor = h.readCursor(qs, filters.Sorts, valErrs)
+
+	return filters
+}
+
+// The readLanguage() helper returns the language names are matched in: the lang query
+// parameter, else the most preferred supported language of the Accept-Language header,
+// else the language of the catalog. Only the primary subtag counts, so en-GB is
+// searched as en. Languages without a text search configuration of their own end up
+// with the simple one. An unsupported lang parameter is recorded in valErrs.
+func (h *Handlers) readLanguage(
+	r *http.Request,
+	qs url.Values,
+	valErrs map[string]string,
+) string {
+	supported := data.SearchLanguages()
+
+	if qs.Has("lang") {
+		lang := primaryLanguage(qs.Get("lang"))
+		if !slices.Contains(supported, lang) {
+			valErrs["lang"] = fmt.Sprintf("must be one of %v", supported)
+			return ""
+		}
+		return lang
+	}
+
+	best, bestWeight := "", 0.0
+	for entry := range strings.SplitSeq(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		lang := primaryLanguage(tag)
+		if !slices.Contains(supported, lang) {
+			continue
+		}
+
+		weight := 1.0
+		if s, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			w, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			weight = w
+		}
+
+		if weight > bestWeight {
+			best, bestWeight = lang, weight
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	return h.searchLanguage
+}
+
+// primaryLanguage returns the lower-cased primary subtag of a language tag.
+func primaryLanguage(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(lang)
+}
+
+// maxAttributeConditions is the number of attribute filters a listing accepts.
+const maxAttributeConditions = 10
+
+// The readAttributeConditions() helper reads the attr.{name} query parameters. A plain
+// name filters on equality and a name suffixed with _gte or _lte on a numeric range,
+// e.g. attr.wattage_gte=500. Parse errors are recorded in valErrs under the parameter.
+func (h *Handlers) readAttributeConditions(
+	qs url.Values,
+	valErrs map[string]string,
+) []data.AttributeCondition {
+	var conditions []data.AttributeCondition
+
+	// Read the parameters in a stable order so that the conditions are too.
+	for _, key := range slices.Sorted(maps.Keys(qs)) {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok {
+			continue
+		}
+
+		op := data.OpEq
+		for _, rangeOp := range []data.FilterOp{data.OpGte, data.OpLte} {
+			if n, ok := strings.CutSuffix(name, "_"+string(rangeOp)); ok {
+				name, op = n, rangeOp
+			}
+		}
+		if !isAttributeName(name) {
+			valErrs[key] = "must name an attribute of lower case letters, digits and underscores"
+			continue
+		}
+
+		s := qs.Get(key)
+		var value any = s
+		if op != data.OpEq {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				valErrs[key] = fmt.Sprintf("must be a numeric value: %s", s)
+				continue
+			}
+			value = f
+		}
+
+		conditions = append(conditions, data.AttributeCondition{Name: name, Op: op, Value: value})
+	}
+
+	if len(conditions) > maxAttributeConditions {
+		valErrs["attr"] = fmt.Sprintf("must not have more than %d filters", maxAttributeConditions)
+	}
+
+	return conditions
+}
+
+// The readCursor() helper switches a listing to keyset pagination when the cursor
+// parameter is present. An empty cursor starts at the first page. A cursor can only
+// be used with the sort it was issued for, since its values are those of the sort
+// columns.
+func (h *Handlers) readCursor(
+	qs url.Values,
+	sorts []string,
+	valErrs map[string]string,
+) *data.Cursor {
+	if !qs.Has("cursor") {
+		return nil
+	}
+
+	cursor, err := data.DecodeCursor(qs.Get("cursor"))
+	if err != nil {
+		valErrs["cursor"] = "must be a cursor returned by a previous request"
+		return nil
+	}
+
+	if len(cursor.Values) > 0 && !slices.Equal(cursor.Sorts, sorts) {
+		valErrs["cursor"] = "does not match the requested sort"
+		return nil
+	}
+
+	return cursor
+}
+
+// The readConditions() helper reads a condition for every field and operator declared
+// in spec. An equality filter is read from the field name itself and any other
+// operator from the field name suffixed with the operator, e.g. price_gte.
+func (h *Handlers) readConditions(
+	qs url.Values,
+	spec data.FilterSpec,
+	valErrs map[string]string,
+) []data.Condition {
+	var conditions []data.Condition
+
+	for _, field := range spec {
+		for _, op := range field.Ops {
+			key := field.Name
+			if op != data.OpEq {
+				key = fmt.Sprintf("%s_%s", field.Name, op)
+			}
+
+			s := qs.Get(key)
+			if s == "" {
+				continue
+			}
+
+			var value any
+			var err error
+
+			switch field.Type {
+			case data.IntField:
+				if op == data.OpEq {
+					ids := h.readInt64Slice(qs, key, nil, valErrs)
+					if ids == nil {
+						continue
+					}
+					value = ids
+				} else if value, err = strconv.ParseInt(s, 10, 64); err != nil {
+					valErrs[key] = fmt.Sprintf("must be an integer value: %s", s)
+					continue
+				}
+			case data.MoneyField:
+				if value, err = data.ParseMoney(s); err != nil {
+					valErrs[key] = fmt.Sprintf("must be a decimal value: %s", s)
+					continue
+				}
+			case data.BoolField:
+				if value, err = strconv.ParseBool(s); err != nil {
+					valErrs[key] = fmt.Sprintf("must be a boolean value: %s", s)
+					continue
+				}
+			}
+
+			conditions = append(conditions, data.Condition{Field: field.Name, Op: op, Value: value})
+		}
+	}
+
+	return conditions
+}
@@ -6,9 +6,11 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/chlovec/go-ecommerce/products/internal/data"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -118,3 +120,29 @@ func TestReadJSON(t *testing.T) {
 		assert.Equal(t, "json: Unmarshal(non-pointer handlers.TestStruct)", err.Error())
 	})
 }
+
+func TestReadConditions(t *testing.T) {
+	h := Handlers{}
+
+	t.Run("reads the conditions of the filter spec", func(t *testing.T) {
+		qs := url.Values{"category_id": {"1,2"}, "price_gte": {"10.50"}, "in_stock": {"true"}}
+		valErrs := map[string]string{}
+
+		conditions := h.readConditions(qs, data.ProductFilterSpec, valErrs)
+		assert.Empty(t, valErrs)
+		assert.Equal(t, []data.Condition{
+			{Field: "price", Op: data.OpGte, Value: data.Money(10_500)},
+			{Field: "category_id", Op: data.OpEq, Value: []int64{1, 2}},
+			{Field: "in_stock", Op: data.OpEq, Value: true},
+		}, conditions)
+	})
+
+	t.Run("leaves out an invalid list of ids", func(t *testing.T) {
+		qs := url.Values{"category_id": {"1,two"}}
+		valErrs := map[string]string{}
+
+		conditions := h.readConditions(qs, data.ProductFilterSpec, valErrs)
+		assert.Equal(t, map[string]string{"category_id": `invalid id: "two"`}, valErrs)
+		assert.Empty(t, conditions)
+	})
+}
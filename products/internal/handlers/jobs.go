@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// The kinds of job queued by the handlers.
+const (
+	jobImportProducts     = "import_products"
+	jobRebuildSearch      = "rebuild_search"
+	jobBulkUpdateProducts = "bulk_update_products"
+)
+
+// A JobReport records that the first progress steps of a job are done, with the
+// result so far.
+type JobReport func(progress int, result any)
+
+// A JobRunner runs a claimed job of its kind, resuming after the steps the job made
+// progress with, and returns its result or the error it fails with. It reports each
+// step it completes. When ctx is cancelled it returns ctx.Err() as soon as it can,
+// leaving the step it was on to be run again.
+type JobRunner func(ctx context.Context, job *data.Job, report JobReport) (any, error)
+
+// JobRunners returns the runner of each kind of job the handlers queue.
+func (h *Handlers) JobRunners() map[string]JobRunner {
+	return map[string]JobRunner{
+		jobImportProducts:     h.runImportJob,
+		jobRebuildSearch:      h.runRebuildSearchJob,
+		jobBulkUpdateProducts: h.runBulkUpdateJob,
+	}
+}
+
+// GET v1/api/jobs/{id}
+func (h *Handlers) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := h.models.Job.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"job": job}, nil)
+}
+
+// The enqueueJob() helper queues a job of the kind for the workers, with the payload
+// and the number of steps it takes, and responds with 202 Accepted and the job. The
+// Location header points to the job, which reports its progress.
+func (h *Handlers) enqueueJob(
+	w http.ResponseWriter,
+	r *http.Request,
+	kind string,
+	payload any,
+	total int,
+) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	job := data.Job{Kind: kind, Payload: body, Total: total}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Job.Insert(ctx, &job)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/api/jobs/%d", job.ID))
+
+	h.writeJSON(w, r, http.StatusAccepted, envelope{"job": job}, headers)
+}
+
+// The logJobError() helper logs an unexpected error met while running a job.
+func (h *Handlers) logJobError(job *data.Job, err error) {
+	h.logger.Error(err.Error(), "job", job.ID, "kind", job.Kind)
+}
+
+// The jobServerError() helper logs an unexpected error that fails a job, and returns
+// the error the job is reported with in its place.
+func (h *Handlers) jobServerError(job *data.Job, err error) error {
+	h.logJobError(job, err)
+	return errors.New(serverErrorMessage)
+}
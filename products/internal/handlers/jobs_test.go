@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRepository) Insert(ctx context.Context, job *data.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) GetByID(ctx context.Context, id int64) (*data.Job, error) {
+	args := m.Called(ctx, id)
+	job, _ := args.Get(0).(*data.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) Claim(
+	ctx context.Context,
+	kinds []string,
+	lease time.Duration,
+) (*data.Job, error) {
+	args := m.Called(ctx, kinds, lease)
+	job, _ := args.Get(0).(*data.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) Report(ctx context.Context, job *data.Job, lease time.Duration) error {
+	args := m.Called(ctx, job, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Finish(ctx context.Context, job *data.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Release(ctx context.Context, job *data.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+var jobCreatedAt = time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+// queueJob serves the request with a handler that queues a job, checks that it
+// responds with 202 Accepted and the location of the job, and returns the job.
+func queueJob(
+	t *testing.T,
+	h *Handlers,
+	handler http.HandlerFunc,
+	rw *httptest.ResponseRecorder,
+	req *http.Request,
+) *data.Job {
+	t.Helper()
+
+	mockJobRepo := new(MockJobRepository)
+	h.models.Job = mockJobRepo
+
+	var queued *data.Job
+	mockJobRepo.On("Insert", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			queued = args.Get(1).(*data.Job)
+			queued.ID, queued.CreatedAt, queued.Status = 12, jobCreatedAt, data.JobQueued
+		}).
+		Return(nil).Once()
+
+	handler(rw, req)
+	res := rw.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	assert.Equal(t, "/v1/api/jobs/12", res.Header.Get("Location"))
+	mockJobRepo.AssertExpectations(t)
+
+	return queued
+}
+
+func TestEnqueueJob(t *testing.T) {
+	var buf bytes.Buffer
+
+	t.Run("queues the job for the actor", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(t, &buf, nil, http.MethodPost, "/search/rebuild")
+		req.Header.Set("X-Actor", "alice")
+
+		mockJobRepo := new(MockJobRepository)
+		h.models.Job = mockJobRepo
+		mockJobRepo.On("Insert", mock.MatchedBy(func(ctx context.Context) bool {
+			return data.ActorFromContext(ctx) == "alice"
+		}), &data.Job{Kind: jobRebuildSearch, Payload: []byte(`{"n":1}`), Total: 3}).
+			Run(func(args mock.Arguments) {
+				job := args.Get(1).(*data.Job)
+				job.ID, job.CreatedAt, job.Status = 12, jobCreatedAt, data.JobQueued
+				job.Actor = "alice"
+			}).
+			Return(nil)
+
+		h.enqueueJob(rw, req, jobRebuildSearch, map[string]int{"n": 1}, 3)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"job": {
+				"id": 12,
+				"created_at": "2023-07-01T10:00:00Z",
+				"kind": "rebuild_search",
+				"actor": "alice",
+				"status": "queued",
+				"progress": 0,
+				"total": 3,
+				"attempts": 0
+			}
+		}`
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		assert.Equal(t, "/v1/api/jobs/12", res.Header.Get("Location"))
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(t, &buf, nil, http.MethodPost, "/search/rebuild")
+
+		mockJobRepo := new(MockJobRepository)
+		h.models.Job = mockJobRepo
+		mockJobRepo.On("Insert", mock.Anything, mock.Anything).Return(errors.New("insert error"))
+
+		h.enqueueJob(rw, req, jobRebuildSearch, struct{}{}, 1)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{"error": "the server encountered a problem and could not process your request"}`,
+			string(body),
+		)
+		assert.Empty(t, res.Header.Get("Location"))
+		buf.Reset()
+	})
+}
+
+func TestGetJobHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	finishedAt := jobCreatedAt.Add(time.Minute)
+	failure := "the server encountered a problem and could not process your request"
+
+	testCases := []struct {
+		name             string
+		id               string
+		job              *data.Job
+		getErr           error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name: "returns a finished job",
+			id:   "12",
+			job: &data.Job{
+				ID:         12,
+				CreatedAt:  jobCreatedAt,
+				Kind:       jobImportProducts,
+				Status:     data.JobSucceeded,
+				Progress:   2,
+				Total:      2,
+				Result:     []byte(`{"created": 2, "updated": 0, "failed": 0, "errors": []}`),
+				Attempts:   1,
+				StartedAt:  &jobCreatedAt,
+				FinishedAt: &finishedAt,
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"job": {
+					"id": 12,
+					"created_at": "2023-07-01T10:00:00Z",
+					"kind": "import_products",
+					"actor": "",
+					"status": "succeeded",
+					"progress": 2,
+					"total": 2,
+					"result": {"created": 2, "updated": 0, "failed": 0, "errors": []},
+					"attempts": 1,
+					"started_at": "2023-07-01T10:00:00Z",
+					"finished_at": "2023-07-01T10:01:00Z"
+				}
+			}`,
+		},
+		{
+			name: "returns a failed job",
+			id:   "13",
+			job: &data.Job{
+				ID:         13,
+				CreatedAt:  jobCreatedAt,
+				Kind:       jobRebuildSearch,
+				Status:     data.JobFailed,
+				Total:      1,
+				Error:      &failure,
+				Attempts:   1,
+				StartedAt:  &jobCreatedAt,
+				FinishedAt: &finishedAt,
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"job": {
+					"id": 13,
+					"created_at": "2023-07-01T10:00:00Z",
+					"kind": "rebuild_search",
+					"actor": "",
+					"status": "failed",
+					"progress": 0,
+					"total": 1,
+					"error": "the server encountered a problem and could not process your request",
+					"attempts": 1,
+					"started_at": "2023-07-01T10:00:00Z",
+					"finished_at": "2023-07-01T10:01:00Z"
+				}
+			}`,
+		},
+		{
+			name:             "not found",
+			id:               "14",
+			getErr:           data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "abc",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: abc"}`,
+		},
+		{
+			name:             "server error",
+			id:               "15",
+			getErr:           errors.New("db error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, _ := setupProductRequestTest(t, &buf, nil, http.MethodGet, "/jobs/"+tc.id)
+			req = withIDParam(req, tc.id)
+
+			mockJobRepo := new(MockJobRepository)
+			h.models.Job = mockJobRepo
+			mockJobRepo.On("GetByID", mock.Anything, mock.Anything).Return(tc.job, tc.getErr)
+
+			h.GetJobHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
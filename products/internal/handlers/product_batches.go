@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+const (
+	// maxBatchSize is the number of products a single batch request may create.
+	maxBatchSize = 5_000
+
+	// maxBatchBodyBytes limits the size of the body of a batch request to 16MB, room
+	// for maxBatchSize products with descriptions of a few kilobytes.
+	maxBatchBodyBytes = 16 << 20
+
+	// batchTimeout is the deadline of a batch request, which writes far more rows than
+	// the 5 seconds of a single write allow for.
+	batchTimeout = 30 * time.Second
+)
+
+// The modes of a batch request. An all_or_nothing batch creates no product unless all
+// of them can be created; a partial batch creates the products that can be.
+const (
+	batchAllOrNothing = "all_or_nothing"
+	batchPartial      = "partial"
+)
+
+// batchAbortedMessage is reported for the valid products of an all_or_nothing batch
+// that were left out because other products of the batch failed.
+const batchAbortedMessage = "not created because other products in the batch failed"
+
+// batchResult is the outcome of one product of a batch request. Status and Error are
+// those a POST v1/api/products request for the product alone would respond with.
+type batchResult struct {
+	Index   int           `json:"index"`
+	Status  int           `json:"status"`
+	Product *data.Product `json:"product,omitempty"`
+	Error   any           `json:"error,omitempty"`
+}
+
+// POST v1/api/products/batch?mode={all_or_nothing|partial}
+//
+// The body is an array of the products to create. The response holds a result for
+// each of them, in order, and is sent with 201 Created if all of them were created
+// and with 207 Multi-Status otherwise.
+func (h *Handlers) CreateProductBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = batchAllOrNothing
+	}
+	if mode != batchAllOrNothing && mode != batchPartial {
+		valErrs := map[string]string{"mode": "must be one of [all_or_nothing partial]"}
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Parse request body. If it fails, respond with 400 Bad Request.
+	var payload []productDTO
+
+	err := h.readJSONLimit(w, r, &payload, maxBatchBodyBytes)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(payload) == 0 || len(payload) > maxBatchSize {
+		err = fmt.Errorf("body must contain between 1 and %d products", maxBatchSize)
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a deadline long enough for the whole batch. The response
+	// may be sent as late.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), batchTimeout)
+	defer cancel()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(batchTimeout))
+
+	// Validate the products one by one. The products that fail are reported with the
+	// errors a single create request would get and are not sent to the database.
+	results := make([]batchResult, len(payload))
+	products := []*data.Product{}
+	indexes := []int{}
+	categories := map[int]*data.Category{}
+
+	for i, item := range payload {
+		results[i].Index = i
+
+		err = h.validator.Struct(item)
+		if err != nil {
+			results[i].Status = http.StatusUnprocessableEntity
+			results[i].Error = getValidationMessages(err)
+			continue
+		}
+
+		product := item.product()
+
+		// The attributes must satisfy the attribute schema of the category. Categories
+		// that do not exist or are deleted are reported by InsertBatch.
+		category, ok := categories[product.CategoryID]
+		if !ok {
+			category, err = h.models.Category.GetByID(ctx, int64(product.CategoryID))
+			if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+				h.serverErrorResponse(w, r, err)
+				return
+			}
+			categories[product.CategoryID] = category
+		}
+		if category != nil {
+			if valErrs := attributeErrors(category, &product); len(valErrs) > 0 {
+				results[i].Status = http.StatusUnprocessableEntity
+				results[i].Error = valErrs
+				continue
+			}
+		}
+
+		products = append(products, &product)
+		indexes = append(indexes, i)
+	}
+
+	var errs []error
+	if len(products) > 0 && (mode == batchPartial || len(products) == len(payload)) {
+		errs, err = h.models.Product.InsertBatch(ctx, products, mode == batchPartial)
+		if err != nil {
+			h.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	failed := len(products) < len(payload) || slices.ContainsFunc(errs, func(err error) bool {
+		return err != nil
+	})
+
+	created := 0
+	for j, product := range products {
+		result := &results[indexes[j]]
+
+		switch {
+		case errs != nil && errs[j] != nil:
+			result.Status, result.Error = batchItemError(errs[j])
+		case failed && mode == batchAllOrNothing:
+			result.Status, result.Error = http.StatusFailedDependency, batchAbortedMessage
+		default:
+			result.Status, result.Product = http.StatusCreated, product
+			created++
+		}
+	}
+
+	status := http.StatusCreated
+	if created < len(payload) {
+		status = http.StatusMultiStatus
+	}
+
+	env := envelope{"results": results, "created": created}
+	h.writeJSON(w, r, status, env, nil)
+}
+
+// batchItemError returns the status code and the error message a product of a batch
+// that InsertBatch rejected is reported with.
+func batchItemError(err error) (int, string) {
+	if errors.Is(err, data.ErrDuplicateSlug) || errors.Is(err, data.ErrDuplicateExternalID) {
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusBadRequest, err.Error()
+}
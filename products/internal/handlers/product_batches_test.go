@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateProductBatchHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	payload := `[
+		{"name": "Blue Shoe", "category_id": 1, "price": "10.99"},
+		{"name": "ab", "category_id": 1},
+		{"name": "Red Shoe", "category_id": 9}
+	]`
+	blueShoe := &data.Product{Name: "Blue Shoe", CategoryID: 1, Price: 10_990, Currency: "USD"}
+	redShoe := &data.Product{Name: "Red Shoe", CategoryID: 9, Currency: "USD"}
+	invalidCategory := fmt.Errorf("category_id 9 does not exist: %w", data.ErrInvalidCategoryId)
+
+	t.Run("creates every product", func(t *testing.T) {
+		input := `[{"name": "Blue Shoe", "category_id": 1, "price": "10.99"}]`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/batch",
+		)
+		mockProductRepo.On("InsertBatch", mock.Anything, []*data.Product{blueShoe}, false).
+			Run(func(args mock.Arguments) {
+				p := args.Get(1).([]*data.Product)[0]
+				p.ID = 7
+				p.Slug = "blue-shoe"
+				p.Version = 1
+			}).
+			Return([]error{nil}, nil)
+
+		h.CreateProductBatchHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"created": 1,
+			"results": [{
+				"index": 0,
+				"status": 201,
+				"product": {
+					"id": 7,
+					"name": "Blue Shoe",
+					"slug": "blue-shoe",
+					"category_id": 1,
+					"description": "",
+					"price": "10.99",
+					"currency": "USD",
+					"quantity": 0,
+					"version": 1
+				}
+			}]
+		}`
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("partial batch creates the valid products", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPost, "/products/batch?mode=partial",
+		)
+		mockProductRepo.On("InsertBatch", mock.Anything, []*data.Product{blueShoe, redShoe}, true).
+			Run(func(args mock.Arguments) {
+				p := args.Get(1).([]*data.Product)[0]
+				p.ID = 7
+				p.Slug = "blue-shoe"
+				p.Version = 1
+			}).
+			Return([]error{nil, invalidCategory}, nil)
+
+		h.CreateProductBatchHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"created": 1,
+			"results": [
+				{
+					"index": 0,
+					"status": 201,
+					"product": {
+						"id": 7,
+						"name": "Blue Shoe",
+						"slug": "blue-shoe",
+						"category_id": 1,
+						"description": "",
+						"price": "10.99",
+						"currency": "USD",
+						"quantity": 0,
+						"version": 1
+					}
+				},
+				{
+					"index": 1,
+					"status": 422,
+					"error": {"name": "must be at least 3 characters long"}
+				},
+				{
+					"index": 2,
+					"status": 400,
+					"error": "category_id 9 does not exist: invalid category_id"
+				}
+			]
+		}`
+		assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("all or nothing batch creates nothing if a product is invalid", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPost, "/products/batch",
+		)
+
+		h.CreateProductBatchHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"created": 0,
+			"results": [
+				{
+					"index": 0,
+					"status": 424,
+					"error": "not created because other products in the batch failed"
+				},
+				{
+					"index": 1,
+					"status": 422,
+					"error": {"name": "must be at least 3 characters long"}
+				},
+				{
+					"index": 2,
+					"status": 424,
+					"error": "not created because other products in the batch failed"
+				}
+			]
+		}`
+		assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "InsertBatch", mock.Anything, mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("all or nothing batch creates nothing if a slug is taken", func(t *testing.T) {
+		input := `[
+			{"name": "Blue Shoe", "category_id": 1, "price": "10.99"},
+			{"name": "Red Shoe", "slug": "shoe", "category_id": 9}
+		]`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/batch?mode=all_or_nothing",
+		)
+		shoe := *redShoe
+		shoe.Slug = "shoe"
+		mockProductRepo.On("InsertBatch", mock.Anything, []*data.Product{blueShoe, &shoe}, false).
+			Return([]error{nil, fmt.Errorf("slug shoe: %w", data.ErrDuplicateSlug)}, nil)
+
+		h.CreateProductBatchHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"created": 0,
+			"results": [
+				{
+					"index": 0,
+					"status": 424,
+					"error": "not created because other products in the batch failed"
+				},
+				{
+					"index": 1,
+					"status": 409,
+					"error": "slug shoe: slug already exists"
+				}
+			]
+		}`
+		assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name             string
+		target           string
+		body             string
+		expectedResponse string
+	}{
+		{
+			name:             "invalid mode",
+			target:           "/products/batch?mode=some",
+			body:             payload,
+			expectedResponse: `{"error": {"mode": "must be one of [all_or_nothing partial]"}}`,
+		},
+		{
+			name:             "empty batch",
+			target:           "/products/batch",
+			body:             `[]`,
+			expectedResponse: `{"error": "body must contain between 1 and 5000 products"}`,
+		},
+		{
+			name:             "body is not an array",
+			target:           "/products/batch",
+			body:             `{"name": "Blue Shoe", "category_id": 1}`,
+			expectedResponse: `{"error": "body contains incorrect JSON type (at character 1)"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockProductRepo := setupProductRequestTest(
+				t, &buf, strings.NewReader(tc.body), http.MethodPost, tc.target,
+			)
+
+			h.CreateProductBatchHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			mockProductRepo.AssertNotCalled(t, "InsertBatch", mock.Anything, mock.Anything, mock.Anything)
+			buf.Reset()
+		})
+	}
+}
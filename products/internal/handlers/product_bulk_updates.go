@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// defaultRoundTo is the increment adjusted prices are rounded to when the request
+// does not choose one, a cent.
+const defaultRoundTo data.Money = 10
+
+var amountRangeMessage = fmt.Sprintf(
+	"must be an amount between -%s and %s",
+	data.MaxMoney,
+	data.MaxMoney,
+)
+
+// bulkUpdateDTO is the body of a bulk update request.
+type bulkUpdateDTO struct {
+	Filter    bulkFilterDTO    `json:"filter"`
+	Operation bulkOperationDTO `json:"operation"`
+	DryRun    bool             `json:"dry_run"`
+}
+
+// bulkUpdateJob is the payload of a bulk update job, the request body and the
+// language its name filter is matched in.
+type bulkUpdateJob struct {
+	Filter    bulkFilterDTO    `json:"filter"`
+	Operation bulkOperationDTO `json:"operation"`
+	Language  string           `json:"language"`
+}
+
+// bulkFilterDTO selects the products of a bulk update. At least one of its filters
+// must be set, so that a request cannot update the whole catalog by accident.
+type bulkFilterDTO struct {
+	CategoryID           *int64  `json:"category_id"           validate:"omitempty,gte=1"`
+	IncludeSubcategories bool    `json:"include_subcategories"`
+	IDs                  []int64 `json:"ids"                   validate:"omitempty,max=5000,dive,gte=1"`
+	Name                 string  `json:"name"                  validate:"omitempty,max=100"`
+}
+
+// filters returns the filters of the products the request updates.
+func (f bulkFilterDTO) filters(language string) data.Filters {
+	filters := data.Filters{
+		IDs:                  f.IDs,
+		Name:                 f.Name,
+		Language:             language,
+		IncludeSubcategories: f.IncludeSubcategories,
+	}
+	if f.CategoryID != nil {
+		filters.Conditions = []data.Condition{
+			{Field: "category_id", Op: data.OpEq, Value: []int64{*f.CategoryID}},
+		}
+	}
+	return filters
+}
+
+// bulkOperationDTO is the change a bulk update makes. Type selects the fields it reads,
+// see data.BulkOperation.
+type bulkOperationDTO struct {
+	Type       string          `json:"type"       validate:"required,oneof=set_price adjust_price set_quantity set_attributes"`
+	Price      *data.Money     `json:"price"      validate:"omitempty,money"`
+	Percent    *float64        `json:"percent"    validate:"omitempty,gt=-100,lte=1000"`
+	Amount     *data.Money     `json:"amount"`
+	RoundTo    *data.Money     `json:"round_to"   validate:"omitempty,gt=0,money"`
+	Rounding   string          `json:"rounding"   validate:"omitempty,oneof=nearest up down"`
+	Quantity   *int            `json:"quantity"   validate:"omitempty,gte=0"`
+	Attributes data.Attributes `json:"attributes" validate:"omitempty,max=50,dive,keys,attrname,endkeys,attrvalue"`
+}
+
+// check returns the errors of the fields the type of the operation needs that are
+// missing or out of range, which the tags cannot express.
+func (o bulkOperationDTO) check() map[string]string {
+	valErrs := map[string]string{}
+
+	switch o.Type {
+	case data.BulkSetPrice:
+		if o.Price == nil {
+			valErrs["price"] = "is required"
+		}
+	case data.BulkAdjustPrice:
+		switch {
+		case o.Percent == nil && o.Amount == nil:
+			valErrs["percent"] = "either percent or amount is required"
+		case o.Percent != nil && o.Amount != nil:
+			valErrs["percent"] = "must not be set together with amount"
+		case o.Amount != nil && (*o.Amount < -data.MaxMoney || *o.Amount > data.MaxMoney):
+			valErrs["amount"] = amountRangeMessage
+		}
+	case data.BulkSetQuantity:
+		if o.Quantity == nil {
+			valErrs["quantity"] = "is required"
+		}
+	case data.BulkSetAttributes:
+		if len(o.Attributes) == 0 {
+			valErrs["attributes"] = "is required"
+		}
+	}
+
+	return valErrs
+}
+
+// operation returns the operation of the request, with the defaults of the fields
+// that were left out.
+func (o bulkOperationDTO) operation() data.BulkOperation {
+	op := data.BulkOperation{
+		Operation:  o.Type,
+		Percent:    o.Percent,
+		RoundTo:    defaultRoundTo,
+		Rounding:   o.Rounding,
+		Attributes: o.Attributes,
+	}
+	if o.Price != nil {
+		op.Price = *o.Price
+	}
+	if o.Amount != nil {
+		op.Amount = *o.Amount
+	}
+	if o.RoundTo != nil {
+		op.RoundTo = *o.RoundTo
+	}
+	if op.Rounding == "" {
+		op.Rounding = data.RoundNearest
+	}
+	if o.Quantity != nil {
+		op.Quantity = *o.Quantity
+	}
+	return op
+}
+
+// POST v1/api/products/bulk-update?lang={lang}
+//
+// The body selects products with a filter, by category_id, ids or name, and applies
+// one operation to all of them: set_price, adjust_price by a percent or an amount
+// rounded to a multiple of round_to, set_quantity or set_attributes, which merges the
+// attributes into those of each product. The products are written in one transaction
+// by a job, and only those that change have their version bumped. A dry_run request
+// changes nothing and responds with a preview of the first changes instead. The name
+// is matched in the language of lang, as in GET v1/api/products.
+func (h *Handlers) BulkUpdateProductsHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	valErrs := map[string]string{}
+	language := h.readLanguage(r, r.URL.Query(), valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Parse request body. If it fails, respond with 400 Bad Request.
+	var payload bulkUpdateDTO
+
+	err := h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the request body. If validation fails, respond with 422 Unprocessable
+	// Entity.
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	valErrs = payload.Operation.check()
+	filter := payload.Filter
+	if filter.CategoryID == nil && len(filter.IDs) == 0 && filter.Name == "" {
+		valErrs["filter"] = "must set at least one of category_id, ids or name"
+	}
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+		return
+	}
+
+	filters := filter.filters(language)
+	op := payload.Operation.operation()
+
+	// Create a context with a deadline long enough to go through every matching
+	// product, which a dry run writes and rolls back. The response may be sent as late.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), batchTimeout)
+	defer cancel()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(batchTimeout))
+
+	// The attributes must satisfy the attribute schemas of the categories of the
+	// products.
+	if op.Operation == data.BulkSetAttributes {
+		valErrs, err := h.checkBulkAttributes(ctx, filters, op.Attributes)
+		if err != nil {
+			h.serverErrorResponse(w, r, err)
+			return
+		}
+		if len(valErrs) > 0 {
+			h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+			return
+		}
+	}
+
+	if !payload.DryRun {
+		job := bulkUpdateJob{Filter: filter, Operation: payload.Operation, Language: language}
+		h.enqueueJob(w, r, jobBulkUpdateProducts, job, 1)
+		return
+	}
+
+	result, err := h.models.Product.BulkUpdate(ctx, filters, op, true)
+	if err != nil {
+		h.bulkUpdateErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"dry_run": true,
+		"matched": result.Matched,
+		"updated": result.Updated,
+		"preview": result.Preview,
+	}
+
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// The runBulkUpdateJob() method runs a bulk update job, writing every matching product
+// in a single step. The step is saved with the products, and a job claimed again once
+// it is done responds with its saved result rather than updating the products again.
+func (h *Handlers) runBulkUpdateJob(ctx context.Context, job *data.Job, _ JobReport) (any, error) {
+	if job.Progress >= 1 {
+		return job.Result, nil
+	}
+
+	var payload bulkUpdateJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, h.jobServerError(job, err)
+	}
+
+	step := &data.JobStep{Job: job, Progress: 1}
+	result, err := h.models.Product.BulkUpdate(
+		data.ContextWithJobStep(ctx, step),
+		payload.Filter.filters(payload.Language),
+		payload.Operation.operation(),
+		false,
+	)
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case errors.Is(err, data.ErrJobLeaseLost),
+			errors.Is(err, data.ErrMoneyOutOfRange),
+			errors.Is(err, data.ErrNegativeQuantity),
+			errors.Is(err, data.ErrInsufficientStock):
+			return nil, err
+		default:
+			return nil, h.jobServerError(job, err)
+		}
+	}
+
+	return envelope{"matched": result.Matched, "updated": result.Updated}, nil
+}
+
+// The bulkUpdateErrorResponse() helper responds to a failed bulk update. A price or a
+// quantity out of range is reported with 422 Unprocessable Entity and a quantity below
+// the units reserved with 409 Conflict.
+func (h *Handlers) bulkUpdateErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrMoneyOutOfRange):
+		h.priceOutOfRangeResponse(w, r, err)
+	case errors.Is(err, data.ErrNegativeQuantity):
+		message := map[string]string{"quantity": "must be greater than or equal to 0"}
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, message, err)
+	case errors.Is(err, data.ErrInsufficientStock):
+		h.conflictResponse(w, r, err)
+	default:
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// The checkBulkAttributes() helper validates the attributes a bulk update sets against
+// the attribute schema of every category of the matching products. Only the
+// attributes that are set are checked, the others are left as they are. The
+// validation errors are keyed like those of the request body and name the category.
+func (h *Handlers) checkBulkAttributes(
+	ctx context.Context,
+	filters data.Filters,
+	attributes data.Attributes,
+) (map[string]string, error) {
+	facets, err := h.models.Product.GetFacets(ctx, filters, data.FacetRequest{Category: true})
+	if err != nil {
+		return nil, err
+	}
+
+	valErrs := map[string]string{}
+	for _, facet := range facets.Category {
+		// The products of a deleted category keep the attributes they have.
+		category, err := h.models.Category.GetByID(ctx, facet.CategoryID)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for name, msg := range category.AttributeSchema.Validate(attributes) {
+			key := fmt.Sprintf("Attributes[%s]", name)
+			if _, ok := attributes[name]; ok && valErrs[key] == "" {
+				valErrs[key] = fmt.Sprintf("%s in category_id %d", msg, category.ID)
+			}
+		}
+	}
+
+	return valErrs, nil
+}
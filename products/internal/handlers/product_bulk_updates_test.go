@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBulkUpdateProductsHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	categoryFilters := data.Filters{
+		Conditions: []data.Condition{{Field: "category_id", Op: data.OpEq, Value: []int64{3}}},
+	}
+
+	t.Run("queues the update as a job", func(t *testing.T) {
+		input := `{
+			"filter": {"category_id": 3},
+			"operation": {"type": "adjust_price", "percent": -15, "rounding": "down"}
+		}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/bulk-update",
+		)
+
+		job := queueJob(t, &h, h.BulkUpdateProductsHandler, rw, req)
+		assert.Equal(t, jobBulkUpdateProducts, job.Kind)
+		assert.Equal(t, 1, job.Total)
+		assert.JSONEq(t, `{
+			"filter": {"category_id": 3, "include_subcategories": false, "ids": null, "name": ""},
+			"operation": {
+				"type": "adjust_price",
+				"price": null,
+				"percent": -15,
+				"amount": null,
+				"round_to": null,
+				"rounding": "down",
+				"quantity": null,
+				"attributes": null
+			},
+			"language": ""
+		}`, string(job.Payload))
+		mockProductRepo.AssertNotCalled(
+			t, "BulkUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		)
+		buf.Reset()
+	})
+
+	t.Run("dry run previews the changes", func(t *testing.T) {
+		input := `{
+			"filter": {"ids": [4, 9], "name": "shoe"},
+			"operation": {"type": "set_price", "price": "12.50"},
+			"dry_run": true
+		}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/bulk-update?lang=en",
+		)
+
+		filters := data.Filters{IDs: []int64{4, 9}, Name: "shoe", Language: "en"}
+		op := data.BulkOperation{
+			Operation: data.BulkSetPrice,
+			Price:     12_500,
+			RoundTo:   10,
+			Rounding:  data.RoundNearest,
+		}
+		before, after := data.Money(10_000), data.Money(12_500)
+		mockProductRepo.On("BulkUpdate", mock.Anything, filters, op, true).
+			Return(&data.BulkUpdateResult{
+				Matched: 2,
+				Updated: 1,
+				Preview: []data.BulkChange{
+					{ID: 4, Name: "Blue Shoe", Version: 3, Before: &before, After: &after},
+				},
+			}, nil)
+
+		h.BulkUpdateProductsHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"dry_run": true,
+			"matched": 2,
+			"updated": 1,
+			"preview": [
+				{"id": 4, "name": "Blue Shoe", "version": 3, "before": "10.00", "after": "12.50"}
+			]
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("checks attributes against the schemas of the matching categories", func(t *testing.T) {
+		input := `{
+			"filter": {"category_id": 3, "include_subcategories": true},
+			"operation": {"type": "set_attributes", "attributes": {"capacity_gb": 12}}
+		}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/bulk-update",
+		)
+
+		filters := categoryFilters
+		filters.IncludeSubcategories = true
+		mockProductRepo.On("GetFacets", mock.Anything, filters, data.FacetRequest{Category: true}).
+			Return(&data.Facets{Category: []data.CategoryFacet{
+				{CategoryID: 3, Count: 2},
+				{CategoryID: 4, Count: 1},
+			}}, nil)
+
+		mockCategoryRepo := new(MockCategoryRepository)
+		mockCategoryRepo.On("GetByID", mock.Anything, int64(3)).Return(&data.Category{
+			ID: 3,
+			AttributeSchema: data.AttributeSchema{
+				"ecc": {Type: data.AttributeBool, Required: true},
+			},
+		}, nil)
+		mockCategoryRepo.On("GetByID", mock.Anything, int64(4)).Return(&data.Category{
+			ID: 4,
+			AttributeSchema: data.AttributeSchema{
+				"capacity_gb": {Type: data.AttributeInt, Values: []string{"8", "16"}},
+			},
+		}, nil)
+		h.models.Category = mockCategoryRepo
+
+		h.BulkUpdateProductsHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		// The required attribute that is not set is left as it is on each product.
+		expectedResponse := `{
+			"error": {"Attributes[capacity_gb]": "must be one of [8 16] in category_id 4"}
+		}`
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(
+			t, "BulkUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		)
+		buf.Reset()
+	})
+
+	t.Run("sets attributes", func(t *testing.T) {
+		input := `{
+			"filter": {"category_id": 3},
+			"operation": {"type": "set_attributes", "attributes": {"color": "red"}}
+		}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/bulk-update",
+		)
+
+		mockProductRepo.On("GetFacets", mock.Anything, categoryFilters, data.FacetRequest{Category: true}).
+			Return(&data.Facets{Category: []data.CategoryFacet{{CategoryID: 3, Count: 2}}}, nil)
+
+		job := queueJob(t, &h, h.BulkUpdateProductsHandler, rw, req)
+		assert.Equal(t, jobBulkUpdateProducts, job.Kind)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name             string
+		target           string
+		input            string
+		updateErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "unsupported language",
+			target:           "/products/bulk-update?lang=xx",
+			input:            `{}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"lang": "must be one of [en es]"}}`,
+		},
+		{
+			name:             "invalid json",
+			input:            `{"filter": }`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "body contains badly-formed JSON (at character 12)"}`,
+		},
+		{
+			name: "invalid fields",
+			input: `{
+				"filter": {"category_id": 0},
+				"operation": {"type": "adjust_price", "percent": -100, "rounding": "half"}
+			}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {
+				"category_id": "must be greater than or equal to 1",
+				"percent": "must be greater than -100",
+				"rounding": "must be one of [nearest up down]"
+			}}`,
+		},
+		{
+			name:             "unknown operation",
+			input:            `{"filter": {"ids": [1]}, "operation": {"type": "delete"}}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"type": "must be one of [set_price adjust_price set_quantity set_attributes]"}}`,
+		},
+		{
+			name:           "missing filter and operation fields",
+			input:          `{"operation": {"type": "set_quantity"}}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {
+				"filter": "must set at least one of category_id, ids or name",
+				"quantity": "is required"
+			}}`,
+		},
+		{
+			name:             "percent and amount together",
+			input:            `{"filter": {"ids": [1]}, "operation": {"type": "adjust_price", "percent": 5, "amount": "1"}}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"percent": "must not be set together with amount"}}`,
+		},
+		{
+			name:             "amount out of range",
+			input:            `{"filter": {"ids": [1]}, "operation": {"type": "adjust_price", "amount": "-10000000"}}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"amount": "must be an amount between -9999999.999 and 9999999.999"}}`,
+		},
+		{
+			name:             "price out of range",
+			input:            `{"dry_run": true, "filter": {"ids": [1]}, "operation": {"type": "adjust_price", "amount": "-5"}}`,
+			updateErr:        fmt.Errorf("price -2.00 of product 1: %w", data.ErrMoneyOutOfRange),
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"price": "must be an amount between 0 and 9999999.999"}}`,
+		},
+		{
+			name:             "quantity below the units reserved",
+			input:            `{"dry_run": true, "filter": {"ids": [1]}, "operation": {"type": "set_quantity", "quantity": 0}}`,
+			updateErr:        fmt.Errorf("quantity: %w", data.ErrInsufficientStock),
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "quantity: insufficient stock"}`,
+		},
+		{
+			name:             "quantity below 0",
+			input:            `{"dry_run": true, "filter": {"ids": [1]}, "operation": {"type": "set_quantity", "quantity": 0}}`,
+			updateErr:        fmt.Errorf("quantity: %w", data.ErrNegativeQuantity),
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"quantity": "must be greater than or equal to 0"}}`,
+		},
+		{
+			name:             "server error",
+			input:            `{"dry_run": true, "filter": {"ids": [1]}, "operation": {"type": "set_quantity", "quantity": 0}}`,
+			updateErr:        errors.New("db error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.target
+			if target == "" {
+				target = "/products/bulk-update"
+			}
+			rw, req, h, mockProductRepo := setupProductRequestTest(
+				t, &buf, strings.NewReader(tc.input), http.MethodPost, target,
+			)
+			mockProductRepo.On("BulkUpdate", mock.Anything, mock.Anything, mock.Anything, true).
+				Return(nil, tc.updateErr)
+
+			h.BulkUpdateProductsHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestRunBulkUpdateJob(t *testing.T) {
+	var buf bytes.Buffer
+
+	job := &data.Job{
+		ID:   12,
+		Kind: jobBulkUpdateProducts,
+		Payload: []byte(`{
+			"filter": {"ids": [4, 9], "name": "shoe"},
+			"operation": {"type": "set_price", "price": "12.50"},
+			"language": "en"
+		}`),
+		Total: 1,
+	}
+	filters := data.Filters{IDs: []int64{4, 9}, Name: "shoe", Language: "en"}
+	op := data.BulkOperation{
+		Operation: data.BulkSetPrice,
+		Price:     12_500,
+		RoundTo:   10,
+		Rounding:  data.RoundNearest,
+	}
+
+	t.Run("updates the products", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/bulk-update",
+		)
+		// The update completes the single step of the job.
+		withStep := mock.MatchedBy(func(ctx context.Context) bool {
+			step := data.JobStepFromContext(ctx)
+			return step != nil && step.Job == job && step.Progress == 1
+		})
+		mockProductRepo.On("BulkUpdate", withStep, filters, op, false).
+			Return(&data.BulkUpdateResult{Matched: 2, Updated: 1, Preview: []data.BulkChange{}}, nil)
+
+		result, err := h.runBulkUpdateJob(context.Background(), job, func(int, any) {})
+		assert.NoError(t, err)
+		assert.Equal(t, envelope{"matched": 2, "updated": 1}, result)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("returns the saved result of a job that is done", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/bulk-update",
+		)
+		done := *job
+		done.Progress, done.Result = 1, []byte(`{"matched":2,"updated":1}`)
+
+		result, err := h.runBulkUpdateJob(context.Background(), &done, func(int, any) {})
+		assert.NoError(t, err)
+		assert.Equal(t, json.RawMessage(`{"matched":2,"updated":1}`), result)
+		mockProductRepo.AssertNotCalled(t, "BulkUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name          string
+		updateErr     error
+		expectedError string
+		expectedLog   string
+	}{
+		{
+			name:          "price out of range",
+			updateErr:     fmt.Errorf("price -2.00 of product 4: %w", data.ErrMoneyOutOfRange),
+			expectedError: "price -2.00 of product 4: amount out of range",
+		},
+		{
+			name:          "quantity below the units reserved",
+			updateErr:     fmt.Errorf("quantity: %w", data.ErrInsufficientStock),
+			expectedError: "quantity: insufficient stock",
+		},
+		{
+			name:          "job claimed by another worker",
+			updateErr:     data.ErrJobLeaseLost,
+			expectedError: "job is no longer held by this worker",
+		},
+		{
+			name:          "server error",
+			updateErr:     errors.New("db error"),
+			expectedError: "the server encountered a problem and could not process your request",
+			expectedLog:   `msg="db error" job=12 kind=bulk_update_products`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, h, mockProductRepo := setupProductRequestTest(
+				t, &buf, nil, http.MethodPost, "/products/bulk-update",
+			)
+			mockProductRepo.On("BulkUpdate", mock.Anything, filters, op, false).
+				Return(nil, tc.updateErr)
+
+			result, err := h.runBulkUpdateJob(context.Background(), job, func(int, any) {})
+			assert.Nil(t, result)
+			assert.Equal(t, tc.expectedError, err.Error())
+			if tc.expectedLog != "" {
+				assert.Contains(t, buf.String(), tc.expectedLog)
+			}
+			buf.Reset()
+		})
+	}
+}
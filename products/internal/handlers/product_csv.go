@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// productCSVColumns are the columns of a product export, in order, and the product
+// fields the columns of an import can be mapped to.
+var productCSVColumns = []string{
+	"id",
+	"external_id",
+	"name",
+	"slug",
+	"category_id",
+	"description",
+	"price",
+	"currency",
+	"quantity",
+	"attributes",
+	"version",
+}
+
+// ignoredCSVColumn is the field a column of an import is mapped to to leave it out.
+const ignoredCSVColumn = "-"
+
+// importRowError is the error of a row of an import. Row is the line of the row in the
+// CSV file, the header being line 1. Status and Error are those a request writing the
+// product alone would respond with.
+type importRowError struct {
+	Row    int `json:"row"`
+	Status int `json:"status"`
+	Error  any `json:"error"`
+}
+
+// GET v1/api/products/export.csv?name={name}&lang={lang}&sort={sort}&category_id={id}
+// &include_subcategories={bool}&attr.{name}={value}&include_deleted={bool}&updated_since={time}
+//
+// The filters are those of GET v1/api/products. Every matching product is written as
+// a row of the CSV file, so the paging parameters are rejected. Attributes are written
+// as a JSON object.
+func (h *Handlers) ExportProductCSVHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	filters := h.readProductFilters(r, qs, valErrs)
+	h.rejectPaging(qs, valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	err := h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// The export is written as the products are read, which takes longer than the
+	// deadlines of a single read allow for. It stops if the client goes away.
+	ctx, cancel := context.WithTimeout(r.Context(), batchTimeout)
+	defer cancel()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(batchTimeout))
+
+	// The headers are only sent with the first product, so that an error reading the
+	// products can still be sent as a JSON error response.
+	cw := csv.NewWriter(w)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+		w.WriteHeader(http.StatusOK)
+		return cw.Write(productCSVColumns)
+	}
+
+	err = h.models.Product.Stream(ctx, filters, func(product *data.Product) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		record, err := productCSVRecord(product)
+		if err != nil {
+			return err
+		}
+		return cw.Write(record)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+
+	if err != nil {
+		if !started {
+			h.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Part of the file has been sent already. Abort the response so that the
+		// client does not take what it got for the whole export.
+		h.logError(r, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// productCSVRecord returns the row of the product in an export.
+func productCSVRecord(product *data.Product) ([]string, error) {
+	externalID := ""
+	if product.ExternalID != nil {
+		externalID = *product.ExternalID
+	}
+
+	attributes := ""
+	if len(product.Attributes) > 0 {
+		b, err := json.Marshal(product.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		attributes = string(b)
+	}
+
+	return []string{
+		strconv.Itoa(product.ID),
+		externalID,
+		product.Name,
+		product.Slug,
+		strconv.Itoa(product.CategoryID),
+		product.Description,
+		product.Price.String(),
+		product.Currency,
+		strconv.Itoa(product.Quantity),
+		attributes,
+		strconv.Itoa(product.Version),
+	}, nil
+}
+
+// POST v1/api/products/import?map.{column}={field}
+//
+// The body is a CSV file whose header row names the product field of each column,
+// as in an export. A map.{column} param maps a column to another field, or leaves it
+// out when mapped to -. A row with an id updates that product, a row with the
+// external_id of a product updates it and any other row creates a product. Empty
+// cells leave the fields of an updated product as they are. The import is run by a
+// job, one step per row: every row is validated like the body of the request that
+// writes it and written on its own. The result of the job counts the rows written and
+// reports the errors of the others.
+func (h *Handlers) ImportProductCSVHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body. If it fails, respond with 400 Bad Request.
+	records, err := h.readCSVBody(w, r, maxBatchBodyBytes)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(records) < 2 || len(records) > maxBatchSize+1 {
+		err = fmt.Errorf("body must contain a header row and between 1 and %d rows", maxBatchSize)
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	valErrs := map[string]string{}
+	fields := h.readCSVFields(r.URL.Query(), records[0], valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	payload := importJob{Fields: fields, Rows: records[1:]}
+	h.enqueueJob(w, r, jobImportProducts, payload, len(payload.Rows))
+}
+
+// importJob is the payload of an import job: the product field each column is mapped
+// to, "" for the columns left out, and the rows below the header row.
+type importJob struct {
+	Fields []string   `json:"fields"`
+	Rows   [][]string `json:"rows"`
+}
+
+// importResult is the result of an import job.
+type importResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []importRowError `json:"errors"`
+}
+
+// add counts a row of the import that ended with the status, and its error if it
+// failed.
+func (r *importResult) add(row, status int, rowErr any) {
+	switch status {
+	case http.StatusCreated:
+		r.Created++
+	case http.StatusOK:
+		r.Updated++
+	default:
+		r.Failed++
+		r.Errors = append(r.Errors, importRowError{Row: row, Status: status, Error: rowErr})
+	}
+}
+
+// The runImportJob() method runs an import job, writing the products of its rows.
+func (h *Handlers) runImportJob(ctx context.Context, job *data.Job, report JobReport) (any, error) {
+	var payload importJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, h.jobServerError(job, err)
+	}
+
+	result := importResult{Errors: []importRowError{}}
+	if len(job.Result) > 0 {
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			return nil, h.jobServerError(job, err)
+		}
+	}
+
+	for i := job.Progress; i < len(payload.Rows); i++ {
+		// The job stops between rows once it is cancelled.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		row := importRow{}
+		for j, field := range payload.Fields {
+			if field != "" && payload.Rows[i][j] != "" {
+				row[field] = payload.Rows[i][j]
+			}
+		}
+
+		// The product of a row is written with the progress of the job, so that the row
+		// is not written again if the job resumes before the progress is reported.
+		step := func(status int) *data.JobStep {
+			next := result
+			next.add(i+2, status, nil)
+			return &data.JobStep{Job: job, Progress: i + 1, Result: next}
+		}
+
+		status, rowErr, err := h.importProduct(ctx, job, row, step)
+		if err != nil {
+			// A row cut short by the job being stopped is run again when it resumes.
+			return nil, err
+		}
+
+		result.add(i+2, status, rowErr)
+		report(i+1, result)
+	}
+
+	return result, nil
+}
+
+// The readCSVBody() helper reads every record of the CSV request body, which may be at
+// most maxBytes long. Every record must have as many fields as the first one.
+func (h *Handlers) readCSVBody(
+	w http.ResponseWriter,
+	r *http.Request,
+	maxBytes int64,
+) ([][]string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	records, err := csv.NewReader(r.Body).ReadAll()
+	if err != nil {
+		var parseError *csv.ParseError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &parseError):
+			return nil, fmt.Errorf("body contains badly-formed CSV (%s)", parseError)
+		default:
+			return nil, err
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	// Spreadsheets may start the file with a byte order mark.
+	records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+
+	return records, nil
+}
+
+// The readCSVFields() helper returns the product field each column of the header row
+// is mapped to, or "" for the columns that are left out. Columns that are mapped to
+// no known field, or to the same field as another column, are reported in valErrs.
+func (h *Handlers) readCSVFields(
+	qs url.Values,
+	header []string,
+	valErrs map[string]string,
+) []string {
+	allowed := fmt.Sprintf("[%s %s]", strings.Join(productCSVColumns, " "), ignoredCSVColumn)
+	fields := make([]string, len(header))
+	columns := map[string]bool{}
+
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		columns[column] = true
+
+		field := column
+		if qs.Has("map." + column) {
+			field = qs.Get("map." + column)
+		}
+
+		switch {
+		case field == ignoredCSVColumn:
+			continue
+		case !slices.Contains(productCSVColumns, field):
+			valErrs["map."+column] = "must be one of " + allowed
+		case slices.Contains(fields, field):
+			valErrs["map."+column] = fmt.Sprintf("must not map a second column to %s", field)
+		default:
+			fields[i] = field
+		}
+	}
+
+	for key := range qs {
+		column, ok := strings.CutPrefix(key, "map.")
+		if ok && !columns[column] {
+			valErrs[key] = "must name a column of the header row"
+		}
+	}
+
+	return fields
+}
+
+// importRow holds the non-empty cells of a row of an import, keyed by product field.
+type importRow map[string]string
+
+// The importProduct() helper creates or updates the product of the row, along with the
+// job step the row completes. It returns http.StatusCreated or http.StatusOK when the
+// product is written, and the status and error a single request would respond with
+// otherwise. The error is only set when the row was cut short by the job being
+// stopped.
+func (h *Handlers) importProduct(
+	ctx context.Context,
+	job *data.Job,
+	row importRow,
+	step func(status int) *data.JobStep,
+) (int, any, error) {
+	valErrs := map[string]string{}
+	id := row.id(valErrs)
+	payload := row.payload(valErrs)
+
+	if len(valErrs) > 0 {
+		return http.StatusUnprocessableEntity, valErrs, nil
+	}
+
+	// Find the product the row updates, if any.
+	var product *data.Product
+	var err error
+	switch {
+	case id != 0:
+		product, err = h.models.Product.GetByID(ctx, id)
+	case payload.ExternalID != nil:
+		product, err = h.models.Product.GetByExternalID(ctx, *payload.ExternalID)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return h.importError(job, err)
+	}
+
+	if product == nil {
+		return h.importCreate(ctx, job, payload.productDTO(), step)
+	}
+	return h.importUpdate(ctx, job, product, payload, step)
+}
+
+// The importCreate() helper creates the product of a row, as POST v1/api/products does,
+// and saves the job step of the row with it.
+func (h *Handlers) importCreate(
+	ctx context.Context,
+	job *data.Job,
+	payload productDTO,
+	step func(status int) *data.JobStep,
+) (int, any, error) {
+	err := h.validator.Struct(payload)
+	if err != nil {
+		return http.StatusUnprocessableEntity, getValidationMessages(err), nil
+	}
+
+	product := payload.product()
+
+	valErrs, err := h.checkAttributeSchema(ctx, &product)
+	if err != nil {
+		return h.importError(job, err)
+	}
+	if len(valErrs) > 0 {
+		return http.StatusUnprocessableEntity, valErrs, nil
+	}
+
+	err = h.models.Product.Insert(data.ContextWithJobStep(ctx, step(http.StatusCreated)), &product)
+	if err != nil {
+		return h.importError(job, err)
+	}
+
+	return http.StatusCreated, nil, nil
+}
+
+// The importUpdate() helper updates the product with the cells of a row, as
+// PATCH v1/api/products/{id} does, and saves the job step of the row with it.
+func (h *Handlers) importUpdate(
+	ctx context.Context,
+	job *data.Job,
+	product *data.Product,
+	payload updateProductDTO,
+	step func(status int) *data.JobStep,
+) (int, any, error) {
+	err := h.validator.Struct(payload)
+	if err != nil {
+		return http.StatusUnprocessableEntity, getValidationMessages(err), nil
+	}
+
+	if payload.Version != nil && *payload.Version != product.Version {
+		return h.importError(job, data.ErrEditConflict)
+	}
+
+	payload.apply(product)
+
+	if payload.CategoryID != nil || payload.Attributes != nil {
+		valErrs, err := h.checkAttributeSchema(ctx, product)
+		if err != nil {
+			return h.importError(job, err)
+		}
+		if len(valErrs) > 0 {
+			return http.StatusUnprocessableEntity, valErrs, nil
+		}
+	}
+
+	err = h.models.Product.Update(data.ContextWithJobStep(ctx, step(http.StatusOK)), product)
+	if err != nil {
+		return h.importError(job, err)
+	}
+
+	return http.StatusOK, nil, nil
+}
+
+// The importError() helper returns the status and the error a row that failed to be
+// written with err is reported with. Unexpected errors are logged. A row cut short by
+// the job being stopped, or taken over by another worker, returns err instead.
+func (h *Handlers) importError(job *data.Job, err error) (int, any, error) {
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, data.ErrJobLeaseLost):
+		return 0, nil, err
+	case errors.Is(err, data.ErrRecordNotFound):
+		return http.StatusNotFound, notFoundMessage, nil
+	case errors.Is(err, data.ErrEditConflict):
+		return http.StatusConflict, editConflictMessage, nil
+	case errors.Is(err, data.ErrInvalidCategoryId):
+		return http.StatusBadRequest, err.Error(), nil
+	case errors.Is(err, data.ErrMoneyOutOfRange):
+		return http.StatusUnprocessableEntity, map[string]string{"price": moneyRangeMessage}, nil
+	case errors.Is(err, data.ErrInsufficientStock),
+		errors.Is(err, data.ErrDuplicateSlug),
+		errors.Is(err, data.ErrDuplicateExternalID):
+		return http.StatusConflict, err.Error(), nil
+	default:
+		h.logJobError(job, err)
+		return http.StatusInternalServerError, serverErrorMessage, nil
+	}
+}
+
+// id returns the id of the product the row updates, or 0 if it has none.
+func (r importRow) id(valErrs map[string]string) int64 {
+	s, ok := r["id"]
+	if !ok {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || id < 1 {
+		valErrs["id"] = "must be a positive integer"
+		return 0
+	}
+	return id
+}
+
+// payload returns the fields of the row as the body of a PATCH request. The cells that
+// cannot be parsed are reported in valErrs.
+func (r importRow) payload(valErrs map[string]string) updateProductDTO {
+	var payload updateProductDTO
+
+	for field, s := range r {
+		switch field {
+		case "external_id":
+			payload.ExternalID = &s
+		case "name":
+			payload.Name = &s
+		case "slug":
+			payload.Slug = &s
+		case "category_id":
+			payload.CategoryID = r.int(field, valErrs)
+		case "description":
+			payload.Description = &s
+		case "price":
+			price, err := data.ParseMoney(strings.TrimSpace(s))
+			if err != nil {
+				valErrs[field] = "must be a decimal value"
+				continue
+			}
+			payload.Price = &price
+		case "currency":
+			payload.Currency = &s
+		case "quantity":
+			payload.Quantity = r.int(field, valErrs)
+		case "attributes":
+			var attributes data.Attributes
+			if err := json.Unmarshal([]byte(s), &attributes); err != nil || attributes == nil {
+				valErrs[field] = "must be a JSON object"
+				continue
+			}
+			payload.Attributes = &attributes
+		case "version":
+			payload.Version = r.int(field, valErrs)
+		}
+	}
+
+	return payload
+}
+
+// int parses the cell of the field as an integer.
+func (r importRow) int(field string, valErrs map[string]string) *int {
+	n, err := strconv.Atoi(strings.TrimSpace(r[field]))
+	if err != nil {
+		valErrs[field] = "must be an integer"
+		return nil
+	}
+	return &n
+}
+
+// productDTO returns the fields that were present as the body of a POST request.
+func (p updateProductDTO) productDTO() productDTO {
+	var payload productDTO
+	if p.Name != nil {
+		payload.Name = *p.Name
+	}
+	if p.Slug != nil {
+		payload.Slug = *p.Slug
+	}
+	if p.ExternalID != nil {
+		payload.ExternalID = *p.ExternalID
+	}
+	if p.CategoryID != nil {
+		payload.CategoryID = *p.CategoryID
+	}
+	if p.Description != nil {
+		payload.Description = *p.Description
+	}
+	if p.Price != nil {
+		payload.Price = *p.Price
+	}
+	if p.Currency != nil {
+		payload.Currency = *p.Currency
+	}
+	if p.Quantity != nil {
+		payload.Quantity = *p.Quantity
+	}
+	if p.Attributes != nil {
+		payload.Attributes = *p.Attributes
+	}
+	return payload
+}
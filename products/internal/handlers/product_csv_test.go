@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportProductCSVHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	erp7 := "ERP-7"
+	filters := data.Filters{
+		IDs:          []int64{},
+		Name:         "shoe",
+		Sorts:        []string{"-price"},
+		SortSafelist: data.ProductFilterSpec.SortSafelist(),
+		Page:         1,
+		PageSize:     20,
+	}
+
+	t.Run("exports the matching products", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?name=shoe&sort=-price",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return([]*data.Product{
+			{
+				ID:          7,
+				ExternalID:  &erp7,
+				Name:        "Trail Shoe",
+				Slug:        "trail-shoe",
+				CategoryID:  1,
+				Description: "Light, with a \"rock plate\"",
+				Price:       45_000,
+				Currency:    "USD",
+				Quantity:    3,
+				Attributes:  data.Attributes{"color": "red"},
+				Version:     2,
+			},
+			{ID: 4, Name: "Road Shoe", Slug: "road-shoe", CategoryID: 1, Price: 12_990, Currency: "USD", Version: 1},
+		}, nil)
+
+		h.ExportProductCSVHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedBody := "id,external_id,name,slug,category_id,description,price,currency,quantity,attributes,version\n" +
+			"7,ERP-7,Trail Shoe,trail-shoe,1,\"Light, with a \"\"rock plate\"\"\",45.00,USD,3,\"{\"\"color\"\":\"\"red\"\"}\",2\n" +
+			"4,,Road Shoe,road-shoe,1,,12.99,USD,0,,1\n"
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", res.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="products.csv"`, res.Header.Get("Content-Disposition"))
+		assert.Equal(t, expectedBody, string(body))
+		buf.Reset()
+	})
+
+	t.Run("exports the header row when no product matches", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?name=shoe&sort=-price",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(nil, nil)
+
+		h.ExportProductCSVHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedBody := "id,external_id,name,slug,category_id,description,price,currency,quantity,attributes,version\n"
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, expectedBody, string(body))
+		buf.Reset()
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?name=shoe&sort=-price",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(nil, errors.New("query error"))
+
+		h.ExportProductCSVHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": "the server encountered a problem and could not process your request"}`
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("error after the first product aborts the response", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?name=shoe&sort=-price",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).
+			Return([]*data.Product{{ID: 4, Name: "Road Shoe"}}, errors.New("connection reset"))
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.ExportProductCSVHandler(rw, req)
+		})
+		buf.Reset()
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?include_deleted=maybe",
+		)
+
+		h.ExportProductCSVHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": {"include_deleted": "must be a boolean value: maybe"}}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Stream", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("paging parameters", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/export.csv?page_size=100&cursor=abc",
+		)
+
+		h.ExportProductCSVHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": {
+			"page_size": "is not supported, every matching product is sent",
+			"cursor": "is not supported, every matching product is sent"
+		}}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Stream", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+}
+
+func TestImportProductCSVHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	erp1, erp2 := "ERP-1", "ERP-2"
+	roadShoe := func() *data.Product {
+		return &data.Product{
+			ID:         4,
+			Name:       "Road Shoe",
+			Slug:       "road-shoe",
+			CategoryID: 1,
+			Price:      12_990,
+			Currency:   "USD",
+			Version:    3,
+		}
+	}
+	trailShoe := func() *data.Product {
+		return &data.Product{
+			ID:         7,
+			ExternalID: &erp1,
+			Name:       "Trail Shoe",
+			Slug:       "trail-shoe",
+			CategoryID: 1,
+			Price:      45_000,
+			Currency:   "USD",
+			Version:    1,
+		}
+	}
+
+	t.Run("creates and updates products by id and external id", func(t *testing.T) {
+		input := "\ufeffSKU,Title,category_id,price,quantity,attributes,version,notes\n" +
+			"ERP-1,Trail Shoe,,49.99,,,,on sale\n" +
+			"ERP-2,Hiking Shoe,1,60,2,\"{\"\"color\"\": \"\"red\"\"}\",,new\n" +
+			",Road Shoe,,13.49,5,,,\n"
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			strings.NewReader(input),
+			http.MethodPost,
+			"/products/import?map.SKU=external_id&map.Title=name&map.notes=-",
+		)
+
+		mockProductRepo.On("GetByExternalID", mock.Anything, "ERP-1").Return(trailShoe(), nil)
+		updatedTrail := trailShoe()
+		updatedTrail.Price = 49_990
+		mockProductRepo.On("Update", mock.Anything, updatedTrail).Return(nil)
+
+		mockProductRepo.On("GetByExternalID", mock.Anything, "ERP-2").Return(nil, data.ErrRecordNotFound)
+		hikingShoe := &data.Product{
+			Name:       "Hiking Shoe",
+			ExternalID: &erp2,
+			CategoryID: 1,
+			Price:      60_000,
+			Currency:   "USD",
+			Quantity:   2,
+			Attributes: data.Attributes{"color": "red"},
+		}
+		mockProductRepo.On("Insert", mock.Anything, hikingShoe).Return(nil)
+
+		job := queueJob(t, &h, h.ImportProductCSVHandler, rw, req)
+		assert.Equal(t, jobImportProducts, job.Kind)
+		assert.Equal(t, 3, job.Total)
+
+		progress := []int{}
+		result := runImportJob(t, h, job, func(p int, _ any) { progress = append(progress, p) })
+
+		// The last row creates a product, which needs a category_id.
+		expectedResult := `{
+			"created": 1,
+			"updated": 1,
+			"failed": 1,
+			"errors": [{"row": 4, "status": 422, "error": {"category_id": "is required"}}]
+		}`
+		assert.JSONEq(t, expectedResult, result)
+		assert.Equal(t, []int{1, 2, 3}, progress)
+		buf.Reset()
+	})
+
+	t.Run("updates products by id", func(t *testing.T) {
+		input := "id,name,quantity,version\n4,Road Runner,8,3\n"
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/import",
+		)
+
+		mockProductRepo.On("GetByID", mock.Anything, int64(4)).Return(roadShoe(), nil)
+		updated := roadShoe()
+		updated.Name = "Road Runner"
+		updated.Slug = ""
+		updated.Quantity = 8
+		mockProductRepo.On("Update", mock.Anything, updated).Return(nil)
+
+		job := queueJob(t, &h, h.ImportProductCSVHandler, rw, req)
+		result := runImportJob(t, h, job, func(int, any) {})
+
+		assert.JSONEq(t, `{"created": 0, "updated": 1, "failed": 0, "errors": []}`, result)
+		buf.Reset()
+	})
+
+	t.Run("reports the errors of the rows", func(t *testing.T) {
+		input := "id,external_id,name,category_id,price,quantity,version\n" +
+			"x,,Bad Id,1,1,1,\n" +
+			",,Bad Cells,one,1.2345,-,\n" +
+			"9,,Missing,,,,\n" +
+			"4,,,,,,2\n" +
+			"4,ERP-1,,,,,\n" +
+			",,Lost Category,9,1,,\n" +
+			",,Broken,1,1,,\n"
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(input), http.MethodPost, "/products/import",
+		)
+
+		mockProductRepo.On("GetByID", mock.Anything, int64(9)).Return(nil, data.ErrRecordNotFound)
+		mockProductRepo.On("GetByID", mock.Anything, int64(4)).Return(roadShoe(), nil)
+		mockProductRepo.On("Update", mock.Anything, mock.Anything).
+			Return(fmt.Errorf("external_id %q: %w", "ERP-1", data.ErrDuplicateExternalID))
+		mockProductRepo.On("Insert", mock.Anything, mock.MatchedBy(func(p *data.Product) bool {
+			return p.Name == "Lost Category"
+		})).Return(fmt.Errorf("category_id 9 does not exist: %w", data.ErrInvalidCategoryId))
+		mockProductRepo.On("Insert", mock.Anything, mock.MatchedBy(func(p *data.Product) bool {
+			return p.Name == "Broken"
+		})).Return(errors.New("connection reset"))
+
+		job := queueJob(t, &h, h.ImportProductCSVHandler, rw, req)
+		result := runImportJob(t, h, job, func(int, any) {})
+
+		expectedResult := `{
+			"created": 0,
+			"updated": 0,
+			"failed": 7,
+			"errors": [
+				{"row": 2, "status": 422, "error": {"id": "must be a positive integer"}},
+				{
+					"row": 3,
+					"status": 422,
+					"error": {
+						"category_id": "must be an integer",
+						"price": "must be a decimal value",
+						"quantity": "must be an integer"
+					}
+				},
+				{"row": 4, "status": 404, "error": "the requested resource could not be found"},
+				{
+					"row": 5,
+					"status": 409,
+					"error": "unable to update the record due to an edit conflict, please try again"
+				},
+				{"row": 6, "status": 409, "error": "external_id \"ERP-1\": external_id already exists"},
+				{"row": 7, "status": 400, "error": "category_id 9 does not exist: invalid category_id"},
+				{
+					"row": 8,
+					"status": 500,
+					"error": "the server encountered a problem and could not process your request"
+				}
+			]
+		}`
+		assert.JSONEq(t, expectedResult, result)
+		buf.Reset()
+	})
+
+	t.Run("resumes after the rows that are done", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/import",
+		)
+
+		mockProductRepo.On("GetByID", mock.Anything, int64(4)).Return(roadShoe(), nil)
+		updated := roadShoe()
+		updated.Quantity = 8
+		mockProductRepo.On("Update", mock.Anything, updated).Return(nil)
+
+		job := &data.Job{
+			ID:       12,
+			Kind:     jobImportProducts,
+			Payload:  []byte(`{"fields": ["id", "quantity"], "rows": [["9", "1"], ["4", "8"]]}`),
+			Progress: 1,
+			Total:    2,
+			Result: []byte(`{
+				"created": 0,
+				"updated": 0,
+				"failed": 1,
+				"errors": [{"row": 2, "status": 404, "error": "the requested resource could not be found"}]
+			}`),
+		}
+		result := runImportJob(t, h, job, func(int, any) {})
+
+		expectedResult := `{
+			"created": 0,
+			"updated": 1,
+			"failed": 1,
+			"errors": [{"row": 2, "status": 404, "error": "the requested resource could not be found"}]
+		}`
+		assert.JSONEq(t, expectedResult, result)
+		mockProductRepo.AssertNotCalled(t, "GetByID", mock.Anything, int64(9))
+		buf.Reset()
+	})
+
+	t.Run("writes each row with the progress of the job", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/import",
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		mockProductRepo.On("GetByID", mock.Anything, int64(4)).Return(roadShoe(), nil)
+		updated := roadShoe()
+		updated.Quantity = 8
+
+		var step *data.JobStep
+		mockProductRepo.On("Update", mock.Anything, updated).
+			Run(func(args mock.Arguments) {
+				step = data.JobStepFromContext(args.Get(0).(context.Context))
+				// The job is stopped once the row is written.
+				cancel()
+			}).
+			Return(nil)
+
+		job := &data.Job{
+			ID:      12,
+			Kind:    jobImportProducts,
+			Payload: []byte(`{"fields": ["id", "quantity"], "rows": [["4", "8"], ["5", "1"]]}`),
+			Total:   2,
+		}
+
+		progress := 0
+		result, err := h.runImportJob(ctx, job, func(p int, _ any) { progress = p })
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, result)
+
+		// The row that was written is counted, the next one is left for the job to
+		// resume with.
+		assert.Equal(t, 1, progress)
+		assert.Equal(t, &data.JobStep{
+			Job:      job,
+			Progress: 1,
+			Result:   importResult{Updated: 1, Errors: []importRowError{}},
+		}, step)
+		mockProductRepo.AssertNotCalled(t, "GetByID", mock.Anything, int64(5))
+		buf.Reset()
+	})
+
+	t.Run("stops when the job is claimed by another worker", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/import",
+		)
+
+		mockProductRepo.On("Insert", mock.Anything, mock.Anything).Return(data.ErrJobLeaseLost)
+
+		job := &data.Job{
+			ID:      12,
+			Kind:    jobImportProducts,
+			Payload: []byte(`{"fields": ["name", "category_id"], "rows": [["Road Shoe", "1"]]}`),
+			Total:   1,
+		}
+
+		result, err := h.runImportJob(context.Background(), job, func(int, any) {})
+		assert.Equal(t, data.ErrJobLeaseLost, err)
+		assert.Nil(t, result)
+		assert.NotContains(t, buf.String(), "level=ERROR")
+		buf.Reset()
+	})
+
+	t.Run("stops without counting the row it was cut short on", func(t *testing.T) {
+		_, _, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/import",
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		mockProductRepo.On("GetByID", mock.Anything, int64(4)).
+			Run(func(mock.Arguments) { cancel() }).
+			Return(nil, context.Canceled)
+
+		job := &data.Job{
+			ID:      12,
+			Kind:    jobImportProducts,
+			Payload: []byte(`{"fields": ["id", "quantity"], "rows": [["4", "8"], ["5", "1"]]}`),
+			Total:   2,
+		}
+
+		reported := false
+		result, err := h.runImportJob(ctx, job, func(int, any) { reported = true })
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, result)
+		assert.False(t, reported)
+		assert.NotContains(t, buf.String(), "level=ERROR")
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name             string
+		target           string
+		body             string
+		expectedResponse string
+	}{
+		{
+			name:             "unknown column",
+			target:           "/products/import",
+			body:             "name,colour\nShoe,red\n",
+			expectedResponse: `{"error": {"map.colour": "must be one of [id external_id name slug category_id description price currency quantity attributes version -]"}}`,
+		},
+		{
+			name:             "two columns mapped to the same field",
+			target:           "/products/import?map.title=name",
+			body:             "name,title\nShoe,Shoe\n",
+			expectedResponse: `{"error": {"map.title": "must not map a second column to name"}}`,
+		},
+		{
+			name:             "mapping of a missing column",
+			target:           "/products/import?map.sku=external_id",
+			body:             "name\nShoe\n",
+			expectedResponse: `{"error": {"map.sku": "must name a column of the header row"}}`,
+		},
+		{
+			name:             "badly-formed CSV",
+			target:           "/products/import",
+			body:             "name,price\nShoe\n",
+			expectedResponse: `{"error": "body contains badly-formed CSV (record on line 2: wrong number of fields)"}`,
+		},
+		{
+			name:             "no rows",
+			target:           "/products/import",
+			body:             "name,price\n",
+			expectedResponse: `{"error": "body must contain a header row and between 1 and 5000 rows"}`,
+		},
+		{
+			name:             "empty body",
+			target:           "/products/import",
+			body:             "",
+			expectedResponse: `{"error": "body must not be empty"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockProductRepo := setupProductRequestTest(
+				t, &buf, strings.NewReader(tc.body), http.MethodPost, tc.target,
+			)
+
+			mockJobRepo := new(MockJobRepository)
+			h.models.Job = mockJobRepo
+
+			h.ImportProductCSVHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			mockJobRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+			mockProductRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+			buf.Reset()
+		})
+	}
+}
+
+// runImportJob runs an import job to the end and returns its result as JSON.
+func runImportJob(t *testing.T, h Handlers, job *data.Job, report JobReport) string {
+	t.Helper()
+
+	result, err := h.runImportJob(context.Background(), job, report)
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(result)
+	assert.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+const (
+	// streamFlushProducts is the number of products written to a stream between two
+	// flushes.
+	streamFlushProducts = 100
+
+	// streamTimeout is how long a stream may run for, so that it does not hold a
+	// connection of the database pool for good. A sync that runs out of time resumes
+	// from the updated_at of the last product it received.
+	streamTimeout = 10 * time.Minute
+
+	// streamWriteTimeout is how long the client of a stream may take to read what is
+	// flushed to it. It replaces the write timeout of the server, which covers whole
+	// responses.
+	streamWriteTimeout = 30 * time.Second
+)
+
+// GET v1/api/products/stream?updated_since={time}&sort={sort}&category_id={id}
+// &include_subcategories={bool}&attr.{name}={value}&include_deleted={bool}
+//
+// The filters are those of GET v1/api/products. Every matching product is written as a
+// line of JSON, along with the time it was last updated, as it is read from the
+// database. The products are sorted by update time unless sort says otherwise, so that
+// a sync can resume from the updated_at of the last product it received; the products
+// updated at that time are sent again. Writes still in flight when a stream is read
+// may commit with an earlier update time, so a sync should step back a few seconds.
+// Deleted products are only sent with include_deleted=true. The paging parameters are
+// rejected, and a stream that runs longer than streamTimeout is aborted.
+func (h *Handlers) StreamProductHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	filters := h.readProductFilters(r, qs, valErrs)
+	h.rejectPaging(qs, valErrs)
+	if len(filters.Sorts) == 0 {
+		filters.Sorts = []string{"updated_at"}
+	}
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	err := h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// The stream ends with the catalog, when the client goes away and the request
+	// context is cancelled, or when it runs out of time. Each flush must be read in
+	// time.
+	ctx, cancel := context.WithTimeout(r.Context(), streamTimeout)
+	defer cancel()
+	rc := http.NewResponseController(w)
+	flush := func() error {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return rc.Flush()
+	}
+
+	// The headers are only sent with the first product, so that an error reading the
+	// products can still be sent as a JSON error response.
+	enc := json.NewEncoder(w)
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	written := 0
+	err = h.models.Product.Stream(ctx, filters, func(product *data.Product) error {
+		if !started {
+			start()
+		}
+
+		if err := enc.Encode(product); err != nil {
+			return err
+		}
+
+		written++
+		if written%streamFlushProducts == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		if !started {
+			start()
+		}
+		err = flush()
+	}
+
+	if err != nil {
+		switch {
+		case r.Context().Err() != nil:
+			// The client went away, there is no one to tell.
+		case !started:
+			h.serverErrorResponse(w, r, err)
+		default:
+			// Part of the stream has been sent already, or the stream ran out of time.
+			// Abort the response so that the client does not take what it got for the
+			// whole catalog.
+			h.logError(r, err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStreamProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	updatedSince := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2023, time.July, 2, 10, 0, 0, 0, time.UTC)
+	filters := data.Filters{
+		IDs:          []int64{},
+		Sorts:        []string{"updated_at"},
+		SortSafelist: data.ProductFilterSpec.SortSafelist(),
+		Page:         1,
+		PageSize:     20,
+		UpdatedSince: &updatedSince,
+	}
+	products := []*data.Product{
+		{
+			ID:         7,
+			Name:       "Trail Shoe",
+			Slug:       "trail-shoe",
+			CategoryID: 1,
+			Price:      45_000,
+			Currency:   "USD",
+			Version:    2,
+			UpdatedAt:  updatedAt,
+		},
+		{
+			ID:         4,
+			Name:       "Road Shoe",
+			Slug:       "road-shoe",
+			CategoryID: 1,
+			Price:      12_990,
+			Currency:   "USD",
+			Version:    1,
+			UpdatedAt:  updatedAt.Add(time.Minute),
+		},
+	}
+
+	t.Run("streams the products updated since the given time", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(products, nil)
+
+		h.StreamProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedBody := `{"id":7,"name":"Trail Shoe","slug":"trail-shoe","category_id":1,"description":"",` +
+			`"price":"45.00","currency":"USD","quantity":0,"version":2,"updated_at":"2023-07-02T10:00:00Z"}` + "\n" +
+			`{"id":4,"name":"Road Shoe","slug":"road-shoe","category_id":1,"description":"",` +
+			`"price":"12.99","currency":"USD","quantity":0,"version":1,"updated_at":"2023-07-02T10:01:00Z"}` + "\n"
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		assert.Equal(t, expectedBody, string(body))
+		assert.True(t, rw.Flushed)
+		buf.Reset()
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(nil, nil)
+
+		h.StreamProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		assert.Empty(t, body)
+		buf.Reset()
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(nil, errors.New("query error"))
+
+		h.StreamProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": "the server encountered a problem and could not process your request"}`
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("error after the first product aborts the response", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		mockProductRepo.On("Stream", mock.Anything, filters).
+			Return(products[:1], errors.New("connection reset"))
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.StreamProductHandler(rw, req)
+		})
+		buf.Reset()
+	})
+
+	t.Run("stream runs out of time", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+			deadline, ok := ctx.Deadline()
+			return ok && time.Until(deadline) <= streamTimeout
+		})
+		mockProductRepo.On("Stream", hasDeadline, filters).
+			Return(products[:1], context.DeadlineExceeded)
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.StreamProductHandler(rw, req)
+		})
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("client goes away", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=2023-07-01T00:00:00Z",
+		)
+		ctx, cancel := context.WithCancel(req.Context())
+		req = req.WithContext(ctx)
+		cancel()
+		mockProductRepo.On("Stream", mock.Anything, filters).Return(products[:1], context.Canceled)
+
+		assert.NotPanics(t, func() {
+			h.StreamProductHandler(rw, req)
+		})
+		assert.Empty(t, buf.String())
+		buf.Reset()
+	})
+
+	t.Run("invalid updated_since", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?updated_since=yesterday",
+		)
+
+		h.StreamProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": {"updated_since": "invalid datetime: yesterday"}}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Stream", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("paging parameters", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/stream?page=2&page_size=100&cursor=abc",
+		)
+
+		h.StreamProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": {
+			"page": "is not supported, every matching product is sent",
+			"page_size": "is not supported, every matching product is sent",
+			"cursor": "is not supported, every matching product is sent"
+		}}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Stream", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+}
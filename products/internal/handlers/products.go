@@ -5,17 +5,107 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/chlovec/go-ecommerce/products/internal/data"
 )
 
+// defaultCurrency is the currency of products created without one.
+const defaultCurrency = "USD"
+
+// productDTO holds the fields of a new product. Price is an exact decimal amount that
+// may be sent either as a string or as a JSON number. Currency defaults to USD.
+// Attributes are named with lower case letters, digits and underscores and hold
+// strings, numbers or booleans. A product without a slug gets one derived from its name.
+// ExternalID is the id of the product in the system it is imported from, if any.
 type productDTO struct {
-	Name        string  `json:"name"        validate:"required,min=3,max=100"`
-	CategoryID  int     `json:"category_id" validate:"required"`
-	Description string  `json:"description" validate:"omitempty"`
-	Price       float64 `json:"price"       validate:"omitempty,gte=0"`
-	Quantity    int     `json:"quantity"    validate:"omitempty,gte=0"`
+	Name        string          `json:"name"        validate:"required,min=3,max=100"`
+	Slug        string          `json:"slug"        validate:"omitempty,slug"`
+	ExternalID  string          `json:"external_id" validate:"omitempty,max=100"`
+	CategoryID  int             `json:"category_id" validate:"required"`
+	Description string          `json:"description" validate:"omitempty"`
+	Price       data.Money      `json:"price"       validate:"omitempty,money"`
+	Currency    string          `json:"currency"    validate:"omitempty,iso4217"`
+	Quantity    int             `json:"quantity"    validate:"omitempty,gte=0"`
+	Attributes  data.Attributes `json:"attributes"  validate:"omitempty,max=50,dive,keys,attrname,endkeys,attrvalue"`
+}
+
+// product returns the product the payload creates.
+func (p productDTO) product() data.Product {
+	product := data.Product{
+		Name:        p.Name,
+		Slug:        p.Slug,
+		CategoryID:  p.CategoryID,
+		Description: p.Description,
+		Price:       p.Price,
+		Currency:    p.Currency,
+		Quantity:    p.Quantity,
+		Attributes:  p.Attributes,
+	}
+	if product.Currency == "" {
+		product.Currency = defaultCurrency
+	}
+	if p.ExternalID != "" {
+		product.ExternalID = &p.ExternalID
+	}
+	return product
+}
+
+// updateProductDTO holds the fields that may be changed by a PATCH request. Pointer
+// fields let us tell apart a field that was omitted from one that was explicitly set
+// to its zero value. Attributes replace every attribute of the product. Version is
+// optional; when supplied it must match the stored version of the product or the
+// request is rejected with an edit conflict. Renaming a product derives its slug from
+// the new name unless a slug is sent with it; the old slug keeps redirecting to it. An
+// empty external_id removes the external id of the product.
+type updateProductDTO struct {
+	Name        *string          `json:"name"        validate:"omitempty,min=3,max=100"`
+	Slug        *string          `json:"slug"        validate:"omitempty,slug"`
+	ExternalID  *string          `json:"external_id" validate:"omitempty,max=100"`
+	CategoryID  *int             `json:"category_id" validate:"omitempty,gte=1"`
+	Description *string          `json:"description" validate:"omitempty"`
+	Price       *data.Money      `json:"price"       validate:"omitempty,money"`
+	Currency    *string          `json:"currency"    validate:"omitempty,iso4217"`
+	Quantity    *int             `json:"quantity"    validate:"omitempty,gte=0"`
+	Attributes  *data.Attributes `json:"attributes"  validate:"omitempty,max=50,dive,keys,attrname,endkeys,attrvalue"`
+	Version     *int             `json:"version"     validate:"omitempty,gte=1"`
+}
+
+// apply copies over to the product the fields that were present in the payload.
+func (p updateProductDTO) apply(product *data.Product) {
+	if p.Name != nil && *p.Name != product.Name {
+		product.Name = *p.Name
+		product.Slug = ""
+	}
+	if p.Slug != nil {
+		product.Slug = *p.Slug
+	}
+	if p.ExternalID != nil {
+		product.ExternalID = nil
+		if *p.ExternalID != "" {
+			product.ExternalID = p.ExternalID
+		}
+	}
+	if p.CategoryID != nil {
+		product.CategoryID = *p.CategoryID
+	}
+	if p.Description != nil {
+		product.Description = *p.Description
+	}
+	if p.Price != nil {
+		product.Price = *p.Price
+	}
+	if p.Currency != nil {
+		product.Currency = *p.Currency
+	}
+	if p.Quantity != nil {
+		product.Quantity = *p.Quantity
+	}
+	if p.Attributes != nil {
+		product.Attributes = *p.Attributes
+	}
 }
 
 // POST v1/api/products
@@ -40,23 +130,33 @@ func (h *Handlers) CreateProductHandler(w http.ResponseWriter, r *http.Request)
 
 	// Save product to db. If category id does not exist, send 400 Bad Request to the
 	// client. For any other error, respond send 500 Internal Server Error.
-	product := data.Product{
-		Name:        payload.Name,
-		CategoryID:  payload.CategoryID,
-		Description: payload.Description,
-		Price:       payload.Price,
-		Quantity:    payload.Quantity,
-	}
+	product := payload.product()
 
 	// Create a context with a 5-second timeout deadline.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
 	defer cancel()
 
+	// The attributes must satisfy the attribute schema of the category.
+	valErrs, err := h.checkAttributeSchema(ctx, &product)
+	if err != nil {
+		h.attributeSchemaErrorResponse(w, r, err)
+		return
+	}
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+		return
+	}
+
 	err = h.models.Product.Insert(ctx, &product)
 	if err != nil {
-		if errors.Is(err, data.ErrInvalidCategoryId) {
+		switch {
+		case errors.Is(err, data.ErrInvalidCategoryId):
 			h.badRequestResponse(w, r, err)
-		} else {
+		case errors.Is(err, data.ErrMoneyOutOfRange):
+			h.priceOutOfRangeResponse(w, r, err)
+		case errors.Is(err, data.ErrDuplicateSlug), errors.Is(err, data.ErrDuplicateExternalID):
+			h.conflictResponse(w, r, err)
+		default:
 			h.serverErrorResponse(w, r, err)
 		}
 
@@ -71,3 +171,391 @@ func (h *Handlers) CreateProductHandler(w http.ResponseWriter, r *http.Request)
 	headers.Set("Location", fmt.Sprintf("/v1/api/products/%d", product.ID))
 	h.writeJSON(w, r, http.StatusCreated, envelope{"product": product}, headers)
 }
+
+// GET v1/api/products/{id}?include=variants
+func (h *Handlers) GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	h.writeProduct(w, r, id)
+}
+
+// GET v1/api/products/by-slug/{slug}?include=variants
+func (h *Handlers) GetProductBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate slug param.
+	slug, err := h.readSlugParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, current, err := h.models.Product.LookupSlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// An old slug of a renamed product redirects to its current one.
+	if current != slug {
+		h.slugRedirectResponse(w, r, "/v1/api/products/by-slug/", current)
+		return
+	}
+
+	h.writeProduct(w, r, id)
+}
+
+// The writeProduct() helper responds with the product with the given id, embedding its
+// variants when the include query param asks for them.
+func (h *Handlers) writeProduct(w http.ResponseWriter, r *http.Request, id int64) {
+	// The variants are only embedded in the response when asked for.
+	withVariants := false
+	for _, include := range h.readCSV(r.URL.Query(), "include", []string{}) {
+		if include != "variants" {
+			valErrs := map[string]string{"include": "must be one of [variants]"}
+			h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+			return
+		}
+		withVariants = true
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	getProduct := h.models.Product.GetByID
+	if withVariants {
+		getProduct = h.models.Product.GetByIDWithVariants
+	}
+
+	product, err := getProduct(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"product": product}, nil)
+}
+
+// GET /v1/api/products?name={name}&lang={lang}&page={page}&page_size={page_size}&sort={sort}
+// &category_id={id}&include_subcategories={bool}&facets={facets}&price_buckets={amounts}
+// &attr.{name}={value}&attr.{name}_gte={number}&attr.{name}_lte={number}&include_deleted={bool}
+// &updated_since={time}
+func (h *Handlers) ListProductHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	filters := h.readProductFilters(r, qs, valErrs)
+	facetRequest := h.readFacets(qs, valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	err := h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	products, metadata, err := h.models.Product.GetAll(ctx, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			h.badRequestResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"products": products, "metadata": metadata}
+
+	// Facets are counted over every matching product, not just the current page.
+	if facetRequest != nil {
+		facets, err := h.models.Product.GetFacets(ctx, filters, *facetRequest)
+		if err != nil {
+			h.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// The readProductFilters() helper reads the filters of a product listing. Facets are
+// read separately, as only the JSON listing counts them.
+func (h *Handlers) readProductFilters(
+	r *http.Request,
+	qs url.Values,
+	valErrs map[string]string,
+) data.Filters {
+	filters := h.readFilters(qs, data.ProductFilterSpec, valErrs)
+	filters.Language = h.readLanguage(r, qs, valErrs)
+	filters.Attributes = h.readAttributeConditions(qs, valErrs)
+	filters.IncludeSubcategories = h.readBool(qs, "include_subcategories", false, valErrs)
+	filters.IncludeDeleted = h.readBool(qs, "include_deleted", false, valErrs)
+	filters.UpdatedSince = h.readTime(qs, "updated_since", nil, valErrs)
+	return filters
+}
+
+// The rejectPaging() helper records the paging parameters in valErrs for the listings
+// that send every matching product in one response, which would otherwise ignore them.
+func (h *Handlers) rejectPaging(qs url.Values, valErrs map[string]string) {
+	for _, key := range []string{"page", "page_size", "cursor"} {
+		if qs.Has(key) {
+			valErrs[key] = "is not supported, every matching product is sent"
+		}
+	}
+}
+
+// The readFacets() helper reads the facets requested for a product listing, or nil
+// when there are none. The price buckets are given as their ascending lower bounds,
+// e.g. price_buckets=25,50,100 for the buckets 0-25, 25-50, 50-100 and 100 and up.
+func (h *Handlers) readFacets(qs url.Values, valErrs map[string]string) *data.FacetRequest {
+	names := h.readCSV(qs, "facets", []string{})
+	if len(names) == 0 {
+		return nil
+	}
+
+	var req data.FacetRequest
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "category":
+			req.Category = true
+		case "price":
+			req.Price = true
+		case "stock":
+			req.Stock = true
+		default:
+			valErrs["facets"] = fmt.Sprintf("must be one of [category price stock]: %s", name)
+			return nil
+		}
+	}
+
+	for i, s := range h.readCSV(qs, "price_buckets", []string{}) {
+		bound, err := data.ParseMoney(strings.TrimSpace(s))
+		if err != nil {
+			valErrs["price_buckets"] = fmt.Sprintf("must be a decimal value: %s", s)
+			return nil
+		}
+		if bound <= 0 || bound > data.MaxMoney || (i > 0 && bound <= req.PriceBounds[i-1]) {
+			valErrs["price_buckets"] = "must be ascending amounts greater than 0"
+			return nil
+		}
+		req.PriceBounds = append(req.PriceBounds, bound)
+	}
+
+	if len(req.PriceBounds) > 20 {
+		valErrs["price_buckets"] = "must not have more than 20 amounts"
+		return nil
+	}
+
+	return &req
+}
+
+// PATCH v1/api/products/{id}
+func (h *Handlers) UpdateProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Parse and validate the request body before touching the database.
+	var payload updateProductDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	// Fetch the existing product. If it does not exist, respond with 404 Not Found.
+	product, err := h.models.Product.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// If the client supplied the version it last read, make sure it is still current.
+	if payload.Version != nil && *payload.Version != product.Version {
+		h.editConflictResponse(w, r, data.ErrEditConflict)
+		return
+	}
+
+	payload.apply(product)
+
+	// Moving the product to another category or replacing its attributes checks the
+	// attributes against the attribute schema of the category again.
+	if payload.CategoryID != nil || payload.Attributes != nil {
+		valErrs, err := h.checkAttributeSchema(ctx, product)
+		if err != nil {
+			h.attributeSchemaErrorResponse(w, r, err)
+			return
+		}
+		if len(valErrs) > 0 {
+			h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+			return
+		}
+	}
+
+	err = h.models.Product.Update(ctx, product)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			h.editConflictResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidCategoryId):
+			h.badRequestResponse(w, r, err)
+		case errors.Is(err, data.ErrMoneyOutOfRange):
+			h.priceOutOfRangeResponse(w, r, err)
+		case errors.Is(err, data.ErrInsufficientStock),
+			errors.Is(err, data.ErrDuplicateSlug),
+			errors.Is(err, data.ErrDuplicateExternalID):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"product": product}, nil)
+}
+
+// The checkAttributeSchema() helper validates the attributes of the product against
+// the attribute schema of its category. The validation errors are keyed like those of
+// the request body, e.g. "Attributes[color]". A category that does not exist is
+// reported as data.ErrInvalidCategoryId.
+func (h *Handlers) checkAttributeSchema(
+	ctx context.Context,
+	product *data.Product,
+) (map[string]string, error) {
+	category, err := h.models.Category.GetByID(ctx, int64(product.CategoryID))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, fmt.Errorf(
+				"category_id %d does not exist: %w",
+				product.CategoryID,
+				data.ErrInvalidCategoryId,
+			)
+		}
+		return nil, err
+	}
+
+	return attributeErrors(category, product), nil
+}
+
+// attributeErrors returns the validation errors of the attributes of the product
+// against the attribute schema of the category, keyed like those of the request body.
+func attributeErrors(category *data.Category, product *data.Product) map[string]string {
+	valErrs := map[string]string{}
+	for name, msg := range category.AttributeSchema.Validate(product.Attributes) {
+		valErrs[fmt.Sprintf("Attributes[%s]", name)] = msg
+	}
+	return valErrs
+}
+
+// The attributeSchemaErrorResponse() helper responds to a failure to load the attribute
+// schema of the category of a product.
+func (h *Handlers) attributeSchemaErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, data.ErrInvalidCategoryId) {
+		h.badRequestResponse(w, r, err)
+	} else {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// DELETE v1/api/products/{id}
+func (h *Handlers) DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Product.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "product successfully deleted"}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// POST v1/api/products/{id}/restore
+func (h *Handlers) RestoreProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	// A product can only be restored into a category that is not deleted itself.
+	product, err := h.models.Product.Restore(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidCategoryId):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"product": product}, nil)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -14,7 +15,6 @@ import (
 	"testing"
 
 	"github.com/chlovec/go-ecommerce/products/internal/data"
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -28,6 +28,129 @@ func (m *MockProductRepository) Insert(ctx context.Context, product *data.Produc
 	return args.Error(0)
 }
 
+func (m *MockProductRepository) InsertBatch(
+	ctx context.Context,
+	products []*data.Product,
+	partial bool,
+) ([]error, error) {
+	args := m.Called(ctx, products, partial)
+	errs, _ := args.Get(0).([]error)
+	return errs, args.Error(1)
+}
+
+func (m *MockProductRepository) GetByID(ctx context.Context, id int64) (*data.Product, error) {
+	args := m.Called(ctx, id)
+	product, _ := args.Get(0).(*data.Product)
+	return product, args.Error(1)
+}
+
+func (m *MockProductRepository) GetByExternalID(
+	ctx context.Context,
+	externalID string,
+) (*data.Product, error) {
+	args := m.Called(ctx, externalID)
+	product, _ := args.Get(0).(*data.Product)
+	return product, args.Error(1)
+}
+
+func (m *MockProductRepository) GetByIDWithVariants(
+	ctx context.Context,
+	id int64,
+) (*data.Product, error) {
+	args := m.Called(ctx, id)
+	product, _ := args.Get(0).(*data.Product)
+	return product, args.Error(1)
+}
+
+func (m *MockProductRepository) GetAll(
+	ctx context.Context,
+	filters data.Filters,
+) ([]*data.Product, data.Metadata, error) {
+	args := m.Called(ctx, filters)
+	products, _ := args.Get(0).([]*data.Product)
+	metadata, _ := args.Get(1).(data.Metadata)
+	return products, metadata, args.Error(2)
+}
+
+// Stream passes the products the expectation returns to fn, then returns its error.
+func (m *MockProductRepository) Stream(
+	ctx context.Context,
+	filters data.Filters,
+	fn func(*data.Product) error,
+) error {
+	args := m.Called(ctx, filters)
+	products, _ := args.Get(0).([]*data.Product)
+	for _, product := range products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockProductRepository) Search(
+	ctx context.Context,
+	q string,
+	filters data.Filters,
+) ([]*data.SearchResult, data.Metadata, error) {
+	args := m.Called(ctx, q, filters)
+	results, _ := args.Get(0).([]*data.SearchResult)
+	metadata, _ := args.Get(1).(data.Metadata)
+	return results, metadata, args.Error(2)
+}
+
+func (m *MockProductRepository) GetFacets(
+	ctx context.Context,
+	filters data.Filters,
+	req data.FacetRequest,
+) (*data.Facets, error) {
+	args := m.Called(ctx, filters, req)
+	facets, _ := args.Get(0).(*data.Facets)
+	return facets, args.Error(1)
+}
+
+func (m *MockProductRepository) Update(ctx context.Context, product *data.Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) BulkUpdate(
+	ctx context.Context,
+	filters data.Filters,
+	op data.BulkOperation,
+	dryRun bool,
+) (*data.BulkUpdateResult, error) {
+	args := m.Called(ctx, filters, op, dryRun)
+	result, _ := args.Get(0).(*data.BulkUpdateResult)
+	return result, args.Error(1)
+}
+
+func (m *MockProductRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) Restore(ctx context.Context, id int64) (*data.Product, error) {
+	args := m.Called(ctx, id)
+	product, _ := args.Get(0).(*data.Product)
+	return product, args.Error(1)
+}
+
+func (m *MockProductRepository) Purge(
+	ctx context.Context,
+	before time.Time,
+	limit int,
+) (int64, error) {
+	args := m.Called(ctx, before, limit)
+	purged, _ := args.Get(0).(int64)
+	return purged, args.Error(1)
+}
+
+func (m *MockProductRepository) LookupSlug(ctx context.Context, slug string) (int64, string, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(int64), args.String(1), args.Error(2)
+}
+
 func setupProductHandlerTest(
 	t *testing.T,
 	w io.Writer,
@@ -35,16 +158,34 @@ func setupProductHandlerTest(
 ) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockProductRepository) {
 	t.Helper()
 
+	return setupProductRequestTest(t, w, body, http.MethodPost, "/products")
+}
+
+func setupProductRequestTest(
+	t *testing.T,
+	w io.Writer,
+	body io.Reader,
+	httpMethod string,
+	httpTarget string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockProductRepository) {
+	t.Helper()
+
 	logger := slog.New(slog.NewTextHandler(w, nil))
-	req := httptest.NewRequest(http.MethodPost, "/products", body)
+	req := httptest.NewRequest(httpMethod, httpTarget, body)
 	rw := httptest.NewRecorder()
 	mockProductRepo := new(MockProductRepository)
 
+	// Products are checked against the attribute schema of their category when they are
+	// written. By default the category exists and declares no attributes.
+	mockCategoryRepo := new(MockCategoryRepository)
+	mockCategoryRepo.On("GetByID", mock.Anything, mock.Anything).Return(&data.Category{}, nil).Maybe()
+
 	handlers := Handlers{
 		logger:    logger,
-		validator: validator.New(),
+		validator: newValidator(),
 		models: data.Models{
-			Product: mockProductRepo,
+			Product:  mockProductRepo,
+			Category: mockCategoryRepo,
 		},
 	}
 
@@ -65,7 +206,8 @@ func TestCreateProductHandler(t *testing.T) {
 		Name:        "Test Product",
 		CategoryID:  1,
 		Description: "A test product",
-		Price:       19.99,
+		Price:       19_990,
+		Currency:    "USD",
 		Quantity:    10,
 	}
 
@@ -75,6 +217,7 @@ func TestCreateProductHandler(t *testing.T) {
 			Run(func(args mock.Arguments) {
 				p := args.Get(1).(*data.Product)
 				p.ID = 123
+				p.Slug = "test-product"
 				p.Version = 1
 				p.CreatedAt = time.Now()
 			}).
@@ -94,9 +237,11 @@ func TestCreateProductHandler(t *testing.T) {
 			"product": {
 				"id": 123,
 				"name": "Test Product",
+				"slug": "test-product",
 				"category_id": 1,
 				"description": "A test product",
-				"price": 19.99,
+				"price": "19.99",
+				"currency": "USD",
 				"quantity": 10,
 				"version": 1
 			}
@@ -119,12 +264,14 @@ func TestCreateProductHandler(t *testing.T) {
 		mockProduct := data.Product{
 			Name:       "Test Product",
 			CategoryID: 1,
+			Currency:   "USD",
 		}
 		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(input))
 		mockProductRepo.On("Insert", mock.Anything, &mockProduct).
 			Run(func(args mock.Arguments) {
 				p := args.Get(1).(*data.Product)
 				p.ID = 123
+				p.Slug = "test-product"
 				p.Version = 1
 				p.CreatedAt = time.Now()
 			}).
@@ -141,9 +288,11 @@ func TestCreateProductHandler(t *testing.T) {
 			"product": {
 				"id": 123,
 				"name": "Test Product",
+				"slug": "test-product",
 				"category_id": 1,
 				"description": "",
-				"price": 0,
+				"price": "0.00",
+				"currency": "USD",
 				"quantity": 0,
 				"version": 1
 			}
@@ -240,7 +389,7 @@ func TestCreateProductHandler(t *testing.T) {
 				"error": {
 					"category_id": "is required",
 					"name": "must be at most 100 characters long",
-					"price": "must be greater than or equal to 0",
+					"price": "must be an amount between 0 and 9999999.999",
 					"quantity": "must be greater than or equal to 0"
 				}
 			}`,
@@ -248,7 +397,7 @@ func TestCreateProductHandler(t *testing.T) {
 		)
 
 		// Assert Log
-		logMsg := "level=ERROR msg=\"Key: 'productDTO.Name' Error:Field validation for 'Name' failed on the 'max' tag\\nKey: 'productDTO.CategoryID' Error:Field validation for 'CategoryID' failed on the 'required' tag\\nKey: 'productDTO.Price' Error:Field validation for 'Price' failed on the 'gte' tag\\nKey: 'productDTO.Quantity' Error:Field validation for 'Quantity' failed on the 'gte' tag\" method=POST uri=/products\n"
+		logMsg := "level=ERROR msg=\"Key: 'productDTO.Name' Error:Field validation for 'Name' failed on the 'max' tag\\nKey: 'productDTO.CategoryID' Error:Field validation for 'CategoryID' failed on the 'required' tag\\nKey: 'productDTO.Price' Error:Field validation for 'Price' failed on the 'money' tag\\nKey: 'productDTO.Quantity' Error:Field validation for 'Quantity' failed on the 'gte' tag\" method=POST uri=/products\n"
 		assert.Contains(t, buf.String(), logMsg)
 		buf.Reset()
 	})
@@ -306,4 +455,1653 @@ func TestCreateProductHandler(t *testing.T) {
 		assert.Contains(t, buf.String(), logMsg)
 		buf.Reset()
 	})
+
+	t.Run("create product with exact price and currency", func(t *testing.T) {
+		input := `{
+			"name": "Test Product",
+			"slug": "test-product",
+			"category_id": 1,
+			"price": "0.125",
+			"currency": "EUR"
+		}`
+
+		mockProduct := data.Product{
+			Name:       "Test Product",
+			Slug:       "test-product",
+			CategoryID: 1,
+			Price:      125,
+			Currency:   "EUR",
+		}
+		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		mockProductRepo.On("Insert", mock.Anything, &mockProduct).Return(nil)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		expectedResponse := `{
+			"product": {
+				"id": 0,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 1,
+				"description": "",
+				"price": "0.125",
+				"currency": "EUR",
+				"quantity": 0,
+				"version": 0
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("create product with attributes", func(t *testing.T) {
+		input := `{
+			"name": "Space Heater",
+			"slug": "space-heater",
+			"category_id": 1,
+			"attributes": {"color": "red", "wattage": 1500, "portable": true}
+		}`
+
+		mockProduct := data.Product{
+			Name:       "Space Heater",
+			Slug:       "space-heater",
+			CategoryID: 1,
+			Currency:   "USD",
+			Attributes: data.Attributes{"color": "red", "wattage": float64(1500), "portable": true},
+		}
+		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		mockProductRepo.On("Insert", mock.Anything, &mockProduct).Return(nil)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		expectedResponse := `{
+			"product": {
+				"id": 0,
+				"name": "Space Heater",
+				"slug": "space-heater",
+				"category_id": 1,
+				"description": "",
+				"price": "0.00",
+				"currency": "USD",
+				"quantity": 0,
+				"attributes": {"color": "red", "wattage": 1500, "portable": true},
+				"version": 0
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("invalid attributes", func(t *testing.T) {
+		input := `{
+			"name": "Space Heater",
+			"category_id": 1,
+			"attributes": {"Color": "red", "sizes": ["s", "m"], "finish": null}
+		}`
+		rw, req, h, _ := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		expectedResponse := `{
+			"error": {
+				"Attributes[Color]": "must be lower case letters, digits and underscores starting with a letter",
+				"Attributes[sizes]": "must be a string of at most 200 characters, a number or a boolean",
+				"Attributes[finish]": "must be a string of at most 200 characters, a number or a boolean"
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("price with too many decimal places", func(t *testing.T) {
+		input := `{"name": "Test Product", "category_id": 1, "price": 19.9999}`
+		rw, req, h, _ := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":"invalid money amount: \"19.9999\""}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("price and currency out of range", func(t *testing.T) {
+		input := `{
+			"name": "Test Product",
+			"category_id": 1,
+			"price": "10000000",
+			"currency": "usd"
+		}`
+		rw, req, h, _ := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{
+				"error": {
+					"price": "must be an amount between 0 and 9999999.999",
+					"currency": "must be a valid ISO 4217 currency code"
+				}
+			}`,
+			string(body),
+		)
+		buf.Reset()
+	})
+
+	t.Run("price rejected by the database", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(payload))
+		mockProductRepo.On("Insert", mock.Anything, &productToInsert).
+			Return(data.ErrMoneyOutOfRange)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{"error":{"price":"must be an amount between 0 and 9999999.999"}}`,
+			string(body),
+		)
+		buf.Reset()
+	})
+}
+
+func withIDParam(req *http.Request, id string) *http.Request {
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestGetProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var id int64 = 23
+
+	product := data.Product{
+		ID:          23,
+		Name:        "Test Product",
+		Slug:        "test-product",
+		CategoryID:  1,
+		Description: "A test product",
+		Price:       19_990,
+		Currency:    "USD",
+		Quantity:    10,
+		Version:     2,
+		CreatedAt:   time.Now(),
+	}
+
+	t.Run("fetch product successfully", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(&product, nil)
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"product": {
+				"id": 23,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 1,
+				"description": "A test product",
+				"price": "19.99",
+				"currency": "USD",
+				"quantity": 10,
+				"version": 2
+			}
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t, expectedResponse, string(body))
+		assert.Equal(t, "", buf.String())
+		buf.Reset()
+	})
+
+	t.Run("fetch product with its variants", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/23?include=variants",
+		)
+		req = withIDParam(req, "23")
+		price := data.Money(24_990)
+		withVariants := product
+		withVariants.Variants = []*data.Variant{{
+			ID:        3,
+			ProductID: 23,
+			SKU:       "TP-L",
+			Options:   data.VariantOptions{"size": "L"},
+			Price:     &price,
+			Quantity:  4,
+			Version:   1,
+		}}
+		mockProductRepo.On("GetByIDWithVariants", mock.Anything, id).Return(&withVariants, nil)
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"product": {
+				"id": 23,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 1,
+				"description": "A test product",
+				"price": "19.99",
+				"currency": "USD",
+				"quantity": 10,
+				"version": 2,
+				"variants": [{
+					"id": 3,
+					"product_id": 23,
+					"sku": "TP-L",
+					"options": {"size": "L"},
+					"price": "24.99",
+					"quantity": 4,
+					"version": 1
+				}]
+			}
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("unknown include", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/23?include=reviews",
+		)
+		req = withIDParam(req, "23")
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":{"include":"must be one of [variants]"}}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(t, &buf, nil, http.MethodGet, "/products/0")
+		req = withIDParam(req, "0")
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":"invalid id parameter: 0"}`, string(body))
+
+		logMsg := "level=ERROR msg=\"invalid id parameter: 0\" method=GET uri=/products/0\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+
+	t.Run("record not found", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(nil, data.ErrRecordNotFound)
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.JSONEq(t, `{"error":"the requested resource could not be found"}`, string(body))
+
+		logMsg := "level=ERROR msg=\"record not found\" method=GET uri=/products/23\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(nil, errors.New("db error"))
+
+		h.GetProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		logMsg := "level=ERROR msg=\"db error\" method=GET uri=/products/23\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+}
+
+func TestGetProductBySlugHandler(t *testing.T) {
+	var buf bytes.Buffer
+	product := &data.Product{ID: 23, Name: "Blue Shoe", Slug: "blue-shoe", CategoryID: 1, Version: 2}
+
+	testCases := []struct {
+		name             string
+		target           string
+		slug             string
+		current          string
+		lookupErr        error
+		expectedStatus   int
+		expectedLocation string
+		expectedResponse string
+	}{
+		{
+			name:           "fetch product by its slug",
+			target:         "/products/by-slug/blue-shoe",
+			slug:           "blue-shoe",
+			current:        "blue-shoe",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"product": {
+					"id": 23,
+					"name": "Blue Shoe",
+					"slug": "blue-shoe",
+					"category_id": 1,
+					"description": "",
+					"price": "0.00",
+					"currency": "",
+					"quantity": 0,
+					"version": 2
+				}
+			}`,
+		},
+		{
+			name:             "old slug redirects to the current one",
+			target:           "/products/by-slug/red-shoe?include=variants",
+			slug:             "red-shoe",
+			current:          "blue-shoe",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/v1/api/products/by-slug/blue-shoe?include=variants",
+			expectedResponse: `{"slug": "blue-shoe"}`,
+		},
+		{
+			name:             "slug not found",
+			target:           "/products/by-slug/green-shoe",
+			slug:             "green-shoe",
+			lookupErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid slug",
+			target:           "/products/by-slug/Blue-Shoe",
+			slug:             "Blue-Shoe",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid slug parameter: Blue-Shoe"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockProductRepo := setupProductRequestTest(t, &buf, nil, http.MethodGet, tc.target)
+			req.SetPathValue("slug", tc.slug)
+			mockProductRepo.On("LookupSlug", mock.Anything, tc.slug).
+				Return(int64(23), tc.current, tc.lookupErr)
+			mockProductRepo.On("GetByID", mock.Anything, int64(23)).Return(product, nil)
+
+			h.GetProductBySlugHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.Equal(t, tc.expectedLocation, res.Header.Get("Location"))
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestListProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	product := data.Product{
+		ID:          23,
+		Name:        "Test Product",
+		Slug:        "test-product",
+		CategoryID:  1,
+		Description: "A test product",
+		Price:       19_990,
+		Currency:    "USD",
+		Quantity:    10,
+		Version:     1,
+		CreatedAt:   time.Now(),
+	}
+
+	t.Run("fetch products successfully with query strings", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?page=2&page_size=10&name=test&id=23,24&sort=-created_at",
+		)
+
+		filters := data.Filters{
+			IDs:          []int64{23, 24},
+			Name:         "test",
+			Sorts:        []string{"-created_at"},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+			Page:         2,
+			PageSize:     10,
+		}
+		metadata := data.Metadata{
+			CurrentPage: 2, PageSize: 10, FirstPage: 1, LastPage: 2, TotalRecords: 12,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{&product}, metadata, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"products": [{
+				"id": 23,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 1,
+				"description": "A test product",
+				"price": "19.99",
+				"currency": "USD",
+				"quantity": 10,
+				"version": 1
+			}],
+			"metadata": {
+				"current_page": 2,
+				"page_size": 10,
+				"first_page": 1,
+				"last_page": 2,
+				"total_records": 12
+			}
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		assert.Equal(t, "", buf.String())
+		buf.Reset()
+	})
+
+	t.Run("error parsing query strings", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?page=abc",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":{"page":"must be an integer value: abc"}}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("query string validation error", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?page_size=101",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(
+			t,
+			`{"error":{"page_size":"must be less than or equal to 100"}}`,
+			string(body),
+		)
+		buf.Reset()
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products",
+		)
+
+		filters := data.Filters{
+			IDs:          []int64{},
+			Page:         1,
+			PageSize:     20,
+			Sorts:        []string{},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return(nil, data.Metadata{}, errors.New("db error"))
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		logMsg := "level=ERROR msg=\"db error\" method=GET uri=/products\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+}
+
+func TestUpdateProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var id int64 = 23
+
+	newProduct := func() *data.Product {
+		return &data.Product{
+			ID:          23,
+			Name:        "Test Product",
+			Slug:        "test-product",
+			CategoryID:  1,
+			Description: "A test product",
+			Price:       19_990,
+			Currency:    "USD",
+			Quantity:    10,
+			Version:     3,
+		}
+	}
+
+	t.Run("update product successfully", func(t *testing.T) {
+		payload := `{"name": "Updated Product", "price": 0, "version": 3}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+
+		expectedUpdate := newProduct()
+		expectedUpdate.Name = "Updated Product"
+		expectedUpdate.Slug = ""
+		expectedUpdate.Price = 0
+
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, expectedUpdate).
+			Run(func(args mock.Arguments) {
+				p := args.Get(1).(*data.Product)
+				p.Slug = "updated-product"
+				p.Version = 4
+			}).
+			Return(nil)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"product": {
+				"id": 23,
+				"name": "Updated Product",
+				"slug": "updated-product",
+				"category_id": 1,
+				"description": "A test product",
+				"price": "0.00",
+				"currency": "USD",
+				"quantity": 10,
+				"version": 4
+			}
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		assert.Equal(t, "", buf.String())
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("failed validation", func(t *testing.T) {
+		payload := `{"name": "ab", "quantity": -1}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {
+				"name": "must be at least 3 characters long",
+				"quantity": "must be greater than or equal to 0"
+			}
+		}`
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "GetByID", mock.Anything, id)
+		buf.Reset()
+	})
+
+	t.Run("record not found", func(t *testing.T) {
+		payload := `{"name": "Updated Product"}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(nil, data.ErrRecordNotFound)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("stale version in payload", func(t *testing.T) {
+		payload := `{"name": "Updated Product", "version": 2}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": "unable to update the record due to an edit conflict, please try again"
+		}`
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		payload := `{"quantity": 5}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, mock.Anything).Return(data.ErrEditConflict)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		logMsg := "level=ERROR msg=\"edit conflict\" method=PATCH uri=/products/23\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+
+	t.Run("invalid category id", func(t *testing.T) {
+		payload := `{"category_id": 999}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, mock.Anything).
+			Return(data.ErrInvalidCategoryId)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":"invalid category_id"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("quantity below reserved stock", func(t *testing.T) {
+		payload := `{"quantity": 1}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, mock.Anything).
+			Return(data.ErrInsufficientStock)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error":"insufficient stock"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("slug is taken", func(t *testing.T) {
+		payload := `{"name": "Updated Product", "slug": "shoe"}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+
+		expectedUpdate := newProduct()
+		expectedUpdate.Name = "Updated Product"
+		expectedUpdate.Slug = "shoe"
+
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, expectedUpdate).
+			Return(fmt.Errorf("slug shoe: %w", data.ErrDuplicateSlug))
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error":"slug shoe: slug already exists"}`, string(body))
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("external id is taken", func(t *testing.T) {
+		payload := `{"external_id": "ERP-1"}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+
+		externalID := "ERP-1"
+		expectedUpdate := newProduct()
+		expectedUpdate.ExternalID = &externalID
+
+		mockProductRepo.On("GetByID", mock.Anything, id).Return(newProduct(), nil)
+		mockProductRepo.On("Update", mock.Anything, expectedUpdate).
+			Return(fmt.Errorf("external_id %q: %w", externalID, data.ErrDuplicateExternalID))
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error":"external_id \"ERP-1\": external_id already exists"}`, string(body))
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("invalid slug", func(t *testing.T) {
+		payload := `{"slug": "Blue Shoe"}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {
+				"slug": "must be at most 100 lower case letters and digits separated by single hyphens"
+			}
+		}`
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+}
+
+func TestDeleteProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var id int64 = 23
+
+	t.Run("delete product successfully", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodDelete, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("Delete", mock.Anything, id).Return(nil)
+
+		h.DeleteProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"message":"product successfully deleted"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("record not found", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodDelete, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("Delete", mock.Anything, id).Return(data.ErrRecordNotFound)
+
+		h.DeleteProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodDelete, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("Delete", mock.Anything, id).Return(errors.New("delete error"))
+
+		h.DeleteProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		logMsg := "level=ERROR msg=\"delete error\" method=DELETE uri=/products/23\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+}
+
+func TestRestoreProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var id int64 = 23
+
+	t.Run("restore product successfully", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/23/restore",
+		)
+		req = withIDParam(req, "23")
+		product := &data.Product{ID: 23, Name: "Test Product", Slug: "test-product", CategoryID: 7, Version: 3}
+		mockProductRepo.On("Restore", mock.Anything, id).Return(product, nil)
+
+		h.RestoreProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		expectedResponse := `{
+			"product": {
+				"id": 23,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 7,
+				"description": "",
+				"price": "0.00",
+				"currency": "",
+				"quantity": 0,
+				"version": 3
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("product is not deleted", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/23/restore",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("Restore", mock.Anything, id).Return(nil, data.ErrRecordNotFound)
+
+		h.RestoreProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("category is deleted", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/23/restore",
+		)
+		req = withIDParam(req, "23")
+		err := fmt.Errorf("category_id 7 is deleted: %w", data.ErrInvalidCategoryId)
+		mockProductRepo.On("Restore", mock.Anything, id).Return(nil, err)
+
+		h.RestoreProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error":"category_id 7 is deleted: invalid category_id"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodPost, "/products/23/restore",
+		)
+		req = withIDParam(req, "23")
+		mockProductRepo.On("Restore", mock.Anything, id).Return(nil, errors.New("restore error"))
+
+		h.RestoreProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		logMsg := "level=ERROR msg=\"restore error\" method=POST uri=/products/23/restore\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+}
+
+func TestListProductHandler_Conditions(t *testing.T) {
+	var buf bytes.Buffer
+
+	t.Run("resource specific filters", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?price_gte=10.5&price_lte=20&quantity_gte=1&category_id=3,4&in_stock=true&sort=-price",
+		)
+
+		filters := data.Filters{
+			IDs: []int64{},
+			Conditions: []data.Condition{
+				{Field: "price", Op: data.OpGte, Value: data.Money(10_500)},
+				{Field: "price", Op: data.OpLte, Value: data.Money(20_000)},
+				{Field: "quantity", Op: data.OpGte, Value: int64(1)},
+				{Field: "category_id", Op: data.OpEq, Value: []int64{3, 4}},
+				{Field: "in_stock", Op: data.OpEq, Value: true},
+			},
+			Sorts:        []string{"-price"},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+			Page:         1,
+			PageSize:     20,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"products": [], "metadata": {}}`, string(body))
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("invalid filter values", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?price_gte=cheap&quantity_lte=x&category_id=3,a&in_stock=maybe",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {
+				"price_gte": "must be a decimal value: cheap",
+				"quantity_lte": "must be an integer value: x",
+				"category_id": "invalid id: \"a\"",
+				"in_stock": "must be a boolean value: maybe"
+			}
+		}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("include subcategories", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?category_id=7&include_subcategories=true",
+		)
+
+		filters := data.Filters{
+			IDs: []int64{},
+			Conditions: []data.Condition{
+				{Field: "category_id", Op: data.OpEq, Value: []int64{7}},
+			},
+			Sorts:                []string{},
+			SortSafelist:         data.ProductFilterSpec.SortSafelist(),
+			Page:                 1,
+			PageSize:             20,
+			IncludeSubcategories: true,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("include deleted", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?include_deleted=true",
+		)
+
+		filters := data.Filters{
+			IDs:            []int64{},
+			Sorts:          []string{},
+			SortSafelist:   data.ProductFilterSpec.SortSafelist(),
+			Page:           1,
+			PageSize:       20,
+			IncludeDeleted: true,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("invalid include_subcategories", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?include_subcategories=maybe",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {"include_subcategories": "must be a boolean value: maybe"}
+		}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("attribute filters", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?attr.wattage_gte=500&attr.color=red&attr.wattage_lte=1e3",
+		)
+
+		filters := data.Filters{
+			IDs: []int64{},
+			Attributes: []data.AttributeCondition{
+				{Name: "color", Op: data.OpEq, Value: "red"},
+				{Name: "wattage", Op: data.OpGte, Value: float64(500)},
+				{Name: "wattage", Op: data.OpLte, Value: float64(1000)},
+			},
+			Sorts:        []string{},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+			Page:         1,
+			PageSize:     20,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("invalid attribute filters", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?attr.Color=red&attr.wattage_gte=lots&attr.=1",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {
+				"attr.": "must name an attribute of lower case letters, digits and underscores",
+				"attr.Color": "must name an attribute of lower case letters, digits and underscores",
+				"attr.wattage_gte": "must be a numeric value: lots"
+			}
+		}`
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("sort field not in product safelist", func(t *testing.T) {
+		rw, req, h, _ := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?sort=price,category_id",
+		)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"error": {
+				"Sorts[1]": "must be one of [id created_at updated_at name price quantity -id -created_at -updated_at -name -price -quantity]"
+			}
+		}`
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+}
+
+func TestListProductHandler_Cursor(t *testing.T) {
+	var buf bytes.Buffer
+
+	product := data.Product{
+		ID:         23,
+		Name:       "Test Product",
+		Slug:       "test-product",
+		CategoryID: 1,
+		Price:      19_990,
+		Currency:   "USD",
+		Version:    1,
+	}
+	cursor := data.Cursor{Sorts: []string{"-price"}, Values: []string{"19.990", "23"}}
+
+	t.Run("reads the page after the cursor", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products?sort=-price&page_size=1&cursor="+cursor.Encode(),
+		)
+
+		filters := data.Filters{
+			IDs:          []int64{},
+			Sorts:        []string{"-price"},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+			Cursor:       &cursor,
+			Page:         1,
+			PageSize:     1,
+		}
+		next := data.Cursor{Sorts: []string{"-price"}, Values: []string{"9.990", "31"}}
+		metadata := data.Metadata{PageSize: 1, NextCursor: next.Encode()}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{&product}, metadata, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := fmt.Sprintf(`{
+			"products": [{
+				"id": 23,
+				"name": "Test Product",
+				"slug": "test-product",
+				"category_id": 1,
+				"description": "",
+				"price": "19.99",
+				"currency": "USD",
+				"quantity": 0,
+				"version": 1
+			}],
+			"metadata": {"page_size": 1, "next_cursor": %q}
+		}`, next.Encode())
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("empty cursor starts keyset pagination", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?cursor=",
+		)
+
+		filters := data.Filters{
+			IDs:          []int64{},
+			Sorts:        []string{},
+			SortSafelist: data.ProductFilterSpec.SortSafelist(),
+			Cursor:       &data.Cursor{},
+			Page:         1,
+			PageSize:     20,
+		}
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{PageSize: 20}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		buf.Reset()
+	})
+
+	testCases := []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{
+			name:     "malformed cursor",
+			target:   "/products?cursor=abc!",
+			expected: `{"error":{"cursor":"must be a cursor returned by a previous request"}}`,
+		},
+		{
+			name:     "cursor issued for another sort",
+			target:   "/products?sort=name&cursor=" + cursor.Encode(),
+			expected: `{"error":{"cursor":"does not match the requested sort"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, _ := setupProductRequestTest(t, &buf, nil, http.MethodGet, tc.target)
+
+			h.ListProductHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+			assert.JSONEq(t, tc.expected, string(body))
+			buf.Reset()
+		})
+	}
+
+	t.Run("cursor rejected by the model", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, nil, http.MethodGet, "/products?sort=-price&cursor="+cursor.Encode(),
+		)
+
+		mockProductRepo.On("GetAll", mock.Anything, mock.Anything).
+			Return(nil, data.Metadata{}, data.ErrInvalidCursor)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error":"invalid cursor"}`, string(body))
+		buf.Reset()
+	})
+}
+
+func TestListProductHandler_Facets(t *testing.T) {
+	var buf bytes.Buffer
+	twenty := data.Money(20_000)
+	facets := &data.Facets{
+		Category: []data.CategoryFacet{{CategoryID: 3, Name: "Running", Count: 2}},
+		Price: []data.PriceFacet{
+			{Min: 0, Max: &twenty, Count: 1},
+			{Min: twenty, Count: 1},
+		},
+		Stock: &data.StockFacet{InStock: 2, OutOfStock: 0},
+	}
+	filters := data.Filters{
+		IDs:          []int64{},
+		Sorts:        []string{},
+		SortSafelist: data.ProductFilterSpec.SortSafelist(),
+		Page:         1,
+		PageSize:     20,
+	}
+
+	testCases := []struct {
+		name             string
+		target           string
+		facetRequest     data.FacetRequest
+		facetsErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:   "returns the facets with the page",
+			target: "/products?facets=category,price,stock&price_buckets=20",
+			facetRequest: data.FacetRequest{
+				Category:    true,
+				Price:       true,
+				PriceBounds: []data.Money{20_000},
+				Stock:       true,
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"products": [],
+				"metadata": {},
+				"facets": {
+					"category": [{"category_id": 3, "name": "Running", "count": 2}],
+					"price": [
+						{"min": "0.00", "max": "20.00", "count": 1},
+						{"min": "20.00", "max": null, "count": 1}
+					],
+					"stock": {"in_stock": 2, "out_of_stock": 0}
+				}
+			}`,
+		},
+		{
+			name:             "facets error",
+			target:           "/products?facets=stock",
+			facetRequest:     data.FacetRequest{Stock: true},
+			facetsErr:        errors.New("facets error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:             "unknown facet",
+			target:           "/products?facets=stock,color",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"facets": "must be one of [category price stock]: color"}}`,
+		},
+		{
+			name:             "invalid price bucket",
+			target:           "/products?facets=price&price_buckets=10,cheap",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"price_buckets": "must be a decimal value: cheap"}}`,
+		},
+		{
+			name:             "price buckets out of order",
+			target:           "/products?facets=price&price_buckets=50,10",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"price_buckets": "must be ascending amounts greater than 0"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockProductRepo := setupProductRequestTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				tc.target,
+			)
+			mockProductRepo.On("GetAll", mock.Anything, filters).
+				Return([]*data.Product{}, data.Metadata{}, nil)
+			mockProductRepo.On("GetFacets", mock.Anything, filters, tc.facetRequest).
+				Return(facets, tc.facetsErr)
+
+			h.ListProductHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+
+	t.Run("no facets requested", func(t *testing.T) {
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/products",
+		)
+		mockProductRepo.On("GetAll", mock.Anything, filters).
+			Return([]*data.Product{}, data.Metadata{}, nil)
+
+		h.ListProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockProductRepo.AssertNotCalled(t, "GetFacets", mock.Anything, mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+}
+
+func TestProductHandler_AttributeSchema(t *testing.T) {
+	var buf bytes.Buffer
+	category := &data.Category{
+		ID:   1,
+		Name: "Memory",
+		AttributeSchema: data.AttributeSchema{
+			"capacity_gb": {Type: data.AttributeInt, Required: true, Values: []string{"8", "16"}},
+			"ecc":         {Type: data.AttributeBool},
+		},
+	}
+
+	withCategory := func(h *Handlers, category *data.Category, err error) {
+		mockCategoryRepo := new(MockCategoryRepository)
+		mockCategoryRepo.On("GetByID", mock.Anything, int64(1)).Return(category, err)
+		h.models.Category = mockCategoryRepo
+	}
+
+	t.Run("creates a product matching the schema", func(t *testing.T) {
+		input := `{"name": "DDR5 Module", "category_id": 1, "attributes": {"capacity_gb": 16}}`
+		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		withCategory(&h, category, nil)
+		mockProductRepo.On("Insert", mock.Anything, mock.Anything).Return(nil)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		mockProductRepo.AssertExpectations(t)
+		buf.Reset()
+	})
+
+	t.Run("rejects a product violating the schema", func(t *testing.T) {
+		input := `{"name": "DDR5 Module", "category_id": 1, "attributes": {"ecc": "yes"}}`
+		rw, req, h, mockProductRepo := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		withCategory(&h, category, nil)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		expectedResponse := `{
+			"error": {
+				"Attributes[capacity_gb]": "is required",
+				"Attributes[ecc]": "must be a boolean"
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		mockProductRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("rejects a value that is not allowed", func(t *testing.T) {
+		input := `{"name": "DDR5 Module", "category_id": 1, "attributes": {"capacity_gb": 12}}`
+		rw, req, h, _ := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		withCategory(&h, category, nil)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, `{"error": {"Attributes[capacity_gb]": "must be one of [8 16]"}}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("category does not exist", func(t *testing.T) {
+		input := `{"name": "DDR5 Module", "category_id": 1}`
+		rw, req, h, _ := setupProductHandlerTest(t, &buf, strings.NewReader(input))
+		withCategory(&h, nil, data.ErrRecordNotFound)
+
+		h.CreateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.JSONEq(t, `{"error": "category_id 1 does not exist: invalid category_id"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("moving a product checks the schema of the new category", func(t *testing.T) {
+		payload := `{"category_id": 1}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		withCategory(&h, category, nil)
+		mockProductRepo.On("GetByID", mock.Anything, int64(23)).
+			Return(&data.Product{ID: 23, Name: "DDR5 Module", CategoryID: 2, Version: 1}, nil)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, `{"error": {"Attributes[capacity_gb]": "is required"}}`, string(body))
+		mockProductRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("other updates do not check the schema", func(t *testing.T) {
+		payload := `{"quantity": 5}`
+		rw, req, h, mockProductRepo := setupProductRequestTest(
+			t, &buf, strings.NewReader(payload), http.MethodPatch, "/products/23",
+		)
+		req = withIDParam(req, "23")
+		mockCategoryRepo := new(MockCategoryRepository)
+		h.models.Category = mockCategoryRepo
+		mockProductRepo.On("GetByID", mock.Anything, int64(23)).
+			Return(&data.Product{ID: 23, Name: "DDR5 Module", CategoryID: 1, Version: 1}, nil)
+		mockProductRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+		h.UpdateProductHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		mockCategoryRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
 }
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// defaultReservationTTL is how long units are held when the client does not ask for a
+// specific ttl.
+const defaultReservationTTL = 15 * time.Minute
+
+type reservationDTO struct {
+	Quantity   int `json:"quantity"    validate:"required,gte=1"`
+	TTLSeconds int `json:"ttl_seconds" validate:"omitempty,gte=1,lte=86400"`
+}
+
+// POST v1/api/products/{id}/reservations
+func (h *Handlers) CreateReservationHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload reservationDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	ttl := defaultReservationTTL
+	if payload.TTLSeconds > 0 {
+		ttl = time.Duration(payload.TTLSeconds) * time.Second
+	}
+
+	reservation := data.Reservation{ProductID: productID, Quantity: payload.Quantity}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Hold the units. If the product does not exist respond with 404 Not Found, and if
+	// there is not enough available stock with 409 Conflict.
+	err = h.models.Reservation.Insert(ctx, &reservation, ttl)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrInsufficientStock):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/api/reservations/%d", reservation.ID))
+	h.writeJSON(w, r, http.StatusCreated, envelope{"reservation": reservation}, headers)
+}
+
+// GET v1/api/reservations/{id}
+func (h *Handlers) GetReservationHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reservation, err := h.models.Reservation.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"reservation": reservation}, nil)
+}
+
+// POST v1/api/reservations/{id}/commit
+func (h *Handlers) CommitReservationHandler(w http.ResponseWriter, r *http.Request) {
+	h.finishReservation(w, r, h.models.Reservation.Commit)
+}
+
+// POST v1/api/reservations/{id}/release
+func (h *Handlers) ReleaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	h.finishReservation(w, r, h.models.Reservation.Release)
+}
+
+// The finishReservation() helper runs finish, a commit or a release, on the
+// reservation named by the id param. A reservation that is no longer held is reported
+// with 409 Conflict.
+func (h *Handlers) finishReservation(
+	w http.ResponseWriter,
+	r *http.Request,
+	finish func(ctx context.Context, id int64) (*data.Reservation, error),
+) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	reservation, err := finish(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrReservationNotHeld):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"reservation": reservation}, nil)
+}
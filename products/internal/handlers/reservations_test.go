@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReservationRepository struct {
+	mock.Mock
+}
+
+func (m *MockReservationRepository) Insert(
+	ctx context.Context,
+	reservation *data.Reservation,
+	ttl time.Duration,
+) error {
+	args := m.Called(ctx, reservation, ttl)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepository) GetByID(
+	ctx context.Context,
+	id int64,
+) (*data.Reservation, error) {
+	args := m.Called(ctx, id)
+	reservation, _ := args.Get(0).(*data.Reservation)
+	return reservation, args.Error(1)
+}
+
+func (m *MockReservationRepository) Commit(
+	ctx context.Context,
+	id int64,
+) (*data.Reservation, error) {
+	args := m.Called(ctx, id)
+	reservation, _ := args.Get(0).(*data.Reservation)
+	return reservation, args.Error(1)
+}
+
+func (m *MockReservationRepository) Release(
+	ctx context.Context,
+	id int64,
+) (*data.Reservation, error) {
+	args := m.Called(ctx, id)
+	reservation, _ := args.Get(0).(*data.Reservation)
+	return reservation, args.Error(1)
+}
+
+func (m *MockReservationRepository) ExpireHolds(ctx context.Context, limit int) (int64, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func setupReservationRequestTest(
+	t *testing.T,
+	w io.Writer,
+	body io.Reader,
+	httpMethod string,
+	httpTarget string,
+	id string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockReservationRepository) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	req := withIDParam(httptest.NewRequest(httpMethod, httpTarget, body), id)
+	rw := httptest.NewRecorder()
+	mockReservationRepo := new(MockReservationRepository)
+
+	handlers := Handlers{
+		logger:    logger,
+		validator: newValidator(),
+		models: data.Models{
+			Reservation: mockReservationRepo,
+		},
+	}
+
+	return rw, req, handlers, mockReservationRepo
+}
+
+func TestCreateReservationHandler(t *testing.T) {
+	var buf bytes.Buffer
+	expiresAt := time.Date(2023, time.July, 1, 10, 15, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name             string
+		id               string
+		payload          string
+		expectedTTL      time.Duration
+		insertErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "holds stock with the default ttl",
+			id:             "12",
+			payload:        `{"quantity": 3}`,
+			expectedTTL:    15 * time.Minute,
+			expectedStatus: http.StatusCreated,
+			expectedResponse: `{
+				"reservation": {
+					"id": 5,
+					"product_id": 12,
+					"quantity": 3,
+					"status": "held",
+					"expires_at": "2023-07-01T10:15:00Z",
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:           "holds stock with a ttl",
+			id:             "12",
+			payload:        `{"quantity": 3, "ttl_seconds": 60}`,
+			expectedTTL:    time.Minute,
+			expectedStatus: http.StatusCreated,
+			expectedResponse: `{
+				"reservation": {
+					"id": 5,
+					"product_id": 12,
+					"quantity": 3,
+					"status": "held",
+					"expires_at": "2023-07-01T10:15:00Z",
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "insufficient stock",
+			id:               "12",
+			payload:          `{"quantity": 3}`,
+			expectedTTL:      15 * time.Minute,
+			insertErr:        data.ErrInsufficientStock,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "insufficient stock"}`,
+		},
+		{
+			name:             "product not found",
+			id:               "12",
+			payload:          `{"quantity": 3}`,
+			expectedTTL:      15 * time.Minute,
+			insertErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "server error",
+			id:               "12",
+			payload:          `{"quantity": 3}`,
+			expectedTTL:      15 * time.Minute,
+			insertErr:        errors.New("insert error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "failed validation",
+			id:             "12",
+			payload:        `{"quantity": 0, "ttl_seconds": 100000}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {
+					"quantity": "is required",
+					"ttl_seconds": "must be less than or equal to 86400"
+				}
+			}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "abc",
+			payload:          `{"quantity": 3}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: abc"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockReservationRepo := setupReservationRequestTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPost,
+				"/products/"+tc.id+"/reservations",
+				tc.id,
+			)
+			mockReservationRepo.On(
+				"Insert",
+				mock.Anything,
+				&data.Reservation{ProductID: 12, Quantity: 3},
+				tc.expectedTTL,
+			).
+				Run(func(args mock.Arguments) {
+					reservation := args.Get(1).(*data.Reservation)
+					reservation.ID = 5
+					reservation.Status = data.ReservationHeld
+					reservation.ExpiresAt = expiresAt
+					reservation.Version = 1
+				}).
+				Return(tc.insertErr)
+
+			h.CreateReservationHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			if tc.expectedStatus == http.StatusCreated {
+				assert.Equal(t, "/v1/api/reservations/5", res.Header.Get("Location"))
+			}
+			buf.Reset()
+		})
+	}
+}
+
+func TestFinishReservationHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	expiresAt := time.Date(2023, time.July, 1, 10, 15, 0, 0, time.UTC)
+	committed := data.Reservation{
+		ID:        5,
+		ProductID: 12,
+		Quantity:  3,
+		Status:    data.ReservationCommitted,
+		ExpiresAt: expiresAt,
+		Version:   2,
+	}
+
+	testCases := []struct {
+		name             string
+		method           string
+		reservation      *data.Reservation
+		err              error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "commit",
+			method:         "Commit",
+			reservation:    &committed,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"reservation": {
+					"id": 5,
+					"product_id": 12,
+					"quantity": 3,
+					"status": "committed",
+					"expires_at": "2023-07-01T10:15:00Z",
+					"version": 2
+				}
+			}`,
+		},
+		{
+			name:             "commit a reservation that is no longer held",
+			method:           "Commit",
+			err:              data.ErrReservationNotHeld,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "reservation is no longer held"}`,
+		},
+		{
+			name:             "release a reservation that does not exist",
+			method:           "Release",
+			err:              data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "release error",
+			method:           "Release",
+			err:              errors.New("release error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockReservationRepo := setupReservationRequestTest(
+				t, &buf, nil, http.MethodPost, "/reservations/5", "5",
+			)
+			mockReservationRepo.On(tc.method, mock.Anything, int64(5)).Return(tc.reservation, tc.err)
+
+			if tc.method == "Commit" {
+				h.CommitReservationHandler(rw, req)
+			} else {
+				h.ReleaseReservationHandler(rw, req)
+			}
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestGetReservationHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	t.Run("found", func(t *testing.T) {
+		rw, req, h, mockReservationRepo := setupReservationRequestTest(
+			t, &buf, nil, http.MethodGet, "/reservations/5", "5",
+		)
+		reservation := data.Reservation{ID: 5, ProductID: 12, Quantity: 3, Status: data.ReservationHeld}
+		mockReservationRepo.On("GetByID", mock.Anything, int64(5)).Return(&reservation, nil)
+
+		h.GetReservationHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		rw, req, h, mockReservationRepo := setupReservationRequestTest(
+			t, &buf, nil, http.MethodGet, "/reservations/5", "5",
+		)
+		mockReservationRepo.On("GetByID", mock.Anything, int64(5)).
+			Return(nil, data.ErrRecordNotFound)
+
+		h.GetReservationHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// GET v1/api/products/{id}/revisions?page={page}&page_size={page_size}&sort={sort}
+// &version_gte={version}&version_lte={version}
+func (h *Handlers) ListProductRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	h.listRevisions(w, r, data.ProductRevision)
+}
+
+// GET v1/api/products/{id}/revisions/{version}
+func (h *Handlers) GetProductRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	h.getRevision(w, r, data.ProductRevision)
+}
+
+// GET v1/api/categories/{id}/revisions?page={page}&page_size={page_size}&sort={sort}
+// &version_gte={version}&version_lte={version}
+func (h *Handlers) ListCategoryRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	h.listRevisions(w, r, data.CategoryRevision)
+}
+
+// GET v1/api/categories/{id}/revisions/{version}
+func (h *Handlers) GetCategoryRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	h.getRevision(w, r, data.CategoryRevision)
+}
+
+// The listRevisions() helper responds with a page of the revisions of the product or
+// category named by the id param. Deleted records keep their history, so a record is
+// only reported with 404 Not Found if it has none.
+func (h *Handlers) listRevisions(w http.ResponseWriter, r *http.Request, entity data.RevisionEntity) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	filters := h.readFilters(qs, data.RevisionFilterSpec, valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	err = h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revisions, metadata, err := h.models.Revision.GetAll(ctx, entity, id, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrInvalidCursor):
+			h.badRequestResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"revisions": revisions, "metadata": metadata}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// The getRevision() helper responds with a single revision of the product or category
+// named by the id param, along with the fields it changed.
+func (h *Handlers) getRevision(w http.ResponseWriter, r *http.Request, entity data.RevisionEntity) {
+	// Read and validate the id and version params.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	version, err := h.readIDParamNamed(r, "version")
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revision, err := h.models.Revision.GetByVersion(ctx, entity, id, int(version))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"revision": revision}, nil)
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRevisionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRevisionRepository) GetAll(
+	ctx context.Context,
+	entity data.RevisionEntity,
+	id int64,
+	filters data.Filters,
+) ([]*data.Revision, data.Metadata, error) {
+	args := m.Called(ctx, entity, id, filters)
+	revisions, _ := args.Get(0).([]*data.Revision)
+	return revisions, args.Get(1).(data.Metadata), args.Error(2)
+}
+
+func (m *MockRevisionRepository) GetByVersion(
+	ctx context.Context,
+	entity data.RevisionEntity,
+	id int64,
+	version int,
+) (*data.Revision, error) {
+	args := m.Called(ctx, entity, id, version)
+	revision, _ := args.Get(0).(*data.Revision)
+	return revision, args.Error(1)
+}
+
+func setupRevisionHandlerTest(
+	t *testing.T,
+	w io.Writer,
+	httpTarget string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockRevisionRepository) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	req := httptest.NewRequest(http.MethodGet, httpTarget, nil)
+	rw := httptest.NewRecorder()
+	mockRevisionRepo := new(MockRevisionRepository)
+
+	handlers := Handlers{
+		logger:    logger,
+		validator: newValidator(),
+		models:    data.Models{Revision: mockRevisionRepo},
+	}
+
+	return rw, req, handlers, mockRevisionRepo
+}
+
+func TestListRevisionHandler(t *testing.T) {
+	var buf bytes.Buffer
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("lists the revisions of a product", func(t *testing.T) {
+		rw, req, h, mockRevisionRepo := setupRevisionHandlerTest(
+			t, &buf, "/products/12/revisions?version_gte=2&sort=-version",
+		)
+		req = withIDParam(req, "12")
+
+		filters := data.Filters{
+			IDs:          []int64{},
+			Conditions:   []data.Condition{{Field: "version", Op: data.OpGte, Value: int64(2)}},
+			Sorts:        []string{"-version"},
+			SortSafelist: data.RevisionFilterSpec.SortSafelist(),
+			Page:         1,
+			PageSize:     20,
+		}
+		revisions := []*data.Revision{{
+			ID:        7,
+			Version:   2,
+			Operation: "update",
+			Actor:     "alice",
+			Snapshot:  json.RawMessage(`{"id": 12, "price": "9.99"}`),
+			CreatedAt: createdAt,
+		}}
+		metadata := data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 1}
+		mockRevisionRepo.On("GetAll", mock.Anything, data.ProductRevision, int64(12), filters).
+			Return(revisions, metadata, nil)
+
+		h.ListProductRevisionHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		expectedResponse := `{
+			"revisions": [{
+				"id": 7,
+				"version": 2,
+				"operation": "update",
+				"actor": "alice",
+				"snapshot": {"id": 12, "price": "9.99"},
+				"created_at": "2023-07-01T10:00:00Z"
+			}],
+			"metadata": {
+				"current_page": 1,
+				"page_size": 20,
+				"first_page": 1,
+				"last_page": 1,
+				"total_records": 1
+			}
+		}`
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("category has no history", func(t *testing.T) {
+		rw, req, h, mockRevisionRepo := setupRevisionHandlerTest(t, &buf, "/categories/4/revisions")
+		req = withIDParam(req, "4")
+		mockRevisionRepo.On("GetAll", mock.Anything, data.CategoryRevision, int64(4), mock.Anything).
+			Return(nil, data.Metadata{}, data.ErrRecordNotFound)
+
+		h.ListCategoryRevisionHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		buf.Reset()
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		rw, req, h, mockRevisionRepo := setupRevisionHandlerTest(
+			t, &buf, "/products/12/revisions?version_gte=two",
+		)
+		req = withIDParam(req, "12")
+
+		h.ListProductRevisionHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		mockRevisionRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		rw, req, h, mockRevisionRepo := setupRevisionHandlerTest(t, &buf, "/products/12/revisions")
+		req = withIDParam(req, "12")
+		mockRevisionRepo.On("GetAll", mock.Anything, data.ProductRevision, int64(12), mock.Anything).
+			Return(nil, data.Metadata{}, errors.New("query error"))
+
+		h.ListProductRevisionHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		logMsg := "level=ERROR msg=\"query error\" method=GET uri=/products/12/revisions\n"
+		assert.Contains(t, buf.String(), logMsg)
+		buf.Reset()
+	})
+}
+
+func TestGetRevisionHandler(t *testing.T) {
+	var buf bytes.Buffer
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	previousVersion := 2
+
+	testCases := []struct {
+		name             string
+		version          string
+		revision         *data.Revision
+		getErr           error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:    "returns the revision with its changes",
+			version: "3",
+			revision: &data.Revision{
+				ID:              8,
+				Version:         3,
+				Operation:       "update",
+				Actor:           "alice",
+				Snapshot:        json.RawMessage(`{"id": 12, "price": "9.99", "version": 3}`),
+				CreatedAt:       createdAt,
+				PreviousVersion: &previousVersion,
+				Changes: []data.Change{{
+					Field: "price",
+					From:  json.RawMessage(`"10.99"`),
+					To:    json.RawMessage(`"9.99"`),
+				}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"revision": {
+					"id": 8,
+					"version": 3,
+					"operation": "update",
+					"actor": "alice",
+					"snapshot": {"id": 12, "price": "9.99", "version": 3},
+					"created_at": "2023-07-01T10:00:00Z",
+					"previous_version": 2,
+					"changes": [{"field": "price", "from": "10.99", "to": "9.99"}]
+				}
+			}`,
+		},
+		{
+			name:             "revision not found",
+			version:          "9",
+			getErr:           data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid version",
+			version:          "x",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: x"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/products/12/revisions/" + tc.version
+			rw, req, h, mockRevisionRepo := setupRevisionHandlerTest(t, &buf, target)
+			req = withIDParam(req, "12")
+			req.SetPathValue("version", tc.version)
+			mockRevisionRepo.On("GetByVersion", mock.Anything, data.ProductRevision, int64(12), mock.Anything).
+				Return(tc.revision, tc.getErr)
+
+			h.GetProductRevisionHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestActorContext(t *testing.T) {
+	var buf bytes.Buffer
+
+	rw, req, h, mockProductRepo := setupProductRequestTest(
+		t, &buf, nil, http.MethodDelete, "/products/23",
+	)
+	req = withIDParam(req, "23")
+	req.Header.Set("X-Actor", " alice ")
+	isAlice := mock.MatchedBy(func(ctx context.Context) bool {
+		return data.ActorFromContext(ctx) == "alice"
+	})
+	mockProductRepo.On("Delete", isAlice, int64(23)).Return(nil)
+
+	h.DeleteProductHandler(rw, req)
+	res := rw.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	mockProductRepo.AssertExpectations(t)
+	assert.False(t, strings.Contains(buf.String(), "level=ERROR"))
+}
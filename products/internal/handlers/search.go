@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// GET /v1/api/products/search?q={q}&lang={lang}&page={page}&page_size={page_size}
+// &include_deleted={bool}
+func (h *Handlers) SearchProductHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	q := strings.TrimSpace(qs.Get("q"))
+	filters := h.readFilters(qs, data.ProductFilterSpec, valErrs)
+	filters.Language = h.readLanguage(r, qs, valErrs)
+	filters.Attributes = h.readAttributeConditions(qs, valErrs)
+	filters.IncludeSubcategories = h.readBool(qs, "include_subcategories", false, valErrs)
+	filters.IncludeDeleted = h.readBool(qs, "include_deleted", false, valErrs)
+
+	// Results are always ordered by relevance, so neither a sort nor a cursor applies.
+	if qs.Has("sort") {
+		valErrs["sort"] = "is not supported, results are ordered by relevance"
+	}
+	if qs.Has("cursor") {
+		valErrs["cursor"] = "is not supported, use page and page_size"
+	}
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	switch {
+	case q == "":
+		valErrs["q"] = "must be provided"
+	case utf8.RuneCountInString(q) > 100:
+		valErrs["q"] = "must be at most 100 characters long"
+	}
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+		return
+	}
+
+	err := h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	products, metadata, err := h.models.Product.Search(ctx, q, filters)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"products": products, "metadata": metadata}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// GET /v1/api/suggest?q={q}&limit={limit}
+func (h *Handlers) SuggestHandler(w http.ResponseWriter, r *http.Request) {
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	q := strings.TrimSpace(qs.Get("q"))
+	limit := h.readInt(qs, "limit", 10, valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	switch {
+	case q == "":
+		valErrs["q"] = "must be provided"
+	case utf8.RuneCountInString(q) > 100:
+		valErrs["q"] = "must be at most 100 characters long"
+	}
+	if limit < 1 || limit > 20 {
+		valErrs["limit"] = "must be between 1 and 20"
+	}
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusUnprocessableEntity, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Suggestions are requested on every keystroke, so they get a tighter deadline than
+	// the other handlers.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	suggestions, err := h.models.Suggestion.Suggest(ctx, q, limit)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSuggestionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSuggestionRepository) Suggest(
+	ctx context.Context,
+	q string,
+	limit int,
+) ([]*data.Suggestion, error) {
+	args := m.Called(ctx, q, limit)
+	suggestions, _ := args.Get(0).([]*data.Suggestion)
+	return suggestions, args.Error(1)
+}
+
+func TestSearchProductHandler(t *testing.T) {
+	var buf bytes.Buffer
+	results := []*data.SearchResult{{
+		Product: &data.Product{
+			ID:          4,
+			Name:        "Studio Headphones",
+			Slug:        "studio-headphones",
+			CategoryID:  1,
+			Description: "Wireless headphones with noise cancelling",
+			Price:       data.Money(99_500),
+			Currency:    "USD",
+			Quantity:    3,
+			Version:     1,
+		},
+		Rank: 0.2,
+		Highlights: data.SearchHighlights{
+			Name:        "Studio <mark>Headphones</mark>",
+			Description: "<mark>Wireless</mark> <mark>headphones</mark> with noise cancelling",
+		},
+	}}
+	metadata := data.Metadata{
+		CurrentPage:  1,
+		PageSize:     20,
+		FirstPage:    1,
+		LastPage:     1,
+		TotalRecords: 1,
+	}
+
+	expectedResponse := `{
+		"products": [{
+			"id": 4,
+			"name": "Studio Headphones",
+			"slug": "studio-headphones",
+			"category_id": 1,
+			"description": "Wireless headphones with noise cancelling",
+			"price": "99.50",
+			"currency": "USD",
+			"quantity": 3,
+			"version": 1,
+			"rank": 0.2,
+			"highlights": {
+				"name": "Studio <mark>Headphones</mark>",
+				"description": "<mark>Wireless</mark> <mark>headphones</mark> with noise cancelling"
+			}
+		}],
+		"metadata": {
+			"current_page": 1,
+			"page_size": 20,
+			"first_page": 1,
+			"last_page": 1,
+			"total_records": 1
+		}
+	}`
+
+	testCases := []struct {
+		name             string
+		target           string
+		acceptLanguage   string
+		language         string
+		searchErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "returns ranked results",
+			target:           "/products/search?q=wireless+headph",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: expectedResponse,
+		},
+		{
+			name:             "searches in the requested language",
+			target:           "/products/search?q=wireless+headph&lang=es-MX",
+			acceptLanguage:   "en",
+			language:         "es",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: expectedResponse,
+		},
+		{
+			name:             "searches in the preferred supported language",
+			target:           "/products/search?q=wireless+headph",
+			acceptLanguage:   "fr-FR, en;q=0.5, es;q=0.8",
+			language:         "es",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: expectedResponse,
+		},
+		{
+			name:             "unsupported language",
+			target:           "/products/search?q=wireless+headph&lang=fr",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"lang": "must be one of [en es]"}}`,
+		},
+		{
+			name:             "server error",
+			target:           "/products/search?q=wireless+headph",
+			searchErr:        errors.New("search error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:             "missing query",
+			target:           "/products/search?q=++",
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"q": "must be provided"}}`,
+		},
+		{
+			name:           "sort and cursor",
+			target:         "/products/search?q=shoe&sort=-price&cursor=",
+			expectedStatus: http.StatusBadRequest,
+			expectedResponse: `{
+				"error": {
+					"sort": "is not supported, results are ordered by relevance",
+					"cursor": "is not supported, use page and page_size"
+				}
+			}`,
+		},
+		{
+			name:             "invalid page size",
+			target:           "/products/search?q=shoe&page_size=500",
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"page_size": "must be less than or equal to 100"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockProductRepo := setupProductRequestTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				tc.target,
+			)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			filters := data.Filters{
+				IDs:          []int64{},
+				Sorts:        []string{},
+				SortSafelist: data.ProductFilterSpec.SortSafelist(),
+				Page:         1,
+				PageSize:     20,
+				Language:     tc.language,
+			}
+			mockProductRepo.On("Search", mock.Anything, "wireless headph", filters).
+				Return(results, metadata, tc.searchErr)
+
+			h.SearchProductHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestSuggestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	suggestions := []*data.Suggestion{
+		{Type: data.SuggestCategory, ID: 3, Name: "Headphones"},
+		{Type: data.SuggestProduct, ID: 4, Name: "Wireless Headphones"},
+	}
+
+	testCases := []struct {
+		name             string
+		target           string
+		limit            int
+		suggestErr       error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "returns suggestions",
+			target:         "/suggest?q=haedph",
+			limit:          10,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"suggestions": [
+					{"type": "category", "id": 3, "name": "Headphones"},
+					{"type": "product", "id": 4, "name": "Wireless Headphones"}
+				]
+			}`,
+		},
+		{
+			name:           "custom limit",
+			target:         "/suggest?q=haedph&limit=2",
+			limit:          2,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"suggestions": [
+					{"type": "category", "id": 3, "name": "Headphones"},
+					{"type": "product", "id": 4, "name": "Wireless Headphones"}
+				]
+			}`,
+		},
+		{
+			name:             "server error",
+			target:           "/suggest?q=haedph",
+			limit:            10,
+			suggestErr:       errors.New("suggest error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "failed validation",
+			target:         "/suggest?limit=50",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {"q": "must be provided", "limit": "must be between 1 and 20"}
+			}`,
+		},
+		{
+			name:             "invalid limit",
+			target:           "/suggest?q=haedph&limit=ten",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": {"limit": "must be an integer value: ten"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSuggestionRepo := new(MockSuggestionRepository)
+			h := Handlers{
+				logger:    slog.New(slog.NewTextHandler(&buf, nil)),
+				validator: newValidator(),
+				models:    data.Models{Suggestion: mockSuggestionRepo},
+			}
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			rw := httptest.NewRecorder()
+			mockSuggestionRepo.On("Suggest", mock.Anything, "haedph", tc.limit).
+				Return(suggestions, tc.suggestErr)
+
+			h.SuggestHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+type stockAdjustmentDTO struct {
+	Delta     *int             `json:"delta"     validate:"required,ne=0"`
+	Reason    data.StockReason `json:"reason"    validate:"required,oneof=received sold damaged correction"`
+	Reference string           `json:"reference" validate:"max=255"`
+}
+
+// POST v1/api/products/{id}/stock-adjustments
+func (h *Handlers) CreateStockAdjustmentHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload stockAdjustmentDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(h.actorContext(r), 5*time.Second)
+	defer cancel()
+
+	// Apply the adjustment. If the product does not exist respond with 404 Not Found,
+	// and if it would take away units that are not in stock with 409 Conflict.
+	movement, err := h.models.StockMovement.Adjust(
+		ctx,
+		productID,
+		*payload.Delta,
+		payload.Reason,
+		payload.Reference,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			h.notFoundResponse(w, r, err)
+		case errors.Is(err, data.ErrInsufficientStock):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, envelope{"stock_movement": movement}, nil)
+}
+
+// GET v1/api/products/{id}/stock-movements?page={page}&page_size={page_size}&sort={sort}
+func (h *Handlers) ListStockMovementHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// parse query params
+	qs := r.URL.Query()
+	valErrs := map[string]string{}
+
+	filters := h.readFilters(qs, data.StockMovementFilterSpec, valErrs)
+
+	if len(valErrs) > 0 {
+		h.errorResponse(w, r, http.StatusBadRequest, valErrs, createErr(valErrs))
+		return
+	}
+
+	// Validate
+	err = h.validator.Struct(filters)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// An unknown product has no history rather than an empty one, respond with 404 Not
+	// Found.
+	_, err = h.models.Product.GetByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movements, metadata, err := h.models.StockMovement.GetAll(ctx, productID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			h.badRequestResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"stock_movements": movements, "metadata": metadata}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
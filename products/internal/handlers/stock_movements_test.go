@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStockMovementRepository struct {
+	mock.Mock
+}
+
+func (m *MockStockMovementRepository) Adjust(
+	ctx context.Context,
+	productID int64,
+	delta int,
+	reason data.StockReason,
+	reference string,
+) (*data.StockMovement, error) {
+	args := m.Called(ctx, productID, delta, reason, reference)
+	movement, _ := args.Get(0).(*data.StockMovement)
+	return movement, args.Error(1)
+}
+
+func (m *MockStockMovementRepository) GetAll(
+	ctx context.Context,
+	productID int64,
+	filters data.Filters,
+) ([]*data.StockMovement, data.Metadata, error) {
+	args := m.Called(ctx, productID, filters)
+	movements, _ := args.Get(0).([]*data.StockMovement)
+	return movements, args.Get(1).(data.Metadata), args.Error(2)
+}
+
+func setupStockMovementRequestTest(
+	t *testing.T,
+	w io.Writer,
+	body io.Reader,
+	httpMethod string,
+	httpTarget string,
+	id string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockStockMovementRepository, *MockProductRepository) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	req := withIDParam(httptest.NewRequest(httpMethod, httpTarget, body), id)
+	rw := httptest.NewRecorder()
+	mockStockMovementRepo := new(MockStockMovementRepository)
+	mockProductRepo := new(MockProductRepository)
+
+	handlers := Handlers{
+		logger:    logger,
+		validator: newValidator(),
+		models: data.Models{
+			Product:       mockProductRepo,
+			StockMovement: mockStockMovementRepo,
+		},
+	}
+
+	return rw, req, handlers, mockStockMovementRepo, mockProductRepo
+}
+
+func TestCreateStockAdjustmentHandler(t *testing.T) {
+	var buf bytes.Buffer
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	movement := data.StockMovement{
+		ID:            3,
+		ProductID:     12,
+		Delta:         -2,
+		Reason:        data.StockDamaged,
+		Reference:     "water damage",
+		QuantityAfter: 8,
+		CreatedAt:     createdAt,
+	}
+
+	testCases := []struct {
+		name             string
+		id               string
+		payload          string
+		adjustErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "adjusts stock",
+			id:             "12",
+			payload:        `{"delta": -2, "reason": "damaged", "reference": "water damage"}`,
+			expectedStatus: http.StatusCreated,
+			expectedResponse: `{
+				"stock_movement": {
+					"id": 3,
+					"product_id": 12,
+					"delta": -2,
+					"reason": "damaged",
+					"reference": "water damage",
+					"quantity_after": 8,
+					"created_at": "2023-07-01T10:00:00Z"
+				}
+			}`,
+		},
+		{
+			name:             "insufficient stock",
+			id:               "12",
+			payload:          `{"delta": -2, "reason": "damaged", "reference": "water damage"}`,
+			adjustErr:        data.ErrInsufficientStock,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "insufficient stock"}`,
+		},
+		{
+			name:             "product not found",
+			id:               "12",
+			payload:          `{"delta": -2, "reason": "damaged", "reference": "water damage"}`,
+			adjustErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "server error",
+			id:               "12",
+			payload:          `{"delta": -2, "reason": "damaged", "reference": "water damage"}`,
+			adjustErr:        errors.New("adjust error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "failed validation",
+			id:             "12",
+			payload:        `{"delta": 0, "reason": "lost"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {
+					"delta": "must not be equal to 0",
+					"reason": "must be one of [received sold damaged correction]"
+				}
+			}`,
+		},
+		{
+			name:             "missing delta",
+			id:               "12",
+			payload:          `{"reason": "received"}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"delta": "is required"}}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "abc",
+			payload:          `{"delta": -2, "reason": "damaged"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: abc"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockStockMovementRepo, _ := setupStockMovementRequestTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPost,
+				"/products/"+tc.id+"/stock-adjustments",
+				tc.id,
+			)
+			mockStockMovementRepo.On(
+				"Adjust",
+				mock.Anything,
+				int64(12),
+				-2,
+				data.StockDamaged,
+				"water damage",
+			).Return(&movement, tc.adjustErr)
+
+			h.CreateStockAdjustmentHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestListStockMovementHandler(t *testing.T) {
+	var buf bytes.Buffer
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+	movements := []*data.StockMovement{{
+		ID:            3,
+		ProductID:     12,
+		Delta:         5,
+		Reason:        data.StockReceived,
+		QuantityAfter: 5,
+		CreatedAt:     createdAt,
+	}}
+	metadata := data.Metadata{
+		CurrentPage:  1,
+		PageSize:     20,
+		FirstPage:    1,
+		LastPage:     1,
+		TotalRecords: 1,
+	}
+
+	testCases := []struct {
+		name             string
+		id               string
+		query            string
+		getErr           error
+		listErr          error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "lists movements",
+			id:             "12",
+			query:          "sort=-created_at",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"stock_movements": [{
+					"id": 3,
+					"product_id": 12,
+					"delta": 5,
+					"reason": "received",
+					"reference": "",
+					"quantity_after": 5,
+					"created_at": "2023-07-01T10:00:00Z"
+				}],
+				"metadata": {
+					"current_page": 1,
+					"page_size": 20,
+					"first_page": 1,
+					"last_page": 1,
+					"total_records": 1
+				}
+			}`,
+		},
+		{
+			name:             "product not found",
+			id:               "12",
+			getErr:           data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "server error",
+			id:               "12",
+			listErr:          errors.New("list error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "invalid sort",
+			id:             "12",
+			query:          "sort=name",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {
+					"Sorts[0]": "must be one of [id created_at delta -id -created_at -delta]"
+				}
+			}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "0",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: 0"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockStockMovementRepo, mockProductRepo := setupStockMovementRequestTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				"/products/"+tc.id+"/stock-movements?"+tc.query,
+				tc.id,
+			)
+			mockProductRepo.On("GetByID", mock.Anything, int64(12)).
+				Return(&data.Product{ID: 12}, tc.getErr)
+			mockStockMovementRepo.On("GetAll", mock.Anything, int64(12), mock.Anything).
+				Return(movements, metadata, tc.listErr)
+
+			h.ListStockMovementHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
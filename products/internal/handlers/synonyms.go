@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// synonymDTO holds the fields of a new synonym entry. The term is a single word since
+// searches are expanded word by word; its synonyms may be phrases.
+type synonymDTO struct {
+	Term     string   `json:"term"     validate:"required,max=50,searchword"`
+	Synonyms []string `json:"synonyms" validate:"required,min=1,max=20,dive,required,max=100,searchphrase"`
+}
+
+// updateSynonymDTO holds the fields that may be changed by a PATCH request. The
+// client must send back the version it last read so concurrent edits are detected.
+type updateSynonymDTO struct {
+	Term     *string  `json:"term"     validate:"omitempty,max=50,searchword"`
+	Synonyms []string `json:"synonyms" validate:"omitempty,min=1,max=20,dive,required,max=100,searchphrase"`
+	Version  int      `json:"version"  validate:"required,gte=1"`
+}
+
+type stopWordDTO struct {
+	Word string `json:"word" validate:"required,max=50,searchword"`
+}
+
+// POST v1/api/search/synonyms
+func (h *Handlers) CreateSynonymHandler(w http.ResponseWriter, r *http.Request) {
+	var payload synonymDTO
+
+	err := h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Searches are matched in lower case, so the dictionary is stored that way too.
+	payload.Term = normalizeSearchText(payload.Term)
+	for i, synonym := range payload.Synonyms {
+		payload.Synonyms[i] = normalizeSearchText(synonym)
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	synonym := data.Synonym{
+		Term:     payload.Term,
+		Synonyms: payload.Synonyms,
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Synonym.Insert(ctx, &synonym)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateTerm) {
+			h.conflictResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/api/search/synonyms/%d", synonym.ID))
+	h.writeJSON(w, r, http.StatusCreated, envelope{"synonym": synonym}, headers)
+}
+
+// GET v1/api/search/synonyms
+func (h *Handlers) ListSynonymHandler(w http.ResponseWriter, r *http.Request) {
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	synonyms, err := h.models.Synonym.GetAll(ctx)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"synonyms": synonyms}, nil)
+}
+
+// GET v1/api/search/synonyms/{id}
+func (h *Handlers) GetSynonymHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	synonym, err := h.models.Synonym.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"synonym": synonym}, nil)
+}
+
+// PATCH v1/api/search/synonyms/{id}
+func (h *Handlers) UpdateSynonymHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Parse and validate the request body before touching the database.
+	var payload updateSynonymDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Term != nil {
+		term := normalizeSearchText(*payload.Term)
+		payload.Term = &term
+	}
+	for i, synonym := range payload.Synonyms {
+		payload.Synonyms[i] = normalizeSearchText(synonym)
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	synonym, err := h.models.Synonym.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only copy over the fields that were present in the request body.
+	if payload.Term != nil {
+		synonym.Term = *payload.Term
+	}
+	if payload.Synonyms != nil {
+		synonym.Synonyms = payload.Synonyms
+	}
+	synonym.Version = payload.Version
+
+	err = h.models.Synonym.Update(ctx, synonym)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			h.editConflictResponse(w, r, err)
+		case errors.Is(err, data.ErrDuplicateTerm):
+			h.conflictResponse(w, r, err)
+		default:
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"synonym": synonym}, nil)
+}
+
+// DELETE v1/api/search/synonyms/{id}
+func (h *Handlers) DeleteSynonymHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	id, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Synonym.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "synonym successfully deleted"}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// POST v1/api/search/rebuild
+//
+// The search configuration is rebuilt by a job.
+func (h *Handlers) RebuildSearchHandler(w http.ResponseWriter, r *http.Request) {
+	h.enqueueJob(w, r, jobRebuildSearch, struct{}{}, 1)
+}
+
+// The runRebuildSearchJob() method runs a rebuild job, refreshing the search
+// configuration in a single step.
+func (h *Handlers) runRebuildSearchJob(
+	ctx context.Context,
+	job *data.Job,
+	report JobReport,
+) (any, error) {
+	err := h.models.Synonym.Rebuild(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, h.jobServerError(job, err)
+	}
+
+	result := envelope{"message": "search configuration successfully rebuilt"}
+	report(1, result)
+	return result, nil
+}
+
+// POST v1/api/search/stop-words
+func (h *Handlers) CreateStopWordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload stopWordDTO
+
+	err := h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload.Word = normalizeSearchText(payload.Word)
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	stopWord := data.StopWord{Word: payload.Word}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.models.StopWord.Insert(ctx, &stopWord)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateStopWord) {
+			h.conflictResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, envelope{"stop_word": stopWord}, nil)
+}
+
+// GET v1/api/search/stop-words
+func (h *Handlers) ListStopWordHandler(w http.ResponseWriter, r *http.Request) {
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stopWords, err := h.models.StopWord.GetAll(ctx)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"stop_words": stopWords}, nil)
+}
+
+// DELETE v1/api/search/stop-words/{word}
+func (h *Handlers) DeleteStopWordHandler(w http.ResponseWriter, r *http.Request) {
+	word := normalizeSearchText(r.PathValue("word"))
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := h.models.StopWord.Delete(ctx, word)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "stop word successfully deleted"}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// normalizeSearchText lower cases s and collapses its runs of white space, the form in
+// which the search dictionary is stored.
+func normalizeSearchText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
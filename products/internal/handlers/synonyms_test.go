@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSynonymRepository struct {
+	mock.Mock
+}
+
+func (m *MockSynonymRepository) Insert(ctx context.Context, synonym *data.Synonym) error {
+	args := m.Called(ctx, synonym)
+	return args.Error(0)
+}
+
+func (m *MockSynonymRepository) GetByID(ctx context.Context, id int64) (*data.Synonym, error) {
+	args := m.Called(ctx, id)
+	synonym, _ := args.Get(0).(*data.Synonym)
+	return synonym, args.Error(1)
+}
+
+func (m *MockSynonymRepository) GetAll(ctx context.Context) ([]*data.Synonym, error) {
+	args := m.Called(ctx)
+	synonyms, _ := args.Get(0).([]*data.Synonym)
+	return synonyms, args.Error(1)
+}
+
+func (m *MockSynonymRepository) Update(ctx context.Context, synonym *data.Synonym) error {
+	args := m.Called(ctx, synonym)
+	return args.Error(0)
+}
+
+func (m *MockSynonymRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSynonymRepository) Rebuild(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+type MockStopWordRepository struct {
+	mock.Mock
+}
+
+func (m *MockStopWordRepository) Insert(ctx context.Context, stopWord *data.StopWord) error {
+	args := m.Called(ctx, stopWord)
+	return args.Error(0)
+}
+
+func (m *MockStopWordRepository) GetAll(ctx context.Context) ([]*data.StopWord, error) {
+	args := m.Called(ctx)
+	stopWords, _ := args.Get(0).([]*data.StopWord)
+	return stopWords, args.Error(1)
+}
+
+func (m *MockStopWordRepository) Delete(ctx context.Context, word string) error {
+	args := m.Called(ctx, word)
+	return args.Error(0)
+}
+
+func setupSearchDictionaryTest(
+	t *testing.T,
+	w io.Writer,
+	body io.Reader,
+	httpMethod string,
+	httpTarget string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockSynonymRepository, *MockStopWordRepository) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	req := httptest.NewRequest(httpMethod, httpTarget, body)
+	rw := httptest.NewRecorder()
+	mockSynonymRepo := new(MockSynonymRepository)
+	mockStopWordRepo := new(MockStopWordRepository)
+
+	handlers := Handlers{
+		logger:    logger,
+		validator: newValidator(),
+		models: data.Models{
+			Synonym:  mockSynonymRepo,
+			StopWord: mockStopWordRepo,
+		},
+	}
+
+	return rw, req, handlers, mockSynonymRepo, mockStopWordRepo
+}
+
+func TestCreateSynonymHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		payload          string
+		insertErr        error
+		expectedStatus   int
+		expectedLocation string
+		expectedResponse string
+	}{
+		{
+			name:             "creates the synonyms in lower case",
+			payload:          `{"term": " TV ", "synonyms": ["Television", "flat   screen"]}`,
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "/v1/api/search/synonyms/3",
+			expectedResponse: `{
+				"synonym": {
+					"id": 3,
+					"term": "tv",
+					"synonyms": ["television", "flat screen"],
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "term already exists",
+			payload:          `{"term": "tv", "synonyms": ["television", "flat screen"]}`,
+			insertErr:        fmt.Errorf(`term "tv": %w`, data.ErrDuplicateTerm),
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "term \"tv\": synonyms for this term already exist"}`,
+		},
+		{
+			name:             "server error",
+			payload:          `{"term": "tv", "synonyms": ["television", "flat screen"]}`,
+			insertErr:        errors.New("insert error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "failed validation",
+			payload:        `{"term": "smart tv", "synonyms": []}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {
+					"term": "must be a single word of letters and digits",
+					"synonyms": "must have at least 1 items"
+				}
+			}`,
+		},
+		{
+			name:           "invalid synonym",
+			payload:        `{"term": "tv", "synonyms": ["tele-vision"]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {"Synonyms[0]": "must be words of letters and digits"}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPost,
+				"/search/synonyms",
+			)
+			mockSynonymRepo.On("Insert", mock.Anything, &data.Synonym{
+				Term:     "tv",
+				Synonyms: []string{"television", "flat screen"},
+			}).
+				Run(func(args mock.Arguments) {
+					synonym := args.Get(1).(*data.Synonym)
+					synonym.ID = 3
+					synonym.Version = 1
+				}).
+				Return(tc.insertErr)
+
+			h.CreateSynonymHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.Equal(t, tc.expectedLocation, res.Header.Get("Location"))
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestListSynonymHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+		t,
+		&buf,
+		nil,
+		http.MethodGet,
+		"/search/synonyms",
+	)
+	mockSynonymRepo.On("GetAll", mock.Anything).Return([]*data.Synonym{
+		{ID: 3, Term: "tv", Synonyms: []string{"television"}, Version: 1},
+	}, nil)
+
+	h.ListSynonymHandler(rw, req)
+	res := rw.Result()
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	expectedResponse := `{
+		"synonyms": [{"id": 3, "term": "tv", "synonyms": ["television"], "version": 1}]
+	}`
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, expectedResponse, string(body))
+}
+
+func TestGetSynonymHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		id               string
+		getErr           error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "returns the synonym",
+			id:             "3",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"synonym": {"id": 3, "term": "tv", "synonyms": ["television"], "version": 1}
+			}`,
+		},
+		{
+			name:             "synonym not found",
+			id:               "3",
+			getErr:           data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "tv",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: tv"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+				t,
+				&buf,
+				nil,
+				http.MethodGet,
+				"/search/synonyms/"+tc.id,
+			)
+			req.SetPathValue("id", tc.id)
+			mockSynonymRepo.On("GetByID", mock.Anything, int64(3)).Return(
+				&data.Synonym{ID: 3, Term: "tv", Synonyms: []string{"television"}, Version: 1},
+				tc.getErr,
+			)
+
+			h.GetSynonymHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestUpdateSynonymHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		payload          string
+		updateErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "replaces the synonyms",
+			payload:        `{"synonyms": ["Telly"], "version": 1}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"synonym": {"id": 3, "term": "tv", "synonyms": ["telly"], "version": 2}
+			}`,
+		},
+		{
+			name:             "edit conflict",
+			payload:          `{"synonyms": ["telly"], "version": 1}`,
+			updateErr:        data.ErrEditConflict,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "unable to update the record due to an edit conflict, please try again"}`,
+		},
+		{
+			name:             "missing version",
+			payload:          `{"synonyms": ["telly"]}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"version": "is required"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPatch,
+				"/search/synonyms/3",
+			)
+			req.SetPathValue("id", "3")
+			mockSynonymRepo.On("GetByID", mock.Anything, int64(3)).Return(
+				&data.Synonym{ID: 3, Term: "tv", Synonyms: []string{"television"}, Version: 1},
+				nil,
+			)
+			mockSynonymRepo.On("Update", mock.Anything, &data.Synonym{
+				ID:       3,
+				Term:     "tv",
+				Synonyms: []string{"telly"},
+				Version:  1,
+			}).
+				Run(func(args mock.Arguments) {
+					args.Get(1).(*data.Synonym).Version = 2
+				}).
+				Return(tc.updateErr)
+
+			h.UpdateSynonymHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestDeleteSynonymHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		deleteErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "deletes the synonym",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"message": "synonym successfully deleted"}`,
+		},
+		{
+			name:             "synonym not found",
+			deleteErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+				t,
+				&buf,
+				nil,
+				http.MethodDelete,
+				"/search/synonyms/3",
+			)
+			req.SetPathValue("id", "3")
+			mockSynonymRepo.On("Delete", mock.Anything, int64(3)).Return(tc.deleteErr)
+
+			h.DeleteSynonymHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestRebuildSearchHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	rw, req, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+		t,
+		&buf,
+		nil,
+		http.MethodPost,
+		"/search/rebuild",
+	)
+
+	job := queueJob(t, &h, h.RebuildSearchHandler, rw, req)
+	assert.Equal(t, jobRebuildSearch, job.Kind)
+	assert.Equal(t, 1, job.Total)
+	mockSynonymRepo.AssertNotCalled(t, "Rebuild", mock.Anything)
+}
+
+func TestRunRebuildSearchJob(t *testing.T) {
+	var buf bytes.Buffer
+
+	job := &data.Job{ID: 12, Kind: jobRebuildSearch, Payload: []byte(`{}`), Total: 1}
+
+	t.Run("rebuilds the search configuration", func(t *testing.T) {
+		_, _, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+			t, &buf, nil, http.MethodPost, "/search/rebuild",
+		)
+		mockSynonymRepo.On("Rebuild", mock.Anything).Return(nil)
+
+		progress := 0
+		result, err := h.runRebuildSearchJob(
+			context.Background(),
+			job,
+			func(p int, _ any) { progress = p },
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, envelope{"message": "search configuration successfully rebuilt"}, result)
+		assert.Equal(t, 1, progress)
+		buf.Reset()
+	})
+
+	t.Run("fails with a server error", func(t *testing.T) {
+		_, _, h, mockSynonymRepo, _ := setupSearchDictionaryTest(
+			t, &buf, nil, http.MethodPost, "/search/rebuild",
+		)
+		mockSynonymRepo.On("Rebuild", mock.Anything).Return(errors.New("refresh error"))
+
+		result, err := h.runRebuildSearchJob(context.Background(), job, func(int, any) {})
+		assert.Nil(t, result)
+		assert.Equal(t, "the server encountered a problem and could not process your request", err.Error())
+		assert.Contains(t, buf.String(), `msg="refresh error" job=12 kind=rebuild_search`)
+		buf.Reset()
+	})
+}
+
+func TestStopWordHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	createdAt := time.Date(2023, time.July, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("creates a stop word", func(t *testing.T) {
+		rw, req, h, _, mockStopWordRepo := setupSearchDictionaryTest(
+			t,
+			&buf,
+			strings.NewReader(`{"word": "With"}`),
+			http.MethodPost,
+			"/search/stop-words",
+		)
+		mockStopWordRepo.On("Insert", mock.Anything, &data.StopWord{Word: "with"}).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*data.StopWord).CreatedAt = createdAt
+			}).
+			Return(nil)
+
+		h.CreateStopWordHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"stop_word": {"word": "with", "created_at": "2023-07-01T10:00:00Z"}
+		}`
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("stop word already exists", func(t *testing.T) {
+		rw, req, h, _, mockStopWordRepo := setupSearchDictionaryTest(
+			t,
+			&buf,
+			strings.NewReader(`{"word": "with"}`),
+			http.MethodPost,
+			"/search/stop-words",
+		)
+		mockStopWordRepo.On("Insert", mock.Anything, mock.Anything).
+			Return(fmt.Errorf(`word "with": %w`, data.ErrDuplicateStopWord))
+
+		h.CreateStopWordHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		assert.JSONEq(t, `{"error": "word \"with\": stop word already exists"}`, string(body))
+		buf.Reset()
+	})
+
+	t.Run("invalid stop word", func(t *testing.T) {
+		rw, req, h, _, _ := setupSearchDictionaryTest(
+			t,
+			&buf,
+			strings.NewReader(`{"word": "with out"}`),
+			http.MethodPost,
+			"/search/stop-words",
+		)
+
+		h.CreateStopWordHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{"error": {"word": "must be a single word of letters and digits"}}`
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("lists the stop words", func(t *testing.T) {
+		rw, req, h, _, mockStopWordRepo := setupSearchDictionaryTest(
+			t,
+			&buf,
+			nil,
+			http.MethodGet,
+			"/search/stop-words",
+		)
+		mockStopWordRepo.On("GetAll", mock.Anything).
+			Return([]*data.StopWord{{Word: "with", CreatedAt: createdAt}}, nil)
+
+		h.ListStopWordHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"stop_words": [{"word": "with", "created_at": "2023-07-01T10:00:00Z"}]
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("deletes a stop word", func(t *testing.T) {
+		rw, req, h, _, mockStopWordRepo := setupSearchDictionaryTest(
+			t,
+			&buf,
+			nil,
+			http.MethodDelete,
+			"/search/stop-words/With",
+		)
+		req.SetPathValue("word", "With")
+		mockStopWordRepo.On("Delete", mock.Anything, "with").Return(data.ErrRecordNotFound)
+
+		h.DeleteStopWordHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.JSONEq(t, `{"error": "the requested resource could not be found"}`, string(body))
+		buf.Reset()
+	})
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+)
+
+// variantDTO holds the fields of a new variant. Price is optional; without it the
+// variant sells at the product price.
+type variantDTO struct {
+	SKU      string              `json:"sku"      validate:"required,max=64"`
+	Options  data.VariantOptions `json:"options"  validate:"dive,keys,min=1,max=50,endkeys,min=1,max=100"`
+	Price    *data.Money         `json:"price"    validate:"omitempty,money"`
+	Quantity int                 `json:"quantity" validate:"omitempty,gte=0"`
+}
+
+// updateVariantDTO holds the fields that may be changed by a PATCH request. As with
+// products, a supplied version must match the stored version of the variant. A null
+// price removes the price of the variant, which then sells at the product price.
+type updateVariantDTO struct {
+	SKU      *string              `json:"sku"      validate:"omitempty,min=1,max=64"`
+	Options  *data.VariantOptions `json:"options"  validate:"omitempty,dive,keys,min=1,max=50,endkeys,min=1,max=100"`
+	Price    nullableMoney        `json:"price"    validate:"omitempty,money"`
+	Quantity *int                 `json:"quantity" validate:"omitempty,gte=0"`
+	Version  *int                 `json:"version"  validate:"omitempty,gte=1"`
+}
+
+// nullableMoney is an amount of a PATCH request body that tells a null amount, which
+// clears the field, apart from one that is left out. Set is true when the field is
+// present, and Money is nil when it is null.
+type nullableMoney struct {
+	Set   bool
+	Money *data.Money
+}
+
+// UnmarshalJSON is only called when the field is present, null included.
+func (n *nullableMoney) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Money = nil
+		return nil
+	}
+
+	var amount data.Money
+	if err := amount.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	n.Money = &amount
+	return nil
+}
+
+// validatedNullableMoney returns the amount the validation tags of a nullableMoney
+// field apply to, or nil when there is none.
+func validatedNullableMoney(field reflect.Value) any {
+	n := field.Interface().(nullableMoney)
+	if n.Money == nil {
+		return nil
+	}
+	return *n.Money
+}
+
+// POST v1/api/products/{id}/variants
+func (h *Handlers) CreateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload variantDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	variant := data.Variant{
+		ProductID: productID,
+		SKU:       payload.SKU,
+		Options:   payload.Options,
+		Price:     payload.Price,
+		Quantity:  payload.Quantity,
+	}
+	if variant.Options == nil {
+		variant.Options = data.VariantOptions{}
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Variant.Insert(ctx, &variant)
+	if err != nil {
+		h.variantWriteErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set(
+		"Location",
+		fmt.Sprintf("/v1/api/products/%d/variants/%d", variant.ProductID, variant.ID),
+	)
+	h.writeJSON(w, r, http.StatusCreated, envelope{"variant": variant}, headers)
+}
+
+// GET v1/api/products/{id}/variants
+func (h *Handlers) ListVariantHandler(w http.ResponseWriter, r *http.Request) {
+	// Read and validate id param.
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// An unknown product is reported as 404 Not Found rather than as having no variants.
+	_, err = h.models.Product.GetByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	variants, err := h.models.Variant.GetAll(ctx, productID)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"variants": variants}, nil)
+}
+
+// GET v1/api/products/{id}/variants/{variant_id}
+func (h *Handlers) GetVariantHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := h.readVariantParams(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	variant, err := h.models.Variant.GetByID(ctx, productID, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"variant": variant}, nil)
+}
+
+// PATCH v1/api/products/{id}/variants/{variant_id}
+func (h *Handlers) UpdateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := h.readVariantParams(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Parse and validate the request body before touching the database.
+	var payload updateVariantDTO
+
+	err = h.readJSON(w, r, &payload)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	variant, err := h.models.Variant.GetByID(ctx, productID, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// If the client supplied the version it last read, make sure it is still current.
+	if payload.Version != nil && *payload.Version != variant.Version {
+		h.editConflictResponse(w, r, data.ErrEditConflict)
+		return
+	}
+
+	// Only copy over the fields that were present in the request body.
+	if payload.SKU != nil {
+		variant.SKU = *payload.SKU
+	}
+	if payload.Options != nil {
+		variant.Options = *payload.Options
+	}
+	if payload.Price.Set {
+		variant.Price = payload.Price.Money
+	}
+	if payload.Quantity != nil {
+		variant.Quantity = *payload.Quantity
+	}
+
+	err = h.models.Variant.Update(ctx, variant)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			h.editConflictResponse(w, r, err)
+		} else {
+			h.variantWriteErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, envelope{"variant": variant}, nil)
+}
+
+// DELETE v1/api/products/{id}/variants/{variant_id}
+func (h *Handlers) DeleteVariantHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := h.readVariantParams(r)
+	if err != nil {
+		h.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Create a context with a 5-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.models.Variant.Delete(ctx, productID, id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			h.notFoundResponse(w, r, err)
+		} else {
+			h.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"message": "variant successfully deleted"}
+	h.writeJSON(w, r, http.StatusOK, env, nil)
+}
+
+// The readVariantParams() helper reads the product id and the variant id from the
+// request URL.
+func (h *Handlers) readVariantParams(r *http.Request) (int64, int64, error) {
+	productID, err := h.readIDParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	id, err := h.readIDParamNamed(r, "variant_id")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return productID, id, nil
+}
+
+// The variantWriteErrorResponse() helper responds to a failed variant insert or
+// update. A missing product is reported with 404 Not Found and a SKU or option set
+// that is already taken with 409 Conflict.
+func (h *Handlers) variantWriteErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		h.notFoundResponse(w, r, err)
+	case errors.Is(err, data.ErrDuplicateSKU), errors.Is(err, data.ErrDuplicateVariant):
+		h.conflictResponse(w, r, err)
+	case errors.Is(err, data.ErrMoneyOutOfRange):
+		h.priceOutOfRangeResponse(w, r, err)
+	default:
+		h.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chlovec/go-ecommerce/products/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockVariantRepository struct {
+	mock.Mock
+}
+
+func (m *MockVariantRepository) Insert(ctx context.Context, variant *data.Variant) error {
+	args := m.Called(ctx, variant)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepository) GetByID(
+	ctx context.Context,
+	productID, id int64,
+) (*data.Variant, error) {
+	args := m.Called(ctx, productID, id)
+	variant, _ := args.Get(0).(*data.Variant)
+	return variant, args.Error(1)
+}
+
+func (m *MockVariantRepository) GetAll(
+	ctx context.Context,
+	productID int64,
+) ([]*data.Variant, error) {
+	args := m.Called(ctx, productID)
+	variants, _ := args.Get(0).([]*data.Variant)
+	return variants, args.Error(1)
+}
+
+func (m *MockVariantRepository) Update(ctx context.Context, variant *data.Variant) error {
+	args := m.Called(ctx, variant)
+	return args.Error(0)
+}
+
+func (m *MockVariantRepository) Delete(ctx context.Context, productID, id int64) error {
+	args := m.Called(ctx, productID, id)
+	return args.Error(0)
+}
+
+func withVariantParams(req *http.Request, id, variantID string) *http.Request {
+	req.SetPathValue("id", id)
+	req.SetPathValue("variant_id", variantID)
+	return req
+}
+
+func setupVariantRequestTest(
+	t *testing.T,
+	w io.Writer,
+	body io.Reader,
+	httpMethod string,
+	httpTarget string,
+) (*httptest.ResponseRecorder, *http.Request, Handlers, *MockVariantRepository, *MockProductRepository) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	req := httptest.NewRequest(httpMethod, httpTarget, body)
+	rw := httptest.NewRecorder()
+	mockVariantRepo := new(MockVariantRepository)
+	mockProductRepo := new(MockProductRepository)
+
+	handlers := Handlers{
+		logger:    logger,
+		validator: newValidator(),
+		models: data.Models{
+			Product: mockProductRepo,
+			Variant: mockVariantRepo,
+		},
+	}
+
+	return rw, req, handlers, mockVariantRepo, mockProductRepo
+}
+
+func TestCreateVariantHandler(t *testing.T) {
+	var buf bytes.Buffer
+	price := data.Money(24_990)
+
+	testCases := []struct {
+		name             string
+		id               string
+		payload          string
+		insertErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "creates a variant",
+			id:             "12",
+			payload:        `{"sku": "TS-L", "options": {"size": "L"}, "price": "24.99", "quantity": 3}`,
+			expectedStatus: http.StatusCreated,
+			expectedResponse: `{
+				"variant": {
+					"id": 3,
+					"product_id": 12,
+					"sku": "TS-L",
+					"options": {"size": "L"},
+					"price": "24.99",
+					"quantity": 3,
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "product not found",
+			id:               "12",
+			payload:          `{"sku": "TS-L", "options": {"size": "L"}, "price": "24.99", "quantity": 3}`,
+			insertErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "sku already exists",
+			id:               "12",
+			payload:          `{"sku": "TS-L", "options": {"size": "L"}, "price": "24.99", "quantity": 3}`,
+			insertErr:        data.ErrDuplicateSKU,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "sku already exists"}`,
+		},
+		{
+			name:             "options already exist",
+			id:               "12",
+			payload:          `{"sku": "TS-L", "options": {"size": "L"}, "price": "24.99", "quantity": 3}`,
+			insertErr:        data.ErrDuplicateVariant,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "a variant with the same options already exists"}`,
+		},
+		{
+			name:             "server error",
+			id:               "12",
+			payload:          `{"sku": "TS-L", "options": {"size": "L"}, "price": "24.99", "quantity": 3}`,
+			insertErr:        errors.New("insert error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: `{"error": "the server encountered a problem and could not process your request"}`,
+		},
+		{
+			name:           "failed validation",
+			id:             "12",
+			payload:        `{"sku": "", "price": "-1", "quantity": -1}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: `{
+				"error": {
+					"sku": "is required",
+					"price": "must be an amount between 0 and 9999999.999",
+					"quantity": "must be greater than or equal to 0"
+				}
+			}`,
+		},
+		{
+			name:             "invalid id",
+			id:               "abc",
+			payload:          `{"sku": "TS-L"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: abc"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockVariantRepo, _ := setupVariantRequestTest(
+				t,
+				&buf,
+				strings.NewReader(tc.payload),
+				http.MethodPost,
+				"/products/"+tc.id+"/variants",
+			)
+			req = withIDParam(req, tc.id)
+			mockVariantRepo.On("Insert", mock.Anything, &data.Variant{
+				ProductID: 12,
+				SKU:       "TS-L",
+				Options:   data.VariantOptions{"size": "L"},
+				Price:     &price,
+				Quantity:  3,
+			}).
+				Run(func(args mock.Arguments) {
+					variant := args.Get(1).(*data.Variant)
+					variant.ID = 3
+					variant.Version = 1
+				}).
+				Return(tc.insertErr)
+
+			h.CreateVariantHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			if tc.expectedStatus == http.StatusCreated {
+				assert.Equal(t, "/v1/api/products/12/variants/3", res.Header.Get("Location"))
+			}
+			buf.Reset()
+		})
+	}
+}
+
+func TestListVariantHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	t.Run("lists the variants", func(t *testing.T) {
+		rw, req, h, mockVariantRepo, mockProductRepo := setupVariantRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/12/variants",
+		)
+		req = withIDParam(req, "12")
+		mockProductRepo.On("GetByID", mock.Anything, int64(12)).Return(&data.Product{ID: 12}, nil)
+		mockVariantRepo.On("GetAll", mock.Anything, int64(12)).Return([]*data.Variant{{
+			ID:        3,
+			ProductID: 12,
+			SKU:       "TS-L",
+			Options:   data.VariantOptions{"size": "L"},
+			Version:   1,
+		}}, nil)
+
+		h.ListVariantHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+
+		expectedResponse := `{
+			"variants": [{
+				"id": 3,
+				"product_id": 12,
+				"sku": "TS-L",
+				"options": {"size": "L"},
+				"quantity": 0,
+				"version": 1
+			}]
+		}`
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, expectedResponse, string(body))
+		buf.Reset()
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		rw, req, h, mockVariantRepo, mockProductRepo := setupVariantRequestTest(
+			t, &buf, nil, http.MethodGet, "/products/12/variants",
+		)
+		req = withIDParam(req, "12")
+		mockProductRepo.On("GetByID", mock.Anything, int64(12)).Return(nil, data.ErrRecordNotFound)
+
+		h.ListVariantHandler(rw, req)
+		res := rw.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		mockVariantRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything)
+		buf.Reset()
+	})
+}
+
+func TestGetVariantHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		variantID        string
+		getErr           error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "returns the variant",
+			variantID:      "3",
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"variant": {
+					"id": 3,
+					"product_id": 12,
+					"sku": "TS-L",
+					"options": {"size": "L"},
+					"quantity": 2,
+					"version": 1
+				}
+			}`,
+		},
+		{
+			name:             "variant not found",
+			variantID:        "3",
+			getErr:           data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "invalid variant id",
+			variantID:        "x",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"error": "invalid id parameter: x"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockVariantRepo, _ := setupVariantRequestTest(
+				t, &buf, nil, http.MethodGet, "/products/12/variants/"+tc.variantID,
+			)
+			req = withVariantParams(req, "12", tc.variantID)
+			mockVariantRepo.On("GetByID", mock.Anything, int64(12), int64(3)).Return(&data.Variant{
+				ID:        3,
+				ProductID: 12,
+				SKU:       "TS-L",
+				Options:   data.VariantOptions{"size": "L"},
+				Quantity:  2,
+				Version:   1,
+			}, tc.getErr)
+
+			h.GetVariantHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
+
+func TestUpdateVariantHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		payload          string
+		updateErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:           "updates the variant",
+			payload:        `{"quantity": 8, "price": 21}`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: `{
+				"variant": {
+					"id": 3,
+					"product_id": 12,
+					"sku": "TS-L",
+					"options": {"size": "L"},
+					"price": "21.00",
+					"quantity": 8,
+					"version": 2
+				}
+			}`,
+		},
+		{
+			name:             "stale version",
+			payload:          `{"quantity": 8, "version": 7}`,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "unable to update the record due to an edit conflict, please try again"}`,
+		},
+		{
+			name:             "sku already exists",
+			payload:          `{"quantity": 8, "price": 21}`,
+			updateErr:        data.ErrDuplicateSKU,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "sku already exists"}`,
+		},
+		{
+			name:             "product deleted",
+			payload:          `{"quantity": 8}`,
+			updateErr:        fmt.Errorf("product 12: %w", data.ErrRecordNotFound),
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+		{
+			name:             "edit conflict",
+			payload:          `{"quantity": 8, "price": 21}`,
+			updateErr:        data.ErrEditConflict,
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: `{"error": "unable to update the record due to an edit conflict, please try again"}`,
+		},
+		{
+			name:             "price out of range",
+			payload:          `{"price": "-1"}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"price": "must be an amount between 0 and 9999999.999"}}`,
+		},
+		{
+			name:             "failed validation",
+			payload:          `{"quantity": -8}`,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"error": {"quantity": "must be greater than or equal to 0"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockVariantRepo, _ := setupVariantRequestTest(
+				t, &buf, strings.NewReader(tc.payload), http.MethodPatch, "/products/12/variants/3",
+			)
+			req = withVariantParams(req, "12", "3")
+			mockVariantRepo.On("GetByID", mock.Anything, int64(12), int64(3)).Return(&data.Variant{
+				ID:        3,
+				ProductID: 12,
+				SKU:       "TS-L",
+				Options:   data.VariantOptions{"size": "L"},
+				Quantity:  2,
+				Version:   1,
+			}, nil)
+			mockVariantRepo.On("Update", mock.Anything, mock.AnythingOfType("*data.Variant")).
+				Run(func(args mock.Arguments) {
+					args.Get(1).(*data.Variant).Version = 2
+				}).
+				Return(tc.updateErr)
+
+			h.UpdateVariantHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+
+	price := data.Money(21_000)
+	priceCases := []struct {
+		name          string
+		payload       string
+		expectedPrice *data.Money
+	}{
+		{name: "a null price removes the price", payload: `{"price": null}`},
+		{name: "a missing price keeps the price", payload: `{"quantity": 8}`, expectedPrice: &price},
+	}
+
+	for _, tc := range priceCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockVariantRepo, _ := setupVariantRequestTest(
+				t, &buf, strings.NewReader(tc.payload), http.MethodPatch, "/products/12/variants/3",
+			)
+			req = withVariantParams(req, "12", "3")
+			mockVariantRepo.On("GetByID", mock.Anything, int64(12), int64(3)).Return(&data.Variant{
+				ID:        3,
+				ProductID: 12,
+				SKU:       "TS-L",
+				Options:   data.VariantOptions{"size": "L"},
+				Price:     &price,
+				Version:   1,
+			}, nil)
+			mockVariantRepo.On("Update", mock.Anything, mock.MatchedBy(func(v *data.Variant) bool {
+				return assert.ObjectsAreEqual(tc.expectedPrice, v.Price)
+			})).Return(nil)
+
+			h.UpdateVariantHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			mockVariantRepo.AssertExpectations(t)
+			buf.Reset()
+		})
+	}
+}
+
+func TestDeleteVariantHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	testCases := []struct {
+		name             string
+		deleteErr        error
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "deletes the variant",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"message": "variant successfully deleted"}`,
+		},
+		{
+			name:             "variant not found",
+			deleteErr:        data.ErrRecordNotFound,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: `{"error": "the requested resource could not be found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw, req, h, mockVariantRepo, _ := setupVariantRequestTest(
+				t, &buf, nil, http.MethodDelete, "/products/12/variants/3",
+			)
+			req = withVariantParams(req, "12", "3")
+			mockVariantRepo.On("Delete", mock.Anything, int64(12), int64(3)).Return(tc.deleteErr)
+
+			h.DeleteVariantHandler(rw, req)
+			res := rw.Result()
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			assert.JSONEq(t, tc.expectedResponse, string(body))
+			buf.Reset()
+		})
+	}
+}
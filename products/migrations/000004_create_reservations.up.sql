@@ -0,0 +1,17 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reserved INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE products ADD CONSTRAINT products_quantity_check CHECK (quantity >= 0);
+
+ALTER TABLE products ADD CONSTRAINT products_reserved_check CHECK (reserved >= 0 AND reserved <= quantity);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'held' CHECK (status IN ('held', 'committed', 'released', 'expired')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS reservations_held_expires_at_idx ON reservations (expires_at) WHERE status = 'held';
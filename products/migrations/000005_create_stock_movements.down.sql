@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS products_stock_movement ON products;
+
+DROP FUNCTION IF EXISTS record_stock_movement();
+
+DROP TABLE IF EXISTS stock_movements;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    delta INTEGER NOT NULL CHECK (delta <> 0),
+    reason TEXT NOT NULL CHECK (reason IN ('received', 'sold', 'damaged', 'correction')),
+    reference TEXT NOT NULL DEFAULT '',
+    quantity_after INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_id_created_at_idx ON stock_movements (product_id, created_at);
+
+-- Every change to products.quantity is recorded, whichever statement makes it. The
+-- reason and reference are taken from the stock.reason and stock.reference settings
+-- of the current transaction; without them a new product counts as received stock
+-- and any other change as a correction.
+CREATE OR REPLACE FUNCTION record_stock_movement() RETURNS trigger AS $$
+DECLARE
+    delta INTEGER;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        delta := NEW.quantity;
+    ELSE
+        delta := NEW.quantity - OLD.quantity;
+    END IF;
+
+    IF delta <> 0 THEN
+        INSERT INTO stock_movements (product_id, delta, reason, reference, quantity_after)
+        VALUES (
+            NEW.id,
+            delta,
+            COALESCE(
+                NULLIF(current_setting('stock.reason', true), ''),
+                CASE WHEN TG_OP = 'INSERT' THEN 'received' ELSE 'correction' END
+            ),
+            COALESCE(current_setting('stock.reference', true), ''),
+            NEW.quantity
+        );
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_stock_movement
+    AFTER INSERT OR UPDATE OF quantity ON products
+    FOR EACH ROW EXECUTE FUNCTION record_stock_movement();
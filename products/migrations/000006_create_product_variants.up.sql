@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS product_variants (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku TEXT NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}',
+    price NUMERIC(10, 3),
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT product_variants_sku_key UNIQUE (sku),
+    CONSTRAINT product_variants_options_key UNIQUE (product_id, options)
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_parent_id_idx;
+
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_id_check;
+
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_id_fkey;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
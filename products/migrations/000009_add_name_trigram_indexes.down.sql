@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS categories_name_trgm_idx;
+DROP INDEX IF EXISTS products_name_trgm_idx;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS search_tsquery(regconfig, text, boolean);
+
+DROP MATERIALIZED VIEW IF EXISTS search_expansions;
+
+DROP TABLE IF EXISTS search_stop_words;
+
+DROP TABLE IF EXISTS search_synonyms;
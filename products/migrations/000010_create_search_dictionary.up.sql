@@ -0,0 +1,71 @@
+CREATE TABLE IF NOT EXISTS search_synonyms (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    term TEXT NOT NULL,
+    synonyms TEXT[] NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT search_synonyms_term_key UNIQUE (term)
+);
+
+CREATE TABLE IF NOT EXISTS search_stop_words (
+    word TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW()
+);
+
+-- search_expansions lists, for every word, the words and phrases a search for it also
+-- matches. Synonyms work both ways, so "tv" => {television} also expands "television"
+-- to "tv". It is refreshed whenever the synonyms change.
+CREATE MATERIALIZED VIEW IF NOT EXISTS search_expansions AS
+    SELECT word, array_agg(DISTINCT expansion ORDER BY expansion) AS expansions
+    FROM (
+        SELECT term AS word, unnest(synonyms) AS expansion FROM search_synonyms
+        UNION
+        SELECT unnest(synonyms), term FROM search_synonyms
+    ) AS pairs
+    WHERE word <> expansion
+    GROUP BY word;
+
+CREATE UNIQUE INDEX IF NOT EXISTS search_expansions_word_idx ON search_expansions (word);
+
+-- search_tsquery builds the tsquery for a search typed by a user. Every word of q that
+-- is not a stop word must match, either itself or one of its expansions. With prefix
+-- set the words typed by the user also match the longer words they begin. Only the
+-- letters and digits of q are used, so q cannot inject tsquery operators. It returns
+-- NULL, which matches nothing, when q has no words left.
+CREATE OR REPLACE FUNCTION search_tsquery(config regconfig, q text, prefix boolean DEFAULT false)
+RETURNS tsquery AS $$
+DECLARE
+    result tsquery;
+    term tsquery;
+    token text;
+    expansion text;
+BEGIN
+    FOR token IN
+        SELECT t FROM regexp_split_to_table(lower(q), '[^[:alnum:]]+') AS t
+        WHERE t <> '' AND NOT EXISTS (SELECT 1 FROM search_stop_words s WHERE s.word = t)
+    LOOP
+        IF prefix THEN
+            term := to_tsquery(config, token || ':*');
+        ELSE
+            term := plainto_tsquery(config, token);
+        END IF;
+
+        FOR expansion IN
+            SELECT unnest(e.expansions) FROM search_expansions e WHERE e.word = token
+        LOOP
+            term := term || phraseto_tsquery(config, expansion);
+        END LOOP;
+
+        -- The text search configuration may drop the word as one of its own stop words.
+        CONTINUE WHEN numnode(term) = 0;
+
+        IF result IS NULL THEN
+            result := term;
+        ELSE
+            result := result && term;
+        END IF;
+    END LOOP;
+
+    RETURN result;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_name_spanish_idx;
+DROP INDEX IF EXISTS categories_name_english_idx;
+DROP INDEX IF EXISTS categories_name_simple_idx;
+DROP INDEX IF EXISTS products_name_spanish_idx;
+DROP INDEX IF EXISTS products_name_english_idx;
+DROP INDEX IF EXISTS products_search_spanish_idx;
+DROP INDEX IF EXISTS products_search_english_idx;
@@ -0,0 +1,20 @@
+-- The search_vector column and products_name_idx are built with the simple text search
+-- configuration. Searches in a language with a configuration of its own stem the
+-- words, so they match these expressions instead, which must stay identical to the
+-- ones built by the queries.
+CREATE INDEX IF NOT EXISTS products_search_english_idx ON products USING GIN ((
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', description), 'B')
+));
+
+CREATE INDEX IF NOT EXISTS products_search_spanish_idx ON products USING GIN ((
+    setweight(to_tsvector('spanish', name), 'A') ||
+    setweight(to_tsvector('spanish', description), 'B')
+));
+
+CREATE INDEX IF NOT EXISTS products_name_english_idx ON products USING GIN (to_tsvector('english', name));
+CREATE INDEX IF NOT EXISTS products_name_spanish_idx ON products USING GIN (to_tsvector('spanish', name));
+
+CREATE INDEX IF NOT EXISTS categories_name_simple_idx ON categories USING GIN (to_tsvector('simple', name));
+CREATE INDEX IF NOT EXISTS categories_name_english_idx ON categories USING GIN (to_tsvector('english', name));
+CREATE INDEX IF NOT EXISTS categories_name_spanish_idx ON categories USING GIN (to_tsvector('spanish', name));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS products_attributes_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS attributes;
@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE products ADD CONSTRAINT products_attributes_object_check
+    CHECK (jsonb_typeof(attributes) = 'object');
+
+-- jsonb_path_ops serves both the @> equality filters and the @@ jsonpath range filters
+-- on the attributes.
+CREATE INDEX IF NOT EXISTS products_attributes_idx ON products USING GIN (attributes jsonb_path_ops);
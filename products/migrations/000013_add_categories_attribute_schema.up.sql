@@ -0,0 +1,4 @@
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS attribute_schema JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE categories ADD CONSTRAINT categories_attribute_schema_object_check
+    CHECK (jsonb_typeof(attribute_schema) = 'object');
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_deleted_at_idx;
+
+DROP INDEX IF EXISTS products_deleted_at_idx;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
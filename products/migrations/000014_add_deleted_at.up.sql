@@ -0,0 +1,11 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ(0);
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ(0);
+
+-- Only the deleted rows are indexed; they are looked up by the purge, which removes
+-- the rows deleted before the retention period.
+CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS categories_deleted_at_idx ON categories (deleted_at)
+    WHERE deleted_at IS NOT NULL;
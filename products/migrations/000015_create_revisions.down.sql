@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS categories_revision ON categories;
+
+DROP TRIGGER IF EXISTS products_revision ON products;
+
+DROP FUNCTION IF EXISTS record_revision();
+
+DROP TABLE IF EXISTS revisions;
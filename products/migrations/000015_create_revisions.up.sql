@@ -0,0 +1,87 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    entity TEXT NOT NULL CHECK (entity IN ('product', 'category')),
+    entity_id BIGINT NOT NULL,
+    version INTEGER NOT NULL,
+    operation TEXT NOT NULL
+        CHECK (operation IN ('insert', 'update', 'delete', 'restore', 'purge')),
+    actor TEXT NOT NULL DEFAULT '',
+    snapshot JSONB NOT NULL,
+    UNIQUE (entity, entity_id, version)
+);
+
+-- Every write that bumps the version of a product or category is recorded with a full
+-- snapshot of the row, whichever statement makes it. The actor is taken from the
+-- audit.actor setting of the current transaction. Reserving stock only moves the
+-- reserved units and keeps the version, so reserved is left out of the snapshots along
+-- with the generated search_vector. Purging a row keeps its history and closes it with
+-- a 'purge' revision holding the last state of the row, one version past it, so that
+-- the audit trail outlives the record.
+CREATE OR REPLACE FUNCTION record_revision() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO revisions (entity, entity_id, version, operation, actor, snapshot)
+        VALUES (
+            TG_ARGV[0],
+            OLD.id,
+            OLD.version + 1,
+            'purge',
+            COALESCE(current_setting('audit.actor', true), ''),
+            to_jsonb(OLD) - 'search_vector' - 'reserved'
+        );
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND NEW.version = OLD.version THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO revisions (entity, entity_id, version, operation, actor, snapshot)
+    VALUES (
+        TG_ARGV[0],
+        NEW.id,
+        NEW.version,
+        CASE
+            WHEN TG_OP = 'INSERT' THEN 'insert'
+            WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN 'delete'
+            WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN 'restore'
+            ELSE 'update'
+        END,
+        COALESCE(current_setting('audit.actor', true), ''),
+        to_jsonb(NEW) - 'search_vector' - 'reserved'
+    );
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_revision
+    AFTER INSERT OR UPDATE OR DELETE ON products
+    FOR EACH ROW EXECUTE FUNCTION record_revision('product');
+
+CREATE TRIGGER categories_revision
+    AFTER INSERT OR UPDATE OR DELETE ON categories
+    FOR EACH ROW EXECUTE FUNCTION record_revision('category');
+
+-- The rows written before the history existed start it with their current state, so
+-- that their next change has a version to be compared against.
+INSERT INTO revisions (created_at, entity, entity_id, version, operation, snapshot)
+SELECT
+    CASE WHEN version = 1 THEN created_at ELSE NOW() END,
+    'product',
+    id,
+    version,
+    CASE WHEN version = 1 THEN 'insert' ELSE 'update' END,
+    to_jsonb(products) - 'search_vector' - 'reserved'
+FROM products;
+
+INSERT INTO revisions (created_at, entity, entity_id, version, operation, snapshot)
+SELECT
+    CASE WHEN version = 1 THEN created_at ELSE NOW() END,
+    'category',
+    id,
+    version,
+    CASE WHEN version = 1 THEN 'insert' ELSE 'update' END,
+    to_jsonb(categories)
+FROM categories;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS category_slug_redirects;
+
+DROP TABLE IF EXISTS product_slug_redirects;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS slug;
+
+ALTER TABLE products DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,74 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS slug TEXT;
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS slug TEXT;
+
+-- The unique constraints come before the backfill, whose lookups of taken slugs use
+-- their indexes. They allow the slugs still NULL.
+ALTER TABLE products ADD CONSTRAINT products_slug_key UNIQUE (slug);
+
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+
+-- The existing rows are given slugs the way the application gives them: the slug of
+-- their name, cut to 100 characters, or the kind of the row for a name without letters
+-- or digits. In id order, a row whose slug is already taken gets the first free -2, -3,
+-- ... suffix, with the slug cut short to make room for it.
+DO $$
+DECLARE
+    target record;
+    entry record;
+    base text;
+    candidate text;
+    n integer;
+    taken boolean;
+BEGIN
+    FOR target IN
+        SELECT * FROM (VALUES ('products', 'product'), ('categories', 'category')) t (tbl, fallback)
+    LOOP
+        FOR entry IN EXECUTE format('SELECT id, name FROM %I ORDER BY id', target.tbl) LOOP
+            base := COALESCE(NULLIF(rtrim(left(
+                trim(BOTH '-' FROM regexp_replace(lower(entry.name), '[^[:alnum:]]+', '-', 'g')),
+                100
+            ), '-'), ''), target.fallback);
+
+            candidate := base;
+            n := 2;
+            LOOP
+                EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE slug = $1)', target.tbl)
+                    INTO taken USING candidate;
+                EXIT WHEN NOT taken;
+
+                candidate := rtrim(left(base, 100 - length('-' || n)), '-') || '-' || n;
+                n := n + 1;
+            END LOOP;
+
+            EXECUTE format('UPDATE %I SET slug = $1 WHERE id = $2', target.tbl)
+                USING candidate, entry.id;
+        END LOOP;
+    END LOOP;
+END;
+$$;
+
+ALTER TABLE products ALTER COLUMN slug SET NOT NULL;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+
+-- The slugs a row had before it was renamed keep leading to it. A slug is either the
+-- current slug of a row or the old slug of one, never both, so that old links never
+-- lead to another row.
+CREATE TABLE IF NOT EXISTS product_slug_redirects (
+    slug TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_slug_redirects_product_id_idx
+    ON product_slug_redirects (product_id);
+
+CREATE TABLE IF NOT EXISTS category_slug_redirects (
+    slug TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    category_id BIGINT NOT NULL REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS category_slug_redirects_category_id_idx
+    ON category_slug_redirects (category_id);
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS external_id TEXT;
+
+ALTER TABLE products ADD CONSTRAINT products_external_id_key UNIQUE (external_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS products_updated_at_idx;
+
+DROP TRIGGER IF EXISTS products_updated_at ON products;
+
+DROP FUNCTION IF EXISTS set_updated_at();
+
+ALTER TABLE products DROP COLUMN IF EXISTS updated_at;
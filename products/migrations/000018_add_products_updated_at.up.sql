@@ -0,0 +1,30 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- The rows written before the column existed were last updated by their latest
+-- revision.
+UPDATE products SET updated_at = COALESCE(
+    (
+        SELECT max(r.created_at) FROM revisions r
+        WHERE r.entity = 'product' AND r.entity_id = products.id
+    ),
+    created_at
+);
+
+-- The update time moves with the version, so that it changes with every write of the
+-- product as it is served, whichever statement makes it. Reserving stock keeps both.
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    IF NEW.version <> OLD.version THEN
+        NEW.updated_at = NOW();
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_updated_at
+    BEFORE UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Catalog syncs read the products updated since their last run in update order.
+CREATE INDEX IF NOT EXISTS products_updated_at_idx ON products (updated_at, id);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT NOW(),
+    kind TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'queued',
+    progress INTEGER NOT NULL DEFAULT 0,
+    total INTEGER NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ(0),
+    finished_at TIMESTAMPTZ(0),
+    -- A running job is held by its worker until lease_expires_at. A job whose worker
+    -- died with the server is claimed again once its lease has run out.
+    lease_expires_at TIMESTAMPTZ,
+    CONSTRAINT jobs_status_check
+        CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+-- Workers claim the oldest job that is queued or whose lease has run out.
+CREATE INDEX IF NOT EXISTS jobs_pending_idx ON jobs (id) WHERE status IN ('queued', 'running');